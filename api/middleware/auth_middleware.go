@@ -4,8 +4,11 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/gofrs/uuid"
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	service "github.com/mohamed2394/sahla/internal/services"
 )
 
 func JWTMiddleware(authService service.AuthService, jwtSecret string) echo.MiddlewareFunc {
@@ -57,3 +60,30 @@ func JWTMiddleware(authService service.AuthService, jwtSecret string) echo.Middl
 		}
 	}
 }
+
+// RequireRole only lets through users whose role is one of roles. It must run
+// after JWTMiddleware, which puts the token claims in the context.
+func RequireRole(userRepo repository.UserRepository, roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := c.Get("user").(jwt.MapClaims)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing token claims")
+			}
+
+			userID, _ := claims["user_id"].(string)
+			user, err := userRepo.GetByID(uuid.FromStringOrNil(userID))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "User not found")
+			}
+
+			for _, role := range roles {
+				if user.Role == role {
+					return next(c)
+				}
+			}
+
+			return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	anonymousIdempotencyScope = "anonymous"
+)
+
+// Idempotency stores the first response to a request carrying an
+// Idempotency-Key header and replays it when the request is retried with the
+// same key within ttl. Keys are scoped to the authenticated user, so it must
+// run after JWTMiddleware. A retry with a different body is rejected with 422,
+// and a retry that arrives while the first request is still running gets 409.
+// Only successes and client errors that a retry would get again are stored;
+// other outcomes (errors, panics, 5xx and transient 4xx responses) free the
+// key for another attempt.
+func Idempotency(repo repository.IdempotencyRepository, ttl time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return echo.NewHTTPError(http.StatusBadRequest, "Idempotency-Key is too long")
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Failed to read request body")
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := c.Request().Context()
+			now := time.Now()
+			record := &domains.IdempotencyRecord{
+				UserID:      idempotencyScope(c),
+				Key:         key,
+				Method:      c.Request().Method,
+				Path:        c.Request().URL.Path,
+				RequestHash: requestHash(c.Request(), body),
+				Status:      domains.IdempotencyInProgress,
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(ttl),
+			}
+
+			existing, err := claimIdempotencyKey(ctx, repo, record, now)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check Idempotency-Key")
+			}
+			if existing != nil {
+				return replay(c, existing, record.RequestHash)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			// Store the outcome even if the client has gone away
+			storeCtx := context.Background()
+			defer func() {
+				if r := recover(); r != nil {
+					_ = repo.Delete(storeCtx, record.ID)
+					panic(r)
+				}
+			}()
+
+			err = next(c)
+
+			status := c.Response().Status
+			if err != nil || !replayable(status) {
+				_ = repo.Delete(storeCtx, record.ID)
+				return err
+			}
+			_ = repo.Complete(storeCtx, record.ID, status, recorder.body.Bytes())
+			return nil
+		}
+	}
+}
+
+// replayable reports whether a response with status may be replayed to
+// retries: successes, and client errors other than those that say to try
+// again later, such as a conflict with a request in progress or a rate limit.
+func replayable(status int) bool {
+	switch {
+	case status >= http.StatusOK && status < http.StatusMultipleChoices:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusConflict,
+		status == http.StatusTooEarly, status == http.StatusTooManyRequests:
+		return false
+	}
+	return status >= http.StatusBadRequest && status < http.StatusInternalServerError
+}
+
+// claimIdempotencyKey inserts record as the in-progress owner of its key. If
+// the key is already taken it returns the record holding it instead; expired
+// records are discarded and the key claimed afresh.
+func claimIdempotencyKey(ctx context.Context, repo repository.IdempotencyRepository, record *domains.IdempotencyRecord, now time.Time) (*domains.IdempotencyRecord, error) {
+	var (
+		duplicateErr *utils.ErrDuplicateEntry
+		notFoundErr  *utils.ErrNotFound
+	)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		err := repo.Create(ctx, record)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.As(err, &duplicateErr) {
+			return nil, err
+		}
+
+		existing, err := repo.Get(ctx, record.UserID, record.Key)
+		if errors.As(err, &notFoundErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if existing.ExpiresAt.After(now) {
+			return existing, nil
+		}
+		if err := repo.Delete(ctx, existing.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, errors.New("could not claim idempotency key")
+}
+
+func replay(c echo.Context, record *domains.IdempotencyRecord, requestHash string) error {
+	if record.RequestHash != requestHash {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+	}
+	if record.Status != domains.IdempotencyCompleted {
+		c.Response().Header().Set("Retry-After", "1")
+		return echo.NewHTTPError(http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+	}
+
+	c.Response().Header().Set(IdempotentReplayedHeader, "true")
+	return c.Blob(record.ResponseCode, echo.MIMEApplicationJSONCharsetUTF8, record.ResponseBody)
+}
+
+func idempotencyScope(c echo.Context) string {
+	if claims, ok := c.Get("user").(jwt.MapClaims); ok {
+		if userID, ok := claims["user_id"].(string); ok && userID != "" {
+			return userID
+		}
+	}
+	return anonymousIdempotencyScope
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder copies everything written to the client so it can be
+// replayed later.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
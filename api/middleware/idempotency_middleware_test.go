@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+)
+
+// fakeIdempotencyRepo keeps idempotency records in memory.
+type fakeIdempotencyRepo struct {
+	repository.IdempotencyRepository
+	records map[string]*domains.IdempotencyRecord
+	nextID  uint
+}
+
+func (r *fakeIdempotencyRepo) Create(ctx context.Context, record *domains.IdempotencyRecord) error {
+	if _, ok := r.records[record.UserID+"/"+record.Key]; ok {
+		return &utils.ErrDuplicateEntry{Entity: "IdempotencyRecord", Field: "key", Value: record.Key}
+	}
+	r.nextID++
+	record.ID = r.nextID
+	r.records[record.UserID+"/"+record.Key] = record
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) Get(ctx context.Context, userID, key string) (*domains.IdempotencyRecord, error) {
+	record, ok := r.records[userID+"/"+key]
+	if !ok {
+		return nil, &utils.ErrNotFound{Entity: "IdempotencyRecord", ID: key}
+	}
+	return record, nil
+}
+
+func (r *fakeIdempotencyRepo) Complete(ctx context.Context, id uint, responseCode int, responseBody []byte) error {
+	for _, record := range r.records {
+		if record.ID == id {
+			record.Status = domains.IdempotencyCompleted
+			record.ResponseCode = responseCode
+			record.ResponseBody = responseBody
+		}
+	}
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) Delete(ctx context.Context, id uint) error {
+	for key, record := range r.records {
+		if record.ID == id {
+			delete(r.records, key)
+		}
+	}
+	return nil
+}
+
+func serveIdempotent(t *testing.T, repo *fakeIdempotencyRepo, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	e.POST("/payments", handler, Idempotency(repo, time.Hour))
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{"amount":1000}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyStoresOnlyReplayableResponses(t *testing.T) {
+	tests := []struct {
+		status int
+		stored bool
+	}{
+		{http.StatusCreated, true},
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusConflict, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusGatewayTimeout, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			repo := &fakeIdempotencyRepo{records: map[string]*domains.IdempotencyRecord{}}
+			calls := 0
+			handler := func(c echo.Context) error {
+				calls++
+				return c.JSON(tt.status, map[string]int{"call": calls})
+			}
+
+			serveIdempotent(t, repo, handler)
+			retry := serveIdempotent(t, repo, handler)
+
+			replayed := retry.Header().Get(IdempotentReplayedHeader) == "true"
+			if replayed != tt.stored {
+				t.Fatalf("retry replayed = %v, want %v", replayed, tt.stored)
+			}
+			if wantCalls := map[bool]int{true: 1, false: 2}[tt.stored]; calls != wantCalls {
+				t.Fatalf("handler ran %d times, want %d", calls, wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyFreesKeyWhenHandlerPanics(t *testing.T) {
+	repo := &fakeIdempotencyRepo{records: map[string]*domains.IdempotencyRecord{}}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic was swallowed")
+			}
+		}()
+		serveIdempotent(t, repo, func(c echo.Context) error {
+			panic("boom")
+		})
+	}()
+	if len(repo.records) != 0 {
+		t.Fatalf("%d records left after a panic, want the key freed", len(repo.records))
+	}
+
+	rec := serveIdempotent(t, repo, func(c echo.Context) error {
+		return c.JSON(http.StatusCreated, map[string]string{"status": "created"})
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("retry after a panic got %d, want 201", rec.Code)
+	}
+}
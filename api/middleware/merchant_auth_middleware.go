@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	service "github.com/mohamed2394/sahla/internal/services"
+)
+
+// MerchantAPIKeyHeader carries a merchant API key. Keys are also accepted as
+// a bearer token.
+const MerchantAPIKeyHeader = "X-API-Key"
+
+// MerchantAuth authenticates merchant systems by API key and puts the
+// merchant in the context under "merchant".
+func MerchantAuth(merchants service.MerchantServiceInterface) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(MerchantAPIKeyHeader)
+			if key == "" {
+				key = strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			}
+			if key == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing API key")
+			}
+
+			merchant, err := merchants.AuthenticateAPIKey(c.Request().Context(), key)
+			if errors.Is(err, service.ErrInvalidAPIKey) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "An unexpected error occurred")
+			}
+
+			c.Set("merchant", merchant)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+func RegisterCardRoutes(e *echo.Echo, cardHandler *handler.CardHandler, middlewares ...echo.MiddlewareFunc) {
+	cards := e.Group("/cards", middlewares...)
+	cards.POST("", cardHandler.TokenizeCard)
+	cards.GET("/:token", cardHandler.GetCard)
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+func RegisterChargeRoutes(e *echo.Echo, chargeHandler *handler.ChargeHandler, middlewares ...echo.MiddlewareFunc) {
+	e.POST("/admin/charges/:id/waive", chargeHandler.WaiveCharge, middlewares...)
+}
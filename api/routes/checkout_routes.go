@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+// RegisterCheckoutRoutes registers the checkout sessions merchants open with
+// their API keys behind merchantAuth, and the checkout pages customers pay
+// them at behind requireAuth. The gateway return stays public since
+// customers reach it by redirect.
+func RegisterCheckoutRoutes(e *echo.Echo, checkoutHandler *handler.CheckoutHandler, merchantAuth, requireAuth, idempotency echo.MiddlewareFunc) {
+	merchant := e.Group("/merchant", merchantAuth)
+	merchant.POST("/checkout/sessions", checkoutHandler.CreateSession)
+	merchant.GET("/checkout/sessions/:id", checkoutHandler.GetSession)
+	merchant.POST("/checkout/sessions/:id/cancel", checkoutHandler.CancelSession)
+	merchant.GET("/events", checkoutHandler.ListEvents)
+
+	e.GET("/checkout/return", checkoutHandler.CheckoutReturn)
+	e.GET("/checkout/:id", checkoutHandler.GetCheckout, requireAuth)
+	e.POST("/checkout/:id/complete", checkoutHandler.CompleteCheckout, requireAuth, idempotency)
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+func RegisterCollectionRoutes(e *echo.Echo, collectionHandler *handler.CollectionHandler, middlewares ...echo.MiddlewareFunc) {
+	e.GET("/admin/collections", collectionHandler.ListCollectionCases, middlewares...)
+}
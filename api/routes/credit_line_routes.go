@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+func RegisterCreditLineRoutes(e *echo.Echo, creditLineHandler *handler.CreditLineHandler, middlewares ...echo.MiddlewareFunc) {
+	e.GET("/users/:id/credit-line", creditLineHandler.GetCreditLine, middlewares...)
+}
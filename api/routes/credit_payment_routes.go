@@ -5,12 +5,25 @@ import (
 	handler "github.com/mohamed2394/sahla/internal/handlers"
 )
 
-func RegisterCreditPaymentRoutes(e *echo.Echo, creditPaymentHandler *handler.CreditPaymentHandler) {
-	e.POST("/credit-applications", creditPaymentHandler.CreateCreditApplication)
-	e.PUT("/credit-applications/:id/approve", creditPaymentHandler.ApproveCreditApplication)
-	e.POST("/payments", creditPaymentHandler.CreatePayment)
-	e.GET("/payments/:id", creditPaymentHandler.GetPaymentDetails)
-	e.POST("/installments/:id/process", creditPaymentHandler.ProcessInstallment)
-	e.POST("/webhooks/payments/:id", creditPaymentHandler.HandlePaymentWebhook)
-	e.POST("/webhooks/installments/:id", creditPaymentHandler.HandleInstallmentWebhook)
-}
\ No newline at end of file
+// RegisterCreditPaymentRoutes registers the credit and payment endpoints.
+// middlewares guard the customer-facing routes; gateway returns stay public
+// since customers reach them by redirect.
+// idempotency is applied to the endpoints that create resources.
+// Payments created here are attributed to the merchant of the store they are
+// made at; online payments go through the checkout sessions merchants open
+// with their API key.
+func RegisterCreditPaymentRoutes(e *echo.Echo, creditPaymentHandler *handler.CreditPaymentHandler, idempotency echo.MiddlewareFunc, middlewares ...echo.MiddlewareFunc) {
+	api := e.Group("", middlewares...)
+	api.POST("/credit-applications", creditPaymentHandler.CreateCreditApplication, idempotency)
+	api.GET("/credit-applications/:id", creditPaymentHandler.GetCreditApplication)
+	api.PUT("/credit-applications/:id/approve", creditPaymentHandler.ApproveCreditApplication)
+	api.PUT("/credit-applications/:id/cancel", creditPaymentHandler.CancelCreditApplication)
+	api.GET("/credit-applications/:id/history", creditPaymentHandler.GetCreditApplicationHistory)
+	api.POST("/payments", creditPaymentHandler.CreatePayment, idempotency)
+	api.GET("/payments/:id", creditPaymentHandler.GetPaymentDetails)
+	api.POST("/payments/:id/confirm", creditPaymentHandler.ConfirmPayment)
+	api.POST("/installments/:id/process", creditPaymentHandler.ProcessInstallment)
+
+	e.GET("/payments/return", creditPaymentHandler.PaymentReturn)
+	e.GET("/installments/return", creditPaymentHandler.InstallmentReturn)
+}
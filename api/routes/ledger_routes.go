@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+func RegisterLedgerRoutes(e *echo.Echo, ledgerHandler *handler.LedgerHandler, middlewares ...echo.MiddlewareFunc) {
+	ledger := e.Group("/admin/ledger", middlewares...)
+	ledger.GET("/journals", ledgerHandler.ListJournals)
+	ledger.GET("/balances", ledgerHandler.GetBalances)
+}
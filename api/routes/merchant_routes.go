@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+// RegisterMerchantRoutes registers merchant onboarding behind
+// adminMiddlewares and the endpoints merchants call with their API keys
+// behind merchantAuth.
+func RegisterMerchantRoutes(e *echo.Echo, merchantHandler *handler.MerchantHandler, merchantAuth echo.MiddlewareFunc, adminMiddlewares ...echo.MiddlewareFunc) {
+	admin := e.Group("/admin/merchants", adminMiddlewares...)
+	admin.POST("", merchantHandler.CreateMerchant)
+	admin.GET("", merchantHandler.ListMerchants)
+	admin.GET("/:id", merchantHandler.GetMerchant)
+	admin.PUT("/:id", merchantHandler.UpdateMerchant)
+	admin.POST("/:id/status", merchantHandler.SetMerchantStatus)
+	admin.POST("/:id/stores", merchantHandler.AddStore)
+	admin.PUT("/:id/stores/:storeId", merchantHandler.UpdateStore)
+	admin.POST("/:id/api-keys", merchantHandler.CreateAPIKey)
+	admin.GET("/:id/api-keys", merchantHandler.ListAPIKeys)
+	admin.DELETE("/:id/api-keys/:keyId", merchantHandler.RevokeAPIKey)
+
+	merchant := e.Group("/merchant", merchantAuth)
+	merchant.GET("/profile", merchantHandler.GetProfile)
+	merchant.GET("/payments", merchantHandler.ListOwnPayments)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+// RegisterMerchantWebhookRoutes registers the webhook endpoints merchants
+// manage with their API keys, and the log of deliveries to them, behind
+// merchantAuth.
+func RegisterMerchantWebhookRoutes(e *echo.Echo, webhookHandler *handler.MerchantWebhookHandler, merchantAuth echo.MiddlewareFunc) {
+	merchant := e.Group("/merchant/webhooks", merchantAuth)
+	merchant.POST("/endpoints", webhookHandler.CreateEndpoint)
+	merchant.GET("/endpoints", webhookHandler.ListEndpoints)
+	merchant.DELETE("/endpoints/:id", webhookHandler.DisableEndpoint)
+	merchant.GET("/deliveries", webhookHandler.ListDeliveries)
+	merchant.GET("/deliveries/:id", webhookHandler.GetDelivery)
+	merchant.POST("/deliveries/:id/replay", webhookHandler.ReplayDelivery)
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+func RegisterNotificationRoutes(e *echo.Echo, notificationHandler *handler.NotificationHandler, middlewares ...echo.MiddlewareFunc) {
+	e.GET("/notifications", notificationHandler.ListNotifications, middlewares...)
+}
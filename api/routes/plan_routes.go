@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+// RegisterPlanRoutes registers the plan catalog offered to authenticated
+// customers and its management endpoints behind adminMiddlewares.
+func RegisterPlanRoutes(e *echo.Echo, planHandler *handler.PlanProductHandler, requireAuth echo.MiddlewareFunc, adminMiddlewares ...echo.MiddlewareFunc) {
+	e.GET("/plans", planHandler.ListActivePlans, requireAuth)
+	e.POST("/plans/quote", planHandler.QuotePlan, requireAuth)
+
+	admin := e.Group("/admin/plans", append([]echo.MiddlewareFunc{requireAuth}, adminMiddlewares...)...)
+	admin.GET("", planHandler.ListPlans)
+	admin.POST("", planHandler.CreatePlan)
+	admin.GET("/:id", planHandler.GetPlan)
+	admin.PUT("/:id", planHandler.UpdatePlan)
+	admin.DELETE("/:id", planHandler.DeletePlan)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+// RegisterPrepaymentRoutes registers the early payoff and prepayment
+// endpoints behind middlewares. The gateway return stays public since
+// customers reach it by redirect.
+func RegisterPrepaymentRoutes(e *echo.Echo, prepaymentHandler *handler.PrepaymentHandler, idempotency echo.MiddlewareFunc, middlewares ...echo.MiddlewareFunc) {
+	api := e.Group("", middlewares...)
+	api.GET("/payments/:id/payoff", prepaymentHandler.QuotePayoff)
+	api.POST("/payments/:id/payoff", prepaymentHandler.Payoff, idempotency)
+	api.POST("/payments/:id/prepayments", prepaymentHandler.Prepay, idempotency)
+
+	e.GET("/prepayments/return", prepaymentHandler.PrepaymentReturn)
+}
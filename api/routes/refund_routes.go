@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+// RegisterRefundRoutes registers the refunds of a payment for authenticated
+// users, and refund creation, the refund list and card refund retries behind
+// adminMiddlewares.
+func RegisterRefundRoutes(e *echo.Echo, refundHandler *handler.RefundHandler, requireAuth echo.MiddlewareFunc, adminMiddlewares ...echo.MiddlewareFunc) {
+	e.GET("/payments/:id/refunds", refundHandler.ListRefunds, requireAuth)
+	e.GET("/refunds/:id", refundHandler.GetRefund, requireAuth)
+
+	admin := e.Group("/admin/payments", append([]echo.MiddlewareFunc{requireAuth}, adminMiddlewares...)...)
+	admin.POST("/:id/refunds", refundHandler.CreateRefund)
+
+	adminRefunds := e.Group("/admin/refunds", append([]echo.MiddlewareFunc{requireAuth}, adminMiddlewares...)...)
+	adminRefunds.GET("", refundHandler.ListAdminRefunds)
+	adminRefunds.POST("/:id/retry", refundHandler.RetryRefund)
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+func RegisterReviewRoutes(e *echo.Echo, reviewHandler *handler.ReviewHandler, middlewares ...echo.MiddlewareFunc) {
+	reviews := e.Group("/reviews", middlewares...)
+	reviews.GET("", reviewHandler.ListReviews)
+	reviews.POST("/:id/claim", reviewHandler.ClaimReview)
+	reviews.POST("/:id/approve", reviewHandler.ApproveReview)
+	reviews.POST("/:id/decline", reviewHandler.DeclineReview)
+}
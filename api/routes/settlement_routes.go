@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+// RegisterSettlementRoutes registers payout batches and bank files behind
+// adminMiddlewares, and the payouts merchants follow with their API keys
+// behind merchantAuth.
+func RegisterSettlementRoutes(e *echo.Echo, settlementHandler *handler.SettlementHandler, merchantAuth, requireAuth echo.MiddlewareFunc, adminMiddlewares ...echo.MiddlewareFunc) {
+	admin := e.Group("/admin/settlements", append([]echo.MiddlewareFunc{requireAuth}, adminMiddlewares...)...)
+	admin.POST("/batches", settlementHandler.GenerateBatches)
+	admin.GET("/batches", settlementHandler.ListBatches)
+	admin.GET("/batches/:id", settlementHandler.GetBatch)
+	admin.POST("/batches/:id/paid", settlementHandler.MarkBatchPaid)
+	admin.POST("/batches/:id/failed", settlementHandler.MarkBatchFailed)
+	admin.POST("/files", settlementHandler.ExportBatches)
+	admin.GET("/files/:id", settlementHandler.DownloadBankFile)
+
+	merchant := e.Group("/merchant/settlements", merchantAuth)
+	merchant.GET("", settlementHandler.ListOwnBatches)
+	merchant.GET("/:id", settlementHandler.GetOwnBatch)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	handler "github.com/mohamed2394/sahla/internal/handlers"
+)
+
+// RegisterWebhookRoutes registers the gateway webhook endpoints, which
+// authenticate requests by signature, and the webhook log behind
+// adminMiddlewares.
+func RegisterWebhookRoutes(e *echo.Echo, webhookHandler *handler.WebhookHandler, adminMiddlewares ...echo.MiddlewareFunc) {
+	e.POST("/webhooks/payments/:id", webhookHandler.HandlePaymentWebhook)
+	e.POST("/webhooks/installments/:id", webhookHandler.HandleInstallmentWebhook)
+	e.POST("/webhooks/refunds/:id", webhookHandler.HandleRefundWebhook)
+
+	admin := e.Group("/admin/webhooks", adminMiddlewares...)
+	admin.GET("/inbound", webhookHandler.ListInboundWebhooks)
+}
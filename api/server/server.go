@@ -1,14 +1,28 @@
 package server
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	appMiddleware "github.com/mohamed2394/sahla/api/middleware"
 	"github.com/mohamed2394/sahla/api/routes"
+	"github.com/mohamed2394/sahla/internal/domains"
 	"github.com/mohamed2394/sahla/pkg/db"
+	"github.com/mohamed2394/sahla/pkg/satim"
+	"go.uber.org/zap"
 	storageHandler "github.com/mohamed2394/sahla/storage/handler"
 		minio "github.com/mohamed2394/sahla/storage/minio"
 
@@ -17,6 +31,8 @@ import (
 		validation "github.com/mohamed2394/sahla/internal/validation"
 
 	repository "github.com/mohamed2394/sahla/internal/repositories"
+	service "github.com/mohamed2394/sahla/internal/services"
+	"github.com/mohamed2394/sahla/internal/utils"
 
 )
 
@@ -25,6 +41,8 @@ type Server struct {
 	UserHandler    *handler.UserHandler
 	StorageService *storageService.StorageService
 	StorageHandler *storageHandler.StorageHandler
+
+	stopBackground context.CancelFunc
 }
 
 func NewServer(dsn string) (*Server, error) {
@@ -58,15 +76,269 @@ func NewServer(dsn string) (*Server, error) {
 		return nil, err
 	}
 
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(database)
+	creditAppRepo := repository.NewCreditApplicationRepository(database)
+	transitionRepo := repository.NewCreditApplicationTransitionRepository(database)
+	paymentRepo := repository.NewPaymentRepository(database)
+	installmentRepo := repository.NewInstallmentRepository(database)
+	reviewRepo := repository.NewManualReviewRepository(database)
+	creditLineRepo := repository.NewCreditLineRepository(database)
+	reservationRepo := repository.NewCreditReservationRepository(database)
+	planRepo := repository.NewPlanProductRepository(database)
+	idempotencyRepo := repository.NewIdempotencyRepository(database)
+	webhookLogRepo := repository.NewInboundWebhookLogRepository(database)
+	webhookEventRepo := repository.NewProcessedWebhookEventRepository(database)
+	cardVaultRepo := repository.NewCardVaultRepository(database)
+	jobLeaseRepo := repository.NewJobLeaseRepository(database)
+	collectionAttemptRepo := repository.NewCollectionAttemptRepository(database)
+	collectionCaseRepo := repository.NewCollectionCaseRepository(database)
+	notificationRepo := repository.NewNotificationRepository(database)
+	chargeRepo := repository.NewInstallmentChargeRepository(database)
+	prepaymentRepo := repository.NewPrepaymentRepository(database)
+	receiptRepo := repository.NewPaymentReceiptRepository(database)
+	refundRepo := repository.NewRefundRepository(database)
+	ledgerRepo := repository.NewLedgerRepository(database)
+	merchantRepo := repository.NewMerchantRepository(database)
+	merchantAPIKeyRepo := repository.NewMerchantAPIKeyRepository(database)
+	checkoutSessionRepo := repository.NewCheckoutSessionRepository(database)
+	merchantEventRepo := repository.NewMerchantEventRepository(database)
+	settlementRepo := repository.NewSettlementRepository(database)
+	webhookEndpointRepo := repository.NewWebhookEndpointRepository(database)
+	webhookDeliveryRepo := repository.NewWebhookDeliveryRepository(database)
+	txManager := utils.NewTransactionManager(database)
 
 	// Initialize services
 	storageService := storageService.NewStorageService(minioClient)
+	authService := service.NewAuthService(userRepo, jwtSecret, refreshSecret)
+
+	var creditScorer service.CreditScorer = service.NewScorecardScorer()
+	if modelURL := os.Getenv("SCORING_MODEL_URL"); modelURL != "" {
+		modelConfig := service.DefaultModelScorerConfig(modelURL)
+		modelConfig.Timeout = durationEnv("SCORING_MODEL_TIMEOUT", modelConfig.Timeout)
+		creditScorer = service.NewModelScorer(modelConfig, nil, creditScorer, logger)
+	}
+
+	paymentGateway, err := newPaymentGateway(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	lifecycle := service.NewCreditApplicationLifecycle(creditAppRepo, transitionRepo, txManager, logger)
+	creditLineService := service.NewCreditLineService(creditLineRepo, reservationRepo, durationEnv("CREDIT_RESERVATION_TTL", 0), logger)
+	quoteSecret := os.Getenv("QUOTE_SECRET")
+	if quoteSecret == "" {
+		return nil, fmt.Errorf("QUOTE_SECRET must be set to sign plan quotes")
+	}
+	planService := service.NewPlanProductService(planRepo, []byte(quoteSecret), durationEnv("QUOTE_VALIDITY", 0), logger)
+	reviewService := service.NewReviewService(reviewRepo, creditAppRepo, lifecycle, creditLineService, txManager, logger)
+	notificationService := service.NewNotificationService(notificationRepo, logger)
+	ledgerService := service.NewLedgerService(ledgerRepo, logger)
+	merchantService := service.NewMerchantService(merchantRepo, merchantAPIKeyRepo, paymentRepo, logger)
+	webhookRetryPolicy, err := webhookRetryPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	merchantWebhookService := service.NewMerchantWebhookService(
+		webhookEndpointRepo,
+		webhookDeliveryRepo,
+		merchantEventRepo,
+		webhookClient(durationEnv("MERCHANT_WEBHOOK_TIMEOUT", 10*time.Second)),
+		webhookRetryPolicy,
+		txManager,
+		logger,
+	)
+	merchantEventService := service.NewMerchantEventService(checkoutSessionRepo, merchantEventRepo, merchantWebhookService, logger)
+	dunningPolicy, err := dunningPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	dunningService := service.NewDunningService(
+		installmentRepo,
+		paymentRepo,
+		collectionCaseRepo,
+		creditLineService,
+		notificationService,
+		ledgerService,
+		txManager,
+		dunningPolicy,
+		logger,
+	)
+	lateFeePolicy, err := lateFeePolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	penaltyService := service.NewPenaltyService(installmentRepo, paymentRepo, chargeRepo, ledgerService, paymentGateway, txManager, lateFeePolicy, logger)
+	refundService := service.NewRefundService(
+		paymentRepo,
+		installmentRepo,
+		receiptRepo,
+		refundRepo,
+		creditLineService,
+		ledgerService,
+		merchantEventService,
+		paymentGateway,
+		txManager,
+		logger,
+	)
+	underwritingPolicy, err := underwritingPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	creditPaymentService := service.NewCreditPaymentService(
+		creditAppRepo,
+		paymentRepo,
+		installmentRepo,
+		userRepo,
+		reviewRepo,
+		creditScorer,
+		underwritingPolicy,
+		lifecycle,
+		creditLineService,
+		planService,
+		dunningService,
+		penaltyService,
+		refundService,
+		ledgerService,
+		merchantService,
+		merchantEventService,
+		txManager,
+		logger,
+		paymentGateway,
+	)
+	checkoutService := service.NewCheckoutService(
+		checkoutSessionRepo,
+		creditPaymentService,
+		merchantService,
+		merchantEventService,
+		txManager,
+		publicBaseURL(),
+		durationEnv("CHECKOUT_SESSION_TTL", 0),
+		logger,
+	)
+
+	bankFileConfig, err := bankFileConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	settlementLocation := time.Local
+	if zone := os.Getenv("SETTLEMENT_TIMEZONE"); zone != "" {
+		if settlementLocation, err = time.LoadLocation(zone); err != nil {
+			return nil, fmt.Errorf("invalid SETTLEMENT_TIMEZONE %q: %w", zone, err)
+		}
+	}
+	settlementService := service.NewSettlementService(
+		settlementRepo,
+		merchantService,
+		ledgerService,
+		txManager,
+		bankFileConfig,
+		settlementLocation,
+		logger,
+	)
+
+	prepaymentPolicy := service.DefaultPrepaymentPolicy()
+	if prepaymentPolicy.FeeRebatePercent, err = intEnv("PREPAYMENT_FEE_REBATE_PERCENT", prepaymentPolicy.FeeRebatePercent); err != nil {
+		return nil, err
+	}
+	if err := prepaymentPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	prepaymentService := service.NewPrepaymentService(
+		paymentRepo,
+		installmentRepo,
+		prepaymentRepo,
+		creditLineService,
+		refundService,
+		penaltyService,
+		ledgerService,
+		merchantEventService,
+		paymentGateway,
+		txManager,
+		prepaymentPolicy,
+		logger,
+	)
+
+	cardVaultKey, err := base64.StdEncoding.DecodeString(os.Getenv("CARD_VAULT_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("CARD_VAULT_KEY must be base64: %w", err)
+	}
+	cardVaultService, err := service.NewCardVaultService(cardVaultRepo, cardVaultKey, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	inboundWebhookService := service.NewInboundWebhookService(
+		webhookLogRepo,
+		webhookEventRepo,
+		txManager,
+		os.Getenv("WEBHOOK_SECRET"),
+		durationEnv("WEBHOOK_TOLERANCE", 0),
+		logger,
+	)
+
+	instance := instanceID()
+	installmentCollector := service.NewInstallmentCollector(
+		installmentRepo,
+		paymentRepo,
+		collectionAttemptRepo,
+		cardVaultService,
+		creditPaymentService,
+		paymentGateway,
+		txManager,
+		instance,
+		logger,
+	)
+
+	// Start background jobs
+	ctx, stopBackground := context.WithCancel(context.Background())
+	creditPaymentService.StartReservationSweeper(ctx, time.Minute)
+	go purgeIdempotencyRecords(ctx, idempotencyRepo, time.Hour)
+
+	jobRunner := service.NewJobRunner(jobLeaseRepo, instance, logger)
+	jobRunner.Register(service.CollectionJobName, durationEnv("COLLECTION_INTERVAL", time.Hour), 30*time.Minute,
+		installmentCollector.CollectDue)
+	jobRunner.Register(service.DunningJobName, durationEnv("DUNNING_INTERVAL", 24*time.Hour), 30*time.Minute,
+		dunningService.ProcessDelinquencies)
+	jobRunner.Register(service.LateFeeJobName, durationEnv("LATE_FEE_INTERVAL", 24*time.Hour), 30*time.Minute,
+		penaltyService.AccrueLateFees)
+	jobRunner.Register(service.CheckoutExpiryJobName, durationEnv("CHECKOUT_EXPIRY_INTERVAL", time.Minute), 30*time.Minute,
+		checkoutService.ExpireSessions)
+	jobRunner.Register(service.SettlementJobName, durationEnv("SETTLEMENT_INTERVAL", 24*time.Hour), 30*time.Minute,
+		settlementService.SettlePreviousDay)
+	jobRunner.Register(service.PrepaymentRefundJobName, durationEnv("PREPAYMENT_REFUND_INTERVAL", time.Hour), 30*time.Minute,
+		prepaymentService.RefundUnapplied)
+	jobRunner.Register(service.OverpaymentRefundJobName, durationEnv("OVERPAYMENT_REFUND_INTERVAL", time.Hour), 30*time.Minute,
+		refundService.RefundOverpayments)
+	jobRunner.Register(service.MerchantWebhookJobName, durationEnv("MERCHANT_WEBHOOK_INTERVAL", time.Minute), 30*time.Minute,
+		merchantWebhookService.DeliverDue)
+	jobRunner.Start(ctx, time.Minute)
 
 	// Initialize handlers
+	validator := validation.NewCustomValidator()
 	userHandler := handler.NewUserHandler(userRepo)
 	storageHandler := storageHandler.NewStorageHandler(storageService, "sahlabucket")
+	creditPaymentHandler := handler.NewCreditPaymentHandler(creditPaymentService, cardVaultService, logger, validator)
+	cardHandler := handler.NewCardHandler(cardVaultService, logger, validator)
+	reviewHandler := handler.NewReviewHandler(reviewService, logger, validator)
+	creditLineHandler := handler.NewCreditLineHandler(creditLineService, logger)
+	planHandler := handler.NewPlanProductHandler(planService, logger, validator)
+	notificationHandler := handler.NewNotificationHandler(notificationService, logger)
+	collectionHandler := handler.NewCollectionHandler(dunningService, logger)
+	chargeHandler := handler.NewChargeHandler(penaltyService, logger, validator)
+	prepaymentHandler := handler.NewPrepaymentHandler(prepaymentService, logger, validator)
+	refundHandler := handler.NewRefundHandler(refundService, logger, validator)
+	ledgerHandler := handler.NewLedgerHandler(ledgerService, logger)
+	merchantHandler := handler.NewMerchantHandler(merchantService, logger, validator)
+	checkoutHandler := handler.NewCheckoutHandler(checkoutService, merchantEventService, cardVaultService, logger, validator)
+	merchantWebhookHandler := handler.NewMerchantWebhookHandler(merchantWebhookService, logger, validator)
+	settlementHandler := handler.NewSettlementHandler(settlementService, logger, validator)
+	webhookHandler := handler.NewWebhookHandler(creditPaymentService, refundService, inboundWebhookService, logger, validator)
 
 	// Create Echo instance
 	e := echo.New()
@@ -76,16 +348,284 @@ func NewServer(dsn string) (*Server, error) {
 
 	// Register routes
 	routes.RegisterUserRoutes(e, userHandler)
-	routes.SetupAuthRoutes(e, userRepo, jwtSecret, refreshSecret)
+	routes.SetupAuthRoutes(e, authService)
 	routes.RegisterStorageRoutes(e, storageHandler)
 
+	requireAuth := appMiddleware.JWTMiddleware(authService, jwtSecret)
+	idempotency := appMiddleware.Idempotency(idempotencyRepo, durationEnv("IDEMPOTENCY_TTL", 24*time.Hour))
+	routes.RegisterCreditPaymentRoutes(e, creditPaymentHandler, idempotency, requireAuth)
+	routes.RegisterPrepaymentRoutes(e, prepaymentHandler, idempotency, requireAuth)
+	routes.RegisterCreditLineRoutes(e, creditLineHandler, requireAuth)
+	routes.RegisterCardRoutes(e, cardHandler, requireAuth)
+	routes.RegisterReviewRoutes(e, reviewHandler, requireAuth,
+		appMiddleware.RequireRole(userRepo, domains.RoleReviewer, domains.RoleAdmin))
+	routes.RegisterPlanRoutes(e, planHandler, requireAuth,
+		appMiddleware.RequireRole(userRepo, domains.RoleAdmin))
+	routes.RegisterNotificationRoutes(e, notificationHandler, requireAuth)
+	routes.RegisterCollectionRoutes(e, collectionHandler, requireAuth,
+		appMiddleware.RequireRole(userRepo, domains.RoleAdmin))
+	routes.RegisterChargeRoutes(e, chargeHandler, requireAuth,
+		appMiddleware.RequireRole(userRepo, domains.RoleAdmin))
+	routes.RegisterRefundRoutes(e, refundHandler, requireAuth,
+		appMiddleware.RequireRole(userRepo, domains.RoleAdmin))
+	merchantAuth := appMiddleware.MerchantAuth(merchantService)
+	routes.RegisterMerchantRoutes(e, merchantHandler, merchantAuth, requireAuth,
+		appMiddleware.RequireRole(userRepo, domains.RoleAdmin))
+	routes.RegisterCheckoutRoutes(e, checkoutHandler, merchantAuth, requireAuth, idempotency)
+	routes.RegisterMerchantWebhookRoutes(e, merchantWebhookHandler, merchantAuth)
+	routes.RegisterSettlementRoutes(e, settlementHandler, merchantAuth, requireAuth,
+		appMiddleware.RequireRole(userRepo, domains.RoleAdmin))
+	routes.RegisterLedgerRoutes(e, ledgerHandler, requireAuth,
+		appMiddleware.RequireRole(userRepo, domains.RoleAdmin))
+	routes.RegisterWebhookRoutes(e, webhookHandler, requireAuth,
+		appMiddleware.RequireRole(userRepo, domains.RoleAdmin))
+
 	return &Server{
 		Echo:           e,
 		UserHandler:    userHandler,
 		StorageService: storageService,
+		stopBackground: stopBackground,
 	}, nil
 }
 
+// newPaymentGateway connects to the card gateway at SATIM_URL. Without one, it
+// starts the embedded gateway simulator so payments still work locally.
+func newPaymentGateway(logger *zap.Logger) (service.PaymentGateway, error) {
+	gatewayURL := os.Getenv("SATIM_URL")
+	if gatewayURL == "" {
+		outcome, err := satim.ParseOutcome(os.Getenv("SATIM_SIMULATOR_OUTCOME"))
+		if err != nil {
+			return nil, err
+		}
+
+		addr := os.Getenv("SATIM_SIMULATOR_ADDR")
+		if addr == "" {
+			addr = "localhost:9090"
+		}
+		simulator := satim.NewSimulator(outcome)
+		go func() {
+			if err := simulator.ListenAndServe(addr); err != nil && err != http.ErrServerClosed {
+				logger.Error("Payment gateway simulator stopped", zap.Error(err))
+			}
+		}()
+		logger.Info("Using payment gateway simulator", zap.String("addr", addr), zap.String("outcome", string(outcome)))
+		gatewayURL = "http://" + localAddr(addr)
+	}
+
+	client := satim.NewClient(satim.Config{
+		BaseURL:  gatewayURL,
+		Username: os.Getenv("SATIM_USERNAME"),
+		Password: os.Getenv("SATIM_PASSWORD"),
+		Timeout:  durationEnv("SATIM_TIMEOUT", 5*time.Second),
+	}, nil)
+
+	return service.NewSatimGateway(client, publicBaseURL(), logger), nil
+}
+
+// publicBaseURL is where customers reach this API, for gateway returns and
+// checkout pages.
+func publicBaseURL() string {
+	publicURL := os.Getenv("PUBLIC_BASE_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost:8080"
+	}
+	return publicURL
+}
+
+// purgeIdempotencyRecords deletes expired idempotency keys every interval
+// until ctx is cancelled.
+func purgeIdempotencyRecords(ctx context.Context, repo repository.IdempotencyRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := repo.DeleteExpired(ctx, time.Now()); err != nil {
+				log.Printf("Failed to purge idempotency keys: %v", err)
+			}
+		}
+	}
+}
+
+// localAddr turns a listen address such as ":9090" into one that can be
+// dialled and shown to customers.
+func localAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "" || host == "0.0.0.0" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// webhookClient posts merchant webhooks. It does not follow redirects and
+// goes through no proxy, and it only dials public addresses: the check is
+// made on the resolved address, so an endpoint's host name cannot point it
+// at this network.
+func webhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialPublicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// dialPublicOnly refuses to connect to private, loopback, link-local,
+// unspecified and multicast addresses.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("refusing to dial non-public address %s", host)
+	}
+	return nil
+}
+
+// instanceID names this replica in job leases and collection attempts.
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// dunningPolicyFromEnv reads the retry delays ("1,3,7"), grace period and
+// default period, in days, of the dunning policy from the environment.
+func dunningPolicyFromEnv() (service.DunningPolicy, error) {
+	policy := service.DefaultDunningPolicy()
+	if value := os.Getenv("DUNNING_RETRY_DAYS"); value != "" {
+		policy.RetryDays = nil
+		for _, field := range strings.Split(value, ",") {
+			days, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return policy, fmt.Errorf("invalid DUNNING_RETRY_DAYS %q: %w", value, err)
+			}
+			policy.RetryDays = append(policy.RetryDays, days)
+		}
+	}
+	var err error
+	if policy.GraceDays, err = intEnv("DUNNING_GRACE_DAYS", policy.GraceDays); err != nil {
+		return policy, err
+	}
+	if policy.DefaultDays, err = intEnv("DUNNING_DEFAULT_DAYS", policy.DefaultDays); err != nil {
+		return policy, err
+	}
+	return policy, policy.Validate()
+}
+
+// underwritingPolicyFromEnv reads the credit scores bounding the manual review
+// grey zone from the environment.
+func underwritingPolicyFromEnv() (service.UnderwritingPolicy, error) {
+	policy := service.DefaultUnderwritingPolicy()
+	var err error
+	if policy.ReviewMinScore, err = intEnv("UNDERWRITING_REVIEW_MIN_SCORE", policy.ReviewMinScore); err != nil {
+		return policy, err
+	}
+	if policy.ApproveMinScore, err = intEnv("UNDERWRITING_APPROVE_MIN_SCORE", policy.ApproveMinScore); err != nil {
+		return policy, err
+	}
+	return policy, policy.Validate()
+}
+
+// lateFeePolicyFromEnv reads the late fee model (NONE, FLAT or PERCENTAGE),
+// value, cap and grace period, in days, from the environment.
+func lateFeePolicyFromEnv() (service.LateFeePolicy, error) {
+	policy := service.DefaultLateFeePolicy()
+	if model := os.Getenv("LATE_FEE_MODEL"); model != "" {
+		policy.Model = domains.FeeModel(strings.ToUpper(model))
+	}
+	var err error
+	if policy.Value, err = intEnv("LATE_FEE_VALUE", policy.Value); err != nil {
+		return policy, err
+	}
+	if policy.Cap, err = intEnv("LATE_FEE_CAP", policy.Cap); err != nil {
+		return policy, err
+	}
+	if policy.GraceDays, err = intEnv("LATE_FEE_GRACE_DAYS", policy.GraceDays); err != nil {
+		return policy, err
+	}
+	return policy, policy.Validate()
+}
+
+// webhookRetryPolicyFromEnv reads how many attempts merchant webhooks get
+// and the first and longest delay between them.
+func webhookRetryPolicyFromEnv() (service.WebhookRetryPolicy, error) {
+	policy := service.DefaultWebhookRetryPolicy()
+	var err error
+	if policy.MaxAttempts, err = intEnv("MERCHANT_WEBHOOK_MAX_ATTEMPTS", policy.MaxAttempts); err != nil {
+		return policy, err
+	}
+	policy.BaseDelay = durationEnv("MERCHANT_WEBHOOK_RETRY_BASE", policy.BaseDelay)
+	policy.MaxDelay = durationEnv("MERCHANT_WEBHOOK_RETRY_MAX", policy.MaxDelay)
+	return policy, policy.Validate()
+}
+
+// bankFileConfigFromEnv reads the layout of settlement bank files: the format
+// (CSV or FIXED_WIDTH), the fields ("reference:16,rib:20,amount:15"), the CSV
+// delimiter and the RIB payouts are made from.
+func bankFileConfigFromEnv() (service.BankFileConfig, error) {
+	config := service.DefaultBankFileConfig()
+	if format := os.Getenv("SETTLEMENT_FILE_FORMAT"); format != "" {
+		config.Format = service.BankFileFormat(strings.ToUpper(format))
+	}
+	if value := os.Getenv("SETTLEMENT_FILE_FIELDS"); value != "" {
+		fields, err := service.ParseBankFileFields(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid SETTLEMENT_FILE_FIELDS %q: %w", value, err)
+		}
+		config.Fields = fields
+	}
+	if delimiter := os.Getenv("SETTLEMENT_FILE_DELIMITER"); delimiter != "" {
+		config.Delimiter = []rune(delimiter)[0]
+	}
+	config.PayerRIB = utils.NormalizeRIB(os.Getenv("SETTLEMENT_PAYER_RIB"))
+	return config, config.Validate()
+}
+
+func intEnv(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return n, nil
+}
+
+// durationEnv reads a duration such as "2s" from the environment.
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q: %v", key, value, err)
+		return fallback
+	}
+	return d
+}
+
 func (s *Server) Start(addr string) {
 	log.Println("Server is running at", addr)
 	if err := s.Echo.Start(addr); err != nil {
@@ -94,6 +634,9 @@ func (s *Server) Start(addr string) {
 }
 
 func (s *Server) Close() {
+	if s.stopBackground != nil {
+		s.stopBackground()
+	}
 	dbSQL, err := db.GetDB().DB()
 	if err != nil {
 		log.Fatalf("Error getting db from database: %v", err)
@@ -0,0 +1,16 @@
+package domains
+
+import "gorm.io/gorm"
+
+// CardVaultEntry is a tokenized card. The card number and holder name are
+// only kept encrypted; the CVV is never stored.
+type CardVaultEntry struct {
+	gorm.Model
+	Token         string `gorm:"type:varchar(64);not null;uniqueIndex" json:"token"`
+	UserID        string `gorm:"type:uuid;not null;index:idx_card_vault_user_fingerprint" json:"user_id"`
+	Fingerprint   string `gorm:"type:varchar(64);not null;index:idx_card_vault_user_fingerprint" json:"-"`
+	EncryptedCard []byte `gorm:"not null" json:"-"`
+	Brand         string `gorm:"type:varchar(20);not null" json:"brand"`
+	Last4         string `gorm:"type:varchar(4);not null" json:"last4"`
+	ExpiryDate    string `gorm:"type:varchar(5);not null" json:"expiry_date"`
+}
@@ -0,0 +1,55 @@
+package domains
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChargeType is the kind of an installment charge.
+type ChargeType string
+
+const (
+	// ChargeTypeLateFee is one day of late fee on an overdue installment.
+	ChargeTypeLateFee ChargeType = "LATE_FEE"
+)
+
+// ChargeStatus is the state of an installment charge.
+type ChargeStatus string
+
+const (
+	ChargeAccrued ChargeStatus = "ACCRUED"
+	// ChargePaid charges were collected with their installment.
+	ChargePaid ChargeStatus = "PAID"
+	// ChargeWaived charges are no longer owed. They keep who waived them and
+	// why for audit.
+	ChargeWaived ChargeStatus = "WAIVED"
+)
+
+// InstallmentCharge is an amount owed on top of an installment, such as a
+// late fee. Charges are kept apart from the installment's principal and plan
+// fee, so penalties never change the agreed cost of credit. A charge accrues
+// at most once per installment, type and day.
+type InstallmentCharge struct {
+	gorm.Model
+	InstallmentID uint         `gorm:"not null;uniqueIndex:idx_installment_charges_accrual" json:"installment_id"`
+	PaymentID     uint         `gorm:"not null;index" json:"payment_id"`
+	UserID        string       `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type          ChargeType   `gorm:"type:varchar(20);not null;uniqueIndex:idx_installment_charges_accrual" json:"type"`
+	AccrualDate   string       `gorm:"type:varchar(10);not null;uniqueIndex:idx_installment_charges_accrual" json:"accrual_date"`
+	Amount        int          `gorm:"not null" json:"amount"`
+	Currency      string       `gorm:"type:varchar(3);not null" json:"currency"`
+	Status        ChargeStatus `gorm:"type:varchar(20);not null" json:"status"`
+	WaivedBy      string       `gorm:"type:varchar(100)" json:"waived_by,omitempty"`
+	WaiverReason  string       `gorm:"type:text" json:"waiver_reason,omitempty"`
+	WaivedAt      *time.Time   `json:"waived_at,omitempty"`
+	// GatewayOrderID is the last order the charge was billed to, along with
+	// its installment.
+	GatewayOrderID string     `gorm:"type:varchar(64);index" json:"gateway_order_id,omitempty"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+}
+
+// Outstanding reports whether the charge is still owed.
+func (c *InstallmentCharge) Outstanding() bool {
+	return c.Status == ChargeAccrued
+}
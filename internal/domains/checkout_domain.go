@@ -0,0 +1,77 @@
+package domains
+
+import "time"
+
+// CheckoutSessionStatus is the state of a hosted checkout.
+type CheckoutSessionStatus string
+
+const (
+	// CheckoutOpen sessions are waiting for the customer to pay. A session
+	// whose payment failed is open again so the customer can retry.
+	CheckoutOpen CheckoutSessionStatus = "OPEN"
+	// CheckoutPending sessions have a payment awaiting its gateway outcome.
+	CheckoutPending   CheckoutSessionStatus = "PENDING"
+	CheckoutCompleted CheckoutSessionStatus = "COMPLETED"
+	// CheckoutExpired sessions were not paid in time.
+	CheckoutExpired   CheckoutSessionStatus = "EXPIRED"
+	CheckoutCancelled CheckoutSessionStatus = "CANCELLED"
+)
+
+// CheckoutLine is one item of the order a checkout session pays for.
+type CheckoutLine struct {
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int    `json:"unit_price"`
+}
+
+// Total returns the price of the line.
+func (l CheckoutLine) Total() int {
+	return l.Quantity * l.UnitPrice
+}
+
+// CheckoutSession is an order a merchant sends its customer to pay in
+// installments. The customer signs in, picks a plan and pays, which creates a
+// Payment carrying the merchant's order reference as its OrderID. Customers
+// are sent back to SuccessURL or CancelURL.
+type CheckoutSession struct {
+	ID             string                `gorm:"type:uuid;primaryKey" json:"id"`
+	MerchantID     uint                  `gorm:"not null;index" json:"merchant_id"`
+	StoreID        *uint                 `json:"store_id"`
+	OrderReference string                `gorm:"type:varchar(100);not null" json:"order_reference"`
+	Amount         int                   `gorm:"not null" json:"amount"`
+	Currency       string                `gorm:"type:varchar(3);not null" json:"currency"`
+	Lines          []CheckoutLine        `gorm:"serializer:json" json:"lines"`
+	SuccessURL     string                `gorm:"type:text;not null" json:"success_url"`
+	CancelURL      string                `gorm:"type:text;not null" json:"cancel_url"`
+	Status         CheckoutSessionStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	// UserID is the customer who last tried to pay the session.
+	UserID      string     `gorm:"type:uuid" json:"user_id,omitempty"`
+	PaymentID   *uint      `gorm:"index" json:"payment_id,omitempty"`
+	ExpiresAt   time.Time  `gorm:"not null;index" json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// MerchantEventType names something that happened that a merchant is told
+// about.
+type MerchantEventType string
+
+const (
+	EventPaymentSucceeded       MerchantEventType = "payment.succeeded"
+	EventPaymentFailed          MerchantEventType = "payment.failed"
+	EventPaymentRefunded        MerchantEventType = "payment.refunded"
+	EventInstallmentPaid        MerchantEventType = "installment.paid"
+	EventCheckoutSessionExpired MerchantEventType = "checkout.session.expired"
+)
+
+// MerchantEvent is a notification owed to a merchant. Events are recorded
+// with the change they report, read back by the merchant and posted to its
+// webhook endpoints. Payload is the JSON body of the event.
+type MerchantEvent struct {
+	ID         uint              `gorm:"primarykey" json:"id"`
+	MerchantID uint              `gorm:"not null;index" json:"merchant_id"`
+	Type       MerchantEventType `gorm:"type:varchar(50);not null" json:"type"`
+	Payload    string            `gorm:"type:text;not null" json:"payload"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
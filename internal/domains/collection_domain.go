@@ -0,0 +1,56 @@
+package domains
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CollectionAttemptStatus is the state of one attempt to charge an
+// installment to the customer's card on file.
+type CollectionAttemptStatus string
+
+const (
+	// CollectionAttemptInProgress attempts may have charged the card; they are
+	// reconciled with the gateway before the installment is charged again.
+	CollectionAttemptInProgress CollectionAttemptStatus = "IN_PROGRESS"
+	CollectionAttemptSucceeded  CollectionAttemptStatus = "SUCCEEDED"
+	CollectionAttemptFailed     CollectionAttemptStatus = "FAILED"
+)
+
+// CollectionAttempt records one automatic charge of a due installment. At most
+// one attempt per installment may be in progress at a time.
+type CollectionAttempt struct {
+	ID             uint                    `gorm:"primarykey" json:"id"`
+	InstallmentID  uint                    `gorm:"not null;index" json:"installment_id"`
+	OrderNumber    string                  `gorm:"type:uuid;not null;uniqueIndex" json:"order_number"`
+	GatewayOrderID string                  `gorm:"type:varchar(64);index" json:"gateway_order_id"`
+	Amount         int                     `gorm:"not null" json:"amount"`
+	Status         CollectionAttemptStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	FailureReason  string                  `gorm:"type:text" json:"failure_reason,omitempty"`
+	Owner          string                  `gorm:"type:varchar(100)" json:"owner"`
+	StartedAt      time.Time               `gorm:"not null" json:"started_at"`
+	FinishedAt     *time.Time              `json:"finished_at,omitempty"`
+}
+
+// CollectionCaseStatus is the state of a defaulted debt handed to collections.
+type CollectionCaseStatus string
+
+const (
+	CollectionCaseOpen     CollectionCaseStatus = "OPEN"
+	CollectionCaseResolved CollectionCaseStatus = "RESOLVED"
+)
+
+// CollectionCase flags a customer's account for the collections team after
+// one of their installments defaulted.
+type CollectionCase struct {
+	gorm.Model
+	UserID        string               `gorm:"type:uuid;not null;index" json:"user_id"`
+	PaymentID     uint                 `gorm:"not null;index" json:"payment_id"`
+	InstallmentID uint                 `gorm:"not null;uniqueIndex" json:"installment_id"`
+	AmountDue     int                  `gorm:"not null" json:"amount_due"`
+	Status        CollectionCaseStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	OpenedAt      time.Time            `gorm:"not null" json:"opened_at"`
+	// ResolvedAt is when the defaulted installment was paid.
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
@@ -1,52 +1,234 @@
 package domains
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// CreditApplicationStatus is the lifecycle state of a credit application.
+type CreditApplicationStatus string
+
+const (
+	CreditApplicationDraft       CreditApplicationStatus = "DRAFT"
+	CreditApplicationSubmitted   CreditApplicationStatus = "SUBMITTED"
+	CreditApplicationUnderReview CreditApplicationStatus = "UNDER_REVIEW"
+	CreditApplicationApproved    CreditApplicationStatus = "APPROVED"
+	CreditApplicationRejected    CreditApplicationStatus = "REJECTED"
+	CreditApplicationExpired     CreditApplicationStatus = "EXPIRED"
+	CreditApplicationCancelled   CreditApplicationStatus = "CANCELLED"
+	CreditApplicationClosed      CreditApplicationStatus = "CLOSED"
+)
+
+// creditApplicationTransitions lists the states each state may move to.
+// REJECTED, EXPIRED, CANCELLED and CLOSED are terminal.
+var creditApplicationTransitions = map[CreditApplicationStatus][]CreditApplicationStatus{
+	CreditApplicationDraft:       {CreditApplicationSubmitted, CreditApplicationCancelled},
+	CreditApplicationSubmitted:   {CreditApplicationUnderReview, CreditApplicationCancelled, CreditApplicationExpired},
+	CreditApplicationUnderReview: {CreditApplicationApproved, CreditApplicationRejected, CreditApplicationCancelled, CreditApplicationExpired},
+	CreditApplicationApproved:    {CreditApplicationClosed, CreditApplicationExpired},
+}
+
+// CanTransitionTo reports whether an application in status s may move to next.
+func (s CreditApplicationStatus) CanTransitionTo(next CreditApplicationStatus) bool {
+	for _, allowed := range creditApplicationTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 // CreditApplication represents a credit application in the BNPL system.
 type CreditApplication struct {
 	gorm.Model
-	UserID   string    `gorm:"type:uuid;not null" json:"user_id"`
-	Amount   int       `gorm:"not null" json:"amount"`
-	Currency string    `gorm:"type:varchar(3);not null" json:"currency"`
-	Status   string    `gorm:"type:varchar(20);not null" json:"status"`
-	Payments []Payment `json:"payments"`
+	UserID         string                  `gorm:"type:uuid;not null" json:"user_id"`
+	Amount         int                     `gorm:"not null" json:"amount"`
+	Currency       string                  `gorm:"type:varchar(3);not null" json:"currency"`
+	Status         CreditApplicationStatus `gorm:"type:varchar(20);not null" json:"status"`
+	ApprovedAmount int                     `json:"approved_amount"`
+	CreditScore    int                     `json:"credit_score"`
+	ScoreBand      string                  `gorm:"type:varchar(20)" json:"score_band"`
+	ScoreFactors   []ScoreFactor           `gorm:"serializer:json" json:"score_factors"`
+	ScoreModel     string                  `gorm:"type:varchar(50)" json:"score_model"`
+	ScoreLatency   int64                   `json:"score_latency_ms"`
+	ScoredAt       *time.Time              `json:"scored_at"`
+	DeclineReasons []DeclineReason         `gorm:"serializer:json" json:"decline_reasons"`
+	Payments       []Payment               `json:"payments"`
+}
+
+// Decline reason codes given to customers whose application is rejected
+const (
+	DeclineLowScore               = "LOW_SCORE"
+	DeclineInsufficientHistory    = "INSUFFICIENT_HISTORY"
+	DeclineExistingDelinquency    = "EXISTING_DELINQUENCY"
+	DeclineKYCIncomplete          = "KYC_INCOMPLETE"
+	DeclineHighOutstandingBalance = "HIGH_OUTSTANDING_BALANCE"
+	DeclineUnderwriterDecision    = "UNDERWRITER_DECISION"
+)
+
+// declineReasonDescriptions holds the customer-facing explanation of each
+// decline reason code.
+var declineReasonDescriptions = map[string]string{
+	DeclineLowScore:               "Your credit score is below the level required for approval",
+	DeclineInsufficientHistory:    "You do not yet have enough repayment history with us",
+	DeclineExistingDelinquency:    "You have late or failed installments on an existing plan",
+	DeclineKYCIncomplete:          "Your identity verification is incomplete",
+	DeclineHighOutstandingBalance: "Your outstanding balance is too high relative to the amount requested",
+	DeclineUnderwriterDecision:    "Your application was declined after manual review",
+}
+
+// DeclineReason is an adverse action reason stored on a rejected application.
+type DeclineReason struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// NewDeclineReason builds the DeclineReason for a known code. It reports
+// false for codes that are not defined.
+func NewDeclineReason(code string) (DeclineReason, bool) {
+	description, ok := declineReasonDescriptions[code]
+	return DeclineReason{Code: code, Description: description}, ok
+}
+
+// CreditApplicationTransition is an audit record of one status change of a
+// credit application.
+type CreditApplicationTransition struct {
+	gorm.Model
+	CreditApplicationID uint                    `gorm:"not null;index" json:"credit_application_id"`
+	FromStatus          CreditApplicationStatus `gorm:"type:varchar(20);not null" json:"from_status"`
+	ToStatus            CreditApplicationStatus `gorm:"type:varchar(20);not null" json:"to_status"`
+	Actor               string                  `gorm:"type:varchar(100);not null" json:"actor"`
+	Reason              string                  `gorm:"type:text" json:"reason"`
+	TransitionedAt      time.Time               `gorm:"not null" json:"transitioned_at"`
+}
+
+// CreditLimit returns the amount the application allows the user to spend.
+// Applications approved before ApprovedAmount existed fall back to Amount.
+func (a *CreditApplication) CreditLimit() int {
+	if a.ApprovedAmount > 0 {
+		return a.ApprovedAmount
+	}
+	return a.Amount
+}
+
+// ScoreFactor is one contribution to the credit score of an application.
+type ScoreFactor struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Points      int    `json:"points"`
 }
 
 // Payment represents a payment made towards a credit application.
 type Payment struct {
 	gorm.Model
-	CreditApplicationID uint   `gorm:"not null" json:"credit_application_id"`
-	UserID              string `gorm:"type:uuid;not null" json:"user_id"`
-	OrderID             string `gorm:"type:uuid;not null;unique" json:"order_id"`
-	Amount              int    `gorm:"not null" json:"amount"`
-	Currency            string `gorm:"type:varchar(3);not null" json:"currency"`
+	CreditApplicationID uint          `gorm:"not null" json:"credit_application_id"`
+	UserID              string        `gorm:"type:uuid;not null" json:"user_id"`
+	// OrderID is the merchant's reference for the order, unique per merchant
+	// among payments that have not failed.
+	OrderID             string        `gorm:"type:varchar(100);not null" json:"order_id"`
+	Amount              int           `gorm:"not null" json:"amount"`
+	// FeeAmount is the cost of credit disclosed at purchase, charged on top
+	// of Amount through the installments.
+	FeeAmount           int           `gorm:"not null;default:0" json:"fee_amount"`
+	// RefundedAmount is the part of Amount returned by refunds. A payment
+	// refunded in full is REFUNDED.
+	RefundedAmount      int           `gorm:"not null;default:0" json:"refunded_amount"`
+	Currency            string        `gorm:"type:varchar(3);not null" json:"currency"`
 	PaymentMethod       PaymentMethod `gorm:"embedded" json:"payment_method"`
 	Status              string        `gorm:"type:varchar(20);not null" json:"status"`
+	GatewayOrderID      string        `gorm:"type:varchar(64);index" json:"gateway_order_id"`
+	RedirectURL         string        `gorm:"type:text" json:"redirect_url"`
+	// MerchantID is the merchant the purchase is made from, billed and
+	// settled for it. StoreID is the merchant's store, when known.
+	MerchantID          uint          `gorm:"index" json:"merchant_id"`
+	StoreID             *uint         `gorm:"index" json:"store_id"`
+	MerchantCategory    string        `gorm:"type:varchar(50)" json:"merchant_category"`
+	PlanProductID       *uint         `gorm:"index" json:"plan_product_id"`
+	Plan                PlanTerms     `gorm:"embedded;embeddedPrefix:plan_" json:"plan"`
+	// QuoteID is the signed quote whose terms the payment honoured, if any.
+	QuoteID             string        `gorm:"type:varchar(36)" json:"quote_id"`
+	// ScheduleStart is when the quoted schedule starts. Without a quote the
+	// schedule starts when the installments are created.
+	ScheduleStart       *time.Time    `json:"schedule_start,omitempty"`
+	// QuoteToken is the signed quote presented when creating the payment.
+	QuoteToken          string        `gorm:"-" json:"-"`
+	// ReturnPath is where the gateway sends the customer back to after
+	// paying, the payment return path when empty.
+	ReturnPath          string        `gorm:"-" json:"-"`
 	Installments        []Installment `json:"installments"`
 }
 
+// TotalRepayable returns the purchase amount plus the cost of credit.
+func (p *Payment) TotalRepayable() int {
+	return p.Amount + p.FeeAmount
+}
+
+// OutstandingCharges returns the late fees and other charges still owed on
+// the loaded installments. They are not part of TotalRepayable.
+func (p *Payment) OutstandingCharges() int {
+	total := 0
+	for _, installment := range p.Installments {
+		for _, charge := range installment.Charges {
+			if charge.Outstanding() {
+				total += charge.Amount
+			}
+		}
+	}
+	return total
+}
+
 // PaymentMethod represents the payment method details.
 type PaymentMethod struct {
 	Type    string         `gorm:"type:varchar(20);not null" json:"type"`
 	Details PaymentDetails `gorm:"embedded" json:"details"`
 }
 
-// PaymentDetails represents the details of the payment method.
+// PaymentDetails represents the details of the payment method. The card
+// itself is kept in the card vault; payments only reference its token.
 type PaymentDetails struct {
-	CardNumber     string `gorm:"type:varchar(16)" json:"card_number"`
-	CardHolderName string `gorm:"type:varchar(100)" json:"card_holder_name"`
-	ExpiryDate     string `gorm:"type:varchar(5)" json:"expiry_date"`
-	CVV            string `gorm:"type:varchar(4)" json:"cvv"`
+	CardToken  string `gorm:"type:varchar(64)" json:"card_token"`
+	CardBrand  string `gorm:"type:varchar(20)" json:"card_brand"`
+	CardLast4  string `gorm:"type:varchar(4)" json:"card_last4"`
+	ExpiryDate string `gorm:"type:varchar(5)" json:"expiry_date"`
 }
 
-// Installment represents an installment in the payment plan.
+// Installment represents an installment in the payment plan. Its status is
+// PENDING until it is due, then PAID, or FAILED while collection is retried,
+// OVERDUE once past the grace period and DEFAULTED when written off to
+// collections. A refund that removes all it owes leaves it CANCELLED.
 type Installment struct {
 	gorm.Model
 	PaymentID         uint   `gorm:"not null" json:"payment_id"`
 	InstallmentNumber int    `gorm:"not null" json:"installment_number"`
 	DueDate           string `gorm:"type:date;not null" json:"due_date"`
 	Amount            int    `gorm:"not null" json:"amount"`
+	// Principal and Fee split Amount into the repaid purchase amount and the
+	// plan's cost of credit. Late penalties are never added to either.
+	Principal         int    `gorm:"not null;default:0" json:"principal"`
+	Fee               int    `gorm:"not null;default:0" json:"fee"`
 	Status            string `gorm:"type:varchar(20);not null" json:"status"`
-}
\ No newline at end of file
+	GatewayOrderID    string `gorm:"type:varchar(64);index" json:"gateway_order_id"`
+	RedirectURL       string `gorm:"type:text" json:"redirect_url"`
+	// RetryCount is how many collections have failed; NextRetryAt is when the
+	// next one is scheduled, if the dunning policy allows one.
+	RetryCount        int        `gorm:"not null;default:0" json:"retry_count"`
+	NextRetryAt       *time.Time `gorm:"index" json:"next_retry_at"`
+	// OrderOpenedAt is when the customer last opened a gateway order to pay
+	// the installment by hand. Automatic collection leaves it alone for a
+	// while so that it is not charged twice.
+	OrderOpenedAt *time.Time `json:"order_opened_at,omitempty"`
+	// Charges are the late fees and other charges owed on top of Amount. They
+	// are stored apart and only loaded with the payment details.
+	Charges []InstallmentCharge `gorm:"-" json:"charges,omitempty"`
+}
+
+// DueOn returns the due date as a time at midnight UTC. DueDate is stored as
+// a date column, which reads back with a time part.
+func (i *Installment) DueOn() (time.Time, error) {
+	dueDate := i.DueDate
+	if len(dueDate) > len("2006-01-02") {
+		dueDate = dueDate[:len("2006-01-02")]
+	}
+	return time.Parse("2006-01-02", dueDate)
+}
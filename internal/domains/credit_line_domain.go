@@ -0,0 +1,59 @@
+package domains
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CreditLineStatus is the state of a user's credit line.
+type CreditLineStatus string
+
+const (
+	CreditLineActive CreditLineStatus = "ACTIVE"
+	CreditLineFrozen CreditLineStatus = "FROZEN"
+)
+
+// CreditLine is the single spending limit of a user across all their approved
+// credit applications.
+type CreditLine struct {
+	gorm.Model
+	UserID      string           `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	Currency    string           `gorm:"type:varchar(3);not null" json:"currency"`
+	CreditLimit int              `gorm:"not null" json:"credit_limit"`
+	Utilized    int              `gorm:"not null;default:0" json:"utilized"`
+	Reserved    int              `gorm:"not null;default:0" json:"reserved"`
+	Status      CreditLineStatus `gorm:"type:varchar(20);not null" json:"status"`
+	Version     int              `gorm:"not null;default:0" json:"version"`
+}
+
+// Available returns the amount the user can still spend. Credit held by
+// pending payments is not available.
+func (l *CreditLine) Available() int {
+	available := l.CreditLimit - l.Utilized - l.Reserved
+	if available < 0 || l.Status != CreditLineActive {
+		return 0
+	}
+	return available
+}
+
+// CreditReservationStatus is the state of a credit reservation.
+type CreditReservationStatus string
+
+const (
+	CreditReservationActive   CreditReservationStatus = "ACTIVE"
+	CreditReservationCaptured CreditReservationStatus = "CAPTURED"
+	CreditReservationReleased CreditReservationStatus = "RELEASED"
+	CreditReservationExpired  CreditReservationStatus = "EXPIRED"
+)
+
+// CreditReservation holds part of a credit line for a payment until the
+// gateway confirms or declines it.
+type CreditReservation struct {
+	gorm.Model
+	UserID    string                  `gorm:"type:uuid;not null;index" json:"user_id"`
+	PaymentID uint                    `gorm:"not null;uniqueIndex" json:"payment_id"`
+	Amount    int                     `gorm:"not null" json:"amount"`
+	Status    CreditReservationStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	ExpiresAt time.Time               `gorm:"not null;index" json:"expires_at"`
+}
@@ -0,0 +1,28 @@
+package domains
+
+import "time"
+
+// IdempotencyStatus is the state of a request made with an Idempotency-Key.
+type IdempotencyStatus string
+
+const (
+	IdempotencyInProgress IdempotencyStatus = "IN_PROGRESS"
+	IdempotencyCompleted  IdempotencyStatus = "COMPLETED"
+)
+
+// IdempotencyRecord stores the first response to a request made with a given
+// Idempotency-Key so that retries can be answered without repeating it. Keys
+// are scoped per user.
+type IdempotencyRecord struct {
+	ID           uint              `gorm:"primarykey" json:"id"`
+	UserID       string            `gorm:"type:varchar(64);not null;uniqueIndex:idx_idempotency_user_key" json:"user_id"`
+	Key          string            `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key" json:"key"`
+	Method       string            `gorm:"type:varchar(10);not null" json:"method"`
+	Path         string            `gorm:"type:varchar(255);not null" json:"path"`
+	RequestHash  string            `gorm:"type:varchar(64);not null" json:"request_hash"`
+	Status       IdempotencyStatus `gorm:"type:varchar(20);not null" json:"status"`
+	ResponseCode int               `json:"response_code"`
+	ResponseBody []byte            `json:"-"`
+	CreatedAt    time.Time         `json:"created_at"`
+	ExpiresAt    time.Time         `gorm:"not null;index" json:"expires_at"`
+}
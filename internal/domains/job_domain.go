@@ -0,0 +1,16 @@
+package domains
+
+import "time"
+
+// JobLease is the persisted schedule of a background job. Replicas take the
+// lease before running the job, so each run happens once across the fleet,
+// and NextRunAt survives restarts.
+type JobLease struct {
+	Name           string     `gorm:"type:varchar(100);primaryKey" json:"name"`
+	Owner          string     `gorm:"type:varchar(100)" json:"owner"`
+	LeaseUntil     time.Time  `gorm:"not null" json:"lease_until"`
+	NextRunAt      time.Time  `gorm:"not null" json:"next_run_at"`
+	LastStartedAt  *time.Time `json:"last_started_at,omitempty"`
+	LastFinishedAt *time.Time `json:"last_finished_at,omitempty"`
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+}
@@ -0,0 +1,112 @@
+package domains
+
+import "time"
+
+// LedgerAccount is an account of the double-entry ledger.
+type LedgerAccount string
+
+const (
+	// AccountCustomerReceivable is what customers owe on their purchases.
+	AccountCustomerReceivable LedgerAccount = "CUSTOMER_RECEIVABLE"
+	// AccountMerchantPayable is what is owed to merchants for purchases.
+	AccountMerchantPayable LedgerAccount = "MERCHANT_PAYABLE"
+	// AccountFeeIncome is plan fees and merchant discounts earned.
+	AccountFeeIncome LedgerAccount = "FEE_INCOME"
+	// AccountPenaltyPayable is late fees charged to customers. They are not
+	// income; what is collected is paid out to charity.
+	AccountPenaltyPayable LedgerAccount = "PENALTY_PAYABLE"
+	// AccountCashInTransit is card money collected or refunded through the
+	// gateway and not yet settled with the bank.
+	AccountCashInTransit LedgerAccount = "CASH_IN_TRANSIT"
+	// AccountCreditLosses is receivables written off when installments
+	// default, less what is recovered later.
+	AccountCreditLosses LedgerAccount = "CREDIT_LOSSES"
+	// AccountCustomerOverpayments is money taken from customers that they
+	// did not owe, until it is refunded to their card.
+	AccountCustomerOverpayments LedgerAccount = "CUSTOMER_OVERPAYMENTS"
+)
+
+// LedgerAccounts lists every ledger account.
+var LedgerAccounts = []LedgerAccount{
+	AccountCustomerReceivable,
+	AccountMerchantPayable,
+	AccountFeeIncome,
+	AccountPenaltyPayable,
+	AccountCashInTransit,
+	AccountCreditLosses,
+	AccountCustomerOverpayments,
+}
+
+// Valid reports whether a is a known ledger account.
+func (a LedgerAccount) Valid() bool {
+	for _, account := range LedgerAccounts {
+		if a == account {
+			return true
+		}
+	}
+	return false
+}
+
+// JournalType is the money movement a journal records.
+type JournalType string
+
+const (
+	JournalPurchase   JournalType = "PURCHASE"
+	JournalCollection JournalType = "COLLECTION"
+	JournalRefund     JournalType = "REFUND"
+	JournalFee        JournalType = "FEE"
+	JournalWriteOff   JournalType = "WRITE_OFF"
+	// JournalSettlement pays a merchant what it is owed for a purchase or
+	// refund, less the merchant discount.
+	JournalSettlement JournalType = "SETTLEMENT"
+)
+
+// Journal is one balanced money movement. Journals and their entries are
+// never changed once posted; mistakes are corrected by posting another
+// journal. Reference identifies the event posted, so that each event is
+// posted once.
+type Journal struct {
+	ID          uint          `gorm:"primarykey" json:"id"`
+	Type        JournalType   `gorm:"type:varchar(20);not null;uniqueIndex:idx_journals_reference" json:"type"`
+	Reference   string        `gorm:"type:varchar(100);not null;uniqueIndex:idx_journals_reference" json:"reference"`
+	PaymentID   uint          `gorm:"not null;index" json:"payment_id"`
+	UserID      string        `gorm:"type:uuid;not null;index" json:"user_id"`
+	Currency    string        `gorm:"type:varchar(3);not null" json:"currency"`
+	Description string        `gorm:"type:text" json:"description"`
+	PostedAt    time.Time     `gorm:"not null;index" json:"posted_at"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Entries     []LedgerEntry `gorm:"foreignKey:JournalID" json:"entries"`
+}
+
+// LedgerEntry debits or credits one account as part of a journal. Exactly
+// one of Debit and Credit is set. PaymentID and UserID are copied from the
+// journal so balances can be taken per payment or customer.
+type LedgerEntry struct {
+	ID        uint          `gorm:"primarykey" json:"id"`
+	JournalID uint          `gorm:"not null;index" json:"journal_id"`
+	Account   LedgerAccount `gorm:"type:varchar(30);not null;index:idx_ledger_entries_account" json:"account"`
+	PaymentID uint          `gorm:"not null;index" json:"payment_id"`
+	UserID    string        `gorm:"type:uuid;not null;index" json:"user_id"`
+	Debit     int           `gorm:"not null;default:0" json:"debit"`
+	Credit    int           `gorm:"not null;default:0" json:"credit"`
+	PostedAt  time.Time     `gorm:"not null;index:idx_ledger_entries_account" json:"posted_at"`
+}
+
+// Balanced reports whether the journal's debits equal its credits.
+func (j *Journal) Balanced() bool {
+	balance := 0
+	for _, entry := range j.Entries {
+		balance += entry.Debit - entry.Credit
+	}
+	return balance == 0
+}
+
+// AccountBalance is the total of an account's entries up to a date.
+// Balance is debits less credits, so accounts that normally hold credits,
+// such as fee income, have negative balances.
+type AccountBalance struct {
+	Account LedgerAccount `json:"account"`
+	Debit   int           `json:"debit"`
+	Credit  int           `json:"credit"`
+	Balance int           `json:"balance"`
+}
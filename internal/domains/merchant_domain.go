@@ -0,0 +1,65 @@
+package domains
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MerchantStatus is the onboarding state of a merchant.
+type MerchantStatus string
+
+const (
+	// MerchantPending merchants are being onboarded and cannot take payments.
+	MerchantPending MerchantStatus = "PENDING"
+	MerchantActive  MerchantStatus = "ACTIVE"
+	// MerchantSuspended merchants cannot take new payments until they are
+	// reactivated; their existing payments are still collected and settled.
+	MerchantSuspended MerchantStatus = "SUSPENDED"
+	MerchantClosed    MerchantStatus = "CLOSED"
+)
+
+// Merchant is a business whose goods customers finance. Payments are made
+// at its stores and settled to its bank account.
+type Merchant struct {
+	gorm.Model
+	Name      string `gorm:"type:varchar(100);not null" json:"name"`
+	LegalName string `gorm:"type:varchar(200);not null" json:"legal_name"`
+	// TaxID is the merchant's fiscal identification number (NIF).
+	TaxID    string `gorm:"type:varchar(20);not null;uniqueIndex" json:"tax_id"`
+	Email    string `gorm:"type:varchar(100);not null" json:"email"`
+	Phone    string `gorm:"type:varchar(20)" json:"phone"`
+	Category string `gorm:"type:varchar(50);not null;index" json:"category"`
+	// RIB is the 20 digit bank account merchant settlements are paid to.
+	RIB string `gorm:"type:varchar(20);not null" json:"rib"`
+	// DiscountRateBps is the merchant discount rate, in basis points of the
+	// purchase amount, kept from what the merchant is paid.
+	DiscountRateBps int            `gorm:"not null;default:0" json:"discount_rate_bps"`
+	Status          MerchantStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	ActivatedAt     *time.Time     `json:"activated_at,omitempty"`
+	Stores          []Store        `json:"stores,omitempty"`
+}
+
+// Store is a shop or website of a merchant where payments are made.
+type Store struct {
+	gorm.Model
+	MerchantID uint   `gorm:"not null;index" json:"merchant_id"`
+	Name       string `gorm:"type:varchar(100);not null" json:"name"`
+	Address    string `gorm:"type:text" json:"address"`
+	City       string `gorm:"type:varchar(100)" json:"city"`
+	Active     bool   `gorm:"not null;default:true" json:"active"`
+}
+
+// MerchantAPIKey lets a merchant's systems call the API on its behalf. Only
+// a hash of the key is stored; the key itself is shown once, when created.
+// Prefix is the public part of the key used to look it up.
+type MerchantAPIKey struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	MerchantID uint       `gorm:"not null;index" json:"merchant_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(20);not null;uniqueIndex" json:"prefix"`
+	KeyHash    string     `gorm:"type:varchar(64);not null" json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,76 @@
+package domains
+
+import "time"
+
+// WebhookEndpoint is a URL of a merchant that its events are posted to.
+// Deliveries are signed with Secret, which is shown once, when the endpoint
+// is created. An endpoint with no event types gets every event.
+type WebhookEndpoint struct {
+	ID          uint                `gorm:"primarykey" json:"id"`
+	MerchantID  uint                `gorm:"not null;index" json:"merchant_id"`
+	URL         string              `gorm:"type:varchar(500);not null" json:"url"`
+	Description string              `gorm:"type:varchar(200)" json:"description"`
+	Secret      string              `gorm:"type:varchar(100);not null" json:"-"`
+	EventTypes  []MerchantEventType `gorm:"serializer:json" json:"event_types"`
+	DisabledAt  *time.Time          `json:"disabled_at,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// Subscribes reports whether the endpoint gets events of eventType.
+func (e *WebhookEndpoint) Subscribes(eventType MerchantEventType) bool {
+	if len(e.EventTypes) == 0 {
+		return true
+	}
+	for _, subscribed := range e.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus is the state of an event's delivery to an endpoint.
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending deliveries are due at NextAttemptAt.
+	WebhookDeliveryPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "SUCCEEDED"
+	// WebhookDeliveryDead deliveries ran out of attempts, or their endpoint
+	// was disabled. They are only sent again when replayed.
+	WebhookDeliveryDead WebhookDeliveryStatus = "DEAD"
+)
+
+// WebhookDelivery is an event owed to one endpoint. It is created with the
+// event, so an event is delivered if and only if the change it reports was
+// kept. Attempts counts the attempts since it was created or last replayed.
+type WebhookDelivery struct {
+	ID                 uint                     `gorm:"primarykey" json:"id"`
+	EndpointID         uint                     `gorm:"not null;uniqueIndex:idx_webhook_deliveries_endpoint_event" json:"endpoint_id"`
+	EventID            uint                     `gorm:"not null;uniqueIndex:idx_webhook_deliveries_endpoint_event" json:"event_id"`
+	MerchantID         uint                     `gorm:"not null;index" json:"merchant_id"`
+	EventType          MerchantEventType        `gorm:"type:varchar(50);not null" json:"event_type"`
+	Status             WebhookDeliveryStatus    `gorm:"type:varchar(20);not null;index" json:"status"`
+	Attempts           int                      `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt      *time.Time               `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastAttemptAt      *time.Time               `json:"last_attempt_at,omitempty"`
+	LastResponseStatus int                      `json:"last_response_status,omitempty"`
+	LastError          string                   `gorm:"type:text" json:"last_error,omitempty"`
+	DeliveredAt        *time.Time               `json:"delivered_at,omitempty"`
+	AttemptLog         []WebhookDeliveryAttempt `gorm:"foreignKey:DeliveryID" json:"attempt_log,omitempty"`
+	CreatedAt          time.Time                `json:"created_at"`
+	UpdatedAt          time.Time                `json:"updated_at"`
+}
+
+// WebhookDeliveryAttempt records one POST of a delivery and what the
+// endpoint answered. What the endpoint sent back is not kept, as it may
+// hold anything.
+type WebhookDeliveryAttempt struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	DeliveryID     uint      `gorm:"not null;index" json:"delivery_id"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `gorm:"type:text" json:"error,omitempty"`
+	DurationMs     int64     `gorm:"not null" json:"duration_ms"`
+	AttemptedAt    time.Time `gorm:"not null" json:"attempted_at"`
+}
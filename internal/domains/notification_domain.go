@@ -0,0 +1,23 @@
+package domains
+
+import "time"
+
+// Notification types sent to customers
+const (
+	NotificationInstallmentFailed    = "INSTALLMENT_PAYMENT_FAILED"
+	NotificationInstallmentOverdue   = "INSTALLMENT_OVERDUE"
+	NotificationInstallmentDefaulted = "INSTALLMENT_DEFAULTED"
+)
+
+// Notification is a message to a customer. Notifications are stored in the
+// same transaction as the change they report, and delivered from the store.
+type Notification struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	UserID        string     `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type          string     `gorm:"type:varchar(50);not null" json:"type"`
+	Message       string     `gorm:"type:text;not null" json:"message"`
+	PaymentID     uint       `gorm:"index" json:"payment_id,omitempty"`
+	InstallmentID uint       `gorm:"index" json:"installment_id,omitempty"`
+	CreatedAt     time.Time  `gorm:"not null;index" json:"created_at"`
+	ReadAt        *time.Time `json:"read_at,omitempty"`
+}
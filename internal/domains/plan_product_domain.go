@@ -0,0 +1,113 @@
+package domains
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PlanFrequency is the interval between two installments of a plan.
+type PlanFrequency string
+
+const (
+	PlanFrequencyWeekly   PlanFrequency = "WEEKLY"
+	PlanFrequencyBiweekly PlanFrequency = "BIWEEKLY"
+	PlanFrequencyMonthly  PlanFrequency = "MONTHLY"
+)
+
+// DueDate returns the due date of the period-th installment of a schedule
+// starting at start. Period 0 is due at start.
+func (f PlanFrequency) DueDate(start time.Time, period int) time.Time {
+	switch f {
+	case PlanFrequencyWeekly:
+		return start.AddDate(0, 0, 7*period)
+	case PlanFrequencyBiweekly:
+		return start.AddDate(0, 0, 14*period)
+	default:
+		// Clamp to the end of shorter months rather than rolling over, so a
+		// schedule starting on the 31st stays on month ends
+		firstOfMonth := time.Date(start.Year(), start.Month()+time.Month(period), 1,
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+		day := start.Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return firstOfMonth.AddDate(0, 0, day-1)
+	}
+}
+
+// Valid reports whether f is a known frequency.
+func (f PlanFrequency) Valid() bool {
+	switch f {
+	case PlanFrequencyWeekly, PlanFrequencyBiweekly, PlanFrequencyMonthly:
+		return true
+	}
+	return false
+}
+
+// FeeModel is how the cost of credit of a plan is charged.
+type FeeModel string
+
+const (
+	// FeeModelNone charges nothing on top of the purchase amount.
+	FeeModelNone FeeModel = "NONE"
+	// FeeModelFlat charges FeeValue, in the payment currency, per purchase.
+	FeeModelFlat FeeModel = "FLAT"
+	// FeeModelPercentage charges FeeValue basis points of the purchase amount.
+	FeeModelPercentage FeeModel = "PERCENTAGE"
+)
+
+// Valid reports whether m is a known fee model.
+func (m FeeModel) Valid() bool {
+	switch m {
+	case FeeModelNone, FeeModelFlat, FeeModelPercentage:
+		return true
+	}
+	return false
+}
+
+// PlanTerms are the repayment terms of an installment plan. They are copied
+// onto each payment so that later catalog edits do not change the schedule
+// the customer agreed to.
+type PlanTerms struct {
+	InstallmentCount   int           `gorm:"not null;default:0" json:"installment_count"`
+	Frequency          PlanFrequency `gorm:"type:varchar(20)" json:"frequency"`
+	DownPaymentPercent int           `gorm:"not null;default:0" json:"down_payment_percent"`
+	FeeModel           FeeModel      `gorm:"type:varchar(20)" json:"fee_model"`
+	FeeValue           int           `gorm:"not null;default:0" json:"fee_value"`
+}
+
+// PlanProduct is an installment plan offered to customers at checkout, such
+// as "pay in 4" or "12 months".
+type PlanProduct struct {
+	gorm.Model
+	Code      string    `gorm:"type:varchar(50);not null;uniqueIndex" json:"code"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	Terms     PlanTerms `gorm:"embedded" json:"terms"`
+	MinAmount int       `gorm:"not null;default:0" json:"min_amount"`
+	// MaxAmount of 0 means the plan has no upper bound.
+	MaxAmount int `gorm:"not null;default:0" json:"max_amount"`
+	// MerchantCategories restricts the plan to purchases in these categories.
+	// An empty list makes the plan available in every category.
+	MerchantCategories []string `gorm:"serializer:json" json:"merchant_categories"`
+	Active             bool     `gorm:"not null;default:true" json:"active"`
+}
+
+// CoversAmount reports whether amount is within the plan's bounds.
+func (p *PlanProduct) CoversAmount(amount int) bool {
+	return amount >= p.MinAmount && (p.MaxAmount == 0 || amount <= p.MaxAmount)
+}
+
+// CoversCategory reports whether the plan may finance a purchase in category.
+func (p *PlanProduct) CoversCategory(category string) bool {
+	if len(p.MerchantCategories) == 0 {
+		return true
+	}
+	for _, allowed := range p.MerchantCategories {
+		if allowed == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package domains
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PrepaymentType is whether a prepayment settles a purchase or only part of it.
+type PrepaymentType string
+
+const (
+	// PrepaymentPayoff settles every unpaid installment of a payment.
+	PrepaymentPayoff PrepaymentType = "PAYOFF"
+	// PrepaymentPartial pays part of the remaining balance ahead of schedule.
+	PrepaymentPartial PrepaymentType = "PARTIAL"
+)
+
+// PrepaymentAllocation is how a partial prepayment is spread over the
+// unpaid installments.
+type PrepaymentAllocation string
+
+const (
+	// AllocationNext pays installments in due date order, the last one
+	// reached possibly only in part.
+	AllocationNext PrepaymentAllocation = "NEXT"
+	// AllocationProRata lowers every unpaid installment in proportion to
+	// its amount.
+	AllocationProRata PrepaymentAllocation = "PRO_RATA"
+)
+
+// Valid reports whether a is a known allocation.
+func (a PrepaymentAllocation) Valid() bool {
+	return a == AllocationNext || a == AllocationProRata
+}
+
+// PrepaymentStatus is the state of a prepayment's gateway order.
+type PrepaymentStatus string
+
+const (
+	PrepaymentPending PrepaymentStatus = "PENDING"
+	PrepaymentPaid    PrepaymentStatus = "PAID"
+	PrepaymentFailed  PrepaymentStatus = "FAILED"
+)
+
+// PrepaymentLine is the part of a prepayment applied to one installment.
+// Rebate is plan fee forgiven on the installment instead of being paid.
+type PrepaymentLine struct {
+	InstallmentID uint `json:"installment_id"`
+	Principal     int  `json:"principal"`
+	Fee           int  `json:"fee"`
+	Rebate        int  `json:"rebate,omitempty"`
+}
+
+// Amount returns what the customer pays towards the installment.
+func (l PrepaymentLine) Amount() int {
+	return l.Principal + l.Fee
+}
+
+// Prepayment is a customer payment of installments ahead of their schedule.
+// Its lines are fixed when the gateway order is registered and applied to
+// the installments once the gateway reports the order paid.
+type Prepayment struct {
+	gorm.Model
+	PaymentID      uint                 `gorm:"not null;index" json:"payment_id"`
+	UserID         string               `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type           PrepaymentType       `gorm:"type:varchar(20);not null" json:"type"`
+	Allocation     PrepaymentAllocation `gorm:"type:varchar(20)" json:"allocation,omitempty"`
+	Amount         int                  `gorm:"not null" json:"amount"`
+	Rebate         int                  `gorm:"not null;default:0" json:"rebate"`
+	Currency       string               `gorm:"type:varchar(3);not null" json:"currency"`
+	Lines          []PrepaymentLine     `gorm:"serializer:json" json:"lines"`
+	Status         PrepaymentStatus     `gorm:"type:varchar(20);not null;index" json:"status"`
+	GatewayOrderID string               `gorm:"type:varchar(64);uniqueIndex" json:"gateway_order_id"`
+	RedirectURL    string               `gorm:"type:text" json:"redirect_url"`
+	// LateFees is the part of Amount billed for charges owed on the
+	// installments rather than for the installments themselves.
+	LateFees int `gorm:"not null;default:0" json:"late_fees"`
+	// Unapplied is the part of a paid prepayment that could not be applied
+	// because its installment was settled meanwhile. It is refunded to the
+	// card the prepayment was paid with, at UnappliedRefundedAt.
+	Unapplied           int        `gorm:"not null;default:0" json:"unapplied"`
+	UnappliedRefundedAt *time.Time `json:"unapplied_refunded_at,omitempty"`
+}
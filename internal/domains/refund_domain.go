@@ -0,0 +1,94 @@
+package domains
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PaymentReceipt is money taken from the customer's card towards a payment,
+// by the gateway order that charged it. Card refunds are made against
+// receipts, up to what each one received.
+type PaymentReceipt struct {
+	ID             uint   `gorm:"primarykey" json:"id"`
+	PaymentID      uint   `gorm:"not null;index" json:"payment_id"`
+	InstallmentID  *uint  `gorm:"index" json:"installment_id,omitempty"`
+	PrepaymentID   *uint  `gorm:"index" json:"prepayment_id,omitempty"`
+	GatewayOrderID string `gorm:"type:varchar(64);not null;uniqueIndex" json:"gateway_order_id"`
+	Amount         int    `gorm:"not null" json:"amount"`
+	// Refunded is how much of Amount has been given back to the card.
+	Refunded int `gorm:"not null;default:0" json:"refunded"`
+	// Overpayment receipts are money an order took for an installment that
+	// was already paid or cancelled. All of it is owed back to the card, and
+	// none of it to refunds of the purchase.
+	Overpayment bool      `gorm:"not null;default:false;index" json:"overpayment,omitempty"`
+	ReceivedAt  time.Time `gorm:"not null" json:"received_at"`
+}
+
+// Refundable returns what can still be refunded to the card.
+func (r *PaymentReceipt) Refundable() int {
+	return r.Amount - r.Refunded
+}
+
+// RefundStatus is the state of a refund.
+type RefundStatus string
+
+const (
+	// RefundPending refunds have changed the schedule and are waiting for
+	// their card refunds to be confirmed by the gateway.
+	RefundPending   RefundStatus = "PENDING"
+	RefundSucceeded RefundStatus = "SUCCEEDED"
+	// RefundFailed refunds have a card refund the gateway refused. The
+	// schedule changes stand; an admin retries the card refund.
+	RefundFailed RefundStatus = "FAILED"
+)
+
+// RefundLineKind is where one part of a refund goes.
+type RefundLineKind string
+
+const (
+	// RefundLineSchedule lowers or cancels an unpaid installment.
+	RefundLineSchedule RefundLineKind = "SCHEDULE"
+	// RefundLineCard gives money back to the card that paid a receipt.
+	RefundLineCard RefundLineKind = "CARD"
+)
+
+// RefundLine is one part of a refund: an installment reduction or a card
+// refund of a receipt.
+type RefundLine struct {
+	Kind           RefundLineKind `json:"kind"`
+	InstallmentID  uint           `json:"installment_id,omitempty"`
+	ReceiptID      uint           `json:"receipt_id,omitempty"`
+	GatewayOrderID string         `json:"gateway_order_id,omitempty"`
+	Principal      int            `json:"principal,omitempty"`
+	Fee            int            `json:"fee,omitempty"`
+	Amount         int            `json:"amount"`
+	Status         RefundStatus   `json:"status"`
+	Error          string         `json:"error,omitempty"`
+	// WrittenOff is set when the installment had defaulted and was written
+	// off, so the refund recovers a credit loss rather than a receivable.
+	WrittenOff bool `json:"written_off,omitempty"`
+}
+
+// Refund gives back part or all of a purchase after goods are returned.
+// Amount is the returned part of the purchase; the plan fee on it is
+// forgiven too. Unpaid installments are lowered first, latest first, and
+// whatever was already paid beyond that goes back to the card.
+type Refund struct {
+	gorm.Model
+	PaymentID uint   `gorm:"not null;index" json:"payment_id"`
+	UserID    string `gorm:"type:uuid;not null;index" json:"user_id"`
+	Amount    int    `gorm:"not null" json:"amount"`
+	// FeeReduction is the plan fee forgiven with the returned amount.
+	FeeReduction int `gorm:"not null;default:0" json:"fee_reduction"`
+	// ScheduleReduction and CardRefund split Amount plus FeeReduction
+	// between lowered installments and money back to the card.
+	ScheduleReduction int          `gorm:"not null;default:0" json:"schedule_reduction"`
+	CardRefund        int          `gorm:"not null;default:0" json:"card_refund"`
+	Currency          string       `gorm:"type:varchar(3);not null" json:"currency"`
+	Reason            string       `gorm:"type:text;not null" json:"reason"`
+	RequestedBy       string       `gorm:"type:varchar(100)" json:"requested_by"`
+	Status            RefundStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	Lines             []RefundLine `gorm:"serializer:json" json:"lines"`
+	CompletedAt       *time.Time   `json:"completed_at,omitempty"`
+}
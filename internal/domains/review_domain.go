@@ -0,0 +1,37 @@
+package domains
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ManualReviewStatus is the state of a manual underwriting review.
+type ManualReviewStatus string
+
+const (
+	ManualReviewOpen    ManualReviewStatus = "OPEN"
+	ManualReviewClaimed ManualReviewStatus = "CLAIMED"
+	ManualReviewDecided ManualReviewStatus = "DECIDED"
+)
+
+// Manual review decisions
+const (
+	ReviewDecisionApproved = "APPROVED"
+	ReviewDecisionDeclined = "DECLINED"
+)
+
+// ManualReview is a credit application waiting for, or decided by, a human
+// underwriter because its score fell in the grey zone.
+type ManualReview struct {
+	gorm.Model
+	CreditApplicationID uint               `gorm:"not null;uniqueIndex" json:"credit_application_id"`
+	Status              ManualReviewStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	CreditScore         int                `json:"credit_score"`
+	ReviewerID          string             `gorm:"type:varchar(100)" json:"reviewer_id"`
+	ClaimedAt           *time.Time         `json:"claimed_at"`
+	Decision            string             `gorm:"type:varchar(20)" json:"decision"`
+	DecisionReason      string             `gorm:"type:text" json:"decision_reason"`
+	ApprovedAmount      int                `json:"approved_amount"`
+	DecidedAt           *time.Time         `json:"decided_at"`
+}
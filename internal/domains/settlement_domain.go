@@ -0,0 +1,86 @@
+package domains
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SettlementBatchStatus is the state of a merchant payout.
+type SettlementBatchStatus string
+
+const (
+	// SettlementPending batches are waiting to be sent to the bank.
+	SettlementPending SettlementBatchStatus = "PENDING"
+	// SettlementExported batches are in a bank file awaiting the bank's
+	// confirmation.
+	SettlementExported SettlementBatchStatus = "EXPORTED"
+	SettlementPaid     SettlementBatchStatus = "PAID"
+	// SettlementFailed batches were rejected by the bank. They go in the
+	// next bank file.
+	SettlementFailed SettlementBatchStatus = "FAILED"
+)
+
+// SettlementBatch is one payout to a merchant: its purchases less refunds up
+// to a settlement date, less the merchant discount. The bank account and
+// name paid are those of the merchant when the batch was made.
+type SettlementBatch struct {
+	gorm.Model
+	MerchantID      uint                  `gorm:"not null;index" json:"merchant_id"`
+	SettlementDate  string                `gorm:"type:varchar(10);not null;index" json:"settlement_date"`
+	Currency        string                `gorm:"type:varchar(3);not null" json:"currency"`
+	GrossAmount     int                   `gorm:"not null" json:"gross_amount"`
+	FeeAmount       int                   `gorm:"not null" json:"fee_amount"`
+	NetAmount       int                   `gorm:"not null" json:"net_amount"`
+	ItemCount       int                   `gorm:"not null" json:"item_count"`
+	RIB             string                `gorm:"type:varchar(20);not null" json:"rib"`
+	BeneficiaryName string                `gorm:"type:varchar(200);not null" json:"beneficiary_name"`
+	Status          SettlementBatchStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	BankFileID      *uint                 `gorm:"index" json:"bank_file_id,omitempty"`
+	BankReference   string                `gorm:"type:varchar(100)" json:"bank_reference,omitempty"`
+	FailureReason   string                `gorm:"type:text" json:"failure_reason,omitempty"`
+	ExportedAt      *time.Time            `json:"exported_at,omitempty"`
+	PaidAt          *time.Time            `json:"paid_at,omitempty"`
+	Items           []SettlementItem      `gorm:"foreignKey:BatchID" json:"items,omitempty"`
+}
+
+// SettlementItem is a purchase or refund settled in a batch. Amounts of
+// refunds are negative, and give back the discount kept on them. Each
+// ledger journal is settled once.
+type SettlementItem struct {
+	ID              uint        `gorm:"primarykey" json:"id"`
+	BatchID         uint        `gorm:"not null;index" json:"batch_id"`
+	JournalID       uint        `gorm:"not null;uniqueIndex" json:"journal_id"`
+	Type            JournalType `gorm:"type:varchar(20);not null" json:"type"`
+	PaymentID       uint        `gorm:"not null;index" json:"payment_id"`
+	UserID          string      `gorm:"type:uuid;not null" json:"user_id"`
+	GrossAmount     int         `gorm:"not null" json:"gross_amount"`
+	DiscountRateBps int         `gorm:"not null" json:"discount_rate_bps"`
+	FeeAmount       int         `gorm:"not null" json:"fee_amount"`
+	NetAmount       int         `gorm:"not null" json:"net_amount"`
+	PostedAt        time.Time   `gorm:"not null" json:"posted_at"`
+}
+
+// UnsettledMovement is a purchase or refund owed to or by a merchant that
+// no batch has settled yet. Amount is what it adds to the merchant's payout.
+type UnsettledMovement struct {
+	JournalID  uint
+	Type       JournalType
+	PaymentID  uint
+	UserID     string
+	MerchantID uint
+	Currency   string
+	Amount     int
+	PostedAt   time.Time
+}
+
+// BankFile is a transfer file of settlement batches sent to the bank.
+type BankFile struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	FileName    string    `gorm:"type:varchar(100);not null" json:"file_name"`
+	Format      string    `gorm:"type:varchar(20);not null" json:"format"`
+	Content     string    `gorm:"type:text;not null" json:"-"`
+	BatchCount  int       `gorm:"not null" json:"batch_count"`
+	TotalAmount int       `gorm:"not null" json:"total_amount"`
+	CreatedAt   time.Time `json:"created_at"`
+}
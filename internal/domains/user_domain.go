@@ -20,4 +20,12 @@ type User struct {
 	RefreshTokenExpiresAt time.Time `db:"refresh_token_exp_date" json:"refresh_token_exp_date"`
 	IDImageURL            string    `db:"id_image_url" json:"id_image_url"`
 	CreditScore           int       `db:"credit_score" json:"credit_score"` 
+	Role                  string    `gorm:"type:varchar(20);not null;default:'customer'" db:"role" json:"role"`
 }
+
+// User roles
+const (
+	RoleCustomer = "customer"
+	RoleReviewer = "reviewer"
+	RoleAdmin    = "admin"
+)
@@ -0,0 +1,38 @@
+package domains
+
+import "time"
+
+// InboundWebhookStatus is what happened to a webhook delivery.
+type InboundWebhookStatus string
+
+const (
+	InboundWebhookReceived  InboundWebhookStatus = "RECEIVED"
+	InboundWebhookProcessed InboundWebhookStatus = "PROCESSED"
+	InboundWebhookDuplicate InboundWebhookStatus = "DUPLICATE"
+	InboundWebhookRejected  InboundWebhookStatus = "REJECTED"
+	InboundWebhookFailed    InboundWebhookStatus = "FAILED"
+)
+
+// InboundWebhookLog records every webhook delivery we receive, including the
+// ones we reject, for debugging gateway integrations.
+type InboundWebhookLog struct {
+	ID          uint                 `gorm:"primarykey" json:"id"`
+	Source      string               `gorm:"type:varchar(50);not null;index" json:"source"`
+	ResourceID  uint                 `gorm:"index" json:"resource_id"`
+	EventID     string               `gorm:"type:varchar(255);index" json:"event_id"`
+	Signature   string               `gorm:"type:varchar(128)" json:"signature"`
+	Timestamp   string               `gorm:"type:varchar(20)" json:"timestamp"`
+	Payload     string               `gorm:"type:text" json:"payload"`
+	Status      InboundWebhookStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	Error       string               `gorm:"type:text" json:"error,omitempty"`
+	ReceivedAt  time.Time            `gorm:"not null;index" json:"received_at"`
+	ProcessedAt *time.Time           `json:"processed_at,omitempty"`
+}
+
+// ProcessedWebhookEvent marks a webhook event ID as handled so that repeated
+// deliveries of the same event are ignored.
+type ProcessedWebhookEvent struct {
+	EventID     string    `gorm:"type:varchar(255);primaryKey" json:"event_id"`
+	Source      string    `gorm:"type:varchar(50);not null" json:"source"`
+	ProcessedAt time.Time `gorm:"not null" json:"processed_at"`
+}
@@ -0,0 +1,37 @@
+package dtos
+
+import (
+	"encoding/json"
+
+	"github.com/mohamed2394/sahla/internal/utils"
+)
+
+// CardRequest represents the DTO for a card entered by the customer
+type CardRequest struct {
+	Number     string `json:"number" validate:"required"`
+	HolderName string `json:"holder_name" validate:"required"`
+	ExpiryDate string `json:"expiry_date" validate:"required,len=5"`
+	CVV        string `json:"cvv" validate:"required,min=3,max=4,numeric"`
+}
+
+// MarshalJSON masks the card number and drops the CVV, so a CardRequest that
+// ends up in a log or response never exposes the card.
+func (c CardRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Number     string `json:"number"`
+		HolderName string `json:"holder_name"`
+		ExpiryDate string `json:"expiry_date"`
+	}{
+		Number:     utils.MaskCardNumber(c.Number),
+		HolderName: c.HolderName,
+		ExpiryDate: c.ExpiryDate,
+	})
+}
+
+// CardResponse represents the DTO for a tokenized card
+type CardResponse struct {
+	Token      string `json:"token"`
+	Brand      string `json:"brand"`
+	CardNumber string `json:"card_number"`
+	ExpiryDate string `json:"expiry_date"`
+}
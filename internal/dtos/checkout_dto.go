@@ -0,0 +1,110 @@
+package dtos
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CheckoutLineRequest represents the DTO for one item of a checkout order
+type CheckoutLineRequest struct {
+	Name      string `json:"name" validate:"required,max=200"`
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+	UnitPrice int    `json:"unit_price" validate:"required,min=1"`
+}
+
+// CheckoutSessionRequest represents the DTO for a merchant opening a checkout session.
+// The lines must add up to the amount.
+type CheckoutSessionRequest struct {
+	StoreID        *uint                 `json:"store_id"`
+	OrderReference string                `json:"order_reference" validate:"required,max=100"`
+	Amount         int                   `json:"amount" validate:"required,min=1"`
+	Currency       string                `json:"currency" validate:"required,len=3"`
+	Lines          []CheckoutLineRequest `json:"lines" validate:"required,min=1,dive"`
+	SuccessURL     string                `json:"success_url" validate:"required,url"`
+	CancelURL      string                `json:"cancel_url" validate:"required,url"`
+}
+
+// CheckoutLineResponse represents the DTO for one item of a checkout order
+type CheckoutLineResponse struct {
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int    `json:"unit_price"`
+	Total     int    `json:"total"`
+}
+
+// CheckoutSessionResponse represents the DTO for a checkout session as seen by its merchant.
+// URL is the page to send the customer to.
+type CheckoutSessionResponse struct {
+	ID             string                 `json:"id"`
+	MerchantID     uint                   `json:"merchant_id"`
+	StoreID        *uint                  `json:"store_id,omitempty"`
+	OrderReference string                 `json:"order_reference"`
+	Amount         int                    `json:"amount"`
+	Currency       string                 `json:"currency"`
+	Lines          []CheckoutLineResponse `json:"lines"`
+	Status         string                 `json:"status"`
+	URL            string                 `json:"url"`
+	SuccessURL     string                 `json:"success_url"`
+	CancelURL      string                 `json:"cancel_url"`
+	PaymentID      *uint                  `json:"payment_id,omitempty"`
+	ExpiresAt      time.Time              `json:"expires_at"`
+	CompletedAt    *time.Time             `json:"completed_at,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// CheckoutPageResponse represents the DTO for a checkout session as shown to the customer paying it
+type CheckoutPageResponse struct {
+	ID               string                 `json:"id"`
+	MerchantName     string                 `json:"merchant_name"`
+	MerchantCategory string                 `json:"merchant_category"`
+	OrderReference   string                 `json:"order_reference"`
+	Amount           int                    `json:"amount"`
+	Currency         string                 `json:"currency"`
+	Lines            []CheckoutLineResponse `json:"lines"`
+	Status           string                 `json:"status"`
+	SuccessURL       string                 `json:"success_url"`
+	CancelURL        string                 `json:"cancel_url"`
+	PaymentID        *uint                  `json:"payment_id,omitempty"`
+	ExpiresAt        time.Time              `json:"expires_at"`
+}
+
+// CheckoutCompleteRequest represents the DTO for a customer paying a checkout session.
+// Plans on offer for the session's amount and merchant are listed by the plan endpoints.
+type CheckoutCompleteRequest struct {
+	CreditApplicationID uint                 `json:"credit_application_id" validate:"required"`
+	PaymentMethod       PaymentMethodRequest `json:"payment_method" validate:"required"`
+	PlanID              *uint                `json:"plan_id"`
+	QuoteToken          string               `json:"quote_token"`
+}
+
+// CheckoutCompleteResponse represents the DTO returned once a checkout session's payment is created.
+// The customer pays it at RedirectURL.
+type CheckoutCompleteResponse struct {
+	Session       CheckoutPageResponse `json:"session"`
+	PaymentID     uint                 `json:"payment_id"`
+	PaymentStatus string               `json:"payment_status"`
+	RedirectURL   string               `json:"redirect_url"`
+}
+
+// CheckoutReturnResponse represents the DTO returned to a checkout customer back from the gateway
+// whose payment has no outcome yet
+type CheckoutReturnResponse struct {
+	SessionID     string `json:"session_id"`
+	Status        string `json:"status"`
+	PaymentID     uint   `json:"payment_id"`
+	PaymentStatus string `json:"payment_status"`
+}
+
+// MerchantEventResponse represents the DTO for an event recorded for a merchant
+type MerchantEventResponse struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// MerchantEventListResponse represents the DTO for a page of merchant events
+type MerchantEventListResponse struct {
+	Events []MerchantEventResponse `json:"events"`
+	Total  int                     `json:"total"`
+}
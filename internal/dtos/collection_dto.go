@@ -0,0 +1,20 @@
+package dtos
+
+import "time"
+
+// CollectionCaseResponse represents the DTO for a collection case
+type CollectionCaseResponse struct {
+	ID            uint      `json:"id"`
+	UserID        string    `json:"user_id"`
+	PaymentID     uint      `json:"payment_id"`
+	InstallmentID uint      `json:"installment_id"`
+	AmountDue     int       `json:"amount_due"`
+	Status        string    `json:"status"`
+	OpenedAt      time.Time `json:"opened_at"`
+}
+
+// CollectionCaseListResponse represents the DTO for a page of collection cases
+type CollectionCaseListResponse struct {
+	Cases []CollectionCaseResponse `json:"cases"`
+	Total int                      `json:"total"`
+}
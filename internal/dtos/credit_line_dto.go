@@ -0,0 +1,12 @@
+package dtos
+
+// CreditLineResponse represents the DTO for a user's credit line
+type CreditLineResponse struct {
+	UserID    string `json:"user_id"`
+	Currency  string `json:"currency"`
+	Limit     int    `json:"limit"`
+	Utilized  int    `json:"utilized"`
+	Reserved  int    `json:"reserved"`
+	Available int    `json:"available"`
+	Status    string `json:"status"`
+}
@@ -7,28 +7,73 @@ import (
 
 // CreditApplicationRequest represents the DTO for creating a credit application
 type CreditApplicationRequest struct {
-	UserID   string `json:"user_id" binding:"required"`
 	Amount   int    `json:"amount" binding:"required,min=1"`
 	Currency string `json:"currency" binding:"required,len=3"`
 }
 
 // CreditApplicationResponse represents the DTO for credit application response
 type CreditApplicationResponse struct {
-	ID        uint      `json:"id"`
-	UserID    string    `json:"user_id"`
-	Amount    int       `json:"amount"`
-	Currency  string    `json:"currency"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             uint                    `json:"id"`
+	UserID         string                  `json:"user_id"`
+	Amount         int                     `json:"amount"`
+	Currency       string                  `json:"currency"`
+	Status         string                  `json:"status"`
+	CreditScore    int                     `json:"credit_score,omitempty"`
+	ScoreBand      string                  `json:"score_band,omitempty"`
+	ScoreFactors   []domains.ScoreFactor   `json:"score_factors,omitempty"`
+	ApprovedAmount int                     `json:"approved_amount,omitempty"`
+	DeclineReasons []domains.DeclineReason `json:"decline_reasons,omitempty"`
+	CreatedAt      time.Time               `json:"created_at"`
 }
 
-// PaymentRequest represents the DTO for creating a payment
+// CreditDecisionResponse represents the DTO returned when a credit application is decided
+type CreditDecisionResponse struct {
+	Message     string                    `json:"message"`
+	Application CreditApplicationResponse `json:"application"`
+}
+
+// CancelCreditApplicationRequest represents the DTO for cancelling a credit application
+type CancelCreditApplicationRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// CreditApplicationTransitionResponse represents the DTO for one entry of a credit application's status history
+type CreditApplicationTransitionResponse struct {
+	FromStatus     string    `json:"from_status"`
+	ToStatus       string    `json:"to_status"`
+	Actor          string    `json:"actor"`
+	Reason         string    `json:"reason"`
+	TransitionedAt time.Time `json:"transitioned_at"`
+}
+
+// PaymentRequest represents the DTO for creating a payment in store. The
+// payment is attributed to the merchant of the store.
 type PaymentRequest struct {
 	CreditApplicationID uint                  `json:"credit_application_id" binding:"required"`
-	UserID              string                `json:"user_id" binding:"required"`
+	OrderID             string                `json:"order_id" validate:"omitempty,max=100"`
 	Amount              int                   `json:"amount" binding:"required,min=1"`
 	Currency            string                `json:"currency" binding:"required,len=3"`
-	PaymentMethod       domains.PaymentMethod `json:"payment_method" binding:"required"`
+	PaymentMethod       PaymentMethodRequest  `json:"payment_method" binding:"required"`
+	PlanID              *uint                 `json:"plan_id"`
+	StoreID             uint                  `json:"store_id" validate:"required"`
+	QuoteToken          string                `json:"quote_token"`
+}
+
+// PaymentMethodRequest represents the DTO for the payment method of a new payment.
+// Either a card token from POST /cards or the card itself must be given.
+type PaymentMethodRequest struct {
+	Type      string       `json:"type" validate:"required,oneof=card"`
+	CardToken string       `json:"card_token" validate:"required_without=Card"`
+	Card      *CardRequest `json:"card" validate:"required_without=CardToken"`
+}
+
+// PaymentMethodResponse represents the DTO for the masked payment method of a payment
+type PaymentMethodResponse struct {
+	Type       string `json:"type"`
+	CardToken  string `json:"card_token,omitempty"`
+	CardBrand  string `json:"card_brand,omitempty"`
+	CardNumber string `json:"card_number,omitempty"`
+	ExpiryDate string `json:"expiry_date,omitempty"`
 }
 
 // PaymentResponse represents the DTO for payment response
@@ -36,21 +81,58 @@ type PaymentResponse struct {
 	ID                  uint                    `json:"id"`
 	CreditApplicationID uint                    `json:"credit_application_id"`
 	UserID              string                  `json:"user_id"`
+	OrderID             string                  `json:"order_id"`
+	MerchantID          uint                    `json:"merchant_id"`
+	StoreID             *uint                   `json:"store_id,omitempty"`
+	MerchantCategory    string                  `json:"merchant_category,omitempty"`
 	Amount              int                     `json:"amount"`
+	FeeAmount           int                     `json:"fee_amount"`
+	TotalRepayable      int                     `json:"total_repayable"`
+	OutstandingCharges  int                     `json:"outstanding_charges"`
+	RefundedAmount      int                     `json:"refunded_amount"`
 	Currency            string                  `json:"currency"`
-	PaymentMethod       domains.PaymentMethod   `json:"payment_method"`
+	PaymentMethod       PaymentMethodResponse   `json:"payment_method"`
 	Status              string                  `json:"status"`
+	RedirectURL         string                  `json:"redirect_url,omitempty"`
+	PlanID              *uint                   `json:"plan_id,omitempty"`
+	Plan                PlanTermsResponse       `json:"plan"`
 	Installments        []InstallmentResponse   `json:"installments,omitempty"`
 	CreatedAt           time.Time               `json:"created_at"`
 }
 
 // InstallmentResponse represents the DTO for installment response
 type InstallmentResponse struct {
-	ID                uint      `json:"id"`
-	PaymentID         uint      `json:"payment_id"`
-	InstallmentNumber int       `json:"installment_number"`
-	DueDate           string    `json:"due_date"`
-	Amount            int       `json:"amount"`
-	Status            string    `json:"status"`
-	CreatedAt         time.Time `json:"created_at"`
+	ID                uint             `json:"id"`
+	PaymentID         uint             `json:"payment_id"`
+	InstallmentNumber int              `json:"installment_number"`
+	DueDate           string           `json:"due_date"`
+	Amount            int              `json:"amount"`
+	Principal         int              `json:"principal"`
+	Fee               int              `json:"fee"`
+	Status            string           `json:"status"`
+	RetryCount        int              `json:"retry_count,omitempty"`
+	NextRetryAt       *time.Time       `json:"next_retry_at,omitempty"`
+	RedirectURL       string           `json:"redirect_url,omitempty"`
+	Charges           []ChargeResponse `json:"charges,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+}
+
+// ChargeResponse represents the DTO for a late fee or other installment charge
+type ChargeResponse struct {
+	ID            uint       `json:"id"`
+	InstallmentID uint       `json:"installment_id"`
+	Type          string     `json:"type"`
+	AccrualDate   string     `json:"accrual_date"`
+	Amount        int        `json:"amount"`
+	Currency      string     `json:"currency"`
+	Status        string     `json:"status"`
+	WaivedBy      string     `json:"waived_by,omitempty"`
+	WaiverReason  string     `json:"waiver_reason,omitempty"`
+	WaivedAt      *time.Time `json:"waived_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// WaiveChargeRequest represents the DTO for waiving a charge
+type WaiveChargeRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
 }
\ No newline at end of file
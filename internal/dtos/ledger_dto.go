@@ -0,0 +1,45 @@
+package dtos
+
+import "time"
+
+// LedgerEntryResponse represents the DTO for one debit or credit of a journal
+type LedgerEntryResponse struct {
+	Account string `json:"account"`
+	Debit   int    `json:"debit"`
+	Credit  int    `json:"credit"`
+}
+
+// JournalResponse represents the DTO for a posted journal
+type JournalResponse struct {
+	ID          uint                  `json:"id"`
+	Type        string                `json:"type"`
+	Reference   string                `json:"reference"`
+	PaymentID   uint                  `json:"payment_id"`
+	UserID      string                `json:"user_id"`
+	Currency    string                `json:"currency"`
+	Description string                `json:"description"`
+	PostedAt    time.Time             `json:"posted_at"`
+	Entries     []LedgerEntryResponse `json:"entries"`
+}
+
+// JournalListResponse represents the DTO for a page of journals
+type JournalListResponse struct {
+	Journals []JournalResponse `json:"journals"`
+	Total    int               `json:"total"`
+}
+
+// AccountBalanceResponse represents the DTO for the balance of a ledger account
+type AccountBalanceResponse struct {
+	Account string `json:"account"`
+	Debit   int    `json:"debit"`
+	Credit  int    `json:"credit"`
+	Balance int    `json:"balance"`
+}
+
+// LedgerBalancesResponse represents the DTO for account balances as of a date
+type LedgerBalancesResponse struct {
+	AsOf      time.Time                `json:"as_of"`
+	UserID    string                   `json:"user_id,omitempty"`
+	PaymentID uint                     `json:"payment_id,omitempty"`
+	Balances  []AccountBalanceResponse `json:"balances"`
+}
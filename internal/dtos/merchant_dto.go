@@ -0,0 +1,103 @@
+package dtos
+
+import "time"
+
+// MerchantRequest represents the DTO for onboarding or updating a merchant
+type MerchantRequest struct {
+	Name      string `json:"name" validate:"required,max=100"`
+	LegalName string `json:"legal_name" validate:"required,max=200"`
+	TaxID     string `json:"tax_id" validate:"required,max=20"`
+	Email     string `json:"email" validate:"required,email,max=100"`
+	Phone     string `json:"phone" validate:"max=20"`
+	Category  string `json:"category" validate:"required,max=50"`
+	RIB       string `json:"rib" validate:"required,max=30"`
+	// DiscountRateBps is the merchant discount rate in basis points.
+	DiscountRateBps int `json:"discount_rate_bps" validate:"min=0,max=10000"`
+}
+
+// MerchantStatusRequest represents the DTO for activating, suspending or closing a merchant
+type MerchantStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=ACTIVE SUSPENDED CLOSED"`
+}
+
+// StoreRequest represents the DTO for adding or updating a store
+type StoreRequest struct {
+	Name    string `json:"name" validate:"required,max=100"`
+	Address string `json:"address" validate:"max=500"`
+	City    string `json:"city" validate:"max=100"`
+	// Active is only used on update; new stores are active.
+	Active *bool `json:"active"`
+}
+
+// APIKeyRequest represents the DTO for issuing a merchant API key
+type APIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// StoreResponse represents the DTO for a merchant store
+type StoreResponse struct {
+	ID         uint   `json:"id"`
+	MerchantID uint   `json:"merchant_id"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	City       string `json:"city"`
+	Active     bool   `json:"active"`
+}
+
+// MerchantResponse represents the DTO for a merchant
+type MerchantResponse struct {
+	ID              uint            `json:"id"`
+	Name            string          `json:"name"`
+	LegalName       string          `json:"legal_name"`
+	TaxID           string          `json:"tax_id"`
+	Email           string          `json:"email"`
+	Phone           string          `json:"phone,omitempty"`
+	Category        string          `json:"category"`
+	RIB             string          `json:"rib"`
+	DiscountRateBps int             `json:"discount_rate_bps"`
+	Status          string          `json:"status"`
+	ActivatedAt     *time.Time      `json:"activated_at,omitempty"`
+	Stores          []StoreResponse `json:"stores"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// MerchantListResponse represents the DTO for a page of merchants
+type MerchantListResponse struct {
+	Merchants []MerchantResponse `json:"merchants"`
+	Total     int                `json:"total"`
+}
+
+// APIKeyResponse represents the DTO for a merchant API key. Key is only set
+// when the key is created.
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyListResponse represents the DTO for the API keys of a merchant
+type APIKeyListResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
+
+// MerchantPaymentResponse represents the DTO for a payment as seen by the merchant it was made at
+type MerchantPaymentResponse struct {
+	ID             uint      `json:"id"`
+	OrderID        string    `json:"order_id"`
+	StoreID        *uint     `json:"store_id,omitempty"`
+	Amount         int       `json:"amount"`
+	RefundedAmount int       `json:"refunded_amount"`
+	Currency       string    `json:"currency"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// MerchantPaymentListResponse represents the DTO for a page of a merchant's payments
+type MerchantPaymentListResponse struct {
+	Payments []MerchantPaymentResponse `json:"payments"`
+	Total    int                       `json:"total"`
+}
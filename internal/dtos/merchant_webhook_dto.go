@@ -0,0 +1,60 @@
+package dtos
+
+import "time"
+
+// WebhookEndpointRequest represents the DTO for a merchant registering a webhook endpoint.
+// No event types subscribes the endpoint to every event.
+type WebhookEndpointRequest struct {
+	URL         string   `json:"url" validate:"required,url,max=500"`
+	Description string   `json:"description" validate:"max=200"`
+	EventTypes  []string `json:"event_types" validate:"dive,required"`
+}
+
+// WebhookEndpointResponse represents the DTO for a webhook endpoint. Secret is only set
+// when the endpoint is created.
+type WebhookEndpointResponse struct {
+	ID          uint       `json:"id"`
+	URL         string     `json:"url"`
+	Description string     `json:"description,omitempty"`
+	EventTypes  []string   `json:"event_types"`
+	Secret      string     `json:"secret,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty"`
+}
+
+// WebhookEndpointListResponse represents the DTO for the webhook endpoints of a merchant
+type WebhookEndpointListResponse struct {
+	Endpoints []WebhookEndpointResponse `json:"endpoints"`
+}
+
+// WebhookDeliveryAttemptResponse represents the DTO for one attempt at a webhook delivery
+type WebhookDeliveryAttemptResponse struct {
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
+
+// WebhookDeliveryResponse represents the DTO for an event's delivery to a webhook endpoint.
+// AttemptLog is only set when a single delivery is fetched.
+type WebhookDeliveryResponse struct {
+	ID                 uint                             `json:"id"`
+	EndpointID         uint                             `json:"endpoint_id"`
+	EventID            uint                             `json:"event_id"`
+	EventType          string                           `json:"event_type"`
+	Status             string                           `json:"status"`
+	Attempts           int                              `json:"attempts"`
+	NextAttemptAt      *time.Time                       `json:"next_attempt_at,omitempty"`
+	LastAttemptAt      *time.Time                       `json:"last_attempt_at,omitempty"`
+	LastResponseStatus int                              `json:"last_response_status,omitempty"`
+	LastError          string                           `json:"last_error,omitempty"`
+	DeliveredAt        *time.Time                       `json:"delivered_at,omitempty"`
+	CreatedAt          time.Time                        `json:"created_at"`
+	AttemptLog         []WebhookDeliveryAttemptResponse `json:"attempt_log,omitempty"`
+}
+
+// WebhookDeliveryListResponse represents the DTO for a page of webhook deliveries
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Total      int                       `json:"total"`
+}
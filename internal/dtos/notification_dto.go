@@ -0,0 +1,20 @@
+package dtos
+
+import "time"
+
+// NotificationResponse represents the DTO for a customer notification
+type NotificationResponse struct {
+	ID            uint       `json:"id"`
+	Type          string     `json:"type"`
+	Message       string     `json:"message"`
+	PaymentID     uint       `json:"payment_id,omitempty"`
+	InstallmentID uint       `json:"installment_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ReadAt        *time.Time `json:"read_at,omitempty"`
+}
+
+// NotificationListResponse represents the DTO for a page of notifications
+type NotificationListResponse struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	Total         int                    `json:"total"`
+}
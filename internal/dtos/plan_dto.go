@@ -0,0 +1,75 @@
+package dtos
+
+import "time"
+
+// PlanProductRequest represents the DTO for creating or replacing an installment plan
+type PlanProductRequest struct {
+	Code               string   `json:"code" validate:"required,max=50"`
+	Name               string   `json:"name" validate:"required,max=100"`
+	InstallmentCount   int      `json:"installment_count" validate:"required,min=1"`
+	Frequency          string   `json:"frequency" validate:"required,oneof=WEEKLY BIWEEKLY MONTHLY"`
+	DownPaymentPercent int      `json:"down_payment_percent" validate:"min=0,max=99"`
+	FeeModel           string   `json:"fee_model" validate:"required,oneof=NONE FLAT PERCENTAGE"`
+	FeeValue           int      `json:"fee_value" validate:"min=0"`
+	MinAmount          int      `json:"min_amount" validate:"min=0"`
+	MaxAmount          int      `json:"max_amount" validate:"min=0"`
+	MerchantCategories []string `json:"merchant_categories"`
+	Active             *bool    `json:"active"`
+}
+
+// PlanTermsResponse represents the DTO for the repayment terms of a plan
+type PlanTermsResponse struct {
+	InstallmentCount   int    `json:"installment_count"`
+	Frequency          string `json:"frequency"`
+	DownPaymentPercent int    `json:"down_payment_percent"`
+	FeeModel           string `json:"fee_model"`
+	FeeValue           int    `json:"fee_value"`
+}
+
+// PlanProductResponse represents the DTO for an installment plan of the catalog
+type PlanProductResponse struct {
+	ID                 uint              `json:"id"`
+	Code               string            `json:"code"`
+	Name               string            `json:"name"`
+	Terms              PlanTermsResponse `json:"terms"`
+	MinAmount          int               `json:"min_amount"`
+	MaxAmount          int               `json:"max_amount,omitempty"`
+	MerchantCategories []string          `json:"merchant_categories,omitempty"`
+	Active             bool              `json:"active"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+}
+
+// PlanQuoteRequest represents the DTO for previewing the schedule of a purchase
+type PlanQuoteRequest struct {
+	Amount           int    `json:"amount" validate:"required,min=1"`
+	Currency         string `json:"currency" validate:"required,len=3"`
+	PlanID           *uint  `json:"plan_id"`
+	MerchantCategory string `json:"merchant_category" validate:"max=50"`
+}
+
+// QuotedInstallmentResponse represents the DTO for one installment of a quoted schedule
+type QuotedInstallmentResponse struct {
+	InstallmentNumber int    `json:"installment_number"`
+	DueDate           string `json:"due_date"`
+	Amount            int    `json:"amount"`
+	Principal         int    `json:"principal"`
+	Fee               int    `json:"fee"`
+}
+
+// PlanQuoteResponse represents the DTO for a signed schedule preview. The
+// quote token can be passed as quote_token when paying to get these terms.
+type PlanQuoteResponse struct {
+	QuoteID        string                      `json:"quote_id"`
+	QuoteToken     string                      `json:"quote_token"`
+	ExpiresAt      time.Time                   `json:"expires_at"`
+	PlanID         *uint                       `json:"plan_id,omitempty"`
+	PlanCode       string                      `json:"plan_code,omitempty"`
+	Amount         int                         `json:"amount"`
+	Currency       string                      `json:"currency"`
+	Terms          PlanTermsResponse           `json:"terms"`
+	DownPayment    int                         `json:"down_payment"`
+	FeeAmount      int                         `json:"fee_amount"`
+	TotalRepayable int                         `json:"total_repayable"`
+	Installments   []QuotedInstallmentResponse `json:"installments"`
+}
@@ -0,0 +1,46 @@
+package dtos
+
+import "time"
+
+// PrepaymentRequest represents the DTO for a partial prepayment
+type PrepaymentRequest struct {
+	Amount     int    `json:"amount" validate:"required,min=1"`
+	Allocation string `json:"allocation" validate:"required,oneof=NEXT PRO_RATA"`
+}
+
+// PrepaymentLineResponse represents the DTO for the part of a prepayment applied to one installment
+type PrepaymentLineResponse struct {
+	InstallmentID uint `json:"installment_id"`
+	Principal     int  `json:"principal"`
+	Fee           int  `json:"fee"`
+	Rebate        int  `json:"rebate,omitempty"`
+}
+
+// PayoffQuoteResponse represents the DTO for what settling a payment today costs
+type PayoffQuoteResponse struct {
+	PaymentID    uint                     `json:"payment_id"`
+	Currency     string                   `json:"currency"`
+	Remaining    int                      `json:"remaining"`
+	Rebate       int                      `json:"rebate"`
+	LateFees     int                      `json:"late_fees"`
+	PayoffAmount int                      `json:"payoff_amount"`
+	Lines        []PrepaymentLineResponse `json:"lines"`
+	QuotedAt     time.Time                `json:"quoted_at"`
+}
+
+// PrepaymentResponse represents the DTO for an early payoff or partial prepayment
+type PrepaymentResponse struct {
+	ID          uint                     `json:"id"`
+	PaymentID   uint                     `json:"payment_id"`
+	Type        string                   `json:"type"`
+	Allocation  string                   `json:"allocation,omitempty"`
+	Amount      int                      `json:"amount"`
+	Rebate      int                      `json:"rebate"`
+	LateFees    int                      `json:"late_fees,omitempty"`
+	Currency    string                   `json:"currency"`
+	Status      string                   `json:"status"`
+	RedirectURL string                   `json:"redirect_url,omitempty"`
+	Unapplied   int                      `json:"unapplied,omitempty"`
+	Lines       []PrepaymentLineResponse `json:"lines"`
+	CreatedAt   time.Time                `json:"created_at"`
+}
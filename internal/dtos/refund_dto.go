@@ -0,0 +1,45 @@
+package dtos
+
+import "time"
+
+// RefundRequest represents the DTO for refunding part or all of a purchase
+type RefundRequest struct {
+	Amount int    `json:"amount" validate:"required,min=1"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// RefundLineResponse represents the DTO for one installment reduction or card refund of a refund
+type RefundLineResponse struct {
+	Kind           string `json:"kind"`
+	InstallmentID  uint   `json:"installment_id,omitempty"`
+	GatewayOrderID string `json:"gateway_order_id,omitempty"`
+	Principal      int    `json:"principal,omitempty"`
+	Fee            int    `json:"fee,omitempty"`
+	Amount         int    `json:"amount"`
+	Status         string `json:"status"`
+	Error          string `json:"error,omitempty"`
+}
+
+// RefundResponse represents the DTO for a refund
+type RefundResponse struct {
+	ID                uint                 `json:"id"`
+	PaymentID         uint                 `json:"payment_id"`
+	Amount            int                  `json:"amount"`
+	FeeReduction      int                  `json:"fee_reduction"`
+	ScheduleReduction int                  `json:"schedule_reduction"`
+	CardRefund        int                  `json:"card_refund"`
+	Currency          string               `json:"currency"`
+	Reason            string               `json:"reason"`
+	RequestedBy       string               `json:"requested_by,omitempty"`
+	Status            string               `json:"status"`
+	Lines             []RefundLineResponse `json:"lines"`
+	CreatedAt         time.Time            `json:"created_at"`
+	CompletedAt       *time.Time           `json:"completed_at,omitempty"`
+}
+
+// RefundListResponse represents the DTO for the refunds of a payment, or a
+// page of refunds for admins with the total count
+type RefundListResponse struct {
+	Refunds []RefundResponse `json:"refunds"`
+	Total   int              `json:"total,omitempty"`
+}
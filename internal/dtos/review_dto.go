@@ -0,0 +1,36 @@
+package dtos
+
+import "time"
+
+// ApproveReviewRequest represents the DTO for approving a manual review
+type ApproveReviewRequest struct {
+	Reason         string `json:"reason" validate:"required"`
+	ApprovedAmount int    `json:"approved_amount" validate:"min=0"`
+}
+
+// DeclineReviewRequest represents the DTO for declining a manual review
+type DeclineReviewRequest struct {
+	Reason      string   `json:"reason" validate:"required"`
+	ReasonCodes []string `json:"reason_codes"`
+}
+
+// ManualReviewResponse represents the DTO for manual review response
+type ManualReviewResponse struct {
+	ID                  uint       `json:"id"`
+	CreditApplicationID uint       `json:"credit_application_id"`
+	Status              string     `json:"status"`
+	CreditScore         int        `json:"credit_score"`
+	ReviewerID          string     `json:"reviewer_id,omitempty"`
+	ClaimedAt           *time.Time `json:"claimed_at,omitempty"`
+	Decision            string     `json:"decision,omitempty"`
+	DecisionReason      string     `json:"decision_reason,omitempty"`
+	ApprovedAmount      int        `json:"approved_amount,omitempty"`
+	DecidedAt           *time.Time `json:"decided_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// ManualReviewListResponse represents the DTO for a page of manual reviews
+type ManualReviewListResponse struct {
+	Reviews []ManualReviewResponse `json:"reviews"`
+	Total   int                    `json:"total"`
+}
@@ -0,0 +1,68 @@
+package dtos
+
+import "time"
+
+// GenerateSettlementRequest represents the DTO for making the payout batches of a day
+type GenerateSettlementRequest struct {
+	Date string `json:"date" validate:"required,datetime=2006-01-02"`
+}
+
+// MarkSettlementPaidRequest represents the DTO for the bank confirming a payout
+type MarkSettlementPaidRequest struct {
+	BankReference string `json:"bank_reference" validate:"required,max=100"`
+}
+
+// MarkSettlementFailedRequest represents the DTO for the bank rejecting a payout
+type MarkSettlementFailedRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// SettlementItemResponse represents the DTO for a purchase or refund settled in a batch.
+// Refunds have negative amounts.
+type SettlementItemResponse struct {
+	Type            string    `json:"type"`
+	PaymentID       uint      `json:"payment_id"`
+	GrossAmount     int       `json:"gross_amount"`
+	DiscountRateBps int       `json:"discount_rate_bps"`
+	FeeAmount       int       `json:"fee_amount"`
+	NetAmount       int       `json:"net_amount"`
+	PostedAt        time.Time `json:"posted_at"`
+}
+
+// SettlementBatchResponse represents the DTO for a merchant payout batch
+type SettlementBatchResponse struct {
+	ID              uint                     `json:"id"`
+	MerchantID      uint                     `json:"merchant_id"`
+	SettlementDate  string                   `json:"settlement_date"`
+	Currency        string                   `json:"currency"`
+	GrossAmount     int                      `json:"gross_amount"`
+	FeeAmount       int                      `json:"fee_amount"`
+	NetAmount       int                      `json:"net_amount"`
+	ItemCount       int                      `json:"item_count"`
+	RIB             string                   `json:"rib"`
+	BeneficiaryName string                   `json:"beneficiary_name"`
+	Status          string                   `json:"status"`
+	BankFileID      *uint                    `json:"bank_file_id,omitempty"`
+	BankReference   string                   `json:"bank_reference,omitempty"`
+	FailureReason   string                   `json:"failure_reason,omitempty"`
+	ExportedAt      *time.Time               `json:"exported_at,omitempty"`
+	PaidAt          *time.Time               `json:"paid_at,omitempty"`
+	CreatedAt       time.Time                `json:"created_at"`
+	Items           []SettlementItemResponse `json:"items,omitempty"`
+}
+
+// SettlementBatchListResponse represents the DTO for a page of payout batches
+type SettlementBatchListResponse struct {
+	Batches []SettlementBatchResponse `json:"batches"`
+	Total   int                       `json:"total"`
+}
+
+// BankFileResponse represents the DTO for a bank transfer file, without its content
+type BankFileResponse struct {
+	ID          uint      `json:"id"`
+	FileName    string    `json:"file_name"`
+	Format      string    `json:"format"`
+	BatchCount  int       `json:"batch_count"`
+	TotalAmount int       `json:"total_amount"`
+	CreatedAt   time.Time `json:"created_at"`
+}
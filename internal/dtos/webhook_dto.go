@@ -0,0 +1,30 @@
+package dtos
+
+import "time"
+
+// WebhookStatusRequest represents the body of a payment, installment or refund
+// webhook. ResourceID repeats the ID in the path, so that it is signed
+type WebhookStatusRequest struct {
+	EventID    string `json:"event_id" validate:"required"`
+	ResourceID uint   `json:"resource_id" validate:"required"`
+	Status     string `json:"status" validate:"required"`
+}
+
+// InboundWebhookLogResponse represents the DTO for one logged webhook delivery
+type InboundWebhookLogResponse struct {
+	ID          uint       `json:"id"`
+	Source      string     `json:"source"`
+	ResourceID  uint       `json:"resource_id"`
+	EventID     string     `json:"event_id,omitempty"`
+	Payload     string     `json:"payload"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	ReceivedAt  time.Time  `json:"received_at"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+// InboundWebhookLogListResponse represents the DTO for a page of logged webhook deliveries
+type InboundWebhookLogListResponse struct {
+	Webhooks []InboundWebhookLogResponse `json:"webhooks"`
+	Total    int                         `json:"total"`
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	"github.com/mohamed2394/sahla/internal/utils"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// CardHandler handles HTTP requests for tokenizing cards
+type CardHandler struct {
+	service   services.CardVaultServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewCardHandler creates a new instance of CardHandler
+func NewCardHandler(service services.CardVaultServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *CardHandler {
+	return &CardHandler{
+		service:   service,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// TokenizeCard exchanges a card for a token the authenticated user can pay with
+func (h *CardHandler) TokenizeCard(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+
+	var req dto.CardRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	card, err := h.service.Tokenize(ctx, userID, services.CardInput{
+		Number:     req.Number,
+		HolderName: req.HolderName,
+		ExpiryDate: req.ExpiryDate,
+		CVV:        req.CVV,
+	})
+	if err != nil {
+		return h.handleError(c, err, "failed to tokenize card")
+	}
+
+	return c.JSON(http.StatusCreated, h.createCardResponse(card))
+}
+
+// GetCard returns the masked details of one of the authenticated user's cards
+func (h *CardHandler) GetCard(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+
+	card, err := h.service.GetCard(ctx, userID, c.Param("token"))
+	if err != nil {
+		return h.handleError(c, err, "failed to get card")
+	}
+
+	return c.JSON(http.StatusOK, h.createCardResponse(card))
+}
+
+func (h *CardHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func (h *CardHandler) createCardResponse(card *domains.CardVaultEntry) dto.CardResponse {
+	return dto.CardResponse{
+		Token:      card.Token,
+		Brand:      card.Brand,
+		CardNumber: utils.MaskCardNumber(card.Last4),
+		ExpiryDate: card.ExpiryDate,
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// ChargeHandler handles HTTP requests of admins on late fees and other charges
+type ChargeHandler struct {
+	service   services.PenaltyServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewChargeHandler creates a new instance of ChargeHandler
+func NewChargeHandler(service services.PenaltyServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *ChargeHandler {
+	return &ChargeHandler{
+		service:   service,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// WaiveCharge waives an accrued charge on behalf of the authenticated admin
+func (h *ChargeHandler) WaiveCharge(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid charge ID"})
+	}
+
+	adminID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin not authenticated"})
+	}
+
+	var req dto.WaiveChargeRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	charge, err := h.service.WaiveCharge(ctx, id, adminID, req.Reason)
+	if err != nil {
+		return h.handleError(c, err, "failed to waive charge")
+	}
+
+	return c.JSON(http.StatusOK, chargeResponse(charge))
+}
+
+func (h *ChargeHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// CheckoutHandler handles HTTP requests for hosted checkout: merchants
+// opening sessions with their API keys, and customers paying them
+type CheckoutHandler struct {
+	service   services.CheckoutServiceInterface
+	events    services.MerchantEventServiceInterface
+	cards     services.CardVaultServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewCheckoutHandler creates a new instance of CheckoutHandler
+func NewCheckoutHandler(service services.CheckoutServiceInterface, events services.MerchantEventServiceInterface, cards services.CardVaultServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *CheckoutHandler {
+	return &CheckoutHandler{
+		service:   service,
+		events:    events,
+		cards:     cards,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// CreateSession opens a checkout session for an order of the calling merchant
+func (h *CheckoutHandler) CreateSession(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+
+	var req dto.CheckoutSessionRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if !isValidCurrency(req.Currency) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported currency"})
+	}
+
+	session := &domains.CheckoutSession{
+		MerchantID:     merchant.ID,
+		StoreID:        req.StoreID,
+		OrderReference: req.OrderReference,
+		Amount:         req.Amount,
+		Currency:       req.Currency,
+		Lines:          make([]domains.CheckoutLine, len(req.Lines)),
+		SuccessURL:     req.SuccessURL,
+		CancelURL:      req.CancelURL,
+	}
+	for i, line := range req.Lines {
+		session.Lines[i] = domains.CheckoutLine{
+			Name:      line.Name,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+		}
+	}
+
+	if err := h.service.CreateSession(ctx, session); err != nil {
+		return h.handleError(c, err, "failed to create checkout session")
+	}
+
+	return c.JSON(http.StatusCreated, h.sessionResponse(session))
+}
+
+// GetSession returns a checkout session of the calling merchant
+func (h *CheckoutHandler) GetSession(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+
+	session, err := h.service.GetMerchantSession(ctx, merchant.ID, c.Param("id"))
+	if err != nil {
+		return h.handleError(c, err, "failed to get checkout session")
+	}
+
+	return c.JSON(http.StatusOK, h.sessionResponse(session))
+}
+
+// CancelSession closes an open checkout session of the calling merchant
+func (h *CheckoutHandler) CancelSession(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+
+	session, err := h.service.CancelSession(ctx, merchant.ID, c.Param("id"))
+	if err != nil {
+		return h.handleError(c, err, "failed to cancel checkout session")
+	}
+
+	return c.JSON(http.StatusOK, h.sessionResponse(session))
+}
+
+// ListEvents lists the events recorded for the calling merchant, newest first
+func (h *CheckoutHandler) ListEvents(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	events, total, err := h.events.ListEvents(ctx, merchant.ID, offset, limit)
+	if err != nil {
+		return h.handleError(c, err, "failed to list merchant events")
+	}
+
+	resp := dto.MerchantEventListResponse{
+		Events: make([]dto.MerchantEventResponse, len(events)),
+		Total:  total,
+	}
+	for i, event := range events {
+		resp.Events[i] = dto.MerchantEventResponse{
+			ID:        event.ID,
+			Type:      string(event.Type),
+			Payload:   json.RawMessage(event.Payload),
+			CreatedAt: event.CreatedAt,
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetCheckout shows a checkout session to the customer paying it
+func (h *CheckoutHandler) GetCheckout(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	session, err := h.service.GetSession(ctx, c.Param("id"))
+	if err != nil {
+		return h.handleError(c, err, "failed to get checkout session")
+	}
+
+	resp, err := h.pageResponse(ctx, session)
+	if err != nil {
+		return h.handleError(c, err, "failed to get checkout merchant")
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// CompleteCheckout pays a checkout session on the authenticated customer's
+// credit, with the plan they picked
+func (h *CheckoutHandler) CompleteCheckout(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+
+	var req dto.CheckoutCompleteRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	card, err := resolveCard(ctx, h.cards, userID, req.PaymentMethod)
+	if err != nil {
+		return h.handleError(c, err, "invalid payment method")
+	}
+
+	payment := &domains.Payment{
+		CreditApplicationID: req.CreditApplicationID,
+		UserID:              userID,
+		PaymentMethod: domains.PaymentMethod{
+			Type: req.PaymentMethod.Type,
+			Details: domains.PaymentDetails{
+				CardToken:  card.Token,
+				CardBrand:  card.Brand,
+				CardLast4:  card.Last4,
+				ExpiryDate: card.ExpiryDate,
+			},
+		},
+		PlanProductID: req.PlanID,
+		QuoteToken:    req.QuoteToken,
+	}
+
+	session, err := h.service.CompleteSession(ctx, c.Param("id"), payment)
+	if err != nil {
+		return h.handleError(c, err, "failed to complete checkout session")
+	}
+
+	page, err := h.pageResponse(ctx, session)
+	if err != nil {
+		return h.handleError(c, err, "failed to get checkout merchant")
+	}
+	return c.JSON(http.StatusCreated, dto.CheckoutCompleteResponse{
+		Session:       page,
+		PaymentID:     payment.ID,
+		PaymentStatus: payment.Status,
+		RedirectURL:   payment.RedirectURL,
+	})
+}
+
+// CheckoutReturn handles a checkout customer being sent back by the payment
+// gateway. Once the payment has an outcome the customer is sent on to the
+// merchant's success or cancel URL.
+func (h *CheckoutHandler) CheckoutReturn(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	orderID := c.QueryParam("orderId")
+	if orderID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "orderId is required"})
+	}
+
+	session, payment, err := h.service.ConfirmReturn(ctx, orderID)
+	if err != nil {
+		return h.handleError(c, err, "failed to confirm checkout payment")
+	}
+
+	h.logger.Info("Checkout return handled", zap.String("sessionID", session.ID), zap.Uint("paymentID", payment.ID), zap.String("status", payment.Status))
+	switch payment.Status {
+	case "SUCCESSFUL":
+		return c.Redirect(http.StatusSeeOther, session.SuccessURL)
+	case "FAILED":
+		return c.Redirect(http.StatusSeeOther, session.CancelURL)
+	default:
+		return c.JSON(http.StatusOK, dto.CheckoutReturnResponse{
+			SessionID:     session.ID,
+			Status:        string(session.Status),
+			PaymentID:     payment.ID,
+			PaymentStatus: payment.Status,
+		})
+	}
+}
+
+func (h *CheckoutHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func (h *CheckoutHandler) sessionResponse(session *domains.CheckoutSession) dto.CheckoutSessionResponse {
+	return dto.CheckoutSessionResponse{
+		ID:             session.ID,
+		MerchantID:     session.MerchantID,
+		StoreID:        session.StoreID,
+		OrderReference: session.OrderReference,
+		Amount:         session.Amount,
+		Currency:       session.Currency,
+		Lines:          checkoutLineResponses(session.Lines),
+		Status:         string(session.Status),
+		URL:            h.service.SessionURL(session.ID),
+		SuccessURL:     session.SuccessURL,
+		CancelURL:      session.CancelURL,
+		PaymentID:      session.PaymentID,
+		ExpiresAt:      session.ExpiresAt,
+		CompletedAt:    session.CompletedAt,
+		CreatedAt:      session.CreatedAt,
+	}
+}
+
+func (h *CheckoutHandler) pageResponse(ctx context.Context, session *domains.CheckoutSession) (dto.CheckoutPageResponse, error) {
+	merchant, err := h.service.GetMerchant(ctx, session.MerchantID)
+	if err != nil {
+		return dto.CheckoutPageResponse{}, err
+	}
+	return dto.CheckoutPageResponse{
+		ID:               session.ID,
+		MerchantName:     merchant.Name,
+		MerchantCategory: merchant.Category,
+		OrderReference:   session.OrderReference,
+		Amount:           session.Amount,
+		Currency:         session.Currency,
+		Lines:            checkoutLineResponses(session.Lines),
+		Status:           string(session.Status),
+		SuccessURL:       session.SuccessURL,
+		CancelURL:        session.CancelURL,
+		PaymentID:        session.PaymentID,
+		ExpiresAt:        session.ExpiresAt,
+	}, nil
+}
+
+func checkoutLineResponses(lines []domains.CheckoutLine) []dto.CheckoutLineResponse {
+	resp := make([]dto.CheckoutLineResponse, len(lines))
+	for i, line := range lines {
+		resp[i] = dto.CheckoutLineResponse{
+			Name:      line.Name,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			Total:     line.Total(),
+		}
+	}
+	return resp
+}
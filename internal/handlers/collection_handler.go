@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	"go.uber.org/zap"
+)
+
+// CollectionHandler handles HTTP requests of the collections team
+type CollectionHandler struct {
+	service services.DunningServiceInterface
+	logger  *zap.Logger
+}
+
+// NewCollectionHandler creates a new instance of CollectionHandler
+func NewCollectionHandler(service services.DunningServiceInterface, logger *zap.Logger) *CollectionHandler {
+	return &CollectionHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// ListCollectionCases lists collection cases, optionally filtered by status
+func (h *CollectionHandler) ListCollectionCases(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	status := domains.CollectionCaseStatus(c.QueryParam("status"))
+
+	cases, total, err := h.service.ListCollectionCases(ctx, status, offset, limit)
+	if err != nil {
+		h.logger.Error("Failed to list collection cases", zap.Error(err))
+		return writeError(c, err)
+	}
+
+	resp := dto.CollectionCaseListResponse{
+		Cases: make([]dto.CollectionCaseResponse, len(cases)),
+		Total: total,
+	}
+	for i, collectionCase := range cases {
+		resp.Cases[i] = dto.CollectionCaseResponse{
+			ID:            collectionCase.ID,
+			UserID:        collectionCase.UserID,
+			PaymentID:     collectionCase.PaymentID,
+			InstallmentID: collectionCase.InstallmentID,
+			AmountDue:     collectionCase.AmountDue,
+			Status:        string(collectionCase.Status),
+			OpenedAt:      collectionCase.OpenedAt,
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
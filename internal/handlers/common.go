@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	services "github.com/mohamed2394/sahla/internal/services"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+)
+
+// errUnauthenticated is returned by handler helpers that need the JWT user
+// when the request carries none
+var errUnauthenticated = errors.New("user not authenticated")
+
+// writeError maps service and repository errors to HTTP responses
+func writeError(c echo.Context, err error) error {
+	var (
+		notFoundErr   *utils.ErrNotFound
+		duplicateErr  *utils.ErrDuplicateEntry
+		databaseErr   *utils.ErrDatabase
+		transitionErr *utils.ErrInvalidTransition
+	)
+
+	switch {
+	case errors.Is(err, errUnauthenticated):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.As(err, &notFoundErr):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &duplicateErr):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.As(err, &transitionErr):
+		return c.JSON(http.StatusConflict, map[string]string{"error": transitionErr.Error()})
+	case errors.As(err, &databaseErr):
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "An unexpected error occurred"})
+	case errors.Is(err, utils.ErrInvalidWebhookSignature), errors.Is(err, utils.ErrStaleWebhook):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidWebhookPayload):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCard), errors.Is(err, services.ErrCardExpired):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInsufficientCredit):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Insufficient credit"})
+	case errors.Is(err, services.ErrCreditLineBusy), errors.Is(err, services.ErrInstallmentBeingCollected),
+		errors.Is(err, services.ErrChargeBilled):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrCreditLineFrozen):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Credit line is frozen"})
+	case errors.Is(err, services.ErrInvalidAmount):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid amount"})
+	case errors.Is(err, services.ErrInvalidApprovedAmount), errors.Is(err, services.ErrInvalidDeclineReason):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrManualReviewPending), errors.Is(err, services.ErrReviewNotClaimed):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPlan), errors.Is(err, services.ErrPlanNotEligible),
+		errors.Is(err, services.ErrInvalidQuote), errors.Is(err, services.ErrQuoteExpired):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPrepayment), errors.Is(err, services.ErrInvalidRefund):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrNothingToPrepay):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMerchant):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrMerchantNotActive):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAPIKey):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCheckout):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrCheckoutClosed):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidWebhookEndpoint):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSettlement):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrNothingToSettle):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrWaiverReasonRequired):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrGatewayTimeout):
+		return c.JSON(http.StatusGatewayTimeout, map[string]string{"error": "Payment gateway timed out, try again later"})
+	case errors.Is(err, services.ErrPaymentFailed):
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Payment processing failed"})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "An unexpected error occurred"})
+	}
+}
+
+// userIDFromContext returns the authenticated user ID set by the JWT middleware
+func userIDFromContext(c echo.Context) (string, bool) {
+	claims, ok := c.Get("user").(jwt.MapClaims)
+	if !ok {
+		return "", false
+	}
+	userID, ok := claims["user_id"].(string)
+	return userID, ok && userID != ""
+}
+
+// merchantFromContext returns the merchant authenticated by API key
+func merchantFromContext(c echo.Context) (*domains.Merchant, bool) {
+	merchant, ok := c.Get("merchant").(*domains.Merchant)
+	return merchant, ok && merchant != nil
+}
+
+// actorFromContext returns the authenticated user ID, falling back to a
+// generic API actor for unauthenticated routes
+func actorFromContext(c echo.Context) string {
+	if userID, ok := userIDFromContext(c); ok {
+		return userID
+	}
+	return "api"
+}
+
+func parseUintParam(param string) (uint, error) {
+	id, err := strconv.ParseUint(param, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
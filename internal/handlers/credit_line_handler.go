@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	"go.uber.org/zap"
+)
+
+// CreditLineHandler handles HTTP requests related to users' credit lines
+type CreditLineHandler struct {
+	service services.CreditLineServiceInterface
+	logger  *zap.Logger
+}
+
+// NewCreditLineHandler creates a new instance of CreditLineHandler
+func NewCreditLineHandler(service services.CreditLineServiceInterface, logger *zap.Logger) *CreditLineHandler {
+	return &CreditLineHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetCreditLine returns the limit, utilization and spendable balance of a user
+func (h *CreditLineHandler) GetCreditLine(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user ID"})
+	}
+	// Users may only see their own credit line
+	if authUserID, ok := userIDFromContext(c); !ok || authUserID != userID.String() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "credit line belongs to another user"})
+	}
+
+	line, err := h.service.GetCreditLine(ctx, userID.String())
+	if err != nil {
+		h.logger.Error("Failed to get credit line", zap.Error(err))
+		return writeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.CreditLineResponse{
+		UserID:    line.UserID,
+		Currency:  line.Currency,
+		Limit:     line.CreditLimit,
+		Utilized:  line.Utilized,
+		Reserved:  line.Reserved,
+		Available: line.Available(),
+		Status:    string(line.Status),
+	})
+}
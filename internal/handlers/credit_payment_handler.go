@@ -4,14 +4,13 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	dto "github.com/mohamed2394/sahla/internal/dtos"
 	domains "github.com/mohamed2394/sahla/internal/domains"
 	services"github.com/mohamed2394/sahla/internal/services"
-	utils "github.com/mohamed2394/sahla/internal/utils"
+	"github.com/mohamed2394/sahla/internal/utils"
 	validation"github.com/mohamed2394/sahla/internal/validation"
 	"go.uber.org/zap"
 )
@@ -19,14 +18,16 @@ import (
 // CreditPaymentHandler handles HTTP requests related to credit payments
 type CreditPaymentHandler struct {
 	service   services.CreditPaymentServiceInterface
+	cards     services.CardVaultServiceInterface
 	logger    *zap.Logger
 	validator *validation.CustomValidator
 }
 
 // NewCreditPaymentHandler creates a new instance of CreditPaymentHandler
-func NewCreditPaymentHandler(service services.CreditPaymentServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *CreditPaymentHandler {
+func NewCreditPaymentHandler(service services.CreditPaymentServiceInterface, cards services.CardVaultServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *CreditPaymentHandler {
 	return &CreditPaymentHandler{
 		service:   service,
+		cards:     cards,
 		logger:    logger,
 		validator: validator,
 	}
@@ -37,6 +38,11 @@ func (h *CreditPaymentHandler) CreateCreditApplication(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+
 	var req dto.CreditApplicationRequest
 	if err := c.Bind(&req); err != nil {
 		h.logger.Error("Failed to bind request body", zap.Error(err))
@@ -57,7 +63,7 @@ func (h *CreditPaymentHandler) CreateCreditApplication(c echo.Context) error {
 	}
 
 	app := &domains.CreditApplication{
-		UserID:   req.UserID,
+		UserID:   userID,
 		Amount:   req.Amount,
 		Currency: req.Currency,
 	}
@@ -86,21 +92,135 @@ func (h *CreditPaymentHandler) ApproveCreditApplication(c echo.Context) error {
 		return h.handleError(c, err, "invalid credit application ID")
 	}
 
+	if _, err := h.ownCreditApplication(ctx, c, id); err != nil {
+		return h.handleError(c, err, "failed to get credit application")
+	}
+
 	h.logger.Info("Approving credit application", zap.Uint("applicationID", id))
 
-	if err := h.service.ApproveCreditApplication(ctx, id); err != nil {
+	app, err := h.service.ApproveCreditApplication(ctx, id)
+	if err != nil {
 		h.logger.Error("Failed to approve credit application", zap.Error(err))
 		return h.handleError(c, err, "failed to approve credit application")
 	}
 
-	h.logger.Info("Credit application approved successfully", zap.Uint("applicationID", id))
-	return c.JSON(http.StatusOK, map[string]string{"message": "Credit application approved successfully"})
+	var message string
+	switch app.Status {
+	case domains.CreditApplicationApproved:
+		message = "Credit application approved"
+	case domains.CreditApplicationRejected:
+		message = "Credit application rejected"
+	default:
+		message = "Credit application referred for manual review"
+	}
+
+	h.logger.Info("Credit application decided", zap.Uint("applicationID", id), zap.String("status", string(app.Status)))
+	return c.JSON(http.StatusOK, dto.CreditDecisionResponse{
+		Message:     message,
+		Application: h.createCreditApplicationResponse(app),
+	})
+}
+
+// GetCreditApplication returns a credit application with its decision and decline reasons
+func (h *CreditPaymentHandler) GetCreditApplication(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := h.parseID(c.Param("id"))
+	if err != nil {
+		h.logger.Error("Invalid credit application ID", zap.Error(err))
+		return h.handleError(c, err, "invalid credit application ID")
+	}
+
+	app, err := h.ownCreditApplication(ctx, c, id)
+	if err != nil {
+		h.logger.Error("Failed to get credit application", zap.Error(err))
+		return h.handleError(c, err, "failed to get credit application")
+	}
+
+	return c.JSON(http.StatusOK, h.createCreditApplicationResponse(app))
+}
+// CancelCreditApplication handles the cancellation of a credit application
+func (h *CreditPaymentHandler) CancelCreditApplication(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := h.parseID(c.Param("id"))
+	if err != nil {
+		h.logger.Error("Invalid credit application ID", zap.Error(err))
+		return h.handleError(c, err, "invalid credit application ID")
+	}
+
+	var req dto.CancelCreditApplicationRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Error("Failed to bind request body", zap.Error(err))
+		return h.handleError(c, err, "invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		h.logger.Error("Validation failed", zap.Error(err))
+		return h.handleError(c, err, "validation failed")
+	}
+
+	if _, err := h.ownCreditApplication(ctx, c, id); err != nil {
+		return h.handleError(c, err, "failed to get credit application")
+	}
+
+	if err := h.service.CancelCreditApplication(ctx, id, actorFromContext(c), req.Reason); err != nil {
+		h.logger.Error("Failed to cancel credit application", zap.Error(err))
+		return h.handleError(c, err, "failed to cancel credit application")
+	}
+
+	h.logger.Info("Credit application cancelled successfully", zap.Uint("applicationID", id))
+	return c.JSON(http.StatusOK, map[string]string{"message": "Credit application cancelled successfully"})
+}
+
+// GetCreditApplicationHistory returns the status history of a credit application
+func (h *CreditPaymentHandler) GetCreditApplicationHistory(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := h.parseID(c.Param("id"))
+	if err != nil {
+		h.logger.Error("Invalid credit application ID", zap.Error(err))
+		return h.handleError(c, err, "invalid credit application ID")
+	}
+
+	if _, err := h.ownCreditApplication(ctx, c, id); err != nil {
+		return h.handleError(c, err, "failed to get credit application")
+	}
+
+	history, err := h.service.GetCreditApplicationHistory(ctx, id)
+	if err != nil {
+		h.logger.Error("Failed to get credit application history", zap.Error(err))
+		return h.handleError(c, err, "failed to get credit application history")
+	}
+
+	resp := make([]dto.CreditApplicationTransitionResponse, len(history))
+	for i, transition := range history {
+		resp[i] = dto.CreditApplicationTransitionResponse{
+			FromStatus:     string(transition.FromStatus),
+			ToStatus:       string(transition.ToStatus),
+			Actor:          transition.Actor,
+			Reason:         transition.Reason,
+			TransitionedAt: transition.TransitionedAt,
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
 }
-// CreatePayment handles the creation of a new payment
+
+// CreatePayment handles the creation of a new payment in store. The merchant
+// is the one the store belongs to, never one named by the customer.
 func (h *CreditPaymentHandler) CreatePayment(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+
 	var req dto.PaymentRequest
 	if err := c.Bind(&req); err != nil {
 		h.logger.Error("Failed to bind request body", zap.Error(err))
@@ -114,18 +234,29 @@ func (h *CreditPaymentHandler) CreatePayment(c echo.Context) error {
 		return h.handleError(c, err, "validation failed")
 	}
 
-    if !isValidPaymentMethod(req.PaymentMethod) {
-        err := errors.New("unsupported payment method")
-        h.logger.Error("Validation failed", zap.Error(err))
-        return h.handleError(c, err, "unsupported payment method")
-    }
+	card, err := resolveCard(ctx, h.cards, userID, req.PaymentMethod)
+	if err != nil {
+		return h.handleError(c, err, "invalid payment method")
+	}
 
 	payment := &domains.Payment{
 		CreditApplicationID: req.CreditApplicationID,
-		UserID:              req.UserID,
+		UserID:              userID,
+		OrderID:             req.OrderID,
 		Amount:              req.Amount,
 		Currency:            req.Currency,
-		PaymentMethod:       req.PaymentMethod,
+		PaymentMethod: domains.PaymentMethod{
+			Type: req.PaymentMethod.Type,
+			Details: domains.PaymentDetails{
+				CardToken:  card.Token,
+				CardBrand:  card.Brand,
+				CardLast4:  card.Last4,
+				ExpiryDate: card.ExpiryDate,
+			},
+		},
+		PlanProductID: req.PlanID,
+		StoreID:       &req.StoreID,
+		QuoteToken:    req.QuoteToken,
 	}
 
 	if err := h.service.CreatePayment(ctx, payment); err != nil {
@@ -137,9 +268,20 @@ func (h *CreditPaymentHandler) CreatePayment(c echo.Context) error {
 	return c.JSON(http.StatusCreated, h.createPaymentResponse(payment))
 }
 
-func isValidPaymentMethod(paymentMethod domains.PaymentMethod) bool {
-    return paymentMethod.Type == "card"
+// resolveCard tokenizes the card of a payment request, or looks up the card
+// token it references.
+func resolveCard(ctx context.Context, cards services.CardVaultServiceInterface, userID string, method dto.PaymentMethodRequest) (*domains.CardVaultEntry, error) {
+	if method.Card != nil {
+		return cards.Tokenize(ctx, userID, services.CardInput{
+			Number:     method.Card.Number,
+			HolderName: method.Card.HolderName,
+			ExpiryDate: method.Card.ExpiryDate,
+			CVV:        method.Card.CVV,
+		})
+	}
+	return cards.GetCard(ctx, userID, method.CardToken)
 }
+
 func (h *CreditPaymentHandler) GetPaymentDetails(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
@@ -152,7 +294,7 @@ func (h *CreditPaymentHandler) GetPaymentDetails(c echo.Context) error {
 
 	h.logger.Info("Fetching payment details", zap.Uint("paymentID", id))
 
-	payment, err := h.service.GetPaymentDetails(ctx, id)
+	payment, err := h.ownPayment(ctx, c, id)
 	if err != nil {
 		h.logger.Error("Failed to get payment details", zap.Error(err))
 		return h.handleError(c, err, "failed to get payment details")
@@ -172,123 +314,160 @@ func (h *CreditPaymentHandler) ProcessInstallment(c echo.Context) error {
 		return h.handleError(c, err, "invalid installment ID")
 	}
 
+	if _, err := h.ownInstallment(ctx, c, id); err != nil {
+		return h.handleError(c, err, "failed to get installment")
+	}
+
 	h.logger.Info("Processing installment", zap.Uint("installmentID", id))
 
-	if err := h.service.ProcessInstallment(ctx, id); err != nil {
+	installment, err := h.service.ProcessInstallment(ctx, id)
+	if err != nil {
 		h.logger.Error("Failed to process installment", zap.Error(err))
 		return h.handleError(c, err, "failed to process installment")
 	}
 
 	h.logger.Info("Installment processed successfully", zap.Uint("installmentID", id))
-	return c.JSON(http.StatusOK, map[string]string{"message": "Installment processed successfully"})
+	return c.JSON(http.StatusOK, h.createInstallmentResponse(installment))
 }
 
-// HandlePaymentWebhook processes payment webhook
-func (h *CreditPaymentHandler) HandlePaymentWebhook(c echo.Context) error {
+// ConfirmPayment asks the gateway for the outcome of a payment
+func (h *CreditPaymentHandler) ConfirmPayment(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
-	paymentID, err := h.parseID(c.Param("id"))
+	id, err := h.parseID(c.Param("id"))
 	if err != nil {
 		h.logger.Error("Invalid payment ID", zap.Error(err))
 		return h.handleError(c, err, "invalid payment ID")
 	}
 
-	var req struct {
-		Status string `json:"status" validate:"required"`
-	}
-	if err := c.Bind(&req); err != nil {
-		h.logger.Error("Failed to bind request body", zap.Error(err))
-		return h.handleError(c, err, "invalid request body")
-	}
-
-	h.logger.Info("PaymentWebhook received", zap.Uint("paymentID", paymentID), zap.Any("request", req))
-
-	if err := h.validator.Validate(req); err != nil {
-		h.logger.Error("Validation failed", zap.Error(err))
-		return h.handleError(c, err, "validation failed")
+	if _, err := h.ownPayment(ctx, c, id); err != nil {
+		return h.handleError(c, err, "failed to get payment")
 	}
 
-	if err := h.service.HandlePaymentWebhook(ctx, paymentID, req.Status); err != nil {
-		h.logger.Error("Failed to process payment webhook", zap.Error(err))
-		return h.handleError(c, err, "failed to process payment webhook")
+	payment, err := h.service.ProcessPayment(ctx, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to confirm payment")
 	}
 
-	h.logger.Info("Payment webhook processed successfully", zap.Uint("paymentID", paymentID))
-	return c.JSON(http.StatusOK, map[string]string{"message": "Payment webhook processed successfully"})
+	return c.JSON(http.StatusOK, h.createPaymentResponse(payment))
 }
-// HandleInstallmentWebhook processes installment webhook
-func (h *CreditPaymentHandler) HandleInstallmentWebhook(c echo.Context) error {
+
+// PaymentReturn handles the customer being sent back by the payment gateway
+func (h *CreditPaymentHandler) PaymentReturn(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
-	installmentID, err := h.parseID(c.Param("id"))
-	if err != nil {
-		h.logger.Error("Invalid installment ID", zap.Error(err))
-		return h.handleError(c, err, "invalid installment ID")
+	orderID := c.QueryParam("orderId")
+	if orderID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "orderId is required"})
 	}
 
-	var req struct {
-		Status string `json:"status" validate:"required"`
-	}
-	if err := c.Bind(&req); err != nil {
-		h.logger.Error("Failed to bind request body", zap.Error(err))
-		return h.handleError(c, err, "invalid request body")
+	payment, err := h.service.ConfirmPaymentOrder(ctx, orderID)
+	if err != nil {
+		return h.handleError(c, err, "failed to confirm payment order")
 	}
 
-	h.logger.Info("InstallmentWebhook received", zap.Uint("installmentID", installmentID), zap.Any("request", req))
+	h.logger.Info("Payment return handled", zap.Uint("paymentID", payment.ID), zap.String("status", payment.Status))
+	return c.JSON(http.StatusOK, h.createPaymentResponse(payment))
+}
 
-	if err := h.validator.Validate(req); err != nil {
-		h.logger.Error("Validation failed", zap.Error(err))
-		return h.handleError(c, err, "validation failed")
+// InstallmentReturn handles the customer being sent back by the payment gateway after paying an installment
+func (h *CreditPaymentHandler) InstallmentReturn(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	orderID := c.QueryParam("orderId")
+	if orderID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "orderId is required"})
 	}
 
-	if err := h.service.HandleInstallmentWebhook(ctx, installmentID, req.Status); err != nil {
-		h.logger.Error("Failed to process installment webhook", zap.Error(err))
-		return h.handleError(c, err, "failed to process installment webhook")
+	installment, err := h.service.ConfirmInstallmentOrder(ctx, orderID)
+	if err != nil {
+		return h.handleError(c, err, "failed to confirm installment order")
 	}
 
-	h.logger.Info("Installment webhook processed successfully", zap.Uint("installmentID", installmentID))
-	return c.JSON(http.StatusOK, map[string]string{"message": "Installment webhook processed successfully"})
+	h.logger.Info("Installment return handled", zap.Uint("installmentID", installment.ID), zap.String("status", installment.Status))
+	return c.JSON(http.StatusOK, h.createInstallmentResponse(installment))
 }
 
 func (h *CreditPaymentHandler) handleError(c echo.Context, err error, message string) error {
 	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
 
-	switch {
-	case errors.As(err, &utils.ErrNotFound{}):
-		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
-	case errors.As(err, &utils.ErrDuplicateEntry{}):
-		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
-	case errors.As(err, &utils.ErrDatabase{}):
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "An unexpected error occurred"})
-	case errors.Is(err, services.ErrInsufficientCredit):
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Insufficient credit"})
-	case errors.Is(err, services.ErrInvalidAmount):
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid amount"})
-	case errors.Is(err, services.ErrPaymentFailed):
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Payment processing failed"})
-	default:
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "An unexpected error occurred"})
+// ownCreditApplication returns a credit application of the authenticated
+// user. Applications of other users are reported as not found
+func (h *CreditPaymentHandler) ownCreditApplication(ctx context.Context, c echo.Context, id uint) (*domains.CreditApplication, error) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+	app, err := h.service.GetCreditApplication(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	if app.UserID != userID {
+		return nil, &utils.ErrNotFound{Entity: "CreditApplication", ID: id}
+	}
+	return app, nil
 }
 
-func (h *CreditPaymentHandler) parseID(param string) (uint, error) {
-	id, err := strconv.ParseUint(param, 10, 32)
+// ownPayment returns a payment of the authenticated user. Payments of other
+// users are reported as not found
+func (h *CreditPaymentHandler) ownPayment(ctx context.Context, c echo.Context, id uint) (*domains.Payment, error) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+	payment, err := h.service.GetPaymentDetails(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.UserID != userID {
+		return nil, &utils.ErrNotFound{Entity: "Payment", ID: id}
+	}
+	return payment, nil
+}
+
+// ownInstallment returns an installment of a payment of the authenticated
+// user. Installments of other users are reported as not found
+func (h *CreditPaymentHandler) ownInstallment(ctx context.Context, c echo.Context, id uint) (*domains.Installment, error) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+	installment, err := h.service.GetInstallment(ctx, id)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	payment, err := h.service.GetPaymentDetails(ctx, installment.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.UserID != userID {
+		return nil, &utils.ErrNotFound{Entity: "Installment", ID: id}
 	}
-	return uint(id), nil
+	return installment, nil
+}
+
+func (h *CreditPaymentHandler) parseID(param string) (uint, error) {
+	return parseUintParam(param)
 }
 
 func (h *CreditPaymentHandler) createCreditApplicationResponse(app *domains.CreditApplication) dto.CreditApplicationResponse {
 	return dto.CreditApplicationResponse{
-		ID:        app.ID,
-		UserID:    app.UserID,
-		Amount:    app.Amount,
-		Currency:  app.Currency,
-		Status:    app.Status,
-		CreatedAt: app.CreatedAt,
+		ID:             app.ID,
+		UserID:         app.UserID,
+		Amount:         app.Amount,
+		Currency:       app.Currency,
+		Status:         string(app.Status),
+		CreditScore:    app.CreditScore,
+		ScoreBand:      app.ScoreBand,
+		ScoreFactors:   app.ScoreFactors,
+		ApprovedAmount: app.ApprovedAmount,
+		DeclineReasons: app.DeclineReasons,
+		CreatedAt:      app.CreatedAt,
 	}
 }
 
@@ -297,25 +476,71 @@ func (h *CreditPaymentHandler) createPaymentResponse(payment *domains.Payment) d
 		ID:                  payment.ID,
 		CreditApplicationID: payment.CreditApplicationID,
 		UserID:              payment.UserID,
+		OrderID:             payment.OrderID,
+		MerchantID:          payment.MerchantID,
+		StoreID:             payment.StoreID,
+		MerchantCategory:    payment.MerchantCategory,
 		Amount:              payment.Amount,
+		FeeAmount:           payment.FeeAmount,
+		TotalRepayable:      payment.TotalRepayable(),
+		OutstandingCharges:  payment.OutstandingCharges(),
+		RefundedAmount:      payment.RefundedAmount,
 		Currency:            payment.Currency,
-		PaymentMethod:       payment.PaymentMethod,
+		PaymentMethod: dto.PaymentMethodResponse{
+			Type:       payment.PaymentMethod.Type,
+			CardToken:  payment.PaymentMethod.Details.CardToken,
+			CardBrand:  payment.PaymentMethod.Details.CardBrand,
+			CardNumber: utils.MaskCardNumber(payment.PaymentMethod.Details.CardLast4),
+			ExpiryDate: payment.PaymentMethod.Details.ExpiryDate,
+		},
 		Status:              payment.Status,
+		RedirectURL:         payment.RedirectURL,
+		PlanID:              payment.PlanProductID,
+		Plan:                planTermsResponse(payment.Plan),
 		CreatedAt:           payment.CreatedAt,
 		Installments:        make([]dto.InstallmentResponse, len(payment.Installments)),
 	}
 
-	for i, installment := range payment.Installments {
-		resp.Installments[i] = dto.InstallmentResponse{
-			ID:                installment.ID,
-			PaymentID:         installment.PaymentID,
-			InstallmentNumber: installment.InstallmentNumber,
-			DueDate:           installment.DueDate,
-			Amount:            installment.Amount,
-			Status:            installment.Status,
-			CreatedAt:         installment.CreatedAt,
-		}
+	for i := range payment.Installments {
+		resp.Installments[i] = h.createInstallmentResponse(&payment.Installments[i])
 	}
 
 	return resp
+}
+
+func (h *CreditPaymentHandler) createInstallmentResponse(installment *domains.Installment) dto.InstallmentResponse {
+	resp := dto.InstallmentResponse{
+		ID:                installment.ID,
+		PaymentID:         installment.PaymentID,
+		InstallmentNumber: installment.InstallmentNumber,
+		DueDate:           installment.DueDate,
+		Amount:            installment.Amount,
+		Principal:         installment.Principal,
+		Fee:               installment.Fee,
+		Status:            installment.Status,
+		RetryCount:        installment.RetryCount,
+		NextRetryAt:       installment.NextRetryAt,
+		RedirectURL:       installment.RedirectURL,
+		CreatedAt:         installment.CreatedAt,
+	}
+	for i := range installment.Charges {
+		resp.Charges = append(resp.Charges, chargeResponse(&installment.Charges[i]))
+	}
+	return resp
+}
+
+func chargeResponse(charge *domains.InstallmentCharge) dto.ChargeResponse {
+	return dto.ChargeResponse{
+		ID:            charge.ID,
+		InstallmentID: charge.InstallmentID,
+		Type:          string(charge.Type),
+		AccrualDate:   charge.AccrualDate,
+		Amount:        charge.Amount,
+		Currency:      charge.Currency,
+		Status:        string(charge.Status),
+		WaivedBy:      charge.WaivedBy,
+		WaiverReason:  charge.WaiverReason,
+		WaivedAt:      charge.WaivedAt,
+		CreatedAt:     charge.CreatedAt,
+	}
 }
\ No newline at end of file
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	"go.uber.org/zap"
+)
+
+// LedgerHandler handles HTTP requests for the double-entry ledger
+type LedgerHandler struct {
+	service services.LedgerServiceInterface
+	logger  *zap.Logger
+}
+
+// NewLedgerHandler creates a new instance of LedgerHandler
+func NewLedgerHandler(service services.LedgerServiceInterface, logger *zap.Logger) *LedgerHandler {
+	return &LedgerHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// ListJournals lists posted journals, newest first, optionally of one payment
+func (h *LedgerHandler) ListJournals(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	paymentID, err := optionalUintParam(c.QueryParam("payment_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+	}
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	journals, total, err := h.service.ListJournals(ctx, paymentID, offset, limit)
+	if err != nil {
+		return h.handleError(c, err, "failed to list journals")
+	}
+
+	resp := dto.JournalListResponse{
+		Journals: make([]dto.JournalResponse, len(journals)),
+		Total:    total,
+	}
+	for i, journal := range journals {
+		entries := make([]dto.LedgerEntryResponse, len(journal.Entries))
+		for j, entry := range journal.Entries {
+			entries[j] = dto.LedgerEntryResponse{
+				Account: string(entry.Account),
+				Debit:   entry.Debit,
+				Credit:  entry.Credit,
+			}
+		}
+		resp.Journals[i] = dto.JournalResponse{
+			ID:          journal.ID,
+			Type:        string(journal.Type),
+			Reference:   journal.Reference,
+			PaymentID:   journal.PaymentID,
+			UserID:      journal.UserID,
+			Currency:    journal.Currency,
+			Description: journal.Description,
+			PostedAt:    journal.PostedAt,
+			Entries:     entries,
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetBalances returns account balances as of a date, optionally of one
+// customer or payment. as_of is a date, covering the whole day, or an
+// RFC 3339 time, and defaults to now.
+func (h *LedgerHandler) GetBalances(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	asOf, err := parseAsOf(c.QueryParam("as_of"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "as_of must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+	}
+	paymentID, err := optionalUintParam(c.QueryParam("payment_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+	}
+	userID := c.QueryParam("user_id")
+
+	balances, err := h.service.Balances(ctx, asOf, userID, paymentID)
+	if err != nil {
+		return h.handleError(c, err, "failed to get ledger balances")
+	}
+
+	resp := dto.LedgerBalancesResponse{
+		AsOf:      asOf,
+		UserID:    userID,
+		PaymentID: paymentID,
+		Balances:  make([]dto.AccountBalanceResponse, len(balances)),
+	}
+	for i, balance := range balances {
+		resp.Balances[i] = dto.AccountBalanceResponse{
+			Account: string(balance.Account),
+			Debit:   balance.Debit,
+			Credit:  balance.Credit,
+			Balance: balance.Balance,
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *LedgerHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+// parseAsOf parses an as-of query value. A date means the end of that day
+// in UTC; the database keeps microseconds, so the last microsecond is used.
+func parseAsOf(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		return day.Add(24*time.Hour - time.Microsecond), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// optionalUintParam parses an optional ID, returning 0 when it is absent.
+func optionalUintParam(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return parseUintParam(value)
+}
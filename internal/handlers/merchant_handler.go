@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// MerchantHandler handles HTTP requests for merchant onboarding and for
+// merchants calling with their API keys
+type MerchantHandler struct {
+	service   services.MerchantServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewMerchantHandler creates a new instance of MerchantHandler
+func NewMerchantHandler(service services.MerchantServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *MerchantHandler {
+	return &MerchantHandler{
+		service:   service,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// CreateMerchant starts onboarding a merchant
+func (h *MerchantHandler) CreateMerchant(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.MerchantRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	merchant := merchantFromRequest(req)
+	if err := h.service.CreateMerchant(ctx, merchant); err != nil {
+		return h.handleError(c, err, "failed to create merchant")
+	}
+
+	return c.JSON(http.StatusCreated, merchantResponse(merchant))
+}
+
+// ListMerchants lists merchants, optionally filtered by status
+func (h *MerchantHandler) ListMerchants(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	status := domains.MerchantStatus(c.QueryParam("status"))
+
+	merchants, total, err := h.service.ListMerchants(ctx, status, offset, limit)
+	if err != nil {
+		return h.handleError(c, err, "failed to list merchants")
+	}
+
+	resp := dto.MerchantListResponse{
+		Merchants: make([]dto.MerchantResponse, len(merchants)),
+		Total:     total,
+	}
+	for i, merchant := range merchants {
+		resp.Merchants[i] = merchantResponse(merchant)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetMerchant returns a merchant with its stores
+func (h *MerchantHandler) GetMerchant(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid merchant ID"})
+	}
+
+	merchant, err := h.service.GetMerchant(ctx, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to get merchant")
+	}
+
+	return c.JSON(http.StatusOK, merchantResponse(merchant))
+}
+
+// UpdateMerchant changes a merchant's details
+func (h *MerchantHandler) UpdateMerchant(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid merchant ID"})
+	}
+
+	var req dto.MerchantRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	merchant := merchantFromRequest(req)
+	merchant.ID = id
+	updated, err := h.service.UpdateMerchant(ctx, merchant)
+	if err != nil {
+		return h.handleError(c, err, "failed to update merchant")
+	}
+
+	return c.JSON(http.StatusOK, merchantResponse(updated))
+}
+
+// SetMerchantStatus activates, suspends or closes a merchant
+func (h *MerchantHandler) SetMerchantStatus(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid merchant ID"})
+	}
+
+	var req dto.MerchantStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	merchant, err := h.service.SetMerchantStatus(ctx, id, domains.MerchantStatus(req.Status))
+	if err != nil {
+		return h.handleError(c, err, "failed to update merchant status")
+	}
+
+	return c.JSON(http.StatusOK, merchantResponse(merchant))
+}
+
+// AddStore adds a store to a merchant
+func (h *MerchantHandler) AddStore(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid merchant ID"})
+	}
+
+	var req dto.StoreRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	store := &domains.Store{
+		MerchantID: id,
+		Name:       req.Name,
+		Address:    req.Address,
+		City:       req.City,
+	}
+	if err := h.service.AddStore(ctx, store); err != nil {
+		return h.handleError(c, err, "failed to add store")
+	}
+
+	return c.JSON(http.StatusCreated, storeResponse(store))
+}
+
+// UpdateStore changes a store's details or closes it
+func (h *MerchantHandler) UpdateStore(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid merchant ID"})
+	}
+	storeID, err := parseUintParam(c.Param("storeId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid store ID"})
+	}
+
+	var req dto.StoreRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	store := &domains.Store{
+		MerchantID: id,
+		Name:       req.Name,
+		Address:    req.Address,
+		City:       req.City,
+		Active:     req.Active == nil || *req.Active,
+	}
+	store.ID = storeID
+	updated, err := h.service.UpdateStore(ctx, store)
+	if err != nil {
+		return h.handleError(c, err, "failed to update store")
+	}
+
+	return c.JSON(http.StatusOK, storeResponse(updated))
+}
+
+// CreateAPIKey issues an API key to a merchant. The key is only ever returned here
+func (h *MerchantHandler) CreateAPIKey(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid merchant ID"})
+	}
+
+	var req dto.APIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	apiKey, key, err := h.service.CreateAPIKey(ctx, id, req.Name)
+	if err != nil {
+		return h.handleError(c, err, "failed to create API key")
+	}
+
+	resp := apiKeyResponse(apiKey)
+	resp.Key = key
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// ListAPIKeys lists the API keys of a merchant, without their secrets
+func (h *MerchantHandler) ListAPIKeys(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid merchant ID"})
+	}
+
+	keys, err := h.service.ListAPIKeys(ctx, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to list API keys")
+	}
+
+	resp := dto.APIKeyListResponse{Keys: make([]dto.APIKeyResponse, len(keys))}
+	for i, key := range keys {
+		resp.Keys[i] = apiKeyResponse(key)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RevokeAPIKey revokes an API key of a merchant
+func (h *MerchantHandler) RevokeAPIKey(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid merchant ID"})
+	}
+	keyID, err := parseUintParam(c.Param("keyId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid API key ID"})
+	}
+
+	if err := h.service.RevokeAPIKey(ctx, id, keyID); err != nil {
+		return h.handleError(c, err, "failed to revoke API key")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetProfile returns the merchant the calling API key belongs to
+func (h *MerchantHandler) GetProfile(c echo.Context) error {
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+	return c.JSON(http.StatusOK, merchantResponse(merchant))
+}
+
+// ListOwnPayments lists the payments made at the calling merchant
+func (h *MerchantHandler) ListOwnPayments(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	payments, total, err := h.service.ListPayments(ctx, merchant.ID, offset, limit)
+	if err != nil {
+		return h.handleError(c, err, "failed to list merchant payments")
+	}
+
+	resp := dto.MerchantPaymentListResponse{
+		Payments: make([]dto.MerchantPaymentResponse, len(payments)),
+		Total:    total,
+	}
+	for i, payment := range payments {
+		resp.Payments[i] = dto.MerchantPaymentResponse{
+			ID:             payment.ID,
+			OrderID:        payment.OrderID,
+			StoreID:        payment.StoreID,
+			Amount:         payment.Amount,
+			RefundedAmount: payment.RefundedAmount,
+			Currency:       payment.Currency,
+			Status:         payment.Status,
+			CreatedAt:      payment.CreatedAt,
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *MerchantHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func merchantFromRequest(req dto.MerchantRequest) *domains.Merchant {
+	return &domains.Merchant{
+		Name:            req.Name,
+		LegalName:       req.LegalName,
+		TaxID:           req.TaxID,
+		Email:           req.Email,
+		Phone:           req.Phone,
+		Category:        req.Category,
+		RIB:             req.RIB,
+		DiscountRateBps: req.DiscountRateBps,
+	}
+}
+
+func merchantResponse(merchant *domains.Merchant) dto.MerchantResponse {
+	resp := dto.MerchantResponse{
+		ID:              merchant.ID,
+		Name:            merchant.Name,
+		LegalName:       merchant.LegalName,
+		TaxID:           merchant.TaxID,
+		Email:           merchant.Email,
+		Phone:           merchant.Phone,
+		Category:        merchant.Category,
+		RIB:             merchant.RIB,
+		DiscountRateBps: merchant.DiscountRateBps,
+		Status:          string(merchant.Status),
+		ActivatedAt:     merchant.ActivatedAt,
+		Stores:          make([]dto.StoreResponse, len(merchant.Stores)),
+		CreatedAt:       merchant.CreatedAt,
+	}
+	for i := range merchant.Stores {
+		resp.Stores[i] = storeResponse(&merchant.Stores[i])
+	}
+	return resp
+}
+
+func storeResponse(store *domains.Store) dto.StoreResponse {
+	return dto.StoreResponse{
+		ID:         store.ID,
+		MerchantID: store.MerchantID,
+		Name:       store.Name,
+		Address:    store.Address,
+		City:       store.City,
+		Active:     store.Active,
+	}
+}
+
+func apiKeyResponse(key *domains.MerchantAPIKey) dto.APIKeyResponse {
+	return dto.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// MerchantWebhookHandler handles HTTP requests of merchants managing their
+// webhook endpoints and following deliveries to them
+type MerchantWebhookHandler struct {
+	service   services.MerchantWebhookServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewMerchantWebhookHandler creates a new instance of MerchantWebhookHandler
+func NewMerchantWebhookHandler(service services.MerchantWebhookServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *MerchantWebhookHandler {
+	return &MerchantWebhookHandler{
+		service:   service,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// CreateEndpoint registers a webhook endpoint for the calling merchant. The
+// signing secret is only returned here
+func (h *MerchantWebhookHandler) CreateEndpoint(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+
+	var req dto.WebhookEndpointRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	endpoint := &domains.WebhookEndpoint{
+		MerchantID:  merchant.ID,
+		URL:         req.URL,
+		Description: req.Description,
+	}
+	for _, eventType := range req.EventTypes {
+		endpoint.EventTypes = append(endpoint.EventTypes, domains.MerchantEventType(eventType))
+	}
+
+	if err := h.service.CreateEndpoint(ctx, endpoint); err != nil {
+		return h.handleError(c, err, "failed to create webhook endpoint")
+	}
+
+	resp := webhookEndpointResponse(endpoint)
+	resp.Secret = endpoint.Secret
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// ListEndpoints lists the webhook endpoints of the calling merchant, without
+// their secrets
+func (h *MerchantWebhookHandler) ListEndpoints(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+
+	endpoints, err := h.service.ListEndpoints(ctx, merchant.ID)
+	if err != nil {
+		return h.handleError(c, err, "failed to list webhook endpoints")
+	}
+
+	resp := dto.WebhookEndpointListResponse{Endpoints: make([]dto.WebhookEndpointResponse, len(endpoints))}
+	for i, endpoint := range endpoints {
+		resp.Endpoints[i] = webhookEndpointResponse(endpoint)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// DisableEndpoint stops events being posted to an endpoint of the calling
+// merchant
+func (h *MerchantWebhookHandler) DisableEndpoint(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid webhook endpoint ID"})
+	}
+
+	if err := h.service.DisableEndpoint(ctx, merchant.ID, id); err != nil {
+		return h.handleError(c, err, "failed to disable webhook endpoint")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListDeliveries lists the webhook deliveries of the calling merchant, newest
+// first, optionally to one endpoint or in one status
+func (h *MerchantWebhookHandler) ListDeliveries(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+	endpointID, err := optionalUintParam(c.QueryParam("endpoint_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid webhook endpoint ID"})
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	status := domains.WebhookDeliveryStatus(c.QueryParam("status"))
+
+	deliveries, total, err := h.service.ListDeliveries(ctx, merchant.ID, endpointID, status, offset, limit)
+	if err != nil {
+		return h.handleError(c, err, "failed to list webhook deliveries")
+	}
+
+	resp := dto.WebhookDeliveryListResponse{
+		Deliveries: make([]dto.WebhookDeliveryResponse, len(deliveries)),
+		Total:      total,
+	}
+	for i, delivery := range deliveries {
+		resp.Deliveries[i] = webhookDeliveryResponse(delivery)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetDelivery returns a webhook delivery of the calling merchant with its
+// attempt log
+func (h *MerchantWebhookHandler) GetDelivery(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid webhook delivery ID"})
+	}
+
+	delivery, err := h.service.GetDelivery(ctx, merchant.ID, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to get webhook delivery")
+	}
+
+	return c.JSON(http.StatusOK, webhookDeliveryResponse(delivery))
+}
+
+// ReplayDelivery sends a webhook delivery of the calling merchant again and
+// returns it with the new attempt
+func (h *MerchantWebhookHandler) ReplayDelivery(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid webhook delivery ID"})
+	}
+
+	delivery, err := h.service.ReplayDelivery(ctx, merchant.ID, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to replay webhook delivery")
+	}
+
+	return c.JSON(http.StatusOK, webhookDeliveryResponse(delivery))
+}
+
+func (h *MerchantWebhookHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func webhookEndpointResponse(endpoint *domains.WebhookEndpoint) dto.WebhookEndpointResponse {
+	resp := dto.WebhookEndpointResponse{
+		ID:          endpoint.ID,
+		URL:         endpoint.URL,
+		Description: endpoint.Description,
+		EventTypes:  make([]string, len(endpoint.EventTypes)),
+		CreatedAt:   endpoint.CreatedAt,
+		DisabledAt:  endpoint.DisabledAt,
+	}
+	for i, eventType := range endpoint.EventTypes {
+		resp.EventTypes[i] = string(eventType)
+	}
+	return resp
+}
+
+func webhookDeliveryResponse(delivery *domains.WebhookDelivery) dto.WebhookDeliveryResponse {
+	resp := dto.WebhookDeliveryResponse{
+		ID:                 delivery.ID,
+		EndpointID:         delivery.EndpointID,
+		EventID:            delivery.EventID,
+		EventType:          string(delivery.EventType),
+		Status:             string(delivery.Status),
+		Attempts:           delivery.Attempts,
+		NextAttemptAt:      delivery.NextAttemptAt,
+		LastAttemptAt:      delivery.LastAttemptAt,
+		LastResponseStatus: delivery.LastResponseStatus,
+		LastError:          delivery.LastError,
+		DeliveredAt:        delivery.DeliveredAt,
+		CreatedAt:          delivery.CreatedAt,
+	}
+	for _, attempt := range delivery.AttemptLog {
+		resp.AttemptLog = append(resp.AttemptLog, dto.WebhookDeliveryAttemptResponse{
+			ResponseStatus: attempt.ResponseStatus,
+			Error:          attempt.Error,
+			DurationMs:     attempt.DurationMs,
+			AttemptedAt:    attempt.AttemptedAt,
+		})
+	}
+	return resp
+}
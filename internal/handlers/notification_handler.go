@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	"go.uber.org/zap"
+)
+
+// NotificationHandler handles HTTP requests for customers' notifications
+type NotificationHandler struct {
+	service services.NotificationServiceInterface
+	logger  *zap.Logger
+}
+
+// NewNotificationHandler creates a new instance of NotificationHandler
+func NewNotificationHandler(service services.NotificationServiceInterface, logger *zap.Logger) *NotificationHandler {
+	return &NotificationHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// ListNotifications lists the authenticated user's notifications, newest first
+func (h *NotificationHandler) ListNotifications(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	notifications, total, err := h.service.ListNotifications(ctx, userID, offset, limit)
+	if err != nil {
+		h.logger.Error("Failed to list notifications", zap.Error(err))
+		return writeError(c, err)
+	}
+
+	resp := dto.NotificationListResponse{
+		Notifications: make([]dto.NotificationResponse, len(notifications)),
+		Total:         total,
+	}
+	for i, notification := range notifications {
+		resp.Notifications[i] = dto.NotificationResponse{
+			ID:            notification.ID,
+			Type:          notification.Type,
+			Message:       notification.Message,
+			PaymentID:     notification.PaymentID,
+			InstallmentID: notification.InstallmentID,
+			CreatedAt:     notification.CreatedAt,
+			ReadAt:        notification.ReadAt,
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
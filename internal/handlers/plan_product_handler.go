@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// PlanProductHandler handles HTTP requests for the installment plan catalog
+type PlanProductHandler struct {
+	service   services.PlanProductServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewPlanProductHandler creates a new instance of PlanProductHandler
+func NewPlanProductHandler(service services.PlanProductServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *PlanProductHandler {
+	return &PlanProductHandler{
+		service:   service,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// ListActivePlans lists the plans customers can choose at checkout
+func (h *PlanProductHandler) ListActivePlans(c echo.Context) error {
+	return h.listPlans(c, true)
+}
+
+// ListPlans lists the whole catalog, including inactive plans
+func (h *PlanProductHandler) ListPlans(c echo.Context) error {
+	return h.listPlans(c, c.QueryParam("active") == "true")
+}
+
+func (h *PlanProductHandler) listPlans(c echo.Context, activeOnly bool) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	plans, err := h.service.ListPlans(ctx, activeOnly)
+	if err != nil {
+		return h.handleError(c, err, "failed to list plans")
+	}
+
+	resp := make([]dto.PlanProductResponse, len(plans))
+	for i, plan := range plans {
+		resp[i] = h.createPlanResponse(plan)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetPlan returns one plan of the catalog
+func (h *PlanProductHandler) GetPlan(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid plan ID"})
+	}
+
+	plan, err := h.service.GetPlan(ctx, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to get plan")
+	}
+	return c.JSON(http.StatusOK, h.createPlanResponse(plan))
+}
+
+// CreatePlan adds a plan to the catalog
+func (h *PlanProductHandler) CreatePlan(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.PlanProductRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	plan := planFromRequest(req)
+	if err := h.service.CreatePlan(ctx, plan); err != nil {
+		return h.handleError(c, err, "failed to create plan")
+	}
+	return c.JSON(http.StatusCreated, h.createPlanResponse(plan))
+}
+
+// UpdatePlan replaces the definition of a plan
+func (h *PlanProductHandler) UpdatePlan(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid plan ID"})
+	}
+
+	var req dto.PlanProductRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	plan := planFromRequest(req)
+	plan.ID = id
+	if err := h.service.UpdatePlan(ctx, plan); err != nil {
+		return h.handleError(c, err, "failed to update plan")
+	}
+	return c.JSON(http.StatusOK, h.createPlanResponse(plan))
+}
+
+// DeletePlan removes a plan from the catalog
+func (h *PlanProductHandler) DeletePlan(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid plan ID"})
+	}
+
+	if err := h.service.DeletePlan(ctx, id); err != nil {
+		return h.handleError(c, err, "failed to delete plan")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// QuotePlan previews the schedule of a purchase and signs it so the terms
+// are honoured for a short while
+func (h *PlanProductHandler) QuotePlan(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.PlanQuoteRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	quote, err := h.service.Quote(ctx, services.QuoteRequest{
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+		PlanID:           req.PlanID,
+		MerchantCategory: req.MerchantCategory,
+	})
+	if err != nil {
+		return h.handleError(c, err, "failed to quote plan")
+	}
+
+	resp := dto.PlanQuoteResponse{
+		QuoteID:        quote.ID,
+		QuoteToken:     quote.Token,
+		ExpiresAt:      quote.ExpiresAt,
+		PlanID:         quote.PlanID,
+		PlanCode:       quote.PlanCode,
+		Amount:         quote.Amount,
+		Currency:       quote.Currency,
+		Terms:          planTermsResponse(quote.Terms),
+		DownPayment:    quote.Price.DownPayment,
+		FeeAmount:      quote.Price.Fee,
+		TotalRepayable: quote.Price.TotalRepayable(),
+		Installments:   make([]dto.QuotedInstallmentResponse, len(quote.Installments)),
+	}
+	for i, installment := range quote.Installments {
+		resp.Installments[i] = dto.QuotedInstallmentResponse{
+			InstallmentNumber: installment.InstallmentNumber,
+			DueDate:           installment.DueDate,
+			Amount:            installment.Amount,
+			Principal:         installment.Principal,
+			Fee:               installment.Fee,
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *PlanProductHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func (h *PlanProductHandler) createPlanResponse(plan *domains.PlanProduct) dto.PlanProductResponse {
+	return dto.PlanProductResponse{
+		ID:                 plan.ID,
+		Code:               plan.Code,
+		Name:               plan.Name,
+		Terms:              planTermsResponse(plan.Terms),
+		MinAmount:          plan.MinAmount,
+		MaxAmount:          plan.MaxAmount,
+		MerchantCategories: plan.MerchantCategories,
+		Active:             plan.Active,
+		CreatedAt:          plan.CreatedAt,
+		UpdatedAt:          plan.UpdatedAt,
+	}
+}
+
+func planFromRequest(req dto.PlanProductRequest) *domains.PlanProduct {
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+	return &domains.PlanProduct{
+		Code: req.Code,
+		Name: req.Name,
+		Terms: domains.PlanTerms{
+			InstallmentCount:   req.InstallmentCount,
+			Frequency:          domains.PlanFrequency(req.Frequency),
+			DownPaymentPercent: req.DownPaymentPercent,
+			FeeModel:           domains.FeeModel(req.FeeModel),
+			FeeValue:           req.FeeValue,
+		},
+		MinAmount:          req.MinAmount,
+		MaxAmount:          req.MaxAmount,
+		MerchantCategories: req.MerchantCategories,
+		Active:             active,
+	}
+}
+
+func planTermsResponse(terms domains.PlanTerms) dto.PlanTermsResponse {
+	return dto.PlanTermsResponse{
+		InstallmentCount:   terms.InstallmentCount,
+		Frequency:          string(terms.Frequency),
+		DownPaymentPercent: terms.DownPaymentPercent,
+		FeeModel:           string(terms.FeeModel),
+		FeeValue:           terms.FeeValue,
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// PrepaymentHandler handles HTTP requests for paying installments ahead of schedule
+type PrepaymentHandler struct {
+	service   services.PrepaymentServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewPrepaymentHandler creates a new instance of PrepaymentHandler
+func NewPrepaymentHandler(service services.PrepaymentServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *PrepaymentHandler {
+	return &PrepaymentHandler{
+		service:   service,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// QuotePayoff returns what settling a payment of the authenticated user today costs
+func (h *PrepaymentHandler) QuotePayoff(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+	}
+
+	quote, err := h.service.QuotePayoff(ctx, userID, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to quote payoff")
+	}
+
+	return c.JSON(http.StatusOK, dto.PayoffQuoteResponse{
+		PaymentID:    quote.PaymentID,
+		Currency:     quote.Currency,
+		Remaining:    quote.Remaining,
+		Rebate:       quote.Rebate,
+		LateFees:     quote.LateFees,
+		PayoffAmount: quote.PayoffAmount,
+		Lines:        prepaymentLineResponses(quote.Lines),
+		QuotedAt:     quote.QuotedAt,
+	})
+}
+
+// Payoff starts the early payoff of a payment of the authenticated user
+func (h *PrepaymentHandler) Payoff(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+	}
+
+	prepayment, err := h.service.Payoff(ctx, userID, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to start payoff")
+	}
+
+	return c.JSON(http.StatusCreated, h.createPrepaymentResponse(prepayment))
+}
+
+// Prepay starts a partial prepayment of a payment of the authenticated user
+func (h *PrepaymentHandler) Prepay(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+	}
+
+	var req dto.PrepaymentRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	prepayment, err := h.service.Prepay(ctx, userID, id, req.Amount, domains.PrepaymentAllocation(req.Allocation))
+	if err != nil {
+		return h.handleError(c, err, "failed to start prepayment")
+	}
+
+	return c.JSON(http.StatusCreated, h.createPrepaymentResponse(prepayment))
+}
+
+// PrepaymentReturn handles the customer being sent back by the payment gateway after paying a prepayment
+func (h *PrepaymentHandler) PrepaymentReturn(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	orderID := c.QueryParam("orderId")
+	if orderID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "orderId is required"})
+	}
+
+	prepayment, err := h.service.ConfirmPrepaymentOrder(ctx, orderID)
+	if err != nil {
+		return h.handleError(c, err, "failed to confirm prepayment order")
+	}
+
+	h.logger.Info("Prepayment return handled", zap.Uint("prepaymentID", prepayment.ID), zap.String("status", string(prepayment.Status)))
+	return c.JSON(http.StatusOK, h.createPrepaymentResponse(prepayment))
+}
+
+func (h *PrepaymentHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func (h *PrepaymentHandler) createPrepaymentResponse(prepayment *domains.Prepayment) dto.PrepaymentResponse {
+	return dto.PrepaymentResponse{
+		ID:          prepayment.ID,
+		PaymentID:   prepayment.PaymentID,
+		Type:        string(prepayment.Type),
+		Allocation:  string(prepayment.Allocation),
+		Amount:      prepayment.Amount,
+		Rebate:      prepayment.Rebate,
+		LateFees:    prepayment.LateFees,
+		Currency:    prepayment.Currency,
+		Status:      string(prepayment.Status),
+		RedirectURL: prepayment.RedirectURL,
+		Unapplied:   prepayment.Unapplied,
+		Lines:       prepaymentLineResponses(prepayment.Lines),
+		CreatedAt:   prepayment.CreatedAt,
+	}
+}
+
+func prepaymentLineResponses(lines []domains.PrepaymentLine) []dto.PrepaymentLineResponse {
+	resp := make([]dto.PrepaymentLineResponse, len(lines))
+	for i, line := range lines {
+		resp[i] = dto.PrepaymentLineResponse{
+			InstallmentID: line.InstallmentID,
+			Principal:     line.Principal,
+			Fee:           line.Fee,
+			Rebate:        line.Rebate,
+		}
+	}
+	return resp
+}
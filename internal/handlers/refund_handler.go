@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	"github.com/mohamed2394/sahla/internal/utils"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// RefundHandler handles HTTP requests for refunds of returned purchases
+type RefundHandler struct {
+	service   services.RefundServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewRefundHandler creates a new instance of RefundHandler
+func NewRefundHandler(service services.RefundServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *RefundHandler {
+	return &RefundHandler{
+		service:   service,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// CreateRefund refunds part or all of a payment on behalf of the authenticated admin
+func (h *RefundHandler) CreateRefund(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+	}
+
+	adminID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin not authenticated"})
+	}
+
+	var req dto.RefundRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	refund, err := h.service.CreateRefund(ctx, id, req.Amount, req.Reason, adminID)
+	if err != nil {
+		return h.handleError(c, err, "failed to create refund")
+	}
+
+	return c.JSON(http.StatusCreated, h.createRefundResponse(refund))
+}
+
+// ListRefunds lists the refunds of a payment of the authenticated user
+func (h *RefundHandler) ListRefunds(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+	}
+
+	refunds, err := h.service.ListUserRefunds(ctx, userID, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to list refunds")
+	}
+
+	resp := dto.RefundListResponse{Refunds: make([]dto.RefundResponse, len(refunds))}
+	for i, refund := range refunds {
+		resp.Refunds[i] = h.createRefundResponse(refund)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetRefund returns a single refund of the authenticated user
+func (h *RefundHandler) GetRefund(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not authenticated"})
+	}
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid refund ID"})
+	}
+
+	refund, err := h.service.GetRefund(ctx, id)
+	if err == nil && refund.UserID != userID {
+		err = &utils.ErrNotFound{Entity: "Refund", ID: id}
+	}
+	if err != nil {
+		return h.handleError(c, err, "failed to get refund")
+	}
+
+	return c.JSON(http.StatusOK, h.createRefundResponse(refund))
+}
+
+// ListAdminRefunds lists refunds for admins, optionally filtered by status,
+// e.g. FAILED to find card refunds to retry
+func (h *RefundHandler) ListAdminRefunds(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	status := domains.RefundStatus(c.QueryParam("status"))
+
+	refunds, total, err := h.service.ListRefundsByStatus(ctx, status, offset, limit)
+	if err != nil {
+		return h.handleError(c, err, "failed to list refunds")
+	}
+
+	resp := dto.RefundListResponse{Refunds: make([]dto.RefundResponse, len(refunds)), Total: total}
+	for i, refund := range refunds {
+		resp.Refunds[i] = h.createRefundResponse(refund)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RetryRefund sends the failed card refunds of a refund to the gateway again
+func (h *RefundHandler) RetryRefund(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid refund ID"})
+	}
+
+	refund, err := h.service.RetryCardRefunds(ctx, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to retry refund")
+	}
+
+	return c.JSON(http.StatusOK, h.createRefundResponse(refund))
+}
+
+func (h *RefundHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func (h *RefundHandler) createRefundResponse(refund *domains.Refund) dto.RefundResponse {
+	resp := dto.RefundResponse{
+		ID:                refund.ID,
+		PaymentID:         refund.PaymentID,
+		Amount:            refund.Amount,
+		FeeReduction:      refund.FeeReduction,
+		ScheduleReduction: refund.ScheduleReduction,
+		CardRefund:        refund.CardRefund,
+		Currency:          refund.Currency,
+		Reason:            refund.Reason,
+		RequestedBy:       refund.RequestedBy,
+		Status:            string(refund.Status),
+		Lines:             make([]dto.RefundLineResponse, len(refund.Lines)),
+		CreatedAt:         refund.CreatedAt,
+		CompletedAt:       refund.CompletedAt,
+	}
+	for i, line := range refund.Lines {
+		resp.Lines[i] = dto.RefundLineResponse{
+			Kind:           string(line.Kind),
+			InstallmentID:  line.InstallmentID,
+			GatewayOrderID: line.GatewayOrderID,
+			Principal:      line.Principal,
+			Fee:            line.Fee,
+			Amount:         line.Amount,
+			Status:         string(line.Status),
+			Error:          line.Error,
+		}
+	}
+	return resp
+}
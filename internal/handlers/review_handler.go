@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// ReviewHandler handles HTTP requests of the manual underwriting queue
+type ReviewHandler struct {
+	service   services.ReviewServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewReviewHandler creates a new instance of ReviewHandler
+func NewReviewHandler(service services.ReviewServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *ReviewHandler {
+	return &ReviewHandler{
+		service:   service,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// ListReviews lists manual reviews, optionally filtered by status
+func (h *ReviewHandler) ListReviews(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	status := domains.ManualReviewStatus(c.QueryParam("status"))
+
+	reviews, total, err := h.service.ListReviews(ctx, status, offset, limit)
+	if err != nil {
+		return h.handleError(c, err, "failed to list manual reviews")
+	}
+
+	resp := dto.ManualReviewListResponse{
+		Reviews: make([]dto.ManualReviewResponse, len(reviews)),
+		Total:   total,
+	}
+	for i, review := range reviews {
+		resp.Reviews[i] = h.createReviewResponse(review)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// ClaimReview assigns a manual review to the authenticated reviewer
+func (h *ReviewHandler) ClaimReview(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid review ID"})
+	}
+
+	reviewerID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "reviewer not authenticated"})
+	}
+
+	review, err := h.service.ClaimReview(ctx, id, reviewerID)
+	if err != nil {
+		return h.handleError(c, err, "failed to claim manual review")
+	}
+
+	return c.JSON(http.StatusOK, h.createReviewResponse(review))
+}
+
+// ApproveReview approves the credit application of a claimed review
+func (h *ReviewHandler) ApproveReview(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid review ID"})
+	}
+
+	reviewerID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "reviewer not authenticated"})
+	}
+
+	var req dto.ApproveReviewRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	review, err := h.service.ApproveReview(ctx, id, reviewerID, req.Reason, req.ApprovedAmount)
+	if err != nil {
+		return h.handleError(c, err, "failed to approve manual review")
+	}
+
+	h.logger.Info("Manual review approved", zap.Uint("reviewID", id), zap.String("reviewerID", reviewerID))
+	return c.JSON(http.StatusOK, h.createReviewResponse(review))
+}
+
+// DeclineReview rejects the credit application of a claimed review
+func (h *ReviewHandler) DeclineReview(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid review ID"})
+	}
+
+	reviewerID, ok := userIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "reviewer not authenticated"})
+	}
+
+	var req dto.DeclineReviewRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	review, err := h.service.DeclineReview(ctx, id, reviewerID, req.Reason, req.ReasonCodes)
+	if err != nil {
+		return h.handleError(c, err, "failed to decline manual review")
+	}
+
+	h.logger.Info("Manual review declined", zap.Uint("reviewID", id), zap.String("reviewerID", reviewerID))
+	return c.JSON(http.StatusOK, h.createReviewResponse(review))
+}
+
+func (h *ReviewHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func (h *ReviewHandler) createReviewResponse(review *domains.ManualReview) dto.ManualReviewResponse {
+	return dto.ManualReviewResponse{
+		ID:                  review.ID,
+		CreditApplicationID: review.CreditApplicationID,
+		Status:              string(review.Status),
+		CreditScore:         review.CreditScore,
+		ReviewerID:          review.ReviewerID,
+		ClaimedAt:           review.ClaimedAt,
+		Decision:            review.Decision,
+		DecisionReason:      review.DecisionReason,
+		ApprovedAmount:      review.ApprovedAmount,
+		DecidedAt:           review.DecidedAt,
+		CreatedAt:           review.CreatedAt,
+	}
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// SettlementHandler handles HTTP requests for merchant payouts
+type SettlementHandler struct {
+	service   services.SettlementServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewSettlementHandler creates a new instance of SettlementHandler
+func NewSettlementHandler(service services.SettlementServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *SettlementHandler {
+	return &SettlementHandler{
+		service:   service,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// GenerateBatches makes the payout batches of a day that the daily job has
+// not made yet
+func (h *SettlementHandler) GenerateBatches(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.GenerateSettlementRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	day, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid date"})
+	}
+
+	batches, err := h.service.GenerateBatches(ctx, day)
+	if err != nil {
+		return h.handleError(c, err, "failed to generate settlement batches")
+	}
+
+	resp := dto.SettlementBatchListResponse{
+		Batches: make([]dto.SettlementBatchResponse, len(batches)),
+		Total:   len(batches),
+	}
+	for i, batch := range batches {
+		resp.Batches[i] = settlementBatchResponse(batch)
+	}
+
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// ListBatches lists payout batches, newest first, optionally of one merchant
+// or in one status
+func (h *SettlementHandler) ListBatches(c echo.Context) error {
+	merchantID, err := optionalUintParam(c.QueryParam("merchant_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid merchant ID"})
+	}
+	return h.listBatches(c, merchantID)
+}
+
+// GetBatch returns a payout batch with its items
+func (h *SettlementHandler) GetBatch(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid settlement batch ID"})
+	}
+
+	batch, err := h.service.GetBatch(ctx, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to get settlement batch")
+	}
+
+	return c.JSON(http.StatusOK, settlementBatchResponse(batch))
+}
+
+// MarkBatchPaid records the bank's confirmation of a payout
+func (h *SettlementHandler) MarkBatchPaid(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid settlement batch ID"})
+	}
+
+	var req dto.MarkSettlementPaidRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	batch, err := h.service.MarkBatchPaid(ctx, id, req.BankReference)
+	if err != nil {
+		return h.handleError(c, err, "failed to mark settlement batch paid")
+	}
+
+	return c.JSON(http.StatusOK, settlementBatchResponse(batch))
+}
+
+// MarkBatchFailed records that the bank rejected a payout
+func (h *SettlementHandler) MarkBatchFailed(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid settlement batch ID"})
+	}
+
+	var req dto.MarkSettlementFailedRequest
+	if err := c.Bind(&req); err != nil {
+		return h.handleError(c, err, "invalid request body")
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	batch, err := h.service.MarkBatchFailed(ctx, id, req.Reason)
+	if err != nil {
+		return h.handleError(c, err, "failed to mark settlement batch failed")
+	}
+
+	return c.JSON(http.StatusOK, settlementBatchResponse(batch))
+}
+
+// ExportBatches writes the batches awaiting payout to a new bank file
+func (h *SettlementHandler) ExportBatches(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	file, err := h.service.ExportBatches(ctx)
+	if err != nil {
+		return h.handleError(c, err, "failed to export settlement batches")
+	}
+
+	return c.JSON(http.StatusCreated, dto.BankFileResponse{
+		ID:          file.ID,
+		FileName:    file.FileName,
+		Format:      file.Format,
+		BatchCount:  file.BatchCount,
+		TotalAmount: file.TotalAmount,
+		CreatedAt:   file.CreatedAt,
+	})
+}
+
+// DownloadBankFile returns the content of a bank file for upload to the bank
+func (h *SettlementHandler) DownloadBankFile(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid bank file ID"})
+	}
+
+	file, err := h.service.GetBankFile(ctx, id)
+	if err != nil {
+		return h.handleError(c, err, "failed to get bank file")
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if file.Format == string(services.BankFileCSV) {
+		contentType = "text/csv; charset=utf-8"
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=\""+file.FileName+"\"")
+	return c.Blob(http.StatusOK, contentType, []byte(file.Content))
+}
+
+// ListOwnBatches lists the payouts of the calling merchant
+func (h *SettlementHandler) ListOwnBatches(c echo.Context) error {
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+	return h.listBatches(c, merchant.ID)
+}
+
+// GetOwnBatch returns a payout of the calling merchant with its items
+func (h *SettlementHandler) GetOwnBatch(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	merchant, ok := merchantFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "merchant not authenticated"})
+	}
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid settlement batch ID"})
+	}
+
+	batch, err := h.service.GetBatch(ctx, id)
+	if err == nil && batch.MerchantID != merchant.ID {
+		err = &utils.ErrNotFound{Entity: "SettlementBatch", ID: id}
+	}
+	if err != nil {
+		return h.handleError(c, err, "failed to get settlement batch")
+	}
+
+	return c.JSON(http.StatusOK, settlementBatchResponse(batch))
+}
+
+func (h *SettlementHandler) listBatches(c echo.Context, merchantID uint) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	status := domains.SettlementBatchStatus(c.QueryParam("status"))
+
+	batches, total, err := h.service.ListBatches(ctx, merchantID, status, offset, limit)
+	if err != nil {
+		return h.handleError(c, err, "failed to list settlement batches")
+	}
+
+	resp := dto.SettlementBatchListResponse{
+		Batches: make([]dto.SettlementBatchResponse, len(batches)),
+		Total:   total,
+	}
+	for i, batch := range batches {
+		resp.Batches[i] = settlementBatchResponse(batch)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *SettlementHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func settlementBatchResponse(batch *domains.SettlementBatch) dto.SettlementBatchResponse {
+	resp := dto.SettlementBatchResponse{
+		ID:              batch.ID,
+		MerchantID:      batch.MerchantID,
+		SettlementDate:  batch.SettlementDate,
+		Currency:        batch.Currency,
+		GrossAmount:     batch.GrossAmount,
+		FeeAmount:       batch.FeeAmount,
+		NetAmount:       batch.NetAmount,
+		ItemCount:       batch.ItemCount,
+		RIB:             batch.RIB,
+		BeneficiaryName: batch.BeneficiaryName,
+		Status:          string(batch.Status),
+		BankFileID:      batch.BankFileID,
+		BankReference:   batch.BankReference,
+		FailureReason:   batch.FailureReason,
+		ExportedAt:      batch.ExportedAt,
+		PaidAt:          batch.PaidAt,
+		CreatedAt:       batch.CreatedAt,
+	}
+	for _, item := range batch.Items {
+		resp.Items = append(resp.Items, dto.SettlementItemResponse{
+			Type:            string(item.Type),
+			PaymentID:       item.PaymentID,
+			GrossAmount:     item.GrossAmount,
+			DiscountRateBps: item.DiscountRateBps,
+			FeeAmount:       item.FeeAmount,
+			NetAmount:       item.NetAmount,
+			PostedAt:        item.PostedAt,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domains "github.com/mohamed2394/sahla/internal/domains"
+	dto "github.com/mohamed2394/sahla/internal/dtos"
+	services "github.com/mohamed2394/sahla/internal/services"
+	validation "github.com/mohamed2394/sahla/internal/validation"
+	"go.uber.org/zap"
+)
+
+// WebhookHandler handles signed webhooks sent by the payment gateway
+type WebhookHandler struct {
+	payments  services.CreditPaymentServiceInterface
+	refunds   services.RefundServiceInterface
+	webhooks  services.InboundWebhookServiceInterface
+	logger    *zap.Logger
+	validator *validation.CustomValidator
+}
+
+// NewWebhookHandler creates a new instance of WebhookHandler
+func NewWebhookHandler(payments services.CreditPaymentServiceInterface, refunds services.RefundServiceInterface, webhooks services.InboundWebhookServiceInterface, logger *zap.Logger, validator *validation.CustomValidator) *WebhookHandler {
+	return &WebhookHandler{
+		payments:  payments,
+		refunds:   refunds,
+		webhooks:  webhooks,
+		logger:    logger,
+		validator: validator,
+	}
+}
+
+// HandlePaymentWebhook processes a payment status webhook
+func (h *WebhookHandler) HandlePaymentWebhook(c echo.Context) error {
+	return h.receive(c, services.WebhookSourcePayment, func(ctx context.Context, id uint, status string) error {
+		return h.payments.HandlePaymentWebhook(ctx, id, status)
+	})
+}
+
+// HandleInstallmentWebhook processes an installment status webhook
+func (h *WebhookHandler) HandleInstallmentWebhook(c echo.Context) error {
+	return h.receive(c, services.WebhookSourceInstallment, func(ctx context.Context, id uint, status string) error {
+		return h.payments.HandleInstallmentWebhook(ctx, id, status)
+	})
+}
+
+// HandleRefundWebhook processes the outcome of a refund's card refunds
+func (h *WebhookHandler) HandleRefundWebhook(c echo.Context) error {
+	return h.receive(c, services.WebhookSourceRefund, func(ctx context.Context, id uint, status string) error {
+		return h.refunds.HandleRefundWebhook(ctx, id, status)
+	})
+}
+
+// ListInboundWebhooks lists logged webhook deliveries, newest first
+func (h *WebhookHandler) ListInboundWebhooks(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	entries, total, err := h.webhooks.ListLogs(ctx, c.QueryParam("source"), offset, limit)
+	if err != nil {
+		return h.handleError(c, err, "failed to list inbound webhooks")
+	}
+
+	resp := dto.InboundWebhookLogListResponse{
+		Webhooks: make([]dto.InboundWebhookLogResponse, len(entries)),
+		Total:    total,
+	}
+	for i, entry := range entries {
+		resp.Webhooks[i] = h.createLogResponse(entry)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// receive verifies and deduplicates a delivery before applying its status
+// with apply.
+func (h *WebhookHandler) receive(c echo.Context, source string, apply func(ctx context.Context, id uint, status string) error) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid %s ID", source)})
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+	}
+
+	delivery := services.InboundWebhook{
+		Source:     source,
+		ResourceID: id,
+		Signature:  c.Request().Header.Get(services.WebhookSignatureHeader),
+		Timestamp:  c.Request().Header.Get(services.WebhookTimestampHeader),
+		Body:       body,
+	}
+
+	duplicate, err := h.webhooks.Receive(ctx, delivery, func(ctx context.Context) error {
+		var req dto.WebhookStatusRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("%w: %v", services.ErrInvalidWebhookPayload, err)
+		}
+		if err := h.validator.Validate(req); err != nil {
+			return fmt.Errorf("%w: %v", services.ErrInvalidWebhookPayload, err)
+		}
+		h.logger.Info("Webhook received", zap.String("source", source), zap.Uint("id", id), zap.Any("request", req))
+		return apply(ctx, id, req.Status)
+	})
+	if err != nil {
+		return h.handleError(c, err, "failed to process webhook")
+	}
+
+	if duplicate {
+		return c.JSON(http.StatusOK, map[string]string{"message": "Webhook already processed"})
+	}
+	h.logger.Info("Webhook processed successfully", zap.String("source", source), zap.Uint("id", id))
+	return c.JSON(http.StatusOK, map[string]string{"message": "Webhook processed successfully"})
+}
+
+func (h *WebhookHandler) handleError(c echo.Context, err error, message string) error {
+	h.logger.Error(message, zap.Error(err))
+	return writeError(c, err)
+}
+
+func (h *WebhookHandler) createLogResponse(entry *domains.InboundWebhookLog) dto.InboundWebhookLogResponse {
+	return dto.InboundWebhookLogResponse{
+		ID:          entry.ID,
+		Source:      entry.Source,
+		ResourceID:  entry.ResourceID,
+		EventID:     entry.EventID,
+		Payload:     entry.Payload,
+		Status:      string(entry.Status),
+		Error:       entry.Error,
+		ReceivedAt:  entry.ReceivedAt,
+		ProcessedAt: entry.ProcessedAt,
+	}
+}
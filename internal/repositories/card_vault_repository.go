@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type cardVaultRepository struct {
+	db *gorm.DB
+}
+
+// NewCardVaultRepository creates a new instance of CardVaultRepository
+func NewCardVaultRepository(db *gorm.DB) CardVaultRepository {
+	return &cardVaultRepository{db: db}
+}
+
+func (r *cardVaultRepository) Create(ctx context.Context, entry *domains.CardVaultEntry) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(entry).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *cardVaultRepository) GetByToken(ctx context.Context, token string) (*domains.CardVaultEntry, error) {
+	var entry domains.CardVaultEntry
+	if err := utils.DBFromContext(ctx, r.db).Where("token = ?", token).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "Card", ID: token}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &entry, nil
+}
+
+// GetByFingerprint finds a card the user has already tokenized with the same
+// number and expiry date.
+func (r *cardVaultRepository) GetByFingerprint(ctx context.Context, userID, fingerprint, expiryDate string) (*domains.CardVaultEntry, error) {
+	var entry domains.CardVaultEntry
+	err := utils.DBFromContext(ctx, r.db).
+		Where("user_id = ? AND fingerprint = ? AND expiry_date = ?", userID, fingerprint, expiryDate).
+		First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "Card", ID: fingerprint}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &entry, nil
+}
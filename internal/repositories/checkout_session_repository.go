@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type checkoutSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewCheckoutSessionRepository creates a new instance of CheckoutSessionRepository
+func NewCheckoutSessionRepository(db *gorm.DB) CheckoutSessionRepository {
+	return &checkoutSessionRepository{db: db}
+}
+
+func (r *checkoutSessionRepository) Create(ctx context.Context, session *domains.CheckoutSession) error {
+	err := utils.DBFromContext(ctx, r.db).Create(session).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "CheckoutSession", Field: "id", Value: session.ID}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *checkoutSessionRepository) GetByID(ctx context.Context, id string) (*domains.CheckoutSession, error) {
+	var session domains.CheckoutSession
+	err := utils.DBFromContext(ctx, r.db).Where("id = ?", id).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "CheckoutSession", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &session, nil
+}
+
+func (r *checkoutSessionRepository) GetByPaymentID(ctx context.Context, paymentID uint) (*domains.CheckoutSession, error) {
+	var session domains.CheckoutSession
+	err := utils.DBFromContext(ctx, r.db).Where("payment_id = ?", paymentID).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "CheckoutSession", ID: paymentID}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &session, nil
+}
+
+// StartPayment moves an open session that has not expired by now to PENDING
+// for the customer paying it. It fails with *utils.ErrInvalidTransition
+// otherwise, so only one payment is made for a session at a time.
+func (r *checkoutSessionRepository) StartPayment(ctx context.Context, id, userID string, now time.Time) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.CheckoutSession{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, domains.CheckoutOpen, now).
+		Updates(map[string]interface{}{"status": domains.CheckoutPending, "user_id": userID})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "CheckoutSession", ID: id, From: string(domains.CheckoutOpen), To: string(domains.CheckoutPending)}
+	}
+	return nil
+}
+
+func (r *checkoutSessionRepository) SetPayment(ctx context.Context, id string, paymentID uint) error {
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.CheckoutSession{}).
+		Where("id = ?", id).
+		Update("payment_id", paymentID).Error
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// UpdateStatus moves a session to status to, provided its current status is
+// one of from. It fails with *utils.ErrInvalidTransition otherwise. Completion
+// is timestamped.
+func (r *checkoutSessionRepository) UpdateStatus(ctx context.Context, id string, to domains.CheckoutSessionStatus, from ...domains.CheckoutSessionStatus) error {
+	updates := map[string]interface{}{"status": to}
+	if to == domains.CheckoutCompleted {
+		updates["completed_at"] = gorm.Expr("NOW()")
+	}
+
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.CheckoutSession{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		names := make([]string, len(from))
+		for i, status := range from {
+			names[i] = string(status)
+		}
+		return &utils.ErrInvalidTransition{Entity: "CheckoutSession", ID: id, From: strings.Join(names, "|"), To: string(to)}
+	}
+	return nil
+}
+
+// ListExpired returns the sessions whose expiry is before now, oldest first:
+// open ones, and pending ones whose payment is gone, failed or expired without
+// the session being told.
+func (r *checkoutSessionRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]*domains.CheckoutSession, error) {
+	var sessions []*domains.CheckoutSession
+	err := utils.DBFromContext(ctx, r.db).
+		Where("expires_at <= ?", now).
+		Where("status = ? OR (status = ? AND (payment_id IS NULL OR EXISTS (SELECT 1 FROM payments p WHERE p.id = checkout_sessions.payment_id AND p.status IN ?)))",
+			domains.CheckoutOpen, domains.CheckoutPending, []string{"FAILED", "EXPIRED"}).
+		Order("expires_at").
+		Limit(limit).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return sessions, nil
+}
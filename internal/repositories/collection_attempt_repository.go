@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type collectionAttemptRepository struct {
+	db *gorm.DB
+}
+
+// NewCollectionAttemptRepository creates a new instance of CollectionAttemptRepository
+func NewCollectionAttemptRepository(db *gorm.DB) CollectionAttemptRepository {
+	return &collectionAttemptRepository{db: db}
+}
+
+// Create records a new attempt. It fails with *utils.ErrDuplicateEntry while
+// another attempt for the same installment is in progress.
+func (r *collectionAttemptRepository) Create(ctx context.Context, attempt *domains.CollectionAttempt) error {
+	err := utils.DBFromContext(ctx, r.db).Create(attempt).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "CollectionAttempt", Field: "installment_id", Value: attempt.InstallmentID}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *collectionAttemptRepository) Update(ctx context.Context, attempt *domains.CollectionAttempt) error {
+	if err := utils.DBFromContext(ctx, r.db).Save(attempt).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *collectionAttemptRepository) ListByInstallmentID(ctx context.Context, installmentID uint) ([]*domains.CollectionAttempt, error) {
+	var attempts []*domains.CollectionAttempt
+	err := utils.DBFromContext(ctx, r.db).
+		Where("installment_id = ?", installmentID).
+		Order("started_at").
+		Find(&attempts).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return attempts, nil
+}
+
+// ListStale returns attempts still in progress that started before
+// startedBefore, oldest first.
+func (r *collectionAttemptRepository) ListStale(ctx context.Context, startedBefore time.Time, limit int) ([]*domains.CollectionAttempt, error) {
+	var attempts []*domains.CollectionAttempt
+	err := utils.DBFromContext(ctx, r.db).
+		Where("status = ? AND started_at < ?", domains.CollectionAttemptInProgress, startedBefore).
+		Order("started_at").
+		Limit(limit).
+		Find(&attempts).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return attempts, nil
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type collectionCaseRepository struct {
+	db *gorm.DB
+}
+
+// NewCollectionCaseRepository creates a new instance of CollectionCaseRepository
+func NewCollectionCaseRepository(db *gorm.DB) CollectionCaseRepository {
+	return &collectionCaseRepository{db: db}
+}
+
+// Create opens a case. It fails with *utils.ErrDuplicateEntry if the
+// installment already has one.
+func (r *collectionCaseRepository) Create(ctx context.Context, collectionCase *domains.CollectionCase) error {
+	result := utils.DBFromContext(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "installment_id"}}, DoNothing: true}).
+		Create(collectionCase)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrDuplicateEntry{Entity: "CollectionCase", Field: "installment_id", Value: collectionCase.InstallmentID}
+	}
+	return nil
+}
+
+// Resolve closes the open case of an installment, if it has one.
+func (r *collectionCaseRepository) Resolve(ctx context.Context, installmentID uint, resolvedAt time.Time) error {
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.CollectionCase{}).
+		Where("installment_id = ? AND status = ?", installmentID, domains.CollectionCaseOpen).
+		Updates(map[string]interface{}{
+			"status":      domains.CollectionCaseResolved,
+			"resolved_at": resolvedAt,
+		}).Error
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// CountOpenByUserID returns how many cases of a user are still open.
+func (r *collectionCaseRepository) CountOpenByUserID(ctx context.Context, userID string) (int, error) {
+	var count int64
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.CollectionCase{}).
+		Where("user_id = ? AND status = ?", userID, domains.CollectionCaseOpen).
+		Count(&count).Error
+	if err != nil {
+		return 0, &utils.ErrDatabase{Err: err}
+	}
+	return int(count), nil
+}
+
+func (r *collectionCaseRepository) List(ctx context.Context, status domains.CollectionCaseStatus, offset, limit int) ([]*domains.CollectionCase, int, error) {
+	var cases []*domains.CollectionCase
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.CollectionCase{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	if err := query.Order("opened_at ASC").Offset(offset).Limit(limit).Find(&cases).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return cases, int(total), nil
+}
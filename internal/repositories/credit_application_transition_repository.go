@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type creditApplicationTransitionRepository struct {
+	db *gorm.DB
+}
+
+// NewCreditApplicationTransitionRepository creates a new instance of CreditApplicationTransitionRepository
+func NewCreditApplicationTransitionRepository(db *gorm.DB) CreditApplicationTransitionRepository {
+	return &creditApplicationTransitionRepository{db: db}
+}
+
+func (r *creditApplicationTransitionRepository) Create(ctx context.Context, transition *domains.CreditApplicationTransition) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(transition).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *creditApplicationTransitionRepository) GetByCreditApplicationID(ctx context.Context, creditApplicationID uint) ([]*domains.CreditApplicationTransition, error) {
+	var transitions []*domains.CreditApplicationTransition
+	err := utils.DBFromContext(ctx, r.db).
+		Where("credit_application_id = ?", creditApplicationID).
+		Order("transitioned_at ASC, id ASC").
+		Find(&transitions).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return transitions, nil
+}
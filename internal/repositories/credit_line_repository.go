@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type creditLineRepository struct {
+	db *gorm.DB
+}
+
+// NewCreditLineRepository creates a new instance of CreditLineRepository
+func NewCreditLineRepository(db *gorm.DB) CreditLineRepository {
+	return &creditLineRepository{db: db}
+}
+
+func (r *creditLineRepository) Create(ctx context.Context, line *domains.CreditLine) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(line).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// Open creates the user's credit line, or raises the limit of the line they
+// already have to line.CreditLimit if that is higher, in one statement so
+// concurrent approvals for the same user cannot collide. A raised line gets
+// a new version. line is reloaded either way.
+func (r *creditLineRepository) Open(ctx context.Context, line *domains.CreditLine) error {
+	err := utils.DBFromContext(ctx, r.db).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "credit_limit"}, Value: gorm.Expr("GREATEST(credit_lines.credit_limit, EXCLUDED.credit_limit)")},
+				{Column: clause.Column{Name: "version"}, Value: gorm.Expr("credit_lines.version + 1")},
+				{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("NOW()")},
+			},
+		}).
+		Create(line).Error
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	if err := utils.DBFromContext(ctx, r.db).Where("user_id = ?", line.UserID).First(line).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *creditLineRepository) GetByUserID(ctx context.Context, userID string) (*domains.CreditLine, error) {
+	var line domains.CreditLine
+	if err := utils.DBFromContext(ctx, r.db).Where("user_id = ?", userID).First(&line).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "CreditLine", ID: userID}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &line, nil
+}
+
+func (r *creditLineRepository) Update(ctx context.Context, line *domains.CreditLine) error {
+	if err := utils.DBFromContext(ctx, r.db).Save(line).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// UpdateVersioned saves line only if its stored version still matches
+// line.Version, and bumps the version on success.
+func (r *creditLineRepository) UpdateVersioned(ctx context.Context, line *domains.CreditLine) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.CreditLine{}).
+		Where("id = ? AND version = ?", line.ID, line.Version).
+		Updates(map[string]interface{}{
+			"credit_limit": line.CreditLimit,
+			"utilized":     line.Utilized,
+			"reserved":     line.Reserved,
+			"status":       line.Status,
+			"version":      line.Version + 1,
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrVersionConflict{Entity: "CreditLine", ID: line.ID}
+	}
+	line.Version++
+	return nil
+}
+
+// Adjust atomically moves the utilized and reserved amounts of a user's line
+// by the given deltas, never letting either go below zero.
+func (r *creditLineRepository) Adjust(ctx context.Context, userID string, utilizedDelta, reservedDelta int) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.CreditLine{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{
+			"utilized": gorm.Expr("GREATEST(utilized + ?, 0)", utilizedDelta),
+			"reserved": gorm.Expr("GREATEST(reserved + ?, 0)", reservedDelta),
+			"version":  gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrNotFound{Entity: "CreditLine", ID: userID}
+	}
+	return nil
+}
+
+// SetStatus freezes or reactivates the user's credit line.
+func (r *creditLineRepository) SetStatus(ctx context.Context, userID string, status domains.CreditLineStatus) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.CreditLine{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{
+			"status":  status,
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrNotFound{Entity: "CreditLine", ID: userID}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to the Postgres database at TEST_DSN. Tests that need it
+// are skipped when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DSN not set; skipping Postgres repository test")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect to %s: %v", dsn, err)
+	}
+	if err := db.AutoMigrate(&domains.CreditLine{}); err != nil {
+		t.Fatalf("migrate credit lines: %v", err)
+	}
+	return db
+}
+
+// newTestCreditLine stores an active line for a fresh user and removes it
+// when the test ends.
+func newTestCreditLine(t *testing.T, db *gorm.DB, limit int) *domains.CreditLine {
+	t.Helper()
+	line := &domains.CreditLine{
+		UserID:      uuid.Must(uuid.NewV4()).String(),
+		Currency:    "DZD",
+		CreditLimit: limit,
+		Status:      domains.CreditLineActive,
+	}
+	if err := NewCreditLineRepository(db).Create(context.Background(), line); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Unscoped().Where("user_id = ?", line.UserID).Delete(&domains.CreditLine{})
+	})
+	return line
+}
+
+func TestCreditLineUpdateVersionedRejectsStaleVersion(t *testing.T) {
+	db := testDB(t)
+	repo := NewCreditLineRepository(db)
+	ctx := context.Background()
+	line := newTestCreditLine(t, db, 5000)
+
+	first, err := repo.GetByUserID(ctx, line.UserID)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	stale, err := repo.GetByUserID(ctx, line.UserID)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+
+	first.Reserved = 1000
+	if err := repo.UpdateVersioned(ctx, first); err != nil {
+		t.Fatalf("UpdateVersioned: %v", err)
+	}
+	stale.Reserved = 4000
+	var conflictErr *utils.ErrVersionConflict
+	if err := repo.UpdateVersioned(ctx, stale); !errors.As(err, &conflictErr) {
+		t.Fatalf("update from a stale read: got %v, want *utils.ErrVersionConflict", err)
+	}
+
+	stored, err := repo.GetByUserID(ctx, line.UserID)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if stored.Reserved != 1000 || stored.Version != first.Version {
+		t.Fatalf("line holds %d at version %d, want 1000 at version %d", stored.Reserved, stored.Version, first.Version)
+	}
+}
+
+func TestCreditLineAdjustIsAtomicUnderConcurrency(t *testing.T) {
+	db := testDB(t)
+	repo := NewCreditLineRepository(db)
+	ctx := context.Background()
+	line := newTestCreditLine(t, db, 50000)
+
+	const adjustments = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, adjustments)
+	for i := 0; i < adjustments; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.Adjust(ctx, line.UserID, 100, 50)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Adjust: %v", err)
+		}
+	}
+
+	stored, err := repo.GetByUserID(ctx, line.UserID)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if stored.Utilized != adjustments*100 || stored.Reserved != adjustments*50 || stored.Version != line.Version+adjustments {
+		t.Fatalf("line utilized %d, reserved %d at version %d; want %d, %d at version %d",
+			stored.Utilized, stored.Reserved, stored.Version, adjustments*100, adjustments*50, line.Version+adjustments)
+	}
+
+	if err := repo.Adjust(ctx, line.UserID, -5000, -5000); err != nil {
+		t.Fatalf("Adjust: %v", err)
+	}
+	if stored, _ = repo.GetByUserID(ctx, line.UserID); stored.Utilized != 0 || stored.Reserved != 0 {
+		t.Fatalf("line utilized %d, reserved %d after releasing more than held; want 0, 0", stored.Utilized, stored.Reserved)
+	}
+
+	var notFoundErr *utils.ErrNotFound
+	if err := repo.Adjust(ctx, uuid.Must(uuid.NewV4()).String(), 100, 0); !errors.As(err, &notFoundErr) {
+		t.Fatalf("adjusting a missing line: got %v, want *utils.ErrNotFound", err)
+	}
+}
@@ -19,7 +19,7 @@ func NewCreditApplicationRepository(db *gorm.DB) CreditApplicationRepository {
 }
 
 func (r *creditApplicationRepository) Create(ctx context.Context, app *domains.CreditApplication) error {
-	err := r.db.WithContext(ctx).Create(app).Error
+	err := utils.DBFromContext(ctx, r.db).Create(app).Error
 	if err != nil {
 		return &utils.ErrDatabase{Err: err}
 	}
@@ -28,7 +28,7 @@ func (r *creditApplicationRepository) Create(ctx context.Context, app *domains.C
 
 func (r *creditApplicationRepository) GetByID(ctx context.Context, id uint) (*domains.CreditApplication, error) {
 	var app domains.CreditApplication
-	if err := r.db.WithContext(ctx).First(&app, id).Error; err != nil {
+	if err := utils.DBFromContext(ctx, r.db).First(&app, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, &utils.ErrNotFound{Entity: "CreditApplication", ID: id}
 		}
@@ -37,24 +37,40 @@ func (r *creditApplicationRepository) GetByID(ctx context.Context, id uint) (*do
 	return &app, nil
 }
 
+// Update saves every field except the status, which only changes through UpdateStatus
 func (r *creditApplicationRepository) Update(ctx context.Context, app *domains.CreditApplication) error {
-	return r.db.WithContext(ctx).Save(app).Error
+	return utils.DBFromContext(ctx, r.db).Omit("Status").Save(app).Error
+}
+
+// UpdateStatus moves the application from one status to another. It fails with
+// ErrInvalidTransition if the stored status is no longer from.
+func (r *creditApplicationRepository) UpdateStatus(ctx context.Context, id uint, from, to domains.CreditApplicationStatus) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.CreditApplication{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "CreditApplication", ID: id, From: string(from), To: string(to)}
+	}
+	return nil
 }
 
 func (r *creditApplicationRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&domains.CreditApplication{}, id).Error
+	return utils.DBFromContext(ctx, r.db).Delete(&domains.CreditApplication{}, id).Error
 }
 
 func (r *creditApplicationRepository) List(ctx context.Context, offset, limit int) ([]*domains.CreditApplication, int, error) {
 	var apps []*domains.CreditApplication
 	var total int64
 
-	err := r.db.WithContext(ctx).Model(&domains.CreditApplication{}).Count(&total).Error
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.CreditApplication{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = r.db.WithContext(ctx).Offset(offset).Limit(limit).Find(&apps).Error
+	err = utils.DBFromContext(ctx, r.db).Offset(offset).Limit(limit).Find(&apps).Error
 	if err != nil {
 		return nil, 0, err
 	}
@@ -64,6 +80,6 @@ func (r *creditApplicationRepository) List(ctx context.Context, offset, limit in
 
 func (r *creditApplicationRepository) GetByUserID(ctx context.Context, userID string) ([]*domains.CreditApplication, error) {
 	var apps []*domains.CreditApplication
-	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&apps).Error
+	err := utils.DBFromContext(ctx, r.db).Where("user_id = ?", userID).Find(&apps).Error
 	return apps, err
 }
\ No newline at end of file
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"context"
+	"time"
+
 	"github.com/mohamed2394/sahla/internal/domains"
 )
 
@@ -10,11 +12,18 @@ type CreditApplicationRepository interface {
 	Create(ctx context.Context, app *domains.CreditApplication) error
 	GetByID(ctx context.Context, id uint) (*domains.CreditApplication, error)
 	Update(ctx context.Context, app *domains.CreditApplication) error
+	UpdateStatus(ctx context.Context, id uint, from, to domains.CreditApplicationStatus) error
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context, offset, limit int) ([]*domains.CreditApplication, int, error)
 	GetByUserID(ctx context.Context, userID string) ([]*domains.CreditApplication, error)
 }
 
+// CreditApplicationTransitionRepository defines the interface for credit application status history
+type CreditApplicationTransitionRepository interface {
+	Create(ctx context.Context, transition *domains.CreditApplicationTransition) error
+	GetByCreditApplicationID(ctx context.Context, creditApplicationID uint) ([]*domains.CreditApplicationTransition, error)
+}
+
 // PaymentRepository defines the interface for payment data access
 type PaymentRepository interface {
 	Create(ctx context.Context, payment *domains.Payment) error
@@ -24,6 +33,11 @@ type PaymentRepository interface {
 	List(ctx context.Context, offset, limit int) ([]*domains.Payment, int, error)
 	GetByCreditApplicationID(ctx context.Context, creditApplicationID uint) ([]*domains.Payment, error)
 	GetByOrderID(ctx context.Context, orderID string) (*domains.Payment, error)
+	GetByUserID(ctx context.Context, userID string) ([]*domains.Payment, error)
+	GetByGatewayOrderID(ctx context.Context, gatewayOrderID string) (*domains.Payment, error)
+	UpdateStatus(ctx context.Context, id uint, to string, from ...string) error
+	AddRefunded(ctx context.Context, id uint, amount int) error
+	ListByMerchantID(ctx context.Context, merchantID uint, offset, limit int) ([]*domains.Payment, int, error)
 }
 
 // InstallmentRepository defines the interface for installment data access
@@ -34,4 +48,232 @@ type InstallmentRepository interface {
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context, offset, limit int) ([]*domains.Installment, int, error)
 	GetByPaymentID(ctx context.Context, paymentID uint) ([]*domains.Installment, error)
-}
\ No newline at end of file
+	GetByGatewayOrderID(ctx context.Context, gatewayOrderID string) (*domains.Installment, error)
+	CreateBatch(ctx context.Context, installments []domains.Installment) error
+	UpdateStatus(ctx context.Context, id uint, to string, from ...string) error
+	ListDue(ctx context.Context, dueOn string, now, openedAfter time.Time, limit int) ([]*domains.Installment, error)
+	ListDueBefore(ctx context.Context, statuses []string, dueBefore string, limit int) ([]*domains.Installment, error)
+	RecordFailure(ctx context.Context, installment *domains.Installment) error
+	ListByStatus(ctx context.Context, statuses []string, afterID uint, limit int) ([]*domains.Installment, error)
+	OpenOrder(ctx context.Context, installment *domains.Installment) error
+	BeingCollected(ctx context.Context, id uint) (bool, error)
+	ApplyPrepayment(ctx context.Context, line domains.PrepaymentLine) error
+	ApplyRefund(ctx context.Context, line domains.RefundLine) error
+}
+
+// ManualReviewRepository defines the interface for the manual underwriting review queue
+type ManualReviewRepository interface {
+	Create(ctx context.Context, review *domains.ManualReview) error
+	GetByID(ctx context.Context, id uint) (*domains.ManualReview, error)
+	GetByCreditApplicationID(ctx context.Context, creditApplicationID uint) (*domains.ManualReview, error)
+	Update(ctx context.Context, review *domains.ManualReview) error
+	Claim(ctx context.Context, id uint, reviewerID string, claimedAt time.Time) error
+	List(ctx context.Context, status domains.ManualReviewStatus, offset, limit int) ([]*domains.ManualReview, int, error)
+}
+
+// CreditLineRepository defines the interface for per-user credit line data access
+type CreditLineRepository interface {
+	Create(ctx context.Context, line *domains.CreditLine) error
+	Open(ctx context.Context, line *domains.CreditLine) error
+	GetByUserID(ctx context.Context, userID string) (*domains.CreditLine, error)
+	Update(ctx context.Context, line *domains.CreditLine) error
+	UpdateVersioned(ctx context.Context, line *domains.CreditLine) error
+	Adjust(ctx context.Context, userID string, utilizedDelta, reservedDelta int) error
+	SetStatus(ctx context.Context, userID string, status domains.CreditLineStatus) error
+}
+
+// CreditReservationRepository defines the interface for credit reservation data access
+type CreditReservationRepository interface {
+	Create(ctx context.Context, reservation *domains.CreditReservation) error
+	GetByPaymentID(ctx context.Context, paymentID uint) (*domains.CreditReservation, error)
+	Settle(ctx context.Context, id uint, status domains.CreditReservationStatus) (bool, error)
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]*domains.CreditReservation, error)
+}
+
+// IdempotencyRepository defines the interface for idempotency key storage
+type IdempotencyRepository interface {
+	Create(ctx context.Context, record *domains.IdempotencyRecord) error
+	Get(ctx context.Context, userID, key string) (*domains.IdempotencyRecord, error)
+	Complete(ctx context.Context, id uint, responseCode int, responseBody []byte) error
+	Delete(ctx context.Context, id uint) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// InboundWebhookLogRepository defines the interface for the inbound webhook log
+type InboundWebhookLogRepository interface {
+	Create(ctx context.Context, entry *domains.InboundWebhookLog) error
+	Update(ctx context.Context, entry *domains.InboundWebhookLog) error
+	List(ctx context.Context, source string, offset, limit int) ([]*domains.InboundWebhookLog, int, error)
+}
+
+// ProcessedWebhookEventRepository defines the interface for deduplicating webhook events
+type ProcessedWebhookEventRepository interface {
+	Create(ctx context.Context, event *domains.ProcessedWebhookEvent) error
+}
+
+// CardVaultRepository defines the interface for tokenized card storage
+type CardVaultRepository interface {
+	Create(ctx context.Context, entry *domains.CardVaultEntry) error
+	GetByToken(ctx context.Context, token string) (*domains.CardVaultEntry, error)
+	GetByFingerprint(ctx context.Context, userID, fingerprint, expiryDate string) (*domains.CardVaultEntry, error)
+}
+
+// PlanProductRepository defines the interface for the installment plan catalog
+type PlanProductRepository interface {
+	Create(ctx context.Context, plan *domains.PlanProduct) error
+	GetByID(ctx context.Context, id uint) (*domains.PlanProduct, error)
+	Update(ctx context.Context, plan *domains.PlanProduct) error
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context, activeOnly bool) ([]*domains.PlanProduct, error)
+}
+
+// JobLeaseRepository defines the interface for the schedule and leases of background jobs
+type JobLeaseRepository interface {
+	Acquire(ctx context.Context, name, owner string, now, leaseUntil time.Time) (bool, error)
+	Finish(ctx context.Context, name, owner string, finishedAt, nextRunAt time.Time, lastError string) error
+}
+
+// CollectionAttemptRepository defines the interface for installment collection attempts
+type CollectionAttemptRepository interface {
+	Create(ctx context.Context, attempt *domains.CollectionAttempt) error
+	Update(ctx context.Context, attempt *domains.CollectionAttempt) error
+	ListByInstallmentID(ctx context.Context, installmentID uint) ([]*domains.CollectionAttempt, error)
+	ListStale(ctx context.Context, startedBefore time.Time, limit int) ([]*domains.CollectionAttempt, error)
+}
+
+// CollectionCaseRepository defines the interface for accounts flagged for collections
+type CollectionCaseRepository interface {
+	Create(ctx context.Context, collectionCase *domains.CollectionCase) error
+	Resolve(ctx context.Context, installmentID uint, resolvedAt time.Time) error
+	CountOpenByUserID(ctx context.Context, userID string) (int, error)
+	List(ctx context.Context, status domains.CollectionCaseStatus, offset, limit int) ([]*domains.CollectionCase, int, error)
+}
+
+// NotificationRepository defines the interface for customer notifications
+type NotificationRepository interface {
+	Create(ctx context.Context, notification *domains.Notification) error
+	ListByUserID(ctx context.Context, userID string, offset, limit int) ([]*domains.Notification, int, error)
+}
+
+// InstallmentChargeRepository defines the interface for late fees and other installment charges
+type InstallmentChargeRepository interface {
+	Create(ctx context.Context, charge *domains.InstallmentCharge) error
+	GetByID(ctx context.Context, id uint) (*domains.InstallmentCharge, error)
+	ListByInstallmentID(ctx context.Context, installmentID uint) ([]*domains.InstallmentCharge, error)
+	ListByPaymentID(ctx context.Context, paymentID uint) ([]*domains.InstallmentCharge, error)
+	ListByGatewayOrderID(ctx context.Context, gatewayOrderID string) ([]*domains.InstallmentCharge, error)
+	BillOrder(ctx context.Context, ids []uint, gatewayOrderID string) error
+	MarkPaid(ctx context.Context, id uint, gatewayOrderID string, paidAt time.Time) error
+	Waive(ctx context.Context, id uint, gatewayOrderID, waivedBy, reason string, waivedAt time.Time) error
+}
+
+// PrepaymentRepository defines the interface for early payoffs and partial prepayments
+type PrepaymentRepository interface {
+	Create(ctx context.Context, prepayment *domains.Prepayment) error
+	GetByGatewayOrderID(ctx context.Context, gatewayOrderID string) (*domains.Prepayment, error)
+	Update(ctx context.Context, prepayment *domains.Prepayment) error
+	UpdateStatus(ctx context.Context, id uint, to domains.PrepaymentStatus, from ...domains.PrepaymentStatus) error
+	ListUnrefunded(ctx context.Context, limit int) ([]*domains.Prepayment, error)
+	MarkUnappliedRefunded(ctx context.Context, id uint, refundedAt time.Time) error
+}
+
+// PaymentReceiptRepository defines the interface for card payments received towards a payment
+type PaymentReceiptRepository interface {
+	Create(ctx context.Context, receipt *domains.PaymentReceipt) error
+	ListByPaymentID(ctx context.Context, paymentID uint) ([]*domains.PaymentReceipt, error)
+	AddRefunded(ctx context.Context, id uint, amount int) error
+	ListUnrefundedOverpayments(ctx context.Context, limit int) ([]*domains.PaymentReceipt, error)
+}
+
+// RefundRepository defines the interface for refunds of returned purchases
+type RefundRepository interface {
+	Create(ctx context.Context, refund *domains.Refund) error
+	GetByID(ctx context.Context, id uint) (*domains.Refund, error)
+	ListByPaymentID(ctx context.Context, paymentID uint) ([]*domains.Refund, error)
+	List(ctx context.Context, status domains.RefundStatus, offset, limit int) ([]*domains.Refund, int, error)
+	Update(ctx context.Context, refund *domains.Refund) error
+	UpdateStatus(ctx context.Context, id uint, to domains.RefundStatus, from ...domains.RefundStatus) error
+}
+
+// LedgerRepository defines the interface for the double-entry ledger. Posted
+// journals cannot be changed, so it has no update or delete.
+type LedgerRepository interface {
+	CreateJournal(ctx context.Context, journal *domains.Journal) error
+	ListJournals(ctx context.Context, paymentID uint, offset, limit int) ([]*domains.Journal, int, error)
+	Balances(ctx context.Context, asOf time.Time, userID string, paymentID uint) ([]domains.AccountBalance, error)
+}
+
+// MerchantRepository defines the interface for merchants and their stores
+type MerchantRepository interface {
+	Create(ctx context.Context, merchant *domains.Merchant) error
+	GetByID(ctx context.Context, id uint) (*domains.Merchant, error)
+	List(ctx context.Context, status domains.MerchantStatus, offset, limit int) ([]*domains.Merchant, int, error)
+	Update(ctx context.Context, merchant *domains.Merchant) error
+	UpdateStatus(ctx context.Context, id uint, to domains.MerchantStatus, from ...domains.MerchantStatus) error
+	CreateStore(ctx context.Context, store *domains.Store) error
+	GetStore(ctx context.Context, merchantID, storeID uint) (*domains.Store, error)
+	GetStoreByID(ctx context.Context, storeID uint) (*domains.Store, error)
+	ListStores(ctx context.Context, merchantID uint) ([]*domains.Store, error)
+	UpdateStore(ctx context.Context, store *domains.Store) error
+}
+
+// MerchantAPIKeyRepository defines the interface for merchant API credentials
+type MerchantAPIKeyRepository interface {
+	Create(ctx context.Context, key *domains.MerchantAPIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (*domains.MerchantAPIKey, error)
+	ListByMerchantID(ctx context.Context, merchantID uint) ([]*domains.MerchantAPIKey, error)
+	Revoke(ctx context.Context, merchantID, id uint, revokedAt time.Time) error
+	TouchLastUsed(ctx context.Context, id uint, usedAt time.Time) error
+}
+
+// CheckoutSessionRepository defines the interface for hosted checkout sessions
+type CheckoutSessionRepository interface {
+	Create(ctx context.Context, session *domains.CheckoutSession) error
+	GetByID(ctx context.Context, id string) (*domains.CheckoutSession, error)
+	GetByPaymentID(ctx context.Context, paymentID uint) (*domains.CheckoutSession, error)
+	StartPayment(ctx context.Context, id, userID string, now time.Time) error
+	SetPayment(ctx context.Context, id string, paymentID uint) error
+	UpdateStatus(ctx context.Context, id string, to domains.CheckoutSessionStatus, from ...domains.CheckoutSessionStatus) error
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]*domains.CheckoutSession, error)
+}
+
+// MerchantEventRepository defines the interface for the events owed to merchants
+type MerchantEventRepository interface {
+	Create(ctx context.Context, event *domains.MerchantEvent) error
+	GetByID(ctx context.Context, id uint) (*domains.MerchantEvent, error)
+	ListByMerchantID(ctx context.Context, merchantID uint, offset, limit int) ([]*domains.MerchantEvent, int, error)
+}
+
+// SettlementRepository defines the interface for merchant payout batches and
+// the bank files they are sent in
+type SettlementRepository interface {
+	ListUnsettled(ctx context.Context, before time.Time) ([]domains.UnsettledMovement, error)
+	CreateBatch(ctx context.Context, batch *domains.SettlementBatch) error
+	GetBatch(ctx context.Context, id uint) (*domains.SettlementBatch, error)
+	ListBatches(ctx context.Context, merchantID uint, status domains.SettlementBatchStatus, offset, limit int) ([]*domains.SettlementBatch, int, error)
+	ListByStatus(ctx context.Context, statuses ...domains.SettlementBatchStatus) ([]*domains.SettlementBatch, error)
+	UpdateStatus(ctx context.Context, id uint, to domains.SettlementBatchStatus, changes map[string]interface{}, from ...domains.SettlementBatchStatus) error
+	CreateBankFile(ctx context.Context, file *domains.BankFile) error
+	GetBankFile(ctx context.Context, id uint) (*domains.BankFile, error)
+}
+
+// WebhookEndpointRepository defines the interface for the URLs merchants
+// receive their events at
+type WebhookEndpointRepository interface {
+	Create(ctx context.Context, endpoint *domains.WebhookEndpoint) error
+	GetByID(ctx context.Context, id uint) (*domains.WebhookEndpoint, error)
+	ListByMerchantID(ctx context.Context, merchantID uint) ([]*domains.WebhookEndpoint, error)
+	Disable(ctx context.Context, merchantID, id uint, disabledAt time.Time) error
+}
+
+// WebhookDeliveryRepository defines the interface for event deliveries to
+// webhook endpoints and their attempt log
+type WebhookDeliveryRepository interface {
+	Create(ctx context.Context, delivery *domains.WebhookDelivery) error
+	GetByID(ctx context.Context, id uint) (*domains.WebhookDelivery, error)
+	ListByMerchantID(ctx context.Context, merchantID, endpointID uint, status domains.WebhookDeliveryStatus, offset, limit int) ([]*domains.WebhookDelivery, int, error)
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*domains.WebhookDelivery, error)
+	CreateAttempt(ctx context.Context, attempt *domains.WebhookDeliveryAttempt) error
+	UpdateOutcome(ctx context.Context, delivery *domains.WebhookDelivery) error
+	Reschedule(ctx context.Context, id uint, at time.Time) error
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type creditReservationRepository struct {
+	db *gorm.DB
+}
+
+// NewCreditReservationRepository creates a new instance of CreditReservationRepository
+func NewCreditReservationRepository(db *gorm.DB) CreditReservationRepository {
+	return &creditReservationRepository{db: db}
+}
+
+func (r *creditReservationRepository) Create(ctx context.Context, reservation *domains.CreditReservation) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(reservation).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *creditReservationRepository) GetByPaymentID(ctx context.Context, paymentID uint) (*domains.CreditReservation, error) {
+	var reservation domains.CreditReservation
+	if err := utils.DBFromContext(ctx, r.db).Where("payment_id = ?", paymentID).First(&reservation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "CreditReservation", ID: paymentID}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &reservation, nil
+}
+
+// Settle moves an active reservation to its final status. It reports false if
+// the reservation had already been settled, so callers release or capture the
+// held credit exactly once.
+func (r *creditReservationRepository) Settle(ctx context.Context, id uint, status domains.CreditReservationStatus) (bool, error) {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.CreditReservation{}).
+		Where("id = ? AND status = ?", id, domains.CreditReservationActive).
+		Update("status", status)
+	if result.Error != nil {
+		return false, &utils.ErrDatabase{Err: result.Error}
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *creditReservationRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]*domains.CreditReservation, error) {
+	var reservations []*domains.CreditReservation
+	err := utils.DBFromContext(ctx, r.db).
+		Where("status = ? AND expires_at < ?", domains.CreditReservationActive, now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&reservations).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return reservations, nil
+}
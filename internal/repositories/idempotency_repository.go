@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+// NewIdempotencyRepository creates a new instance of IdempotencyRepository
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Create inserts record, failing with *utils.ErrDuplicateEntry if the user
+// has already used the key.
+func (r *idempotencyRepository) Create(ctx context.Context, record *domains.IdempotencyRecord) error {
+	err := utils.DBFromContext(ctx, r.db).Create(record).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "IdempotencyRecord", Field: "key", Value: record.Key}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) Get(ctx context.Context, userID, key string) (*domains.IdempotencyRecord, error) {
+	var record domains.IdempotencyRecord
+	if err := utils.DBFromContext(ctx, r.db).Where("user_id = ? AND key = ?", userID, key).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "IdempotencyRecord", ID: key}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRepository) Complete(ctx context.Context, id uint, responseCode int, responseBody []byte) error {
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.IdempotencyRecord{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":        domains.IdempotencyCompleted,
+			"response_code": responseCode,
+			"response_body": responseBody,
+		}).Error
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) Delete(ctx context.Context, id uint) error {
+	if err := utils.DBFromContext(ctx, r.db).Delete(&domains.IdempotencyRecord{}, id).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := utils.DBFromContext(ctx, r.db).Where("expires_at < ?", now).Delete(&domains.IdempotencyRecord{})
+	if result.Error != nil {
+		return 0, &utils.ErrDatabase{Err: result.Error}
+	}
+	return result.RowsAffected, nil
+}
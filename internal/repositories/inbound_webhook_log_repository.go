@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type inboundWebhookLogRepository struct {
+	db *gorm.DB
+}
+
+// NewInboundWebhookLogRepository creates a new instance of InboundWebhookLogRepository
+func NewInboundWebhookLogRepository(db *gorm.DB) InboundWebhookLogRepository {
+	return &inboundWebhookLogRepository{db: db}
+}
+
+func (r *inboundWebhookLogRepository) Create(ctx context.Context, entry *domains.InboundWebhookLog) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(entry).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *inboundWebhookLogRepository) Update(ctx context.Context, entry *domains.InboundWebhookLog) error {
+	if err := utils.DBFromContext(ctx, r.db).Save(entry).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *inboundWebhookLogRepository) List(ctx context.Context, source string, offset, limit int) ([]*domains.InboundWebhookLog, int, error) {
+	var entries []*domains.InboundWebhookLog
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.InboundWebhookLog{})
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+	if err := query.Order("received_at DESC").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return entries, int(total), nil
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type installmentChargeRepository struct {
+	db *gorm.DB
+}
+
+// NewInstallmentChargeRepository creates a new instance of InstallmentChargeRepository
+func NewInstallmentChargeRepository(db *gorm.DB) InstallmentChargeRepository {
+	return &installmentChargeRepository{db: db}
+}
+
+// Create stores a charge. It fails with *utils.ErrDuplicateEntry if the
+// installment already has a charge of the same type for the same day.
+func (r *installmentChargeRepository) Create(ctx context.Context, charge *domains.InstallmentCharge) error {
+	err := utils.DBFromContext(ctx, r.db).Create(charge).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "InstallmentCharge", Field: "accrual_date", Value: charge.AccrualDate}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *installmentChargeRepository) GetByID(ctx context.Context, id uint) (*domains.InstallmentCharge, error) {
+	var charge domains.InstallmentCharge
+	if err := utils.DBFromContext(ctx, r.db).First(&charge, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "InstallmentCharge", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &charge, nil
+}
+
+func (r *installmentChargeRepository) ListByInstallmentID(ctx context.Context, installmentID uint) ([]*domains.InstallmentCharge, error) {
+	var charges []*domains.InstallmentCharge
+	err := utils.DBFromContext(ctx, r.db).
+		Where("installment_id = ?", installmentID).
+		Order("accrual_date, id").
+		Find(&charges).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return charges, nil
+}
+
+func (r *installmentChargeRepository) ListByPaymentID(ctx context.Context, paymentID uint) ([]*domains.InstallmentCharge, error) {
+	var charges []*domains.InstallmentCharge
+	err := utils.DBFromContext(ctx, r.db).
+		Where("payment_id = ?", paymentID).
+		Order("installment_id, accrual_date, id").
+		Find(&charges).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return charges, nil
+}
+
+func (r *installmentChargeRepository) ListByGatewayOrderID(ctx context.Context, gatewayOrderID string) ([]*domains.InstallmentCharge, error) {
+	var charges []*domains.InstallmentCharge
+	err := utils.DBFromContext(ctx, r.db).
+		Where("gateway_order_id = ?", gatewayOrderID).
+		Order("accrual_date, id").
+		Find(&charges).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return charges, nil
+}
+
+// BillOrder records that the accrued charges among ids are collected by a
+// gateway order.
+func (r *installmentChargeRepository) BillOrder(ctx context.Context, ids []uint, gatewayOrderID string) error {
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.InstallmentCharge{}).
+		Where("id IN ? AND status = ?", ids, domains.ChargeAccrued).
+		Update("gateway_order_id", gatewayOrderID).Error
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// MarkPaid marks an accrued charge billed to a gateway order as paid. It fails
+// with *utils.ErrInvalidTransition if the charge was waived, paid or billed to
+// another order meanwhile.
+func (r *installmentChargeRepository) MarkPaid(ctx context.Context, id uint, gatewayOrderID string, paidAt time.Time) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.InstallmentCharge{}).
+		Where("id = ? AND status = ? AND gateway_order_id = ?", id, domains.ChargeAccrued, gatewayOrderID).
+		Updates(map[string]interface{}{
+			"status":  domains.ChargePaid,
+			"paid_at": paidAt,
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "InstallmentCharge", ID: id, From: string(domains.ChargeAccrued), To: string(domains.ChargePaid)}
+	}
+	return nil
+}
+
+// Waive marks an accrued charge last billed to gatewayOrderID, or never billed
+// when it is empty, as waived and records who waived it and why. It fails with
+// *utils.ErrInvalidTransition if the charge was waived, paid or billed to
+// another order meanwhile.
+func (r *installmentChargeRepository) Waive(ctx context.Context, id uint, gatewayOrderID, waivedBy, reason string, waivedAt time.Time) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.InstallmentCharge{}).
+		Where("id = ? AND status = ? AND COALESCE(gateway_order_id, '') = ?", id, domains.ChargeAccrued, gatewayOrderID).
+		Updates(map[string]interface{}{
+			"status":        domains.ChargeWaived,
+			"waived_by":     waivedBy,
+			"waiver_reason": reason,
+			"waived_at":     waivedAt,
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "InstallmentCharge", ID: id, From: string(domains.ChargeAccrued), To: string(domains.ChargeWaived)}
+	}
+	return nil
+}
@@ -3,8 +3,11 @@ package repositories
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
 	"gorm.io/gorm"
 )
 
@@ -17,12 +20,12 @@ func NewInstallmentRepository(db *gorm.DB) InstallmentRepository {
 }
 
 func (r *installmentRepository) Create(ctx context.Context, installment *domains.Installment) error {
-	return r.db.WithContext(ctx).Create(installment).Error
+	return utils.DBFromContext(ctx, r.db).Create(installment).Error
 }
 
 func (r *installmentRepository) GetByID(ctx context.Context, id uint) (*domains.Installment, error) {
 	var installment domains.Installment
-	if err := r.db.WithContext(ctx).First(&installment, id).Error; err != nil {
+	if err := utils.DBFromContext(ctx, r.db).First(&installment, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("installment not found")
 		}
@@ -32,23 +35,23 @@ func (r *installmentRepository) GetByID(ctx context.Context, id uint) (*domains.
 }
 
 func (r *installmentRepository) Update(ctx context.Context, installment *domains.Installment) error {
-	return r.db.WithContext(ctx).Save(installment).Error
+	return utils.DBFromContext(ctx, r.db).Save(installment).Error
 }
 
 func (r *installmentRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&domains.Installment{}, id).Error
+	return utils.DBFromContext(ctx, r.db).Delete(&domains.Installment{}, id).Error
 }
 
 func (r *installmentRepository) List(ctx context.Context, offset, limit int) ([]*domains.Installment, int, error) {
 	var installments []*domains.Installment
 	var total int64
 
-	err := r.db.WithContext(ctx).Model(&domains.Installment{}).Count(&total).Error
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.Installment{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = r.db.WithContext(ctx).Offset(offset).Limit(limit).Find(&installments).Error
+	err = utils.DBFromContext(ctx, r.db).Offset(offset).Limit(limit).Find(&installments).Error
 	if err != nil {
 		return nil, 0, err
 	}
@@ -58,6 +61,194 @@ func (r *installmentRepository) List(ctx context.Context, offset, limit int) ([]
 
 func (r *installmentRepository) GetByPaymentID(ctx context.Context, paymentID uint) ([]*domains.Installment, error) {
 	var installments []*domains.Installment
-	err := r.db.WithContext(ctx).Where("payment_id = ?", paymentID).Find(&installments).Error
+	err := utils.DBFromContext(ctx, r.db).Where("payment_id = ?", paymentID).Find(&installments).Error
 	return installments, err
-}
\ No newline at end of file
+}
+
+func (r *installmentRepository) GetByGatewayOrderID(ctx context.Context, gatewayOrderID string) (*domains.Installment, error) {
+	var installment domains.Installment
+	if err := utils.DBFromContext(ctx, r.db).Where("gateway_order_id = ?", gatewayOrderID).First(&installment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "Installment", ID: gatewayOrderID}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &installment, nil
+}
+
+// CreateBatch inserts a whole schedule of installments in one statement.
+func (r *installmentRepository) CreateBatch(ctx context.Context, installments []domains.Installment) error {
+	if len(installments) == 0 {
+		return nil
+	}
+	if err := utils.DBFromContext(ctx, r.db).Create(&installments).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// UpdateStatus moves an installment to status to, provided its current
+// status is one of from. It fails with *utils.ErrInvalidTransition otherwise.
+func (r *installmentRepository) UpdateStatus(ctx context.Context, id uint, to string, from ...string) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Installment{}).
+		Where("id = ? AND status IN ?", id, from).
+		Update("status", to)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "Installment", ID: id, From: strings.Join(from, "|"), To: to}
+	}
+	return nil
+}
+
+// ListDue returns the installments to collect: PENDING ones due on or before
+// dueOn (YYYY-MM-DD), and FAILED or OVERDUE ones whose retry is scheduled by
+// now. Installments with a collection attempt in progress are left out, and
+// so are those the customer opened a gateway order for, by hand or as part of
+// a pending prepayment, after openedAfter.
+func (r *installmentRepository) ListDue(ctx context.Context, dueOn string, now, openedAfter time.Time, limit int) ([]*domains.Installment, error) {
+	var installments []*domains.Installment
+	err := utils.DBFromContext(ctx, r.db).
+		Where("(status = ? AND due_date <= ?) OR (status IN ? AND next_retry_at <= ?)",
+			"PENDING", dueOn, []string{"FAILED", "OVERDUE"}, now).
+		Where("NOT EXISTS (SELECT 1 FROM collection_attempts a WHERE a.installment_id = installments.id AND a.status = ?)",
+			domains.CollectionAttemptInProgress).
+		Where("(order_opened_at IS NULL OR order_opened_at <= ?)", openedAfter).
+		Where(`NOT EXISTS (SELECT 1 FROM prepayments p WHERE p.status = ? AND p.created_at > ? AND p.deleted_at IS NULL
+			AND p.lines::jsonb @> jsonb_build_array(jsonb_build_object('installment_id', installments.id)))`,
+			domains.PrepaymentPending, openedAfter).
+		Order("due_date, id").
+		Limit(limit).
+		Find(&installments).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return installments, nil
+}
+
+// ListDueBefore returns installments in one of statuses that fell due before
+// dueBefore (YYYY-MM-DD), oldest due first.
+func (r *installmentRepository) ListDueBefore(ctx context.Context, statuses []string, dueBefore string, limit int) ([]*domains.Installment, error) {
+	var installments []*domains.Installment
+	err := utils.DBFromContext(ctx, r.db).
+		Where("status IN ? AND due_date < ?", statuses, dueBefore).
+		Order("due_date, id").
+		Limit(limit).
+		Find(&installments).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return installments, nil
+}
+
+// RecordFailure saves the status, retry count and next retry of an
+// installment whose collection failed. It fails with
+// *utils.ErrInvalidTransition if the installment was paid, defaulted or had
+// another failure recorded in the meantime.
+func (r *installmentRepository) RecordFailure(ctx context.Context, installment *domains.Installment) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Installment{}).
+		Where("id = ? AND status IN ? AND retry_count = ?",
+			installment.ID, []string{"PENDING", "FAILED", "OVERDUE"}, installment.RetryCount-1).
+		Updates(map[string]interface{}{
+			"status":        installment.Status,
+			"retry_count":   installment.RetryCount,
+			"next_retry_at": installment.NextRetryAt,
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "Installment", ID: installment.ID, From: "PENDING|FAILED|OVERDUE", To: installment.Status}
+	}
+	return nil
+}
+
+// ListByStatus returns installments in one of statuses with an ID above
+// afterID, in ID order, so that callers can page through all of them.
+func (r *installmentRepository) ListByStatus(ctx context.Context, statuses []string, afterID uint, limit int) ([]*domains.Installment, error) {
+	var installments []*domains.Installment
+	err := utils.DBFromContext(ctx, r.db).
+		Where("status IN ? AND id > ?", statuses, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&installments).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return installments, nil
+}
+
+// OpenOrder saves the gateway order the customer opened to pay an installment
+// by hand, leaving its status alone. It fails with *utils.ErrInvalidTransition
+// if the installment was settled or is being collected meanwhile.
+func (r *installmentRepository) OpenOrder(ctx context.Context, installment *domains.Installment) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Installment{}).
+		Where("id = ? AND status IN ?", installment.ID, []string{"PENDING", "FAILED", "OVERDUE", "DEFAULTED"}).
+		Where("NOT EXISTS (SELECT 1 FROM collection_attempts a WHERE a.installment_id = installments.id AND a.status = ?)",
+			domains.CollectionAttemptInProgress).
+		Updates(map[string]interface{}{
+			"gateway_order_id": installment.GatewayOrderID,
+			"redirect_url":     installment.RedirectURL,
+			"order_opened_at":  installment.OrderOpenedAt,
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "Installment", ID: installment.ID, From: installment.Status, To: installment.Status}
+	}
+	return nil
+}
+
+// BeingCollected reports whether a collection attempt is charging the
+// installment to the card on file.
+func (r *installmentRepository) BeingCollected(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.CollectionAttempt{}).
+		Where("installment_id = ? AND status = ?", id, domains.CollectionAttemptInProgress).
+		Count(&count).Error
+	if err != nil {
+		return false, &utils.ErrDatabase{Err: err}
+	}
+	return count > 0, nil
+}
+
+// ApplyPrepayment lowers an unpaid installment by a prepayment line and marks
+// it PAID once nothing is left. It fails with *utils.ErrInvalidTransition if
+// the installment was settled or is being collected meanwhile, or no longer
+// owes what the line pays.
+func (r *installmentRepository) ApplyPrepayment(ctx context.Context, line domains.PrepaymentLine) error {
+	return r.reduce(ctx, line.InstallmentID, line.Principal, line.Fee+line.Rebate, "PAID",
+		[]string{"PENDING", "FAILED", "OVERDUE"})
+}
+
+// ApplyRefund lowers an unpaid installment by a refund line and marks it
+// CANCELLED once nothing is left. It fails with *utils.ErrInvalidTransition
+// under the same conditions as ApplyPrepayment.
+func (r *installmentRepository) ApplyRefund(ctx context.Context, line domains.RefundLine) error {
+	return r.reduce(ctx, line.InstallmentID, line.Principal, line.Fee, "CANCELLED",
+		[]string{"PENDING", "FAILED", "OVERDUE", "DEFAULTED"})
+}
+
+// reduce takes principal and fee off an installment in one of statuses that
+// has no collection in progress, and moves it to settled once it owes nothing.
+func (r *installmentRepository) reduce(ctx context.Context, id uint, principal, fee int, settled string, statuses []string) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Installment{}).
+		Where("id = ? AND status IN ? AND principal >= ? AND fee >= ?", id, statuses, principal, fee).
+		Where("NOT EXISTS (SELECT 1 FROM collection_attempts a WHERE a.installment_id = installments.id AND a.status = ?)",
+			domains.CollectionAttemptInProgress).
+		Updates(map[string]interface{}{
+			"status":    gorm.Expr("CASE WHEN amount = ? THEN ? ELSE status END", principal+fee, settled),
+			"amount":    gorm.Expr("amount - ?", principal+fee),
+			"principal": gorm.Expr("principal - ?", principal),
+			"fee":       gorm.Expr("fee - ?", fee),
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "Installment", ID: id, From: strings.Join(statuses, "|"), To: settled}
+	}
+	return nil
+}
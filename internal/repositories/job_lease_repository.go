@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type jobLeaseRepository struct {
+	db *gorm.DB
+}
+
+// NewJobLeaseRepository creates a new instance of JobLeaseRepository
+func NewJobLeaseRepository(db *gorm.DB) JobLeaseRepository {
+	return &jobLeaseRepository{db: db}
+}
+
+// Acquire takes the lease of job name for owner until leaseUntil, provided
+// the job is due and nobody else holds an unexpired lease. A job that has
+// never run is due immediately.
+func (r *jobLeaseRepository) Acquire(ctx context.Context, name, owner string, now, leaseUntil time.Time) (bool, error) {
+	result := utils.DBFromContext(ctx, r.db).Exec(`
+		INSERT INTO job_leases (name, owner, lease_until, next_run_at, last_started_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE
+		SET owner = EXCLUDED.owner, lease_until = EXCLUDED.lease_until, last_started_at = EXCLUDED.last_started_at
+		WHERE job_leases.next_run_at <= ? AND job_leases.lease_until < ?`,
+		name, owner, leaseUntil, now, now, now, now)
+	if result.Error != nil {
+		return false, &utils.ErrDatabase{Err: result.Error}
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Finish releases owner's lease on job name and schedules its next run.
+func (r *jobLeaseRepository) Finish(ctx context.Context, name, owner string, finishedAt, nextRunAt time.Time, lastError string) error {
+	result := utils.DBFromContext(ctx, r.db).Exec(`
+		UPDATE job_leases
+		SET lease_until = ?, next_run_at = ?, last_finished_at = ?, last_error = ?
+		WHERE name = ? AND owner = ?`,
+		finishedAt, nextRunAt, finishedAt, lastError, name, owner)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+// NewLedgerRepository creates a new instance of LedgerRepository
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+// CreateJournal stores a journal with its entries. A journal already posted
+// for the same type and reference fails with *utils.ErrDuplicateEntry; the
+// conflict is skipped rather than raised so that an enclosing transaction
+// stays usable.
+func (r *ledgerRepository) CreateJournal(ctx context.Context, journal *domains.Journal) error {
+	db := utils.DBFromContext(ctx, r.db)
+	result := db.Omit("Entries").
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "type"}, {Name: "reference"}}, DoNothing: true}).
+		Create(journal)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrDuplicateEntry{Entity: "Journal", Field: "reference", Value: journal.Reference}
+	}
+
+	for i := range journal.Entries {
+		journal.Entries[i].JournalID = journal.ID
+	}
+	if err := db.Create(&journal.Entries).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// ListJournals returns journals with their entries, newest first. A zero
+// paymentID lists the journals of every payment.
+func (r *ledgerRepository) ListJournals(ctx context.Context, paymentID uint, offset, limit int) ([]*domains.Journal, int, error) {
+	var journals []*domains.Journal
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.Journal{})
+	if paymentID != 0 {
+		query = query.Where("payment_id = ?", paymentID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	err := query.Preload("Entries", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("posted_at DESC, id DESC").Offset(offset).Limit(limit).Find(&journals).Error
+	if err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return journals, int(total), nil
+}
+
+// Balances totals the entries of each account posted up to and including
+// asOf, optionally only those of one customer or payment. Accounts without
+// entries are left out.
+func (r *ledgerRepository) Balances(ctx context.Context, asOf time.Time, userID string, paymentID uint) ([]domains.AccountBalance, error) {
+	var balances []domains.AccountBalance
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.LedgerEntry{}).
+		Select("account, SUM(debit) AS debit, SUM(credit) AS credit, SUM(debit) - SUM(credit) AS balance").
+		Where("posted_at <= ?", asOf)
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if paymentID != 0 {
+		query = query.Where("payment_id = ?", paymentID)
+	}
+	if err := query.Group("account").Order("account").Scan(&balances).Error; err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+
+	return balances, nil
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type manualReviewRepository struct {
+	db *gorm.DB
+}
+
+// NewManualReviewRepository creates a new instance of ManualReviewRepository
+func NewManualReviewRepository(db *gorm.DB) ManualReviewRepository {
+	return &manualReviewRepository{db: db}
+}
+
+func (r *manualReviewRepository) Create(ctx context.Context, review *domains.ManualReview) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(review).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *manualReviewRepository) GetByID(ctx context.Context, id uint) (*domains.ManualReview, error) {
+	var review domains.ManualReview
+	if err := utils.DBFromContext(ctx, r.db).First(&review, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "ManualReview", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &review, nil
+}
+
+func (r *manualReviewRepository) GetByCreditApplicationID(ctx context.Context, creditApplicationID uint) (*domains.ManualReview, error) {
+	var review domains.ManualReview
+	if err := utils.DBFromContext(ctx, r.db).Where("credit_application_id = ?", creditApplicationID).First(&review).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "ManualReview", ID: creditApplicationID}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &review, nil
+}
+
+func (r *manualReviewRepository) Update(ctx context.Context, review *domains.ManualReview) error {
+	if err := utils.DBFromContext(ctx, r.db).Save(review).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// Claim assigns an open review to a reviewer. It fails with ErrInvalidTransition
+// if another reviewer claimed or decided the review first.
+func (r *manualReviewRepository) Claim(ctx context.Context, id uint, reviewerID string, claimedAt time.Time) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.ManualReview{}).
+		Where("id = ? AND status = ?", id, domains.ManualReviewOpen).
+		Updates(map[string]interface{}{
+			"status":      domains.ManualReviewClaimed,
+			"reviewer_id": reviewerID,
+			"claimed_at":  claimedAt,
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "ManualReview", ID: id, From: "non-open", To: string(domains.ManualReviewClaimed)}
+	}
+	return nil
+}
+
+func (r *manualReviewRepository) List(ctx context.Context, status domains.ManualReviewStatus, offset, limit int) ([]*domains.ManualReview, int, error) {
+	var reviews []*domains.ManualReview
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.ManualReview{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	if err := query.Order("created_at ASC").Offset(offset).Limit(limit).Find(&reviews).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return reviews, int(total), nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type merchantAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewMerchantAPIKeyRepository creates a new instance of MerchantAPIKeyRepository
+func NewMerchantAPIKeyRepository(db *gorm.DB) MerchantAPIKeyRepository {
+	return &merchantAPIKeyRepository{db: db}
+}
+
+func (r *merchantAPIKeyRepository) Create(ctx context.Context, key *domains.MerchantAPIKey) error {
+	err := utils.DBFromContext(ctx, r.db).Create(key).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "MerchantAPIKey", Field: "prefix", Value: key.Prefix}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *merchantAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*domains.MerchantAPIKey, error) {
+	var key domains.MerchantAPIKey
+	err := utils.DBFromContext(ctx, r.db).Where("prefix = ?", prefix).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "MerchantAPIKey", ID: prefix}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &key, nil
+}
+
+func (r *merchantAPIKeyRepository) ListByMerchantID(ctx context.Context, merchantID uint) ([]*domains.MerchantAPIKey, error) {
+	var keys []*domains.MerchantAPIKey
+	if err := utils.DBFromContext(ctx, r.db).Where("merchant_id = ?", merchantID).Order("id").Find(&keys).Error; err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return keys, nil
+}
+
+// Revoke disables a merchant's key. It fails with *utils.ErrNotFound if the
+// merchant has no such key that is still active.
+func (r *merchantAPIKeyRepository) Revoke(ctx context.Context, merchantID, id uint, revokedAt time.Time) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.MerchantAPIKey{}).
+		Where("id = ? AND merchant_id = ? AND revoked_at IS NULL", id, merchantID).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrNotFound{Entity: "MerchantAPIKey", ID: id}
+	}
+	return nil
+}
+
+func (r *merchantAPIKeyRepository) TouchLastUsed(ctx context.Context, id uint, usedAt time.Time) error {
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.MerchantAPIKey{}).
+		Where("id = ?", id).
+		Update("last_used_at", usedAt).Error
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type merchantEventRepository struct {
+	db *gorm.DB
+}
+
+// NewMerchantEventRepository creates a new instance of MerchantEventRepository
+func NewMerchantEventRepository(db *gorm.DB) MerchantEventRepository {
+	return &merchantEventRepository{db: db}
+}
+
+func (r *merchantEventRepository) Create(ctx context.Context, event *domains.MerchantEvent) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(event).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *merchantEventRepository) GetByID(ctx context.Context, id uint) (*domains.MerchantEvent, error) {
+	var event domains.MerchantEvent
+	err := utils.DBFromContext(ctx, r.db).First(&event, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "MerchantEvent", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &event, nil
+}
+
+// ListByMerchantID returns a merchant's events, newest first.
+func (r *merchantEventRepository) ListByMerchantID(ctx context.Context, merchantID uint, offset, limit int) ([]*domains.MerchantEvent, int, error) {
+	var events []*domains.MerchantEvent
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.MerchantEvent{}).Where("merchant_id = ?", merchantID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return events, int(total), nil
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type merchantRepository struct {
+	db *gorm.DB
+}
+
+// NewMerchantRepository creates a new instance of MerchantRepository
+func NewMerchantRepository(db *gorm.DB) MerchantRepository {
+	return &merchantRepository{db: db}
+}
+
+func (r *merchantRepository) Create(ctx context.Context, merchant *domains.Merchant) error {
+	err := utils.DBFromContext(ctx, r.db).Create(merchant).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "Merchant", Field: "tax_id", Value: merchant.TaxID}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// GetByID returns a merchant with its stores.
+func (r *merchantRepository) GetByID(ctx context.Context, id uint) (*domains.Merchant, error) {
+	var merchant domains.Merchant
+	err := utils.DBFromContext(ctx, r.db).Preload("Stores", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&merchant, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "Merchant", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &merchant, nil
+}
+
+// List returns merchants in onboarding order, optionally only those in one
+// status.
+func (r *merchantRepository) List(ctx context.Context, status domains.MerchantStatus, offset, limit int) ([]*domains.Merchant, int, error) {
+	var merchants []*domains.Merchant
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.Merchant{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	if err := query.Order("id ASC").Offset(offset).Limit(limit).Find(&merchants).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return merchants, int(total), nil
+}
+
+// Update saves a merchant's details. Its status and stores are changed
+// through UpdateStatus and the store methods only.
+func (r *merchantRepository) Update(ctx context.Context, merchant *domains.Merchant) error {
+	err := utils.DBFromContext(ctx, r.db).Model(merchant).
+		Select("name", "legal_name", "tax_id", "email", "phone", "category", "rib", "discount_rate_bps").
+		Updates(merchant).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "Merchant", Field: "tax_id", Value: merchant.TaxID}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// UpdateStatus moves a merchant to status to, provided its current status is
+// one of from. It fails with *utils.ErrInvalidTransition otherwise. The first
+// activation is timestamped.
+func (r *merchantRepository) UpdateStatus(ctx context.Context, id uint, to domains.MerchantStatus, from ...domains.MerchantStatus) error {
+	updates := map[string]interface{}{"status": to}
+	if to == domains.MerchantActive {
+		updates["activated_at"] = gorm.Expr("COALESCE(activated_at, NOW())")
+	}
+
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Merchant{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		names := make([]string, len(from))
+		for i, status := range from {
+			names[i] = string(status)
+		}
+		return &utils.ErrInvalidTransition{Entity: "Merchant", ID: id, From: strings.Join(names, "|"), To: string(to)}
+	}
+	return nil
+}
+
+func (r *merchantRepository) CreateStore(ctx context.Context, store *domains.Store) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(store).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// GetStore returns a store of a merchant. Stores of other merchants are not
+// found.
+func (r *merchantRepository) GetStore(ctx context.Context, merchantID, storeID uint) (*domains.Store, error) {
+	var store domains.Store
+	err := utils.DBFromContext(ctx, r.db).Where("id = ? AND merchant_id = ?", storeID, merchantID).First(&store).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "Store", ID: fmt.Sprintf("%d/%d", merchantID, storeID)}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &store, nil
+}
+
+// GetStoreByID returns a store of any merchant.
+func (r *merchantRepository) GetStoreByID(ctx context.Context, storeID uint) (*domains.Store, error) {
+	var store domains.Store
+	if err := utils.DBFromContext(ctx, r.db).First(&store, storeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "Store", ID: storeID}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &store, nil
+}
+
+func (r *merchantRepository) ListStores(ctx context.Context, merchantID uint) ([]*domains.Store, error) {
+	var stores []*domains.Store
+	if err := utils.DBFromContext(ctx, r.db).Where("merchant_id = ?", merchantID).Order("id").Find(&stores).Error; err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return stores, nil
+}
+
+func (r *merchantRepository) UpdateStore(ctx context.Context, store *domains.Store) error {
+	if err := utils.DBFromContext(ctx, r.db).Save(store).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
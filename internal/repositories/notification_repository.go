@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+// NewNotificationRepository creates a new instance of NotificationRepository
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) Create(ctx context.Context, notification *domains.Notification) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(notification).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// ListByUserID returns a user's notifications, newest first.
+func (r *notificationRepository) ListByUserID(ctx context.Context, userID string, offset, limit int) ([]*domains.Notification, int, error) {
+	var notifications []*domains.Notification
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.Notification{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return notifications, int(total), nil
+}
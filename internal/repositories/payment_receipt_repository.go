@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type paymentReceiptRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentReceiptRepository creates a new instance of PaymentReceiptRepository
+func NewPaymentReceiptRepository(db *gorm.DB) PaymentReceiptRepository {
+	return &paymentReceiptRepository{db: db}
+}
+
+// Create records a receipt. It fails with *utils.ErrDuplicateEntry if the
+// gateway order was already recorded. The conflict is skipped rather than
+// raised so that an enclosing transaction stays usable.
+func (r *paymentReceiptRepository) Create(ctx context.Context, receipt *domains.PaymentReceipt) error {
+	result := utils.DBFromContext(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "gateway_order_id"}}, DoNothing: true}).
+		Create(receipt)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrDuplicateEntry{Entity: "PaymentReceipt", Field: "gateway_order_id", Value: receipt.GatewayOrderID}
+	}
+	return nil
+}
+
+// ListByPaymentID returns the receipts of a payment, oldest first.
+func (r *paymentReceiptRepository) ListByPaymentID(ctx context.Context, paymentID uint) ([]*domains.PaymentReceipt, error) {
+	var receipts []*domains.PaymentReceipt
+	err := utils.DBFromContext(ctx, r.db).
+		Where("payment_id = ?", paymentID).
+		Order("received_at, id").
+		Find(&receipts).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return receipts, nil
+}
+
+// AddRefunded adds amount to the refunded part of a receipt. It fails with
+// *utils.ErrInvalidTransition if more than the receipt's amount would be
+// refunded.
+func (r *paymentReceiptRepository) AddRefunded(ctx context.Context, id uint, amount int) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.PaymentReceipt{}).
+		Where("id = ? AND refunded + ? <= amount", id, amount).
+		Update("refunded", gorm.Expr("refunded + ?", amount))
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "PaymentReceipt", ID: id, From: "PAID", To: "REFUNDED"}
+	}
+	return nil
+}
+
+// ListUnrefundedOverpayments returns overpayment receipts not fully refunded
+// yet, oldest first.
+func (r *paymentReceiptRepository) ListUnrefundedOverpayments(ctx context.Context, limit int) ([]*domains.PaymentReceipt, error) {
+	var receipts []*domains.PaymentReceipt
+	err := utils.DBFromContext(ctx, r.db).
+		Where("overpayment AND refunded < amount").
+		Order("received_at, id").
+		Limit(limit).
+		Find(&receipts).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return receipts, nil
+}
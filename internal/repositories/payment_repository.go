@@ -4,6 +4,8 @@ import (
 "context"
 	"github.com/mohamed2394/sahla/internal/domains"
 "errors"
+	"strings"
+	utils "github.com/mohamed2394/sahla/internal/utils"
 
 
 )
@@ -16,12 +18,16 @@ func NewPaymentRepository(db *gorm.DB) PaymentRepository {
 }
 
 func (r *paymentRepository) Create(ctx context.Context, payment *domains.Payment) error {
-	return r.db.WithContext(ctx).Create(payment).Error
+	err := utils.DBFromContext(ctx, r.db).Create(payment).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "Payment", Field: "order_id", Value: payment.OrderID}
+	}
+	return err
 }
 
 func (r *paymentRepository) GetByID(ctx context.Context, id uint) (*domains.Payment, error) {
 	var payment domains.Payment
-	if err := r.db.WithContext(ctx).First(&payment, id).Error; err != nil {
+	if err := utils.DBFromContext(ctx, r.db).First(&payment, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("payment not found")
 		}
@@ -31,23 +37,23 @@ func (r *paymentRepository) GetByID(ctx context.Context, id uint) (*domains.Paym
 }
 
 func (r *paymentRepository) Update(ctx context.Context, payment *domains.Payment) error {
-	return r.db.WithContext(ctx).Save(payment).Error
+	return utils.DBFromContext(ctx, r.db).Save(payment).Error
 }
 
 func (r *paymentRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&domains.Payment{}, id).Error
+	return utils.DBFromContext(ctx, r.db).Delete(&domains.Payment{}, id).Error
 }
 
 func (r *paymentRepository) List(ctx context.Context, offset, limit int) ([]*domains.Payment, int, error) {
 	var payments []*domains.Payment
 	var total int64
 
-	err := r.db.WithContext(ctx).Model(&domains.Payment{}).Count(&total).Error
+	err := utils.DBFromContext(ctx, r.db).Model(&domains.Payment{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = r.db.WithContext(ctx).Offset(offset).Limit(limit).Find(&payments).Error
+	err = utils.DBFromContext(ctx, r.db).Offset(offset).Limit(limit).Find(&payments).Error
 	if err != nil {
 		return nil, 0, err
 	}
@@ -57,13 +63,13 @@ func (r *paymentRepository) List(ctx context.Context, offset, limit int) ([]*dom
 
 func (r *paymentRepository) GetByCreditApplicationID(ctx context.Context, creditApplicationID uint) ([]*domains.Payment, error) {
 	var payments []*domains.Payment
-	err := r.db.WithContext(ctx).Where("credit_application_id = ?", creditApplicationID).Find(&payments).Error
+	err := utils.DBFromContext(ctx, r.db).Where("credit_application_id = ?", creditApplicationID).Find(&payments).Error
 	return payments, err
 }
 
 func (r *paymentRepository) GetByOrderID(ctx context.Context, orderID string) (*domains.Payment, error) {
 	var payment domains.Payment
-	if err := r.db.WithContext(ctx).Where("order_id = ?", orderID).First(&payment).Error; err != nil {
+	if err := utils.DBFromContext(ctx, r.db).Where("order_id = ?", orderID).First(&payment).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("payment not found for the given order ID")
 		}
@@ -71,3 +77,74 @@ func (r *paymentRepository) GetByOrderID(ctx context.Context, orderID string) (*
 	}
 	return &payment, nil
 }
+
+func (r *paymentRepository) GetByUserID(ctx context.Context, userID string) ([]*domains.Payment, error) {
+	var payments []*domains.Payment
+	err := utils.DBFromContext(ctx, r.db).Where("user_id = ?", userID).Find(&payments).Error
+	return payments, err
+}
+
+func (r *paymentRepository) GetByGatewayOrderID(ctx context.Context, gatewayOrderID string) (*domains.Payment, error) {
+	var payment domains.Payment
+	if err := utils.DBFromContext(ctx, r.db).Where("gateway_order_id = ?", gatewayOrderID).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "Payment", ID: gatewayOrderID}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &payment, nil
+}
+
+// UpdateStatus moves a payment to status to, provided its current status is
+// one of from. It fails with *utils.ErrInvalidTransition otherwise, so only
+// one concurrent caller can settle a payment.
+func (r *paymentRepository) UpdateStatus(ctx context.Context, id uint, to string, from ...string) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Payment{}).
+		Where("id = ? AND status IN ?", id, from).
+		Update("status", to)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "Payment", ID: id, From: strings.Join(from, "|"), To: to}
+	}
+	return nil
+}
+
+// AddRefunded adds amount to the refunded part of a successful payment and
+// marks it REFUNDED once all of it is. It fails with
+// *utils.ErrInvalidTransition if the payment is not successful or more than
+// its amount would be refunded.
+func (r *paymentRepository) AddRefunded(ctx context.Context, id uint, amount int) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Payment{}).
+		Where("id = ? AND status = ? AND refunded_amount + ? <= amount", id, "SUCCESSFUL", amount).
+		Updates(map[string]interface{}{
+			"status":          gorm.Expr("CASE WHEN refunded_amount + ? = amount THEN ? ELSE status END", amount, "REFUNDED"),
+			"refunded_amount": gorm.Expr("refunded_amount + ?", amount),
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "Payment", ID: id, From: "SUCCESSFUL", To: "REFUNDED"}
+	}
+	return nil
+}
+
+// ListByMerchantID returns the payments attributed to a merchant, newest
+// first.
+func (r *paymentRepository) ListByMerchantID(ctx context.Context, merchantID uint, offset, limit int) ([]*domains.Payment, int, error) {
+	var payments []*domains.Payment
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.Payment{}).Where("merchant_id = ?", merchantID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&payments).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return payments, int(total), nil
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type planProductRepository struct {
+	db *gorm.DB
+}
+
+// NewPlanProductRepository creates a new instance of PlanProductRepository
+func NewPlanProductRepository(db *gorm.DB) PlanProductRepository {
+	return &planProductRepository{db: db}
+}
+
+func (r *planProductRepository) Create(ctx context.Context, plan *domains.PlanProduct) error {
+	err := utils.DBFromContext(ctx, r.db).Create(plan).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "PlanProduct", Field: "code", Value: plan.Code}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *planProductRepository) GetByID(ctx context.Context, id uint) (*domains.PlanProduct, error) {
+	var plan domains.PlanProduct
+	if err := utils.DBFromContext(ctx, r.db).First(&plan, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "PlanProduct", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &plan, nil
+}
+
+func (r *planProductRepository) Update(ctx context.Context, plan *domains.PlanProduct) error {
+	err := utils.DBFromContext(ctx, r.db).Save(plan).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "PlanProduct", Field: "code", Value: plan.Code}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *planProductRepository) Delete(ctx context.Context, id uint) error {
+	result := utils.DBFromContext(ctx, r.db).Delete(&domains.PlanProduct{}, id)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrNotFound{Entity: "PlanProduct", ID: id}
+	}
+	return nil
+}
+
+// List returns the catalog ordered by installment count, so shorter plans
+// are offered first.
+func (r *planProductRepository) List(ctx context.Context, activeOnly bool) ([]*domains.PlanProduct, error) {
+	var plans []*domains.PlanProduct
+	query := utils.DBFromContext(ctx, r.db).Order("installment_count, id")
+	if activeOnly {
+		query = query.Where("active = ?", true)
+	}
+	if err := query.Find(&plans).Error; err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return plans, nil
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type prepaymentRepository struct {
+	db *gorm.DB
+}
+
+// NewPrepaymentRepository creates a new instance of PrepaymentRepository
+func NewPrepaymentRepository(db *gorm.DB) PrepaymentRepository {
+	return &prepaymentRepository{db: db}
+}
+
+func (r *prepaymentRepository) Create(ctx context.Context, prepayment *domains.Prepayment) error {
+	err := utils.DBFromContext(ctx, r.db).Create(prepayment).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "Prepayment", Field: "gateway_order_id", Value: prepayment.GatewayOrderID}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *prepaymentRepository) GetByGatewayOrderID(ctx context.Context, gatewayOrderID string) (*domains.Prepayment, error) {
+	var prepayment domains.Prepayment
+	err := utils.DBFromContext(ctx, r.db).Where("gateway_order_id = ?", gatewayOrderID).First(&prepayment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "Prepayment", ID: gatewayOrderID}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &prepayment, nil
+}
+
+func (r *prepaymentRepository) Update(ctx context.Context, prepayment *domains.Prepayment) error {
+	if err := utils.DBFromContext(ctx, r.db).Save(prepayment).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// UpdateStatus moves a prepayment to status to, provided its current status
+// is one of from. It fails with *utils.ErrInvalidTransition otherwise, so only
+// one concurrent caller can apply a prepayment.
+func (r *prepaymentRepository) UpdateStatus(ctx context.Context, id uint, to domains.PrepaymentStatus, from ...domains.PrepaymentStatus) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Prepayment{}).
+		Where("id = ? AND status IN ?", id, from).
+		Update("status", to)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		names := make([]string, len(from))
+		for i, status := range from {
+			names[i] = string(status)
+		}
+		return &utils.ErrInvalidTransition{Entity: "Prepayment", ID: id, From: strings.Join(names, "|"), To: string(to)}
+	}
+	return nil
+}
+
+// ListUnrefunded returns paid prepayments whose unapplied part was not
+// refunded yet, oldest first.
+func (r *prepaymentRepository) ListUnrefunded(ctx context.Context, limit int) ([]*domains.Prepayment, error) {
+	var prepayments []*domains.Prepayment
+	err := utils.DBFromContext(ctx, r.db).
+		Where("status = ? AND unapplied > 0 AND unapplied_refunded_at IS NULL", domains.PrepaymentPaid).
+		Order("id").
+		Limit(limit).
+		Find(&prepayments).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return prepayments, nil
+}
+
+// MarkUnappliedRefunded records that the unapplied part of a prepayment was
+// refunded. It fails with *utils.ErrInvalidTransition if it already was.
+func (r *prepaymentRepository) MarkUnappliedRefunded(ctx context.Context, id uint, refundedAt time.Time) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Prepayment{}).
+		Where("id = ? AND unapplied > 0 AND unapplied_refunded_at IS NULL", id).
+		Update("unapplied_refunded_at", refundedAt)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrInvalidTransition{Entity: "Prepayment", ID: id, From: "UNREFUNDED", To: "REFUNDED"}
+	}
+	return nil
+}
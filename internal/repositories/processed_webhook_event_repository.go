@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type processedWebhookEventRepository struct {
+	db *gorm.DB
+}
+
+// NewProcessedWebhookEventRepository creates a new instance of ProcessedWebhookEventRepository
+func NewProcessedWebhookEventRepository(db *gorm.DB) ProcessedWebhookEventRepository {
+	return &processedWebhookEventRepository{db: db}
+}
+
+// Create claims an event ID, failing with *utils.ErrDuplicateEntry if it has
+// already been claimed. The conflict is skipped rather than raised so that
+// the enclosing transaction stays usable.
+func (r *processedWebhookEventRepository) Create(ctx context.Context, event *domains.ProcessedWebhookEvent) error {
+	result := utils.DBFromContext(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "event_id"}}, DoNothing: true}).
+		Create(event)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrDuplicateEntry{Entity: "ProcessedWebhookEvent", Field: "event_id", Value: event.EventID}
+	}
+	return nil
+}
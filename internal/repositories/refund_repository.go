@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+// NewRefundRepository creates a new instance of RefundRepository
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+func (r *refundRepository) Create(ctx context.Context, refund *domains.Refund) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(refund).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *refundRepository) GetByID(ctx context.Context, id uint) (*domains.Refund, error) {
+	var refund domains.Refund
+	if err := utils.DBFromContext(ctx, r.db).First(&refund, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "Refund", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &refund, nil
+}
+
+func (r *refundRepository) ListByPaymentID(ctx context.Context, paymentID uint) ([]*domains.Refund, error) {
+	var refunds []*domains.Refund
+	err := utils.DBFromContext(ctx, r.db).
+		Where("payment_id = ?", paymentID).
+		Order("created_at, id").
+		Find(&refunds).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return refunds, nil
+}
+
+// List returns refunds, optionally in one status, newest first, with the
+// total count.
+func (r *refundRepository) List(ctx context.Context, status domains.RefundStatus, offset, limit int) ([]*domains.Refund, int, error) {
+	var refunds []*domains.Refund
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.Refund{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&refunds).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return refunds, int(total), nil
+}
+
+func (r *refundRepository) Update(ctx context.Context, refund *domains.Refund) error {
+	if err := utils.DBFromContext(ctx, r.db).Save(refund).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// UpdateStatus moves a refund to status to, provided its current status is
+// one of from. It fails with *utils.ErrInvalidTransition otherwise.
+func (r *refundRepository) UpdateStatus(ctx context.Context, id uint, to domains.RefundStatus, from ...domains.RefundStatus) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.Refund{}).
+		Where("id = ? AND status IN ?", id, from).
+		Update("status", to)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		names := make([]string, len(from))
+		for i, status := range from {
+			names[i] = string(status)
+		}
+		return &utils.ErrInvalidTransition{Entity: "Refund", ID: id, From: strings.Join(names, "|"), To: string(to)}
+	}
+	return nil
+}
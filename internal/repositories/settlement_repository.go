@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type settlementRepository struct {
+	db *gorm.DB
+}
+
+// NewSettlementRepository creates a new instance of SettlementRepository
+func NewSettlementRepository(db *gorm.DB) SettlementRepository {
+	return &settlementRepository{db: db}
+}
+
+// ListUnsettled returns the merchant payable movements of purchases and
+// refunds posted before before that no batch has settled, oldest first.
+// Payments made before merchants were attributed are left out.
+func (r *settlementRepository) ListUnsettled(ctx context.Context, before time.Time) ([]domains.UnsettledMovement, error) {
+	var movements []domains.UnsettledMovement
+	err := utils.DBFromContext(ctx, r.db).Table("journals").
+		Select("journals.id AS journal_id, journals.type, journals.payment_id, journals.user_id, payments.merchant_id, journals.currency, "+
+			"SUM(ledger_entries.credit) - SUM(ledger_entries.debit) AS amount, journals.posted_at").
+		Joins("JOIN ledger_entries ON ledger_entries.journal_id = journals.id AND ledger_entries.account = ?", domains.AccountMerchantPayable).
+		Joins("JOIN payments ON payments.id = journals.payment_id").
+		Joins("LEFT JOIN settlement_items ON settlement_items.journal_id = journals.id").
+		Where("journals.type IN ? AND journals.posted_at < ?", []domains.JournalType{domains.JournalPurchase, domains.JournalRefund}, before).
+		Where("settlement_items.id IS NULL AND payments.merchant_id <> 0").
+		Group("journals.id, payments.merchant_id").
+		Order("journals.posted_at, journals.id").
+		Scan(&movements).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return movements, nil
+}
+
+// CreateBatch stores a batch with its items. An item whose journal is
+// already settled fails with *utils.ErrDuplicateEntry.
+func (r *settlementRepository) CreateBatch(ctx context.Context, batch *domains.SettlementBatch) error {
+	err := utils.DBFromContext(ctx, r.db).Create(batch).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		journalIDs := make([]uint, len(batch.Items))
+		for i, item := range batch.Items {
+			journalIDs[i] = item.JournalID
+		}
+		return &utils.ErrDuplicateEntry{Entity: "SettlementItem", Field: "journal_id", Value: journalIDs}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// GetBatch returns a batch with its items.
+func (r *settlementRepository) GetBatch(ctx context.Context, id uint) (*domains.SettlementBatch, error) {
+	var batch domains.SettlementBatch
+	err := utils.DBFromContext(ctx, r.db).Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&batch, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "SettlementBatch", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &batch, nil
+}
+
+// ListBatches returns batches newest first, optionally only those of one
+// merchant or in one status.
+func (r *settlementRepository) ListBatches(ctx context.Context, merchantID uint, status domains.SettlementBatchStatus, offset, limit int) ([]*domains.SettlementBatch, int, error) {
+	var batches []*domains.SettlementBatch
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.SettlementBatch{})
+	if merchantID != 0 {
+		query = query.Where("merchant_id = ?", merchantID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&batches).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return batches, int(total), nil
+}
+
+// ListByStatus returns every batch in one of statuses, oldest first.
+func (r *settlementRepository) ListByStatus(ctx context.Context, statuses ...domains.SettlementBatchStatus) ([]*domains.SettlementBatch, error) {
+	var batches []*domains.SettlementBatch
+	if err := utils.DBFromContext(ctx, r.db).Where("status IN ?", statuses).Order("id").Find(&batches).Error; err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return batches, nil
+}
+
+// UpdateStatus moves a batch to status to with changes, provided its current
+// status is one of from. It fails with *utils.ErrInvalidTransition otherwise.
+func (r *settlementRepository) UpdateStatus(ctx context.Context, id uint, to domains.SettlementBatchStatus, changes map[string]interface{}, from ...domains.SettlementBatchStatus) error {
+	updates := map[string]interface{}{"status": to}
+	for column, value := range changes {
+		updates[column] = value
+	}
+
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.SettlementBatch{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		names := make([]string, len(from))
+		for i, status := range from {
+			names[i] = string(status)
+		}
+		return &utils.ErrInvalidTransition{Entity: "SettlementBatch", ID: id, From: strings.Join(names, "|"), To: string(to)}
+	}
+	return nil
+}
+
+func (r *settlementRepository) CreateBankFile(ctx context.Context, file *domains.BankFile) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(file).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *settlementRepository) GetBankFile(ctx context.Context, id uint) (*domains.BankFile, error) {
+	var file domains.BankFile
+	err := utils.DBFromContext(ctx, r.db).First(&file, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "BankFile", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &file, nil
+}
@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type webhookDeliveryRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookDeliveryRepository creates a new instance of WebhookDeliveryRepository
+func NewWebhookDeliveryRepository(db *gorm.DB) WebhookDeliveryRepository {
+	return &webhookDeliveryRepository{db: db}
+}
+
+func (r *webhookDeliveryRepository) Create(ctx context.Context, delivery *domains.WebhookDelivery) error {
+	err := utils.DBFromContext(ctx, r.db).Create(delivery).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &utils.ErrDuplicateEntry{Entity: "WebhookDelivery", Field: "endpoint_id,event_id",
+			Value: fmt.Sprintf("%d,%d", delivery.EndpointID, delivery.EventID)}
+	}
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// GetByID returns a delivery with its attempts, oldest first.
+func (r *webhookDeliveryRepository) GetByID(ctx context.Context, id uint) (*domains.WebhookDelivery, error) {
+	var delivery domains.WebhookDelivery
+	err := utils.DBFromContext(ctx, r.db).
+		Preload("AttemptLog", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&delivery, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "WebhookDelivery", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &delivery, nil
+}
+
+// ListByMerchantID returns a merchant's deliveries, newest first. A zero
+// endpointID or empty status matches every endpoint or status.
+func (r *webhookDeliveryRepository) ListByMerchantID(ctx context.Context, merchantID, endpointID uint, status domains.WebhookDeliveryStatus, offset, limit int) ([]*domains.WebhookDelivery, int, error) {
+	var deliveries []*domains.WebhookDelivery
+	var total int64
+
+	query := utils.DBFromContext(ctx, r.db).Model(&domains.WebhookDelivery{}).Where("merchant_id = ?", merchantID)
+	if endpointID != 0 {
+		query = query.Where("endpoint_id = ?", endpointID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, 0, &utils.ErrDatabase{Err: err}
+	}
+
+	return deliveries, int(total), nil
+}
+
+// ListDue returns pending deliveries whose next attempt is due by now,
+// oldest first.
+func (r *webhookDeliveryRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*domains.WebhookDelivery, error) {
+	var deliveries []*domains.WebhookDelivery
+	err := utils.DBFromContext(ctx, r.db).
+		Where("status = ? AND next_attempt_at <= ?", domains.WebhookDeliveryPending, now).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return deliveries, nil
+}
+
+func (r *webhookDeliveryRepository) CreateAttempt(ctx context.Context, attempt *domains.WebhookDeliveryAttempt) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(attempt).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// UpdateOutcome saves the status, schedule and last attempt of a delivery.
+func (r *webhookDeliveryRepository) UpdateOutcome(ctx context.Context, delivery *domains.WebhookDelivery) error {
+	err := utils.DBFromContext(ctx, r.db).Model(delivery).
+		Select("status", "attempts", "next_attempt_at", "last_attempt_at", "last_response_status", "last_error", "delivered_at").
+		Updates(delivery).Error
+	if err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+// Reschedule makes a delivery pending again, due at at, with its attempts
+// counted afresh.
+func (r *webhookDeliveryRepository) Reschedule(ctx context.Context, id uint, at time.Time) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.WebhookDelivery{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":          domains.WebhookDeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": at,
+		})
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrNotFound{Entity: "WebhookDelivery", ID: id}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	utils "github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/gorm"
+)
+
+type webhookEndpointRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookEndpointRepository creates a new instance of WebhookEndpointRepository
+func NewWebhookEndpointRepository(db *gorm.DB) WebhookEndpointRepository {
+	return &webhookEndpointRepository{db: db}
+}
+
+func (r *webhookEndpointRepository) Create(ctx context.Context, endpoint *domains.WebhookEndpoint) error {
+	if err := utils.DBFromContext(ctx, r.db).Create(endpoint).Error; err != nil {
+		return &utils.ErrDatabase{Err: err}
+	}
+	return nil
+}
+
+func (r *webhookEndpointRepository) GetByID(ctx context.Context, id uint) (*domains.WebhookEndpoint, error) {
+	var endpoint domains.WebhookEndpoint
+	err := utils.DBFromContext(ctx, r.db).First(&endpoint, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ErrNotFound{Entity: "WebhookEndpoint", ID: id}
+		}
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return &endpoint, nil
+}
+
+// ListByMerchantID returns a merchant's endpoints, disabled ones included.
+func (r *webhookEndpointRepository) ListByMerchantID(ctx context.Context, merchantID uint) ([]*domains.WebhookEndpoint, error) {
+	var endpoints []*domains.WebhookEndpoint
+	if err := utils.DBFromContext(ctx, r.db).Where("merchant_id = ?", merchantID).Order("id").Find(&endpoints).Error; err != nil {
+		return nil, &utils.ErrDatabase{Err: err}
+	}
+	return endpoints, nil
+}
+
+// Disable stops events being posted to a merchant's endpoint. It fails with
+// *utils.ErrNotFound if the merchant has no such endpoint that is enabled.
+func (r *webhookEndpointRepository) Disable(ctx context.Context, merchantID, id uint, disabledAt time.Time) error {
+	result := utils.DBFromContext(ctx, r.db).Model(&domains.WebhookEndpoint{}).
+		Where("id = ? AND merchant_id = ? AND disabled_at IS NULL", id, merchantID).
+		Update("disabled_at", disabledAt)
+	if result.Error != nil {
+		return &utils.ErrDatabase{Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ErrNotFound{Entity: "WebhookEndpoint", ID: id}
+	}
+	return nil
+}
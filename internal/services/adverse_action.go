@@ -0,0 +1,29 @@
+package service
+
+import "github.com/mohamed2394/sahla/internal/domains"
+
+// adverseActionReasons explains an automatic rejection from the features the
+// applicant was scored on. LOW_SCORE is always present; the other codes name
+// what pulled the score down.
+func adverseActionReasons(features ApplicantFeatures) []domains.DeclineReason {
+	codes := []string{domains.DeclineLowScore}
+	if !features.HasIDDocument {
+		codes = append(codes, domains.DeclineKYCIncomplete)
+	}
+	if features.LateInstallments > 0 {
+		codes = append(codes, domains.DeclineExistingDelinquency)
+	}
+	if features.OutstandingAmount > 0 && features.OutstandingAmount >= features.RequestedAmount {
+		codes = append(codes, domains.DeclineHighOutstandingBalance)
+	}
+	if features.PaidInstallments == 0 && features.SuccessfulPayments == 0 {
+		codes = append(codes, domains.DeclineInsufficientHistory)
+	}
+
+	reasons := make([]domains.DeclineReason, 0, len(codes))
+	for _, code := range codes {
+		reason, _ := domains.NewDeclineReason(code)
+		reasons = append(reasons, reason)
+	}
+	return reasons
+}
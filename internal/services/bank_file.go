@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"github.com/mohamed2394/sahla/internal/utils"
+)
+
+// BankFileFormat is the layout of the transfer files sent to the bank.
+type BankFileFormat string
+
+const (
+	// BankFileCSV files have a header row naming the fields, then a
+	// delimited row per payout.
+	BankFileCSV BankFileFormat = "CSV"
+	// BankFileFixedWidth files have a line per payout with each field
+	// padded to its width: amounts with leading zeros, text with trailing
+	// spaces.
+	BankFileFixedWidth BankFileFormat = "FIXED_WIDTH"
+)
+
+// Fields a bank file can carry for each payout
+const (
+	BankFieldReference = "reference"
+	BankFieldPayerRIB  = "payer_rib"
+	BankFieldRIB       = "rib"
+	BankFieldName      = "name"
+	BankFieldAmount    = "amount"
+	BankFieldCurrency  = "currency"
+	BankFieldDate      = "date"
+)
+
+var bankFields = []string{
+	BankFieldReference,
+	BankFieldPayerRIB,
+	BankFieldRIB,
+	BankFieldName,
+	BankFieldAmount,
+	BankFieldCurrency,
+	BankFieldDate,
+}
+
+// BankFileField is a field of a bank file line. Width is only used by fixed
+// width files.
+type BankFileField struct {
+	Name  string
+	Width int
+}
+
+// BankFileConfig describes the transfer file our bank accepts. PayerRIB is
+// the account payouts are made from.
+type BankFileConfig struct {
+	Format    BankFileFormat
+	Delimiter rune
+	Fields    []BankFileField
+	PayerRIB  string
+}
+
+// DefaultBankFileConfig returns a semicolon separated file with every field
+// but the payer RIB, which banks usually take from the account uploading the
+// file.
+func DefaultBankFileConfig() BankFileConfig {
+	return BankFileConfig{
+		Format:    BankFileCSV,
+		Delimiter: ';',
+		Fields: []BankFileField{
+			{Name: BankFieldReference, Width: 16},
+			{Name: BankFieldRIB, Width: 20},
+			{Name: BankFieldName, Width: 35},
+			{Name: BankFieldAmount, Width: 15},
+			{Name: BankFieldCurrency, Width: 3},
+			{Name: BankFieldDate, Width: 8},
+		},
+	}
+}
+
+// ParseBankFileFields reads fields such as "reference:16,rib:20,amount:15".
+// Widths may be left out for CSV files.
+func ParseBankFileFields(value string) ([]BankFileField, error) {
+	var fields []BankFileField
+	for _, spec := range strings.Split(value, ",") {
+		name, width, hasWidth := strings.Cut(strings.TrimSpace(spec), ":")
+		field := BankFileField{Name: strings.ToLower(strings.TrimSpace(name))}
+		if hasWidth {
+			n, err := strconv.Atoi(strings.TrimSpace(width))
+			if err != nil {
+				return nil, fmt.Errorf("invalid width of bank file field %q: %w", name, err)
+			}
+			field.Width = n
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func (c BankFileConfig) Validate() error {
+	if c.Format != BankFileCSV && c.Format != BankFileFixedWidth {
+		return fmt.Errorf("unknown bank file format %q", c.Format)
+	}
+	if c.Format == BankFileCSV && (c.Delimiter == 0 || c.Delimiter == '"' || c.Delimiter == '\r' || c.Delimiter == '\n' || c.Delimiter == utf8.RuneError) {
+		return fmt.Errorf("invalid bank file delimiter %q", c.Delimiter)
+	}
+	if len(c.Fields) == 0 {
+		return fmt.Errorf("a bank file needs at least one field")
+	}
+	for _, field := range c.Fields {
+		if !validBankField(field.Name) {
+			return fmt.Errorf("unknown bank file field %q", field.Name)
+		}
+		if c.Format == BankFileFixedWidth && field.Width <= 0 {
+			return fmt.Errorf("bank file field %q needs a width", field.Name)
+		}
+		if field.Name == BankFieldPayerRIB && !utils.RIBValid(c.PayerRIB) {
+			return fmt.Errorf("the payer RIB must be 20 digits with a valid key")
+		}
+	}
+	return nil
+}
+
+// Extension returns the file name extension of the format.
+func (c BankFileConfig) Extension() string {
+	if c.Format == BankFileCSV {
+		return "csv"
+	}
+	return "txt"
+}
+
+// Render lays out a bank file paying each batch its net amount.
+func (c BankFileConfig) Render(batches []*domains.SettlementBatch) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if c.Format == BankFileCSV {
+		w := csv.NewWriter(&buf)
+		w.Comma = c.Delimiter
+		header := make([]string, len(c.Fields))
+		for i, field := range c.Fields {
+			header[i] = field.Name
+		}
+		if err := w.Write(header); err != nil {
+			return nil, err
+		}
+		for _, batch := range batches {
+			record := make([]string, len(c.Fields))
+			for i, field := range c.Fields {
+				record[i] = c.value(field.Name, batch)
+			}
+			if err := w.Write(record); err != nil {
+				return nil, err
+			}
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	}
+
+	for _, batch := range batches {
+		for _, field := range c.Fields {
+			value, err := padField(field, c.value(field.Name, batch))
+			if err != nil {
+				return nil, fmt.Errorf("settlement batch %d: %w", batch.ID, err)
+			}
+			buf.WriteString(value)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func (c BankFileConfig) value(name string, batch *domains.SettlementBatch) string {
+	switch name {
+	case BankFieldReference:
+		return fmt.Sprintf("STL%d", batch.ID)
+	case BankFieldPayerRIB:
+		return c.PayerRIB
+	case BankFieldRIB:
+		return batch.RIB
+	case BankFieldName:
+		return batch.BeneficiaryName
+	case BankFieldAmount:
+		return strconv.Itoa(batch.NetAmount)
+	case BankFieldCurrency:
+		return batch.Currency
+	case BankFieldDate:
+		date, err := time.Parse("2006-01-02", batch.SettlementDate)
+		if err != nil {
+			return batch.SettlementDate
+		}
+		return date.Format("20060102")
+	}
+	return ""
+}
+
+// padField fits a value to its field's width. Names are cut short; any
+// other value that does not fit is an error rather than a wrong transfer.
+func padField(field BankFileField, value string) (string, error) {
+	length := utf8.RuneCountInString(value)
+	if length > field.Width {
+		if field.Name != BankFieldName {
+			return "", fmt.Errorf("%s %q does not fit in %d characters", field.Name, value, field.Width)
+		}
+		return string([]rune(value)[:field.Width]), nil
+	}
+	if field.Name == BankFieldAmount {
+		return strings.Repeat("0", field.Width-length) + value, nil
+	}
+	return value + strings.Repeat(" ", field.Width-length), nil
+}
+
+func validBankField(name string) bool {
+	for _, field := range bankFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
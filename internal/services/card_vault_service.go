@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidCard = errors.New("invalid card details")
+	ErrCardExpired = errors.New("card has expired")
+)
+
+const cardTokenPrefix = "card_"
+
+// CardInput is a card as entered by the customer.
+type CardInput struct {
+	Number     string
+	HolderName string
+	ExpiryDate string
+	CVV        string
+}
+
+// CardData is the sensitive part of a card, as kept encrypted in the vault.
+type CardData struct {
+	Number     string `json:"number"`
+	HolderName string `json:"holder_name"`
+}
+
+type CardVaultServiceInterface interface {
+	Tokenize(ctx context.Context, userID string, card CardInput) (*domains.CardVaultEntry, error)
+	GetCard(ctx context.Context, userID, token string) (*domains.CardVaultEntry, error)
+}
+
+// CardVaultService exchanges cards for opaque tokens. Card numbers and holder
+// names are encrypted at rest; CVVs are checked for shape and dropped.
+type CardVaultService struct {
+	repo           repository.CardVaultRepository
+	cipher         *utils.Cipher
+	fingerprintKey []byte
+	logger         *zap.Logger
+}
+
+// NewCardVaultService creates a CardVaultService that encrypts cards with the
+// given 32 byte key.
+func NewCardVaultService(repo repository.CardVaultRepository, key []byte, logger *zap.Logger) (*CardVaultService, error) {
+	cipher, err := utils.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid card vault key: %w", err)
+	}
+
+	// Fingerprints use a key derived from, but distinct from, the encryption key
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("card-fingerprint"))
+
+	return &CardVaultService{
+		repo:           repo,
+		cipher:         cipher,
+		fingerprintKey: mac.Sum(nil),
+		logger:         logger,
+	}, nil
+}
+
+// Tokenize stores a card for userID and returns its vault entry. Tokenizing
+// the same card twice returns the existing token.
+func (s *CardVaultService) Tokenize(ctx context.Context, userID string, card CardInput) (*domains.CardVaultEntry, error) {
+	number := utils.NormalizeCardNumber(card.Number)
+	if !utils.LuhnValid(number) {
+		return nil, fmt.Errorf("%w: card number", ErrInvalidCard)
+	}
+	if len(card.CVV) < 3 || len(card.CVV) > 4 || !isDigits(card.CVV) {
+		return nil, fmt.Errorf("%w: cvv", ErrInvalidCard)
+	}
+	expiresAt, err := utils.ParseCardExpiry(card.ExpiryDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCard, err)
+	}
+	if !time.Now().Before(expiresAt) {
+		return nil, ErrCardExpired
+	}
+
+	var notFoundErr *utils.ErrNotFound
+	fingerprint := s.fingerprint(number)
+	existing, err := s.repo.GetByFingerprint(ctx, userID, fingerprint, card.ExpiryDate)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.As(err, &notFoundErr) {
+		s.logger.Error("Failed to look up card", zap.Error(err))
+		return nil, fmt.Errorf("failed to look up card: %w", err)
+	}
+
+	token, err := newCardToken()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(CardData{Number: number, HolderName: card.HolderName})
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.cipher.Encrypt(plaintext, []byte(token))
+	if err != nil {
+		s.logger.Error("Failed to encrypt card", zap.Error(err))
+		return nil, fmt.Errorf("failed to encrypt card: %w", err)
+	}
+
+	entry := &domains.CardVaultEntry{
+		Token:         token,
+		UserID:        userID,
+		Fingerprint:   fingerprint,
+		EncryptedCard: encrypted,
+		Brand:         utils.CardBrandOf(number),
+		Last4:         utils.CardLast4(number),
+		ExpiryDate:    card.ExpiryDate,
+	}
+	if err := s.repo.Create(ctx, entry); err != nil {
+		s.logger.Error("Failed to store card", zap.Error(err))
+		return nil, fmt.Errorf("failed to store card: %w", err)
+	}
+
+	s.logger.Info("Card tokenized",
+		zap.String("userID", userID),
+		zap.String("brand", entry.Brand),
+		zap.String("last4", entry.Last4))
+	return entry, nil
+}
+
+// GetCard returns the vault entry for token if it belongs to userID.
+func (s *CardVaultService) GetCard(ctx context.Context, userID, token string) (*domains.CardVaultEntry, error) {
+	entry, err := s.repo.GetByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if entry.UserID != userID {
+		// Do not reveal that the token exists
+		return nil, &utils.ErrNotFound{Entity: "Card", ID: token}
+	}
+	return entry, nil
+}
+
+// Reveal decrypts the card behind token. It is meant for passing cards on to
+// the payment gateway and must never be used to build API responses or logs.
+func (s *CardVaultService) Reveal(ctx context.Context, token string) (*CardData, error) {
+	entry, err := s.repo.GetByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := s.cipher.Decrypt(entry.EncryptedCard, []byte(entry.Token))
+	if err != nil {
+		s.logger.Error("Failed to decrypt card", zap.String("token", token), zap.Error(err))
+		return nil, fmt.Errorf("failed to decrypt card: %w", err)
+	}
+
+	var card CardData
+	if err := json.Unmarshal(plaintext, &card); err != nil {
+		return nil, fmt.Errorf("failed to decode card: %w", err)
+	}
+	return &card, nil
+}
+
+func (s *CardVaultService) fingerprint(number string) string {
+	mac := hmac.New(sha256.New, s.fingerprintKey)
+	mac.Write([]byte(number))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newCardToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return cardTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"go.uber.org/zap"
+)
+
+const testCardNumber = "4111111111111111"
+
+func newTestCardVault(t *testing.T) (*CardVaultService, *fakeCardVaultRepo) {
+	t.Helper()
+	repo := &fakeCardVaultRepo{entries: map[string]*domains.CardVaultEntry{}}
+	vault, err := NewCardVaultService(repo, bytes.Repeat([]byte{7}, 32), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewCardVaultService: %v", err)
+	}
+	return vault, repo
+}
+
+func validExpiry() string {
+	next := time.Now().AddDate(2, 0, 0)
+	return fmt.Sprintf("%02d/%02d", next.Month(), next.Year()%100)
+}
+
+func TestCardVaultTokenizeAndReveal(t *testing.T) {
+	vault, _ := newTestCardVault(t)
+	ctx := context.Background()
+
+	entry, err := vault.Tokenize(ctx, testUserID, CardInput{Number: "4111 1111 1111 1111", HolderName: "A. Customer", ExpiryDate: validExpiry(), CVV: "123"})
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	if entry.Last4 != "1111" || bytes.Contains(entry.EncryptedCard, []byte(testCardNumber)) {
+		t.Fatalf("vault entry keeps last4 %q and must not hold the card number in clear", entry.Last4)
+	}
+
+	card, err := vault.Reveal(ctx, entry.Token)
+	if err != nil {
+		t.Fatalf("Reveal: %v", err)
+	}
+	if card.Number != testCardNumber || card.HolderName != "A. Customer" {
+		t.Fatalf("revealed %+v, want the tokenized card", card)
+	}
+
+	again, err := vault.Tokenize(ctx, testUserID, CardInput{Number: testCardNumber, ExpiryDate: validExpiry(), CVV: "456"})
+	if err != nil {
+		t.Fatalf("Tokenize again: %v", err)
+	}
+	if again.Token != entry.Token {
+		t.Fatalf("same card tokenized as %s and %s, want one token", entry.Token, again.Token)
+	}
+}
+
+func TestCardVaultGetCardHidesOtherUsersCards(t *testing.T) {
+	vault, _ := newTestCardVault(t)
+	ctx := context.Background()
+
+	entry, err := vault.Tokenize(ctx, testUserID, CardInput{Number: testCardNumber, ExpiryDate: validExpiry(), CVV: "123"})
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	if _, err := vault.GetCard(ctx, testUserID, entry.Token); err != nil {
+		t.Fatalf("GetCard: %v", err)
+	}
+	if _, err := vault.GetCard(ctx, "0b6c3b1e-8d1f-4d5e-9a4f-3c2b1a0f9e8d", entry.Token); err == nil {
+		t.Fatal("another user got the card")
+	}
+}
+
+func TestCardVaultRevealRejectsSwappedCiphertext(t *testing.T) {
+	vault, repo := newTestCardVault(t)
+	ctx := context.Background()
+
+	first, err := vault.Tokenize(ctx, testUserID, CardInput{Number: testCardNumber, ExpiryDate: validExpiry(), CVV: "123"})
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	second, err := vault.Tokenize(ctx, testUserID, CardInput{Number: "5555555555554444", ExpiryDate: validExpiry(), CVV: "123"})
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+
+	// Ciphertexts are bound to their token
+	repo.entries[second.Token].EncryptedCard = first.EncryptedCard
+	if _, err := vault.Reveal(ctx, second.Token); err == nil {
+		t.Fatal("revealed a card under another card's token")
+	}
+}
+
+func TestCardVaultTokenizeRejectsInvalidCards(t *testing.T) {
+	tests := []struct {
+		name string
+		card CardInput
+		want error
+	}{
+		{"bad checksum", CardInput{Number: "4111111111111112", ExpiryDate: validExpiry(), CVV: "123"}, ErrInvalidCard},
+		{"short cvv", CardInput{Number: testCardNumber, ExpiryDate: validExpiry(), CVV: "12"}, ErrInvalidCard},
+		{"letters in cvv", CardInput{Number: testCardNumber, ExpiryDate: validExpiry(), CVV: "12a"}, ErrInvalidCard},
+		{"malformed expiry", CardInput{Number: testCardNumber, ExpiryDate: "2030-01", CVV: "123"}, ErrInvalidCard},
+		{"expired", CardInput{Number: testCardNumber, ExpiryDate: "01/20", CVV: "123"}, ErrCardExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault, repo := newTestCardVault(t)
+			if _, err := vault.Tokenize(context.Background(), testUserID, tt.card); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if len(repo.entries) != 0 {
+				t.Fatal("invalid card stored")
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidCheckout = errors.New("invalid checkout session")
+	ErrCheckoutClosed  = errors.New("checkout session is closed")
+)
+
+const (
+	// CheckoutExpiryJobName is the JobRunner name of the checkout session
+	// expiry sweep.
+	CheckoutExpiryJobName = "checkout-session-expiry"
+	// DefaultCheckoutSessionTTL is how long customers have to pay a checkout
+	// session.
+	DefaultCheckoutSessionTTL = 30 * time.Minute
+	// checkoutExpiryBatchSize caps how many sessions one sweep expires.
+	checkoutExpiryBatchSize = 100
+)
+
+type CheckoutServiceInterface interface {
+	CreateSession(ctx context.Context, session *domains.CheckoutSession) error
+	GetSession(ctx context.Context, id string) (*domains.CheckoutSession, error)
+	GetMerchantSession(ctx context.Context, merchantID uint, id string) (*domains.CheckoutSession, error)
+	CancelSession(ctx context.Context, merchantID uint, id string) (*domains.CheckoutSession, error)
+	CompleteSession(ctx context.Context, id string, payment *domains.Payment) (*domains.CheckoutSession, error)
+	ConfirmReturn(ctx context.Context, gatewayOrderID string) (*domains.CheckoutSession, *domains.Payment, error)
+	GetMerchant(ctx context.Context, merchantID uint) (*domains.Merchant, error)
+	SessionURL(id string) string
+}
+
+// CheckoutService runs hosted checkouts: a merchant opens a session for an
+// order and sends its customer to it, the customer signs in, picks a plan and
+// pays, and the payment is made under the merchant's order reference.
+type CheckoutService struct {
+	checkoutRepo repository.CheckoutSessionRepository
+	payments     *CreditPaymentService
+	merchants    *MerchantService
+	events       *MerchantEventService
+	txManager    *utils.TransactionManager
+	baseURL      string
+	sessionTTL   time.Duration
+	logger       *zap.Logger
+}
+
+// NewCheckoutService creates a CheckoutService whose sessions are paid at
+// baseURL. A zero sessionTTL uses DefaultCheckoutSessionTTL.
+func NewCheckoutService(
+	checkoutRepo repository.CheckoutSessionRepository,
+	payments *CreditPaymentService,
+	merchants *MerchantService,
+	events *MerchantEventService,
+	txManager *utils.TransactionManager,
+	baseURL string,
+	sessionTTL time.Duration,
+	logger *zap.Logger,
+) *CheckoutService {
+	if sessionTTL <= 0 {
+		sessionTTL = DefaultCheckoutSessionTTL
+	}
+	return &CheckoutService{
+		checkoutRepo: checkoutRepo,
+		payments:     payments,
+		merchants:    merchants,
+		events:       events,
+		txManager:    txManager,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		sessionTTL:   sessionTTL,
+		logger:       logger,
+	}
+}
+
+// SessionURL returns the page customers pay a session at.
+func (s *CheckoutService) SessionURL(id string) string {
+	return s.baseURL + "/checkout/" + id
+}
+
+// CreateSession opens a checkout session for an order of an active merchant.
+// The order lines must add up to the amount.
+func (s *CheckoutService) CreateSession(ctx context.Context, session *domains.CheckoutSession) error {
+	if session.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	session.OrderReference = strings.TrimSpace(session.OrderReference)
+	if session.OrderReference == "" {
+		return fmt.Errorf("%w: an order reference is required", ErrInvalidCheckout)
+	}
+	if len(session.Lines) == 0 {
+		return fmt.Errorf("%w: at least one order line is required", ErrInvalidCheckout)
+	}
+	total := 0
+	for _, line := range session.Lines {
+		if line.Quantity <= 0 || line.UnitPrice <= 0 {
+			return fmt.Errorf("%w: order lines need a positive quantity and unit price", ErrInvalidCheckout)
+		}
+		total += line.Total()
+	}
+	if total != session.Amount {
+		return fmt.Errorf("%w: order lines add up to %d, not %d", ErrInvalidCheckout, total, session.Amount)
+	}
+
+	if _, err := s.merchants.ResolvePaymentMerchant(ctx, session.MerchantID, session.StoreID); err != nil {
+		return err
+	}
+
+	session.ID = uuid.Must(uuid.NewV4()).String()
+	session.Status = domains.CheckoutOpen
+	session.UserID = ""
+	session.PaymentID = nil
+	session.CompletedAt = nil
+	session.ExpiresAt = time.Now().Add(s.sessionTTL)
+	if err := s.checkoutRepo.Create(ctx, session); err != nil {
+		s.logger.Error("Failed to create checkout session", zap.Uint("merchantID", session.MerchantID), zap.Error(err))
+		return fmt.Errorf("failed to create checkout session: %w", err)
+	}
+
+	s.logger.Info("Checkout session created", zap.String("sessionID", session.ID),
+		zap.Uint("merchantID", session.MerchantID), zap.String("orderReference", session.OrderReference))
+	return nil
+}
+
+// GetSession returns a checkout session. Open sessions past their expiry are
+// expired on the way.
+func (s *CheckoutService) GetSession(ctx context.Context, id string) (*domains.CheckoutSession, error) {
+	session, err := s.checkoutRepo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get checkout session", zap.String("sessionID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get checkout session: %w", err)
+	}
+
+	if session.Status == domains.CheckoutOpen && !time.Now().Before(session.ExpiresAt) {
+		if err := s.expire(ctx, session); err != nil {
+			return nil, err
+		}
+		if session, err = s.checkoutRepo.GetByID(ctx, id); err != nil {
+			s.logger.Error("Failed to get checkout session", zap.String("sessionID", id), zap.Error(err))
+			return nil, fmt.Errorf("failed to get checkout session: %w", err)
+		}
+	}
+	return session, nil
+}
+
+// GetMerchantSession returns a checkout session of a merchant. Sessions of
+// other merchants are reported as not found.
+func (s *CheckoutService) GetMerchantSession(ctx context.Context, merchantID uint, id string) (*domains.CheckoutSession, error) {
+	session, err := s.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.MerchantID != merchantID {
+		return nil, &utils.ErrNotFound{Entity: "CheckoutSession", ID: id}
+	}
+	return session, nil
+}
+
+// CancelSession closes an open session of a merchant. Sessions with a payment
+// under way cannot be cancelled.
+func (s *CheckoutService) CancelSession(ctx context.Context, merchantID uint, id string) (*domains.CheckoutSession, error) {
+	session, err := s.GetMerchantSession(ctx, merchantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkoutRepo.UpdateStatus(ctx, id, domains.CheckoutCancelled, domains.CheckoutOpen); err != nil {
+		s.logger.Error("Failed to cancel checkout session", zap.String("sessionID", id), zap.String("status", string(session.Status)), zap.Error(err))
+		return nil, fmt.Errorf("failed to cancel checkout session: %w", err)
+	}
+
+	s.logger.Info("Checkout session cancelled", zap.String("sessionID", id), zap.Uint("merchantID", merchantID))
+	return s.GetSession(ctx, id)
+}
+
+// CompleteSession pays an open session with payment, which carries the
+// customer, their credit application, card and chosen plan. The payment is
+// made at the session's merchant and store for its amount, with the order
+// reference as its OrderID. The session is linked to the payment in the
+// transaction that records it, so a session never awaits a payment that does
+// not exist; one whose payment fails at the gateway is opened again.
+func (s *CheckoutService) CompleteSession(ctx context.Context, id string, payment *domains.Payment) (*domains.CheckoutSession, error) {
+	session, err := s.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != domains.CheckoutOpen {
+		return nil, fmt.Errorf("%w: session is %s", ErrCheckoutClosed, strings.ToLower(string(session.Status)))
+	}
+
+	payment.OrderID = session.OrderReference
+	payment.MerchantID = session.MerchantID
+	payment.StoreID = session.StoreID
+	payment.Amount = session.Amount
+	payment.Currency = session.Currency
+	payment.ReturnPath = CheckoutReturnPath
+	err = s.payments.createPayment(ctx, payment, func(txCtx context.Context) error {
+		if err := s.checkoutRepo.StartPayment(txCtx, id, payment.UserID, time.Now()); err != nil {
+			s.logger.Error("Failed to start checkout payment", zap.String("sessionID", id), zap.Error(err))
+			return fmt.Errorf("failed to start checkout payment: %w", err)
+		}
+		if err := s.checkoutRepo.SetPayment(txCtx, id, payment.ID); err != nil {
+			s.logger.Error("Failed to link payment to checkout session", zap.String("sessionID", id), zap.Uint("paymentID", payment.ID), zap.Error(err))
+			return fmt.Errorf("failed to link payment to checkout session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to create checkout payment", zap.String("sessionID", id), zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Checkout session payment created", zap.String("sessionID", id), zap.Uint("paymentID", payment.ID))
+	return s.GetSession(ctx, id)
+}
+
+// ConfirmReturn is called when the gateway sends a checkout customer back. It
+// confirms the payment with the gateway and returns it with its session.
+func (s *CheckoutService) ConfirmReturn(ctx context.Context, gatewayOrderID string) (*domains.CheckoutSession, *domains.Payment, error) {
+	payment, err := s.payments.ConfirmPaymentOrder(ctx, gatewayOrderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := s.checkoutRepo.GetByPaymentID(ctx, payment.ID)
+	if err != nil {
+		s.logger.Error("Failed to get checkout session", zap.Uint("paymentID", payment.ID), zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to get checkout session: %w", err)
+	}
+	return session, payment, nil
+}
+
+// GetMerchant returns the merchant a session is paid to.
+func (s *CheckoutService) GetMerchant(ctx context.Context, merchantID uint) (*domains.Merchant, error) {
+	return s.merchants.GetMerchant(ctx, merchantID)
+}
+
+// ExpireSessions expires open sessions that were not paid in time and tells
+// their merchants.
+func (s *CheckoutService) ExpireSessions(ctx context.Context) error {
+	sessions, err := s.checkoutRepo.ListExpired(ctx, time.Now(), checkoutExpiryBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list expired checkout sessions", zap.Error(err))
+		return fmt.Errorf("failed to list expired checkout sessions: %w", err)
+	}
+
+	for _, session := range sessions {
+		if err := s.expire(ctx, session); err != nil {
+			s.logger.Error("Failed to expire checkout session", zap.String("sessionID", session.ID), zap.Error(err))
+		}
+	}
+
+	if len(sessions) > 0 {
+		s.logger.Info("Expired checkout sessions", zap.Int("count", len(sessions)))
+	}
+	return nil
+}
+
+// expire moves an open session, or a pending one whose payment is over, to
+// EXPIRED and records the merchant's event. Sessions paid or cancelled
+// meanwhile are left alone.
+func (s *CheckoutService) expire(ctx context.Context, session *domains.CheckoutSession) error {
+	err := s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.checkoutRepo.UpdateStatus(txCtx, session.ID, domains.CheckoutExpired, domains.CheckoutOpen, domains.CheckoutPending); err != nil {
+			return err
+		}
+		return s.events.Record(txCtx, session.MerchantID, domains.EventCheckoutSessionExpired, CheckoutSessionEventPayload{
+			CheckoutSessionID: session.ID,
+			OrderReference:    session.OrderReference,
+			Amount:            session.Amount,
+			Currency:          session.Currency,
+			Status:            string(domains.CheckoutExpired),
+		})
+	})
+
+	var transitionErr *utils.ErrInvalidTransition
+	if errors.As(err, &transitionErr) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to expire checkout session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+// ActorSystem is recorded as the actor of transitions made automatically.
+const ActorSystem = "system"
+
+// CreditApplicationLifecycle moves credit applications between statuses and
+// writes every transition to the history table.
+type CreditApplicationLifecycle struct {
+	creditAppRepo  repository.CreditApplicationRepository
+	transitionRepo repository.CreditApplicationTransitionRepository
+	txManager      *utils.TransactionManager
+	logger         *zap.Logger
+}
+
+func NewCreditApplicationLifecycle(
+	creditAppRepo repository.CreditApplicationRepository,
+	transitionRepo repository.CreditApplicationTransitionRepository,
+	txManager *utils.TransactionManager,
+	logger *zap.Logger,
+) *CreditApplicationLifecycle {
+	return &CreditApplicationLifecycle{
+		creditAppRepo:  creditAppRepo,
+		transitionRepo: transitionRepo,
+		txManager:      txManager,
+		logger:         logger,
+	}
+}
+
+// Transition moves app to the given status. Illegal transitions fail with
+// *utils.ErrInvalidTransition and leave app untouched. The status change and
+// its history row are written in one transaction.
+func (l *CreditApplicationLifecycle) Transition(ctx context.Context, app *domains.CreditApplication, to domains.CreditApplicationStatus, actor, reason string) error {
+	from := app.Status
+	if !from.CanTransitionTo(to) {
+		l.logger.Warn("Rejected illegal credit application transition",
+			zap.Uint("id", app.ID), zap.String("from", string(from)), zap.String("to", string(to)))
+		return &utils.ErrInvalidTransition{Entity: "CreditApplication", ID: app.ID, From: string(from), To: string(to)}
+	}
+
+	transition := &domains.CreditApplicationTransition{
+		CreditApplicationID: app.ID,
+		FromStatus:          from,
+		ToStatus:            to,
+		Actor:               actor,
+		Reason:              reason,
+		TransitionedAt:      time.Now(),
+	}
+	err := l.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := l.creditAppRepo.UpdateStatus(txCtx, app.ID, from, to); err != nil {
+			return err
+		}
+		if err := l.transitionRepo.Create(txCtx, transition); err != nil {
+			return fmt.Errorf("failed to record credit application transition: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	app.Status = to
+
+	l.logger.Info("Credit application transitioned",
+		zap.Uint("id", app.ID),
+		zap.String("from", string(from)),
+		zap.String("to", string(to)),
+		zap.String("actor", actor))
+	return nil
+}
+
+// History returns the transitions of a credit application, oldest first.
+func (l *CreditApplicationLifecycle) History(ctx context.Context, creditAppID uint) ([]*domains.CreditApplicationTransition, error) {
+	return l.transitionRepo.GetByCreditApplicationID(ctx, creditAppID)
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrCreditLineFrozen = errors.New("credit line is frozen")
+	ErrCreditLineBusy   = errors.New("credit line is being updated concurrently, try again")
+)
+
+const (
+	// DefaultReservationTTL is how long a pending payment may hold credit
+	// before the sweeper releases it.
+	DefaultReservationTTL = 15 * time.Minute
+	// maxReserveAttempts bounds the optimistic retry loop when reserving.
+	maxReserveAttempts = 5
+)
+
+type CreditLineServiceInterface interface {
+	GetCreditLine(ctx context.Context, userID string) (*domains.CreditLine, error)
+}
+
+// CreditLineService maintains the per-user credit line: approvals raise its
+// limit, pending payments reserve part of it, successful purchases draw it
+// down and paid installments restore it.
+type CreditLineService struct {
+	creditLineRepo  repository.CreditLineRepository
+	reservationRepo repository.CreditReservationRepository
+	reservationTTL  time.Duration
+	logger          *zap.Logger
+}
+
+// NewCreditLineService creates a CreditLineService. A zero reservationTTL uses
+// DefaultReservationTTL.
+func NewCreditLineService(
+	creditLineRepo repository.CreditLineRepository,
+	reservationRepo repository.CreditReservationRepository,
+	reservationTTL time.Duration,
+	logger *zap.Logger,
+) *CreditLineService {
+	if reservationTTL <= 0 {
+		reservationTTL = DefaultReservationTTL
+	}
+	return &CreditLineService{
+		creditLineRepo:  creditLineRepo,
+		reservationRepo: reservationRepo,
+		reservationTTL:  reservationTTL,
+		logger:          logger,
+	}
+}
+
+func (s *CreditLineService) GetCreditLine(ctx context.Context, userID string) (*domains.CreditLine, error) {
+	line, err := s.creditLineRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get credit line", zap.String("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get credit line: %w", err)
+	}
+	return line, nil
+}
+
+// EnsureLimit opens the user's credit line or raises its limit to limit.
+// Approving several applications never stacks their amounts.
+func (s *CreditLineService) EnsureLimit(ctx context.Context, userID, currency string, limit int) (*domains.CreditLine, error) {
+	line := &domains.CreditLine{
+		UserID:      userID,
+		Currency:    currency,
+		CreditLimit: limit,
+		Status:      domains.CreditLineActive,
+	}
+	if err := s.creditLineRepo.Open(ctx, line); err != nil {
+		s.logger.Error("Failed to open credit line", zap.String("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to open credit line: %w", err)
+	}
+
+	s.logger.Info("Credit line limit ensured", zap.String("userID", userID), zap.Int("limit", line.CreditLimit))
+	return line, nil
+}
+
+// CheckAvailable fails with ErrInsufficientCredit or ErrCreditLineFrozen when
+// the user cannot spend amount.
+func (s *CreditLineService) CheckAvailable(ctx context.Context, userID string, amount int) error {
+	line, err := s.GetCreditLine(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.checkAvailable(line, amount)
+}
+
+func (s *CreditLineService) checkAvailable(line *domains.CreditLine, amount int) error {
+	if line.Status != domains.CreditLineActive {
+		return ErrCreditLineFrozen
+	}
+
+	if amount > line.Available() {
+		s.logger.Warn("Insufficient credit for payment",
+			zap.String("userID", line.UserID),
+			zap.Int("requestedAmount", amount),
+			zap.Int("availableCredit", line.Available()))
+		return ErrInsufficientCredit
+	}
+
+	return nil
+}
+
+// Reserve atomically holds amount of the user's line. The check and the hold
+// are applied with a versioned update, so parallel payments can never reserve
+// more than is available between them.
+func (s *CreditLineService) Reserve(ctx context.Context, userID string, amount int) error {
+	for attempt := 0; attempt < maxReserveAttempts; attempt++ {
+		line, err := s.GetCreditLine(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkAvailable(line, amount); err != nil {
+			return err
+		}
+
+		line.Reserved += amount
+		err = s.creditLineRepo.UpdateVersioned(ctx, line)
+		if err == nil {
+			s.logger.Info("Credit reserved", zap.String("userID", userID), zap.Int("amount", amount))
+			return nil
+		}
+
+		var conflictErr *utils.ErrVersionConflict
+		if !errors.As(err, &conflictErr) {
+			s.logger.Error("Failed to reserve credit", zap.String("userID", userID), zap.Error(err))
+			return fmt.Errorf("failed to reserve credit: %w", err)
+		}
+		s.logger.Debug("Credit line version conflict, retrying reservation",
+			zap.String("userID", userID), zap.Int("attempt", attempt+1))
+	}
+
+	s.logger.Warn("Gave up reserving credit after repeated conflicts", zap.String("userID", userID))
+	return ErrCreditLineBusy
+}
+
+// TrackReservation records that amount reserved on the user's line belongs to
+// paymentID, so it can later be captured, released or expired.
+func (s *CreditLineService) TrackReservation(ctx context.Context, userID string, paymentID uint, amount int) error {
+	reservation := &domains.CreditReservation{
+		UserID:    userID,
+		PaymentID: paymentID,
+		Amount:    amount,
+		Status:    domains.CreditReservationActive,
+		ExpiresAt: time.Now().Add(s.reservationTTL),
+	}
+	if err := s.reservationRepo.Create(ctx, reservation); err != nil {
+		s.logger.Error("Failed to record credit reservation", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return fmt.Errorf("failed to record credit reservation: %w", err)
+	}
+	return nil
+}
+
+// Unreserve gives back a hold made by Reserve that was never tracked against
+// a payment.
+func (s *CreditLineService) Unreserve(ctx context.Context, userID string, amount int) error {
+	if err := s.creditLineRepo.Adjust(ctx, userID, 0, -amount); err != nil {
+		s.logger.Error("Failed to release credit", zap.String("userID", userID), zap.Error(err))
+		return fmt.Errorf("failed to release credit: %w", err)
+	}
+	return nil
+}
+
+// Capture turns the reservation of a successful payment into utilized credit.
+// A payment whose reservation has already expired or was never made is drawn
+// directly, since the purchase went through regardless.
+func (s *CreditLineService) Capture(ctx context.Context, payment *domains.Payment) error {
+	reservedDelta := 0
+	reservation, err := s.settleReservation(ctx, payment.ID, domains.CreditReservationCaptured)
+	if err != nil {
+		return err
+	}
+	if reservation != nil {
+		reservedDelta = -reservation.Amount
+	}
+
+	if err := s.creditLineRepo.Adjust(ctx, payment.UserID, payment.Amount, reservedDelta); err != nil {
+		s.logger.Error("Failed to draw down credit line", zap.String("userID", payment.UserID), zap.Error(err))
+		return fmt.Errorf("failed to draw down credit line: %w", err)
+	}
+	return nil
+}
+
+// Release gives the credit held by a payment back to the user's line. It is a
+// no-op when the reservation has already been settled.
+func (s *CreditLineService) Release(ctx context.Context, paymentID uint) error {
+	_, err := s.releaseReservation(ctx, paymentID, domains.CreditReservationReleased)
+	return err
+}
+
+// ExpireReservations releases reservations whose TTL has passed and returns
+// the IDs of the payments they belonged to.
+func (s *CreditLineService) ExpireReservations(ctx context.Context, now time.Time, limit int) ([]uint, error) {
+	reservations, err := s.reservationRepo.ListExpired(ctx, now, limit)
+	if err != nil {
+		s.logger.Error("Failed to list expired credit reservations", zap.Error(err))
+		return nil, fmt.Errorf("failed to list expired credit reservations: %w", err)
+	}
+
+	var paymentIDs []uint
+	for _, reservation := range reservations {
+		released, err := s.releaseReservation(ctx, reservation.PaymentID, domains.CreditReservationExpired)
+		if err != nil {
+			return paymentIDs, err
+		}
+		if released {
+			paymentIDs = append(paymentIDs, reservation.PaymentID)
+		}
+	}
+	return paymentIDs, nil
+}
+
+func (s *CreditLineService) releaseReservation(ctx context.Context, paymentID uint, status domains.CreditReservationStatus) (bool, error) {
+	reservation, err := s.settleReservation(ctx, paymentID, status)
+	if err != nil || reservation == nil {
+		return false, err
+	}
+
+	if err := s.Unreserve(ctx, reservation.UserID, reservation.Amount); err != nil {
+		return false, err
+	}
+	s.logger.Info("Credit reservation released",
+		zap.Uint("paymentID", paymentID),
+		zap.String("status", string(status)),
+		zap.Int("amount", reservation.Amount))
+	return true, nil
+}
+
+// settleReservation moves the active reservation of a payment to status. It
+// returns nil when there is no active reservation left to settle.
+func (s *CreditLineService) settleReservation(ctx context.Context, paymentID uint, status domains.CreditReservationStatus) (*domains.CreditReservation, error) {
+	var notFoundErr *utils.ErrNotFound
+
+	reservation, err := s.reservationRepo.GetByPaymentID(ctx, paymentID)
+	if errors.As(err, &notFoundErr) {
+		return nil, nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to get credit reservation", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get credit reservation: %w", err)
+	}
+
+	settled, err := s.reservationRepo.Settle(ctx, reservation.ID, status)
+	if err != nil {
+		s.logger.Error("Failed to settle credit reservation", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to settle credit reservation: %w", err)
+	}
+	if !settled {
+		return nil, nil
+	}
+	return reservation, nil
+}
+
+// Restore gives amount back to the user's line once it has been repaid.
+func (s *CreditLineService) Restore(ctx context.Context, userID string, amount int) error {
+	if err := s.creditLineRepo.Adjust(ctx, userID, -amount, 0); err != nil {
+		s.logger.Error("Failed to restore credit line", zap.String("userID", userID), zap.Error(err))
+		return fmt.Errorf("failed to restore credit line: %w", err)
+	}
+	return nil
+}
+
+// Freeze stops the user from spending their credit line, e.g. after a
+// default. Outstanding installments are still collected.
+func (s *CreditLineService) Freeze(ctx context.Context, userID string) error {
+	if err := s.creditLineRepo.SetStatus(ctx, userID, domains.CreditLineFrozen); err != nil {
+		s.logger.Error("Failed to freeze credit line", zap.String("userID", userID), zap.Error(err))
+		return fmt.Errorf("failed to freeze credit line: %w", err)
+	}
+	s.logger.Warn("Credit line frozen", zap.String("userID", userID))
+	return nil
+}
+
+// Unfreeze lets the user spend their credit line again once the defaults
+// that froze it are paid.
+func (s *CreditLineService) Unfreeze(ctx context.Context, userID string) error {
+	if err := s.creditLineRepo.SetStatus(ctx, userID, domains.CreditLineActive); err != nil {
+		s.logger.Error("Failed to unfreeze credit line", zap.String("userID", userID), zap.Error(err))
+		return fmt.Errorf("failed to unfreeze credit line: %w", err)
+	}
+	s.logger.Info("Credit line unfrozen", zap.String("userID", userID))
+	return nil
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 	repository "github.com/mohamed2394/sahla/internal/repositories"
 
+	"github.com/gofrs/uuid"
 	"github.com/mohamed2394/sahla/internal/domains"
 	"github.com/mohamed2394/sahla/internal/utils"
 	"go.uber.org/zap"
@@ -16,36 +18,135 @@ var (
 	ErrInsufficientCredit = errors.New("insufficient credit")
 	ErrInvalidAmount      = errors.New("invalid amount")
 	ErrPaymentFailed      = errors.New("payment processing failed")
+	ErrGatewayTimeout     = errors.New("payment gateway timed out")
+	// ErrInstallmentBeingCollected is returned when a customer tries to pay
+	// an installment while it is being charged to the card on file.
+	ErrInstallmentBeingCollected = errors.New("installment is being charged to the card on file, try again later")
 )
+
+// reservationSweepBatchSize caps how many expired reservations one sweep
+// releases.
+const reservationSweepBatchSize = 100
+
+// payableInstallmentStatuses are the installments a customer can pay by hand,
+// defaulted ones included.
+var payableInstallmentStatuses = []string{"PENDING", "FAILED", "OVERDUE", "DEFAULTED"}
+
 type CreditPaymentServiceInterface interface {
 	CreateCreditApplication(ctx context.Context, app *domains.CreditApplication) error
-	ApproveCreditApplication(ctx context.Context, id uint) error
+	GetCreditApplication(ctx context.Context, id uint) (*domains.CreditApplication, error)
+	ApproveCreditApplication(ctx context.Context, id uint) (*domains.CreditApplication, error)
+	CancelCreditApplication(ctx context.Context, id uint, actor, reason string) error
+	GetCreditApplicationHistory(ctx context.Context, id uint) ([]*domains.CreditApplicationTransition, error)
 	CreatePayment(ctx context.Context, payment *domains.Payment) error
 	HandlePaymentWebhook(ctx context.Context, paymentID uint, status string) error
-	ProcessPayment(ctx context.Context, paymentID uint) error
-	ProcessInstallment(ctx context.Context, installmentID uint) error
+	ProcessPayment(ctx context.Context, paymentID uint) (*domains.Payment, error)
+	ConfirmPaymentOrder(ctx context.Context, gatewayOrderID string) (*domains.Payment, error)
+	ProcessInstallment(ctx context.Context, installmentID uint) (*domains.Installment, error)
+	ConfirmInstallmentOrder(ctx context.Context, gatewayOrderID string) (*domains.Installment, error)
 	HandleInstallmentWebhook(ctx context.Context, installmentID uint, status string) error
 	GetPaymentDetails(ctx context.Context, paymentID uint) (*domains.Payment, error)
+	GetInstallment(ctx context.Context, installmentID uint) (*domains.Installment, error)
 }
 
 type CreditPaymentService struct {
 	creditAppRepo   repository.CreditApplicationRepository
 	paymentRepo     repository.PaymentRepository
 	installmentRepo repository.InstallmentRepository
+	userRepo        repository.UserRepository
+	reviewRepo      repository.ManualReviewRepository
+	creditScorer    CreditScorer
+	policy          UnderwritingPolicy
+	lifecycle       *CreditApplicationLifecycle
+	creditLines     *CreditLineService
+	plans           *PlanProductService
+	dunning         *DunningService
+	penalties       *PenaltyService
+	refunds         *RefundService
+	ledger          *LedgerService
+	merchants       *MerchantService
+	merchantEvents  *MerchantEventService
+	txManager       *utils.TransactionManager
 	logger          *zap.Logger
 	paymentGateway  PaymentGateway
 }
 
+// Return paths the gateway sends customers back to after paying
+const (
+	PaymentReturnPath     = "/payments/return"
+	InstallmentReturnPath = "/installments/return"
+	PrepaymentReturnPath  = "/prepayments/return"
+	CheckoutReturnPath    = "/checkout/return"
+)
+
+// GatewayOrderRequest describes an order to register with the payment gateway.
+type GatewayOrderRequest struct {
+	OrderNumber string
+	Amount      int
+	Currency    string
+	ReturnPath  string
+	Description string
+}
+
+// GatewayOrder is a registered order and the page where the customer pays it.
+type GatewayOrder struct {
+	OrderID     string
+	RedirectURL string
+}
+
+// GatewayOrderStatus is the outcome of a gateway order.
+type GatewayOrderStatus string
+
+const (
+	GatewayOrderPending  GatewayOrderStatus = "PENDING"
+	GatewayOrderPaid     GatewayOrderStatus = "PAID"
+	GatewayOrderDeclined GatewayOrderStatus = "DECLINED"
+)
+
+// GatewayOrderResult is the outcome of a gateway order. Amount is what a
+// paid order took.
+type GatewayOrderResult struct {
+	Status GatewayOrderStatus
+	Reason string
+	Amount int
+}
+
+// GatewayCard is a card on file passed to the gateway. ExpiryDate is MM/YY.
+type GatewayCard struct {
+	Number     string
+	HolderName string
+	ExpiryDate string
+}
+
+// PaymentGateway registers card orders and confirms their outcome once the
+// customer has been through the gateway's payment page, or once the order has
+// been paid with a card on file. Paid orders can be refunded in part or in
+// full.
 type PaymentGateway interface {
-	ProcessPayment(ctx context.Context, amount int, currency string, paymentMethod domains.PaymentMethod) error
-	SimulatePaymentWebhook(ctx context.Context, paymentID uint) error
-	SimulateInstallmentWebhook(ctx context.Context, installmentID uint) error
+	RegisterOrder(ctx context.Context, req GatewayOrderRequest) (*GatewayOrder, error)
+	ConfirmOrder(ctx context.Context, orderID string) (*GatewayOrderResult, error)
+	PayOrder(ctx context.Context, orderID string, card GatewayCard) error
+	RefundOrder(ctx context.Context, orderID string, amount int) error
 }
 
 func NewCreditPaymentService(
 	creditAppRepo repository.CreditApplicationRepository,
 	paymentRepo repository.PaymentRepository,
 	installmentRepo repository.InstallmentRepository,
+	userRepo repository.UserRepository,
+	reviewRepo repository.ManualReviewRepository,
+	creditScorer CreditScorer,
+	policy UnderwritingPolicy,
+	lifecycle *CreditApplicationLifecycle,
+	creditLines *CreditLineService,
+	plans *PlanProductService,
+	dunning *DunningService,
+	penalties *PenaltyService,
+	refunds *RefundService,
+	ledger *LedgerService,
+	merchants *MerchantService,
+	merchantEvents *MerchantEventService,
+	txManager *utils.TransactionManager,
 	logger *zap.Logger,
 	paymentGateway PaymentGateway,
 ) *CreditPaymentService {
@@ -53,6 +154,20 @@ func NewCreditPaymentService(
 		creditAppRepo:   creditAppRepo,
 		paymentRepo:     paymentRepo,
 		installmentRepo: installmentRepo,
+		userRepo:        userRepo,
+		reviewRepo:      reviewRepo,
+		creditScorer:    creditScorer,
+		policy:          policy,
+		lifecycle:       lifecycle,
+		creditLines:     creditLines,
+		plans:           plans,
+		dunning:         dunning,
+		penalties:       penalties,
+		refunds:         refunds,
+		ledger:          ledger,
+		merchants:       merchants,
+		merchantEvents:  merchantEvents,
+		txManager:       txManager,
 		logger:          logger,
 		paymentGateway:  paymentGateway,
 	}
@@ -65,62 +180,226 @@ func (s *CreditPaymentService) CreateCreditApplication(ctx context.Context, app
 		return ErrInvalidAmount
 	}
 	
-	app.Status = "PENDING"
-	err := s.creditAppRepo.Create(ctx, app)
+	// The application is created and submitted together, so none is left
+	// behind in DRAFT
+	app.Status = domains.CreditApplicationDraft
+	err := s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.creditAppRepo.Create(txCtx, app); err != nil {
+			s.logger.Error("Failed to create credit application", zap.Error(err))
+			return fmt.Errorf("failed to create credit application: %w", err)
+		}
+	
+		if err := s.lifecycle.Transition(txCtx, app, domains.CreditApplicationSubmitted, app.UserID, "application submitted"); err != nil {
+			s.logger.Error("Failed to submit credit application", zap.Error(err))
+			return fmt.Errorf("failed to submit credit application: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		s.logger.Error("Failed to create credit application", zap.Error(err))
-		return fmt.Errorf("failed to create credit application: %w", err)
+		return err
 	}
 	
 	s.logger.Info("Credit application created successfully", zap.Uint("id", app.ID))
 	return nil
 }
 
-func (s *CreditPaymentService) ApproveCreditApplication(ctx context.Context, id uint) error {
+func (s *CreditPaymentService) GetCreditApplication(ctx context.Context, id uint) (*domains.CreditApplication, error) {
+	app, err := s.creditAppRepo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get credit application", zap.Error(err))
+		return nil, fmt.Errorf("failed to get credit application: %w", err)
+	}
+	return app, nil
+}
+
+func (s *CreditPaymentService) ApproveCreditApplication(ctx context.Context, id uint) (*domains.CreditApplication, error) {
 	s.logger.Info("Approving credit application", zap.Uint("id", id))
 	
 	app, err := s.creditAppRepo.GetByID(ctx, id)
 	if err != nil {
 		s.logger.Error("Failed to get credit application", zap.Error(err))
-		return fmt.Errorf("failed to get credit application: %w", err)
+		return nil, fmt.Errorf("failed to get credit application: %w", err)
+	}
+	
+	if app.Status == domains.CreditApplicationSubmitted {
+		err = s.lifecycle.Transition(ctx, app, domains.CreditApplicationUnderReview, ActorSystem, "credit check started")
+		if err != nil {
+			s.logger.Error("Failed to start review of credit application", zap.Error(err))
+			return nil, fmt.Errorf("failed to start review of credit application: %w", err)
+		}
+	} else if app.Status != domains.CreditApplicationUnderReview {
+		s.logger.Warn("Credit application is not awaiting a decision", zap.Uint("id", id), zap.String("status", string(app.Status)))
+		return nil, &utils.ErrInvalidTransition{Entity: "CreditApplication", ID: id, From: string(app.Status), To: string(domains.CreditApplicationApproved)}
+	} else {
+		var notFoundErr *utils.ErrNotFound
+		_, err := s.reviewRepo.GetByCreditApplicationID(ctx, id)
+		if err == nil {
+			return nil, ErrManualReviewPending
+		}
+		if !errors.As(err, &notFoundErr) {
+			s.logger.Error("Failed to get manual review", zap.Error(err))
+			return nil, fmt.Errorf("failed to get manual review: %w", err)
+		}
 	}
 	
-	// Perform credit check (this is a simplified example)
-	creditScore, err := s.performCreditCheck(ctx, app.UserID)
+	result, features, err := s.performCreditCheck(ctx, app)
 	if err != nil {
 		s.logger.Error("Failed to perform credit check", zap.Error(err))
-		return fmt.Errorf("failed to perform credit check: %w", err)
+		return nil, fmt.Errorf("failed to perform credit check: %w", err)
 	}
 	
-	if creditScore < 650 {
-		app.Status = "REJECTED"
-		s.logger.Info("Credit application rejected due to low credit score", zap.Int("creditScore", creditScore))
-	} else {
-		app.Status = "APPROVED"
-		s.logger.Info("Credit application approved", zap.Int("creditScore", creditScore))
+	scoredAt := time.Now()
+	app.CreditScore = result.Score
+	app.ScoreBand = result.Band
+	app.ScoreFactors = result.Factors
+	app.ScoreModel = result.ModelVersion
+	app.ScoreLatency = result.Latency.Milliseconds()
+	app.ScoredAt = &scoredAt
+	
+	decision := s.policy.Decide(result.Score)
+	switch decision {
+	case DecisionApprove:
+		app.ApprovedAmount = app.Amount
+	case DecisionReject:
+		app.DeclineReasons = adverseActionReasons(features)
 	}
 	
-	err = s.creditAppRepo.Update(ctx, app)
+	// The score, the decision and the credit line it opens are recorded
+	// together, so an approval never stands without its credit line
+	queued := false
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.creditAppRepo.Update(txCtx, app); err != nil {
+			s.logger.Error("Failed to update credit application", zap.Error(err))
+			return fmt.Errorf("failed to update credit application: %w", err)
+		}
+		
+		var status domains.CreditApplicationStatus
+		var reason string
+		switch decision {
+		case DecisionApprove:
+			status = domains.CreditApplicationApproved
+			reason = fmt.Sprintf("credit score %d (%s)", result.Score, result.Band)
+		case DecisionReject:
+			status = domains.CreditApplicationRejected
+			reason = fmt.Sprintf("credit score %d (%s) below %d", result.Score, result.Band, s.policy.ReviewMinScore)
+		default:
+			review := &domains.ManualReview{
+				CreditApplicationID: app.ID,
+				Status:              domains.ManualReviewOpen,
+				CreditScore:         result.Score,
+			}
+			if err := s.reviewRepo.Create(txCtx, review); err != nil {
+				s.logger.Error("Failed to queue credit application for manual review", zap.Error(err))
+				return fmt.Errorf("failed to queue credit application for manual review: %w", err)
+			}
+			queued = true
+			return nil
+		}
+		
+		if err := s.lifecycle.Transition(txCtx, app, status, ActorSystem, reason); err != nil {
+			s.logger.Error("Failed to record credit decision", zap.Error(err))
+			return fmt.Errorf("failed to record credit decision: %w", err)
+		}
+		
+		if status == domains.CreditApplicationApproved {
+			if _, err := s.creditLines.EnsureLimit(txCtx, app.UserID, app.Currency, app.CreditLimit()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if queued {
+		s.logger.Info("Credit application queued for manual review", zap.Uint("id", id), zap.Int("creditScore", result.Score))
+		return app, nil
+	}
+	
+	s.logger.Info("Credit application decided", zap.Uint("id", id), zap.String("status", string(app.Status)), zap.Int("creditScore", result.Score))
+	return app, nil
+}
+
+func (s *CreditPaymentService) CancelCreditApplication(ctx context.Context, id uint, actor, reason string) error {
+	s.logger.Info("Cancelling credit application", zap.Uint("id", id), zap.String("actor", actor))
+	
+	app, err := s.creditAppRepo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("Failed to update credit application", zap.Error(err))
-		return fmt.Errorf("failed to update credit application: %w", err)
+		s.logger.Error("Failed to get credit application", zap.Error(err))
+		return fmt.Errorf("failed to get credit application: %w", err)
+	}
+	
+	if err := s.lifecycle.Transition(ctx, app, domains.CreditApplicationCancelled, actor, reason); err != nil {
+		s.logger.Error("Failed to cancel credit application", zap.Error(err))
+		return fmt.Errorf("failed to cancel credit application: %w", err)
 	}
 	
 	return nil
 }
 
-func (s *CreditPaymentService) performCreditCheck(ctx context.Context, userID string) (int, error) {
-    // In a real-world scenario, this would involve calling a credit bureau API
-    // For this example, we'll use our weighted random credit score generator
-    creditScore := utils.GenerateWeightedRandomCreditScore()
-    
-    s.logger.Info("Performed credit check", 
-        zap.String("userID", userID), 
-        zap.Int("creditScore", creditScore))
-    
-    return creditScore, nil
+func (s *CreditPaymentService) GetCreditApplicationHistory(ctx context.Context, id uint) ([]*domains.CreditApplicationTransition, error) {
+	if _, err := s.creditAppRepo.GetByID(ctx, id); err != nil {
+		s.logger.Error("Failed to get credit application", zap.Error(err))
+		return nil, fmt.Errorf("failed to get credit application: %w", err)
+	}
+	
+	history, err := s.lifecycle.History(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get credit application history", zap.Error(err))
+		return nil, fmt.Errorf("failed to get credit application history: %w", err)
+	}
+	
+	return history, nil
+}
+
+func (s *CreditPaymentService) performCreditCheck(ctx context.Context, app *domains.CreditApplication) (*CreditScoreResult, ApplicantFeatures, error) {
+	userID, err := uuid.FromString(app.UserID)
+	if err != nil {
+		return nil, ApplicantFeatures{}, fmt.Errorf("invalid user ID %q: %w", app.UserID, err)
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ApplicantFeatures{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	payments, err := s.paymentRepo.GetByUserID(ctx, app.UserID)
+	if err != nil {
+		return nil, ApplicantFeatures{}, fmt.Errorf("failed to get payment history: %w", err)
+	}
+
+	var installments []*domains.Installment
+	for _, payment := range payments {
+		paymentInstallments, err := s.installmentRepo.GetByPaymentID(ctx, payment.ID)
+		if err != nil {
+			return nil, ApplicantFeatures{}, fmt.Errorf("failed to get installment history: %w", err)
+		}
+		installments = append(installments, paymentInstallments...)
+	}
+
+	features := buildApplicantFeatures(user, app, payments, installments, time.Now())
+	result, err := s.creditScorer.Score(ctx, features)
+	if err != nil {
+		return nil, features, err
+	}
+
+	s.logger.Info("Performed credit check",
+		zap.String("userID", app.UserID),
+		zap.Int("creditScore", result.Score),
+		zap.String("band", result.Band),
+		zap.String("modelVersion", result.ModelVersion),
+		zap.Duration("latency", result.Latency))
+
+	return result, features, nil
 }
 func (s *CreditPaymentService) CreatePayment(ctx context.Context, payment *domains.Payment) error {
+	return s.createPayment(ctx, payment, nil)
+}
+
+// createPayment creates payment and registers it with the gateway. recorded,
+// when given, runs in the transaction that records the payment, so whatever
+// it writes about the payment commits or rolls back with it.
+func (s *CreditPaymentService) createPayment(ctx context.Context, payment *domains.Payment, recorded func(txCtx context.Context) error) error {
 	s.logger.Info("Creating payment", zap.Any("payment", payment))
 	
 	if payment.Amount <= 0 {
@@ -134,43 +413,124 @@ func (s *CreditPaymentService) CreatePayment(ctx context.Context, payment *domai
 		return fmt.Errorf("failed to get credit application: %w", err)
 	}
 	
-	if creditApp.Status != "APPROVED" {
+	if creditApp.Status != domains.CreditApplicationApproved {
 		s.logger.Warn("Attempt to create payment for unapproved credit application", zap.Uint("creditAppID", creditApp.ID))
 		return errors.New("credit application not approved")
 	}
 	
-	totalPayments, err := s.getTotalPaymentsForCreditApp(ctx, payment.CreditApplicationID)
+	if creditApp.UserID != payment.UserID {
+		s.logger.Warn("Attempt to create payment against another user's credit application", zap.Uint("creditAppID", creditApp.ID))
+		return errors.New("credit application does not belong to user")
+	}
+	
+	// Payments are made at an active merchant, whose category decides the
+	// plans on offer
+	merchant, err := s.merchants.ResolvePaymentMerchant(ctx, payment.MerchantID, payment.StoreID)
 	if err != nil {
-		s.logger.Error("Failed to get total payments", zap.Error(err))
-		return fmt.Errorf("failed to get total payments: %w", err)
+		return err
 	}
+	payment.MerchantID = merchant.ID
+	payment.MerchantCategory = merchant.Category
 	
-	if totalPayments+payment.Amount > creditApp.Amount {
-		s.logger.Warn("Insufficient credit for payment", 
-			zap.Int("requestedAmount", payment.Amount), 
-			zap.Int("availableCredit", creditApp.Amount-totalPayments))
-		return ErrInsufficientCredit
+	if payment.QuoteToken != "" {
+		if err := s.plans.HonourQuote(payment, payment.QuoteToken, time.Now()); err != nil {
+			return err
+		}
+	} else {
+		plan, err := s.plans.SelectPlan(ctx, payment.PlanProductID, payment.Amount, payment.MerchantCategory)
+		if err != nil {
+			return err
+		}
+		payment.PlanProductID = nil
+		if plan.ID != 0 {
+			payment.PlanProductID = &plan.ID
+		}
+		payment.Plan = plan.Terms
+		payment.FeeAmount = PricePlan(plan.Terms, payment.Amount).Fee
 	}
 	
 	payment.Status = "PENDING"
-	err = s.paymentRepo.Create(ctx, payment)
-	if err != nil {
-		s.logger.Error("Failed to create payment", zap.Error(err))
-		return fmt.Errorf("failed to create payment: %w", err)
+	if payment.OrderID == "" {
+		payment.OrderID = uuid.Must(uuid.NewV4()).String()
 	}
 	
-	s.logger.Info("Payment created successfully", zap.Uint("id", payment.ID))
-	go func() {
-		time.Sleep(5 * time.Second) // Simulate a delay
-		err := s.paymentGateway.SimulatePaymentWebhook(context.Background(), payment.ID)
-		if err != nil {
-			s.logger.Error("Failed to simulate payment webhook", zap.Error(err))
+	// Hold the amount on the credit line while the payment is pending. The
+	// hold, the payment and the reservation tracking it are written together,
+	// so no credit stays held for a payment that was never recorded
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.creditLines.Reserve(txCtx, payment.UserID, payment.Amount); err != nil {
+			return err
 		}
-	}()
-
+		if err := s.paymentRepo.Create(txCtx, payment); err != nil {
+			s.logger.Error("Failed to create payment", zap.Error(err))
+			return fmt.Errorf("failed to create payment: %w", err)
+		}
+		if err := s.creditLines.TrackReservation(txCtx, payment.UserID, payment.ID, payment.Amount); err != nil {
+			return err
+		}
+		if recorded == nil {
+			return nil
+		}
+		return recorded(txCtx)
+	})
+	if err != nil {
+		return err
+	}
+	
+	// Order IDs are only unique per merchant and may be retried after a
+	// failed payment, so the gateway gets an order number of its own. The
+	// order takes the first installment of the schedule, the down payment
+	// when the plan has one; the rest is collected as it falls due
+	returnPath := payment.ReturnPath
+	if returnPath == "" {
+		returnPath = PaymentReturnPath
+	}
+	firstInstallment := BuildSchedule(paymentTerms(payment), payment.Amount, scheduleStart(payment, time.Now()))[0]
+	order, err := s.paymentGateway.RegisterOrder(ctx, GatewayOrderRequest{
+		OrderNumber: uuid.Must(uuid.NewV4()).String(),
+		Amount:      firstInstallment.Amount,
+		Currency:    payment.Currency,
+		ReturnPath:  returnPath,
+		Description: fmt.Sprintf("First installment of payment %d", payment.ID),
+	})
+	if err != nil {
+		s.logger.Error("Failed to register payment with gateway", zap.Uint("id", payment.ID), zap.Error(err))
+		s.abandonPayment(ctx, payment)
+		return fmt.Errorf("%w: %v", ErrPaymentFailed, err)
+	}
+	
+	payment.GatewayOrderID = order.OrderID
+	payment.RedirectURL = order.RedirectURL
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		s.logger.Error("Failed to update payment", zap.Error(err))
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	
+	s.logger.Info("Payment created successfully", zap.Uint("id", payment.ID), zap.String("gatewayOrderID", order.OrderID))
 	return nil
 
 }
+
+// abandonPayment fails a payment that never reached the gateway, gives its
+// credit back and opens its checkout session again. Should that fail, the
+// reservation sweeper releases the credit once the reservation expires, and
+// the session expires with the payment.
+func (s *CreditPaymentService) abandonPayment(ctx context.Context, payment *domains.Payment) {
+	payment.Status = "FAILED"
+	err := s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.paymentRepo.Update(txCtx, payment); err != nil {
+			return err
+		}
+		if err := s.creditLines.Release(txCtx, payment.ID); err != nil {
+			return err
+		}
+		return s.merchantEvents.PaymentSettled(txCtx, payment, "FAILED")
+	})
+	if err != nil {
+		s.logger.Error("Failed to release credit of abandoned payment", zap.Uint("id", payment.ID), zap.Error(err))
+	}
+}
+
 func (s *CreditPaymentService) HandlePaymentWebhook(ctx context.Context, paymentID uint, status string) error {
 	s.logger.Info("Handling payment webhook", zap.Uint("paymentID", paymentID), zap.String("status", status))
 	
@@ -180,141 +540,334 @@ func (s *CreditPaymentService) HandlePaymentWebhook(ctx context.Context, payment
 		return fmt.Errorf("failed to get payment: %w", err)
 	}
 	
-	if status == "SUCCESSFUL" {
-		payment.Status = "SUCCESSFUL"
-		
-		installments, err := s.createInstallments(ctx, payment)
-		if err != nil {
-			s.logger.Error("Failed to create installments", zap.Error(err))
-			return fmt.Errorf("failed to create installments: %w", err)
-		}
-		
-		payment.Installments = installments
-	} else if status == "FAILED" {
-		payment.Status = "FAILED"
-	} else {
-		return fmt.Errorf("unknown payment status: %s", status)
-	}
-	
-	err = s.paymentRepo.Update(ctx, payment)
-	if err != nil {
-		s.logger.Error("Failed to update payment", zap.Error(err))
-		return fmt.Errorf("failed to update payment: %w", err)
+	if err := s.applyPaymentResult(ctx, payment, status); err != nil {
+		return err
 	}
 	
 	s.logger.Info("Payment webhook handled successfully", zap.Uint("paymentID", paymentID), zap.String("status", status))
 	return nil
 }
 
-
-func (s *CreditPaymentService) ProcessPayment(ctx context.Context, paymentID uint) error {
+// ProcessPayment asks the gateway for the outcome of a payment's order and
+// applies it.
+func (s *CreditPaymentService) ProcessPayment(ctx context.Context, paymentID uint) (*domains.Payment, error) {
 	s.logger.Info("Processing payment", zap.Uint("paymentID", paymentID))
 	
 	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
 	if err != nil {
 		s.logger.Error("Failed to get payment", zap.Error(err))
-		return fmt.Errorf("failed to get payment: %w", err)
+		return nil, fmt.Errorf("failed to get payment: %w", err)
 	}
 	
-	err = s.paymentGateway.ProcessPayment(ctx, payment.Amount, payment.Currency, payment.PaymentMethod)
+	return s.confirmPayment(ctx, payment)
+}
+
+// ConfirmPaymentOrder is called when the gateway sends the customer back. It
+// confirms the order with the gateway rather than trusting the redirect.
+func (s *CreditPaymentService) ConfirmPaymentOrder(ctx context.Context, gatewayOrderID string) (*domains.Payment, error) {
+	s.logger.Info("Confirming payment order", zap.String("gatewayOrderID", gatewayOrderID))
+	
+	payment, err := s.paymentRepo.GetByGatewayOrderID(ctx, gatewayOrderID)
 	if err != nil {
-		s.logger.Error("Payment processing failed", zap.Error(err))
-		payment.Status = "FAILED"
-		_ = s.paymentRepo.Update(ctx, payment)
-		return ErrPaymentFailed
+		s.logger.Error("Failed to get payment", zap.Error(err))
+		return nil, fmt.Errorf("failed to get payment: %w", err)
 	}
 	
-	payment.Status = "SUCCESSFUL"
+	return s.confirmPayment(ctx, payment)
+}
+
+func (s *CreditPaymentService) confirmPayment(ctx context.Context, payment *domains.Payment) (*domains.Payment, error) {
+	if !paymentAwaitingResult(payment) {
+		return payment, nil
+	}
+	if payment.GatewayOrderID == "" {
+		return nil, fmt.Errorf("%w: payment %d has no gateway order", ErrPaymentFailed, payment.ID)
+	}
 	
-	installments, err := s.createInstallments(ctx, payment)
+	result, err := s.paymentGateway.ConfirmOrder(ctx, payment.GatewayOrderID)
 	if err != nil {
-		s.logger.Error("Failed to create installments", zap.Error(err))
-		return fmt.Errorf("failed to create installments: %w", err)
+		s.logger.Error("Failed to confirm payment with gateway", zap.Uint("paymentID", payment.ID), zap.Error(err))
+		if errors.Is(err, ErrGatewayTimeout) {
+			return nil, ErrGatewayTimeout
+		}
+		return nil, fmt.Errorf("failed to confirm payment with gateway: %w", err)
 	}
 	
-	payment.Installments = installments
-	err = s.paymentRepo.Update(ctx, payment)
+	switch result.Status {
+	case GatewayOrderPaid:
+		err = s.applyPaymentResult(ctx, payment, "SUCCESSFUL")
+	case GatewayOrderDeclined:
+		s.logger.Info("Payment declined by gateway", zap.Uint("paymentID", payment.ID), zap.String("reason", result.Reason))
+		err = s.applyPaymentResult(ctx, payment, "FAILED")
+	default:
+		s.logger.Info("Payment not completed yet", zap.Uint("paymentID", payment.ID))
+	}
 	if err != nil {
-		s.logger.Error("Failed to update payment", zap.Error(err))
-		return fmt.Errorf("failed to update payment: %w", err)
+		return nil, err
 	}
 	
-	s.logger.Info("Payment processed successfully", zap.Uint("paymentID", paymentID))
-	return nil
+	return payment, nil
 }
 
-func (s *CreditPaymentService) createInstallments(ctx context.Context, payment *domains.Payment) ([]domains.Installment, error) {
-	s.logger.Info("Creating installments for payment", zap.Uint("paymentID", payment.ID))
-	
-	numberOfInstallments := s.calculateNumberOfInstallments(payment.Amount)
-	installmentAmount := payment.Amount / numberOfInstallments
+// applyPaymentResult records the gateway outcome of a payment: a successful
+// payment is split into installments and drawn from the credit line, and its
+// first installment, which the checkout order took, is marked paid; a failed
+// one gives its reserved credit back. Payments that already have an outcome
+// are left untouched.
+func (s *CreditPaymentService) applyPaymentResult(ctx context.Context, payment *domains.Payment, status string) error {
+	if !paymentAwaitingResult(payment) {
+		s.logger.Info("Ignoring result for settled payment",
+			zap.Uint("paymentID", payment.ID), zap.String("currentStatus", payment.Status))
+		return nil
+	}
+	if status != "SUCCESSFUL" && status != "FAILED" {
+		return fmt.Errorf("unknown payment status: %s", status)
+	}
 	
+	// The status change, the installment schedule, the credit line update,
+	// the ledger and the merchant's event commit or roll back together
 	var installments []domains.Installment
-	for i := 1; i <= numberOfInstallments; i++ {
-		installment := domains.Installment{
-			PaymentID:         payment.ID,
-			InstallmentNumber: i,
-			DueDate:           time.Now().AddDate(0, i, 0).Format("2006-01-02"),
-			Amount:            installmentAmount,
-			Status:            "PENDING",
-		}
-		if i == numberOfInstallments {
-			// Adjust the last installment to account for any rounding errors
-			installment.Amount += payment.Amount - (installmentAmount * numberOfInstallments)
+	err := s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.paymentRepo.UpdateStatus(txCtx, payment.ID, status, "PENDING", "EXPIRED"); err != nil {
+			return err
 		}
 		
-		err := s.installmentRepo.Create(ctx, &installment)
+		if status == "FAILED" {
+			if err := s.creditLines.Release(txCtx, payment.ID); err != nil {
+				return err
+			}
+			return s.merchantEvents.PaymentSettled(txCtx, payment, status)
+		}
+		
+		var err error
+		installments, err = s.createInstallments(txCtx, payment)
 		if err != nil {
-			s.logger.Error("Failed to create installment", zap.Error(err))
-			return nil, fmt.Errorf("failed to create installment: %w", err)
+			s.logger.Error("Failed to create installments", zap.Error(err))
+			return fmt.Errorf("failed to create installments: %w", err)
+		}
+		if err := s.creditLines.Capture(txCtx, payment); err != nil {
+			return err
+		}
+		if err := s.ledger.PostPurchase(txCtx, payment); err != nil {
+			return err
 		}
-		installments = append(installments, installment)
+		if err := s.merchantEvents.PaymentSettled(txCtx, payment, status); err != nil {
+			return err
+		}
+		return s.markInstallmentPaid(txCtx, &installments[0], payment.GatewayOrderID)
+	})
+	
+	var transitionErr *utils.ErrInvalidTransition
+	if errors.As(err, &transitionErr) {
+		s.logger.Info("Payment was settled concurrently", zap.Uint("paymentID", payment.ID))
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to settle payment", zap.Uint("paymentID", payment.ID), zap.Error(err))
+		return fmt.Errorf("failed to settle payment: %w", err)
 	}
 	
-	s.logger.Info("Installments created successfully", zap.Int("count", len(installments)))
-	return installments, nil
+	payment.Status = status
+	payment.Installments = installments
+	return nil
 }
 
-func (s *CreditPaymentService) calculateNumberOfInstallments(amount int) int {
-	// This is a simplified example. In a real-world scenario, this could be more complex
-	// and might depend on various factors like credit score, payment amount, etc.
-	if amount < 1000 {
-		return 3
-	} else if amount < 5000 {
-		return 6
-	} else {
-		return 12
+// paymentAwaitingResult reports whether the gateway outcome of a payment is
+// still open. Expired payments are included: the gateway may confirm them
+// after their credit reservation has lapsed.
+func paymentAwaitingResult(payment *domains.Payment) bool {
+	return payment.Status == "PENDING" || payment.Status == "EXPIRED"
+}
+
+// ExpireStaleReservations releases credit held by payments that have been
+// pending for longer than the reservation TTL and marks those payments
+// EXPIRED.
+func (s *CreditPaymentService) ExpireStaleReservations(ctx context.Context) error {
+	paymentIDs, err := s.creditLines.ExpireReservations(ctx, time.Now(), reservationSweepBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, paymentID := range paymentIDs {
+		payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+		if err != nil {
+			s.logger.Error("Failed to get payment", zap.Uint("paymentID", paymentID), zap.Error(err))
+			continue
+		}
+		if payment.Status != "PENDING" {
+			continue
+		}
+		if err := s.paymentRepo.UpdateStatus(ctx, paymentID, "EXPIRED", "PENDING"); err != nil {
+			s.logger.Error("Failed to expire payment", zap.Uint("paymentID", paymentID), zap.Error(err))
+		}
+	}
+
+	if len(paymentIDs) > 0 {
+		s.logger.Info("Expired stale credit reservations", zap.Int("count", len(paymentIDs)))
 	}
+	return nil
 }
 
-func (s *CreditPaymentService) ProcessInstallment(ctx context.Context, installmentID uint) error {
+// StartReservationSweeper runs ExpireStaleReservations every interval until
+// ctx is cancelled.
+func (s *CreditPaymentService) StartReservationSweeper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.ExpireStaleReservations(ctx); err != nil {
+					s.logger.Error("Credit reservation sweep failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// paymentTerms returns the plan terms a payment is repaid on.
+func paymentTerms(payment *domains.Payment) domains.PlanTerms {
+	if payment.Plan.InstallmentCount == 0 {
+		// Payments created before plans were configurable
+		return defaultPlanTerms(payment.Amount)
+	}
+	return payment.Plan
+}
+
+// scheduleStart returns when the schedule of a payment starts: the quoted
+// start, or now without a quote.
+func scheduleStart(payment *domains.Payment, now time.Time) time.Time {
+	if payment.ScheduleStart != nil {
+		return *payment.ScheduleStart
+	}
+	return now
+}
+
+func (s *CreditPaymentService) createInstallments(ctx context.Context, payment *domains.Payment) ([]domains.Installment, error) {
+	s.logger.Info("Creating installments for payment", zap.Uint("paymentID", payment.ID))
+	
+	installments := BuildSchedule(paymentTerms(payment), payment.Amount, scheduleStart(payment, time.Now()))
+	for i := range installments {
+		installments[i].PaymentID = payment.ID
+	}
+	
+	if err := s.installmentRepo.CreateBatch(ctx, installments); err != nil {
+		s.logger.Error("Failed to create installments", zap.Error(err))
+		return nil, fmt.Errorf("failed to create installments: %w", err)
+	}
+	
+	s.logger.Info("Installments created successfully", zap.Int("count", len(installments)))
+	return installments, nil
+}
+
+// ProcessInstallment registers a gateway order for an unpaid installment and
+// the late fees it owes, and returns it with the URL where the customer pays
+// it. The installment keeps
+// its status until the gateway reports the order paid or declined.
+func (s *CreditPaymentService) ProcessInstallment(ctx context.Context, installmentID uint) (*domains.Installment, error) {
 	s.logger.Info("Processing installment", zap.Uint("installmentID", installmentID))
 	
 	installment, err := s.installmentRepo.GetByID(ctx, installmentID)
 	if err != nil {
 		s.logger.Error("Failed to get installment", zap.Error(err))
-		return fmt.Errorf("failed to get installment: %w", err)
+		return nil, fmt.Errorf("failed to get installment: %w", err)
+	}
+	
+	if !slices.Contains(payableInstallmentStatuses, installment.Status) {
+		return nil, &utils.ErrInvalidTransition{Entity: "Installment", ID: installment.ID, From: installment.Status, To: "PAID"}
 	}
 	
-	// Instead of processing the installment immediately, we'll just mark it as pending
-	installment.Status = "PENDING"
-	err = s.installmentRepo.Update(ctx, installment)
+	// A second order would take the installment twice should the card
+	// charge in flight go through
+	collecting, err := s.installmentRepo.BeingCollected(ctx, installment.ID)
 	if err != nil {
-		s.logger.Error("Failed to update installment status", zap.Error(err))
-		return fmt.Errorf("failed to update installment status: %w", err)
+		s.logger.Error("Failed to check installment collection", zap.Uint("installmentID", installmentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to check installment collection: %w", err)
+	}
+	if collecting {
+		return nil, ErrInstallmentBeingCollected
 	}
 	
-	// Simulate a webhook
-	go func() {
-		time.Sleep(5 * time.Second) // Simulate a delay
-		err := s.paymentGateway.SimulateInstallmentWebhook(context.Background(), installmentID)
-		if err != nil {
-			s.logger.Error("Failed to simulate installment webhook", zap.Error(err))
+	payment, err := s.paymentRepo.GetByID(ctx, installment.PaymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Error(err))
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	
+	charges, fees, err := s.penalties.BillableCharges(ctx, installment.ID)
+	if err != nil {
+		return nil, err
+	}
+	
+	order, err := s.paymentGateway.RegisterOrder(ctx, GatewayOrderRequest{
+		OrderNumber: fmt.Sprintf("%s-%d-%d", payment.OrderID, installment.InstallmentNumber, time.Now().Unix()),
+		Amount:      installment.Amount + fees,
+		Currency:    payment.Currency,
+		ReturnPath:  InstallmentReturnPath,
+		Description: fmt.Sprintf("Installment %d of payment %d", installment.InstallmentNumber, payment.ID),
+	})
+	if err != nil {
+		s.logger.Error("Failed to register installment with gateway", zap.Uint("installmentID", installmentID), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", ErrPaymentFailed, err)
+	}
+	
+	openedAt := time.Now()
+	installment.GatewayOrderID = order.OrderID
+	installment.RedirectURL = order.RedirectURL
+	installment.OrderOpenedAt = &openedAt
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.installmentRepo.OpenOrder(txCtx, installment); err != nil {
+			return err
 		}
-	}()
+		return s.penalties.BillCharges(txCtx, charges, order.OrderID)
+	})
+	if err != nil {
+		s.logger.Error("Failed to save installment order", zap.Uint("installmentID", installmentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to save installment order: %w", err)
+	}
 	
-	s.logger.Info("Installment marked as pending", zap.Uint("installmentID", installmentID))
-	return nil
+	s.logger.Info("Installment order opened", zap.Uint("installmentID", installmentID), zap.String("gatewayOrderID", order.OrderID))
+	return installment, nil
+}
+
+// ConfirmInstallmentOrder is called when the gateway sends the customer back
+// after paying an installment.
+func (s *CreditPaymentService) ConfirmInstallmentOrder(ctx context.Context, gatewayOrderID string) (*domains.Installment, error) {
+	s.logger.Info("Confirming installment order", zap.String("gatewayOrderID", gatewayOrderID))
+	
+	installment, err := s.installmentRepo.GetByGatewayOrderID(ctx, gatewayOrderID)
+	if err != nil {
+		s.logger.Error("Failed to get installment", zap.Error(err))
+		return nil, fmt.Errorf("failed to get installment: %w", err)
+	}
+	
+	if installment.Status == "PAID" {
+		return installment, nil
+	}
+	
+	result, err := s.paymentGateway.ConfirmOrder(ctx, gatewayOrderID)
+	if err != nil {
+		s.logger.Error("Failed to confirm installment with gateway", zap.Uint("installmentID", installment.ID), zap.Error(err))
+		if errors.Is(err, ErrGatewayTimeout) {
+			return nil, ErrGatewayTimeout
+		}
+		return nil, fmt.Errorf("failed to confirm installment with gateway: %w", err)
+	}
+	
+	switch result.Status {
+	case GatewayOrderPaid:
+		err = s.applyInstallmentResult(ctx, installment, "PAID", gatewayOrderID, result.Amount)
+	case GatewayOrderDeclined:
+		s.logger.Info("Installment declined by gateway", zap.Uint("installmentID", installment.ID), zap.String("reason", result.Reason))
+		err = s.applyInstallmentResult(ctx, installment, "FAILED", gatewayOrderID, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	return installment, nil
 }
 
 func (s *CreditPaymentService) HandleInstallmentWebhook(ctx context.Context, installmentID uint, status string) error {
@@ -326,21 +879,133 @@ func (s *CreditPaymentService) HandleInstallmentWebhook(ctx context.Context, ins
 		return fmt.Errorf("failed to get installment: %w", err)
 	}
 	
-	if status == "PAID" {
-		installment.Status = "PAID"
-	} else if status == "FAILED" {
-		installment.Status = "FAILED"
-	} else {
+	if err := s.applyInstallmentResult(ctx, installment, status, installment.GatewayOrderID, 0); err != nil {
+		return err
+	}
+	
+	s.logger.Info("Installment webhook handled successfully", zap.Uint("installmentID", installmentID), zap.String("status", status))
+	return nil
+}
+
+// applyInstallmentResult records the outcome of an installment payment and
+// restores the credit line when it is paid for the first time. Failures are
+// handed to the dunning policy; a failure reported after the installment was
+// paid is ignored. gatewayOrderID names the order that took the money, and
+// is kept as the receipt refunds are made against. amount is what the order
+// took, or 0 when the caller does not know it.
+//
+// An order paid for an installment that was paid otherwise meanwhile, or
+// cancelled, took money that is not owed: it is recorded as an overpayment
+// and refunded to the card.
+func (s *CreditPaymentService) applyInstallmentResult(ctx context.Context, installment *domains.Installment, status, gatewayOrderID string, amount int) error {
+	var err error
+	switch status {
+	case "PAID":
+		err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+			return s.markInstallmentPaid(txCtx, installment, gatewayOrderID)
+		})
+	case "FAILED":
+		err = s.dunning.RecordFailure(ctx, installment, time.Now())
+	default:
 		return fmt.Errorf("unknown installment status: %s", status)
 	}
 	
-	err = s.installmentRepo.Update(ctx, installment)
+	var transitionErr *utils.ErrInvalidTransition
+	if errors.As(err, &transitionErr) && status == "PAID" {
+		err = s.recordOverpayment(ctx, installment, gatewayOrderID, amount)
+	} else if errors.As(err, &transitionErr) {
+		s.logger.Info("Ignoring installment result",
+			zap.Uint("installmentID", installment.ID), zap.String("status", status), zap.String("currentStatus", installment.Status))
+		return nil
+	}
 	if err != nil {
 		s.logger.Error("Failed to update installment", zap.Error(err))
 		return fmt.Errorf("failed to update installment: %w", err)
 	}
+	return nil
+}
+
+// recordOverpayment records what a paid gateway order took for an
+// installment that no longer owed it, so that it is refunded to the card.
+// The gateway is asked for the amount when it is not known. The order that
+// paid the installment is on record already and is ignored.
+func (s *CreditPaymentService) recordOverpayment(ctx context.Context, installment *domains.Installment, gatewayOrderID string, amount int) error {
+	if gatewayOrderID == "" {
+		s.logger.Warn("Ignoring payment of a settled installment without a gateway order", zap.Uint("installmentID", installment.ID))
+		return nil
+	}
+	if amount == 0 {
+		result, err := s.paymentGateway.ConfirmOrder(ctx, gatewayOrderID)
+		if err != nil {
+			return fmt.Errorf("failed to confirm overpaid order: %w", err)
+		}
+		if result.Status != GatewayOrderPaid {
+			return nil
+		}
+		amount = result.Amount
+	}
 	
-	s.logger.Info("Installment webhook handled successfully", zap.Uint("installmentID", installmentID), zap.String("status", status))
+	installmentID := installment.ID
+	return s.refunds.RecordOverpayment(ctx, &domains.PaymentReceipt{
+		PaymentID:      installment.PaymentID,
+		InstallmentID:  &installmentID,
+		GatewayOrderID: gatewayOrderID,
+		Amount:         amount,
+	})
+}
+
+// markInstallmentPaid marks an unpaid installment PAID, including overdue and
+// defaulted ones, gives its principal back to the credit line, posts the
+// collection to the ledger, settles the late fees billed with it, records the
+// receipt of the gateway order that paid it and tells the merchant.
+func (s *CreditPaymentService) markInstallmentPaid(ctx context.Context, installment *domains.Installment, gatewayOrderID string) error {
+	defaulted := installment.Status == "DEFAULTED"
+	err := s.installmentRepo.UpdateStatus(ctx, installment.ID, "PAID", payableInstallmentStatuses...)
+	if err != nil {
+		return err
+	}
+	
+	payment, err := s.paymentRepo.GetByID(ctx, installment.PaymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Error(err))
+		return fmt.Errorf("failed to get payment: %w", err)
+	}
+	
+	// Only the principal was drawn from the credit line
+	if err := s.creditLines.Restore(ctx, payment.UserID, installment.Principal); err != nil {
+		return err
+	}
+	if err := s.ledger.PostInstallmentCollection(ctx, payment, installment, defaulted); err != nil {
+		return err
+	}
+	if defaulted {
+		if err := s.dunning.ResolveDefault(ctx, payment, installment); err != nil {
+			return err
+		}
+	}
+	
+	if gatewayOrderID == "" {
+		s.logger.Warn("Installment paid without a gateway order, no receipt recorded", zap.Uint("installmentID", installment.ID))
+	} else {
+		fees, err := s.penalties.SettleCharges(ctx, gatewayOrderID)
+		if err != nil {
+			return err
+		}
+		installmentID := installment.ID
+		err = s.refunds.RecordReceipt(ctx, &domains.PaymentReceipt{
+			PaymentID:      installment.PaymentID,
+			InstallmentID:  &installmentID,
+			GatewayOrderID: gatewayOrderID,
+			Amount:         installment.Amount + fees,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.merchantEvents.InstallmentPaid(ctx, payment, installment, installment.Amount); err != nil {
+		return err
+	}
+	installment.Status = "PAID"
 	return nil
 }
 
@@ -359,29 +1024,33 @@ func (s *CreditPaymentService) GetPaymentDetails(ctx context.Context, paymentID
         return nil, fmt.Errorf("failed to get installments for payment: %w", err)
     }
     
+    charges, err := s.penalties.ListCharges(ctx, paymentID)
+    if err != nil {
+        return nil, err
+    }
+    chargesByInstallment := make(map[uint][]domains.InstallmentCharge)
+    for _, charge := range charges {
+        chargesByInstallment[charge.InstallmentID] = append(chargesByInstallment[charge.InstallmentID], *charge)
+    }
+    
     // Convert []*domains.Installment to []domains.Installment
     installments := make([]domains.Installment, len(installmentPointers))
     for i, installmentPtr := range installmentPointers {
         if installmentPtr != nil {
             installments[i] = *installmentPtr
+            installments[i].Charges = chargesByInstallment[installmentPtr.ID]
         }
     }
     
     payment.Installments = installments
     return payment, nil
 }
-func (s *CreditPaymentService) getTotalPaymentsForCreditApp(ctx context.Context, creditAppID uint) (int, error) {
-	payments, err := s.paymentRepo.GetByCreditApplicationID(ctx, creditAppID)
+
+func (s *CreditPaymentService) GetInstallment(ctx context.Context, installmentID uint) (*domains.Installment, error) {
+	installment, err := s.installmentRepo.GetByID(ctx, installmentID)
 	if err != nil {
-		return 0, err
+		s.logger.Error("Failed to get installment", zap.Uint("installmentID", installmentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get installment: %w", err)
 	}
-	
-	total := 0
-	for _, payment := range payments {
-		if payment.Status == "SUCCESSFUL" {
-			total += payment.Amount
-		}
-	}
-	
-	return total, nil
-}
\ No newline at end of file
+	return installment, nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"go.uber.org/zap"
+)
+
+const testUserID = "6f1c1b8e-4c8a-4f43-9a4e-2f4b7d8c1a11"
+
+type paymentFixture struct {
+	service      *CreditPaymentService
+	creditLines  *fakeCreditLineRepo
+	reservations *fakeReservationRepo
+	payments     *fakePaymentRepo
+	gateway      *fakeGateway
+}
+
+func newPaymentFixture(t *testing.T, limit int) *paymentFixture {
+	t.Helper()
+	logger := zap.NewNop()
+
+	f := &paymentFixture{
+		creditLines: &fakeCreditLineRepo{lines: map[string]*domains.CreditLine{
+			testUserID: {UserID: testUserID, Currency: "DZD", CreditLimit: limit, Status: domains.CreditLineActive},
+		}},
+		reservations: &fakeReservationRepo{},
+		payments:     newFakePaymentRepo(),
+		gateway:      &fakeGateway{},
+	}
+	app := &domains.CreditApplication{UserID: testUserID, Amount: limit, ApprovedAmount: limit, Currency: "DZD", Status: domains.CreditApplicationApproved}
+	app.ID = 1
+	creditApps := &fakeCreditAppRepo{apps: map[uint]*domains.CreditApplication{1: app}}
+	merchant := &domains.Merchant{Name: "Test merchant", Category: "electronics", Status: domains.MerchantActive}
+	merchant.ID = 1
+	store := domains.Store{MerchantID: 1, Name: "Test store", Active: true}
+	store.ID = 4
+	merchant.Stores = []domains.Store{store}
+	merchants := &fakeMerchantRepo{merchants: map[uint]*domains.Merchant{1: merchant}}
+
+	f.service = NewCreditPaymentService(
+		creditApps,
+		f.payments,
+		nil,
+		nil,
+		nil,
+		nil,
+		DefaultUnderwritingPolicy(),
+		nil,
+		NewCreditLineService(f.creditLines, f.reservations, time.Minute, logger),
+		NewPlanProductService(&fakePlanRepo{}, []byte("quote-secret"), time.Minute, logger),
+		nil,
+		nil,
+		nil,
+		nil,
+		NewMerchantService(merchants, nil, f.payments, logger),
+		nil,
+		newTestTransactionManager(t),
+		logger,
+		f.gateway,
+	)
+	return f
+}
+
+// TestCreatePaymentRetryLoopKeepsParallelPaymentsWithinLimit checks the
+// service's optimistic retry loop: parallel payments that lose a versioned
+// update read the line again and either fit or are refused. The in-memory
+// repository stands in for Postgres; the conditional updates themselves are
+// tested against a database in the repositories package.
+func TestCreatePaymentRetryLoopKeepsParallelPaymentsWithinLimit(t *testing.T) {
+	const (
+		limit    = 5000
+		amount   = 1000
+		payments = 20
+	)
+	f := newPaymentFixture(t, limit)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	start := make(chan struct{})
+	for i := 0; i < payments; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			err := f.service.CreatePayment(context.Background(), &domains.Payment{
+				CreditApplicationID: 1,
+				UserID:              testUserID,
+				MerchantID:          1,
+				Amount:              amount,
+				Currency:            "DZD",
+			})
+			switch {
+			case err == nil:
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			case errors.Is(err, ErrInsufficientCredit), errors.Is(err, ErrCreditLineBusy):
+			default:
+				t.Errorf("CreatePayment: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if succeeded == 0 {
+		t.Fatal("no payment went through")
+	}
+	if succeeded*amount > limit {
+		t.Fatalf("%d payments of %d went through on a limit of %d", succeeded, amount, limit)
+	}
+
+	line, err := f.creditLines.GetByUserID(context.Background(), testUserID)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if line.Reserved != succeeded*amount {
+		t.Fatalf("credit line holds %d, want %d for %d payments", line.Reserved, succeeded*amount, succeeded)
+	}
+	if line.Available() < 0 {
+		t.Fatalf("credit line overdrawn: %d available", line.Available())
+	}
+	if got := len(f.reservations.reservations); got != succeeded {
+		t.Fatalf("%d reservations tracked, want %d", got, succeeded)
+	}
+	if got := len(f.payments.payments); got != succeeded {
+		t.Fatalf("%d payments recorded, want %d", got, succeeded)
+	}
+}
+
+func TestCreatePaymentChargesOnlyFirstInstallment(t *testing.T) {
+	f := newPaymentFixture(t, 5000)
+
+	payment := &domains.Payment{
+		CreditApplicationID: 1,
+		UserID:              testUserID,
+		MerchantID:          1,
+		Amount:              3000,
+		Currency:            "DZD",
+	}
+	if err := f.service.CreatePayment(context.Background(), payment); err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+
+	if len(f.gateway.orders) != 1 {
+		t.Fatalf("%d gateway orders registered, want 1", len(f.gateway.orders))
+	}
+	first := BuildSchedule(paymentTerms(payment), payment.Amount, time.Now())[0]
+	if got := f.gateway.orders[0].Amount; got != first.Amount || got >= payment.Amount {
+		t.Fatalf("checkout order takes %d of a %d purchase, want the first installment of %d", got, payment.Amount, first.Amount)
+	}
+}
+
+func TestCreatePaymentAttributesPaymentToStoreMerchant(t *testing.T) {
+	f := newPaymentFixture(t, 5000)
+
+	storeID := uint(4)
+	payment := &domains.Payment{
+		CreditApplicationID: 1,
+		UserID:              testUserID,
+		StoreID:             &storeID,
+		Amount:              3000,
+		Currency:            "DZD",
+	}
+	if err := f.service.CreatePayment(context.Background(), payment); err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	if payment.MerchantID != 1 || payment.MerchantCategory != "electronics" {
+		t.Fatalf("payment attributed to merchant %d in %q, want merchant 1 in electronics", payment.MerchantID, payment.MerchantCategory)
+	}
+
+	unknown := uint(9)
+	err := f.service.CreatePayment(context.Background(), &domains.Payment{
+		CreditApplicationID: 1,
+		UserID:              testUserID,
+		StoreID:             &unknown,
+		Amount:              1000,
+		Currency:            "DZD",
+	})
+	if !errors.Is(err, ErrInvalidMerchant) {
+		t.Fatalf("payment at an unknown store: got %v, want ErrInvalidMerchant", err)
+	}
+}
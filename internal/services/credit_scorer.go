@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"github.com/mohamed2394/sahla/internal/utils"
+)
+
+// ApplicantFeatures is the set of signals a CreditScorer works from. It is
+// built from data we already hold about the applicant.
+type ApplicantFeatures struct {
+	AccountAgeDays     int  `json:"account_age_days"`
+	HasIDDocument      bool `json:"has_id_document"`
+	HasPhoneNumber     bool `json:"has_phone_number"`
+	HasAddress         bool `json:"has_address"`
+	LoyaltyPoints      int  `json:"loyalty_points"`
+	RequestedAmount    int  `json:"requested_amount"`
+	SuccessfulPayments int  `json:"successful_payments"`
+	FailedPayments     int  `json:"failed_payments"`
+	PaidInstallments   int  `json:"paid_installments"`
+	LateInstallments   int  `json:"late_installments"`
+	OutstandingAmount  int  `json:"outstanding_amount"`
+}
+
+// CreditScoreResult is the outcome of scoring an applicant.
+type CreditScoreResult struct {
+	Score        int
+	Band         string
+	Factors      []domains.ScoreFactor
+	ModelVersion string
+	Latency      time.Duration
+}
+
+// CreditScorer scores an applicant from their features.
+type CreditScorer interface {
+	Score(ctx context.Context, features ApplicantFeatures) (*CreditScoreResult, error)
+}
+
+// Score factor codes produced by the scorecard
+const (
+	FactorAccountAge         = "ACCOUNT_AGE"
+	FactorKYC                = "KYC"
+	FactorContactDetails     = "CONTACT_DETAILS"
+	FactorRepaymentHistory   = "REPAYMENT_HISTORY"
+	FactorLateInstallments   = "LATE_INSTALLMENTS"
+	FactorFailedPayments     = "FAILED_PAYMENTS"
+	FactorOutstandingBalance = "OUTSTANDING_BALANCE"
+	FactorLoyalty            = "LOYALTY"
+)
+
+const (
+	ScorecardModelVersion = "scorecard-v1"
+	scorecardBaseScore    = 550
+)
+
+// ScorecardScorer is a deterministic, rule-based CreditScorer. The same
+// features always produce the same score and factors.
+type ScorecardScorer struct{}
+
+func NewScorecardScorer() *ScorecardScorer {
+	return &ScorecardScorer{}
+}
+
+func (s *ScorecardScorer) Score(ctx context.Context, f ApplicantFeatures) (*CreditScoreResult, error) {
+	start := time.Now()
+	var factors []domains.ScoreFactor
+	add := func(code, description string, points int) {
+		factors = append(factors, domains.ScoreFactor{Code: code, Description: description, Points: points})
+	}
+
+	switch {
+	case f.AccountAgeDays < 30:
+		add(FactorAccountAge, "Account opened less than 30 days ago", 0)
+	case f.AccountAgeDays < 180:
+		add(FactorAccountAge, "Account opened less than 6 months ago", 25)
+	case f.AccountAgeDays < 365:
+		add(FactorAccountAge, "Account opened less than a year ago", 50)
+	default:
+		add(FactorAccountAge, "Account opened more than a year ago", 75)
+	}
+
+	if f.HasIDDocument {
+		add(FactorKYC, "Identity document on file", 60)
+	} else {
+		add(FactorKYC, "No identity document on file", -40)
+	}
+
+	if f.HasPhoneNumber && f.HasAddress {
+		add(FactorContactDetails, "Phone number and address on file", 20)
+	} else {
+		add(FactorContactDetails, "Contact details incomplete", 0)
+	}
+
+	if f.PaidInstallments == 0 {
+		add(FactorRepaymentHistory, "No repayment history", 0)
+	} else {
+		add(FactorRepaymentHistory, fmt.Sprintf("%d installments repaid", f.PaidInstallments), min(f.PaidInstallments*10, 150))
+	}
+
+	if f.LateInstallments > 0 {
+		add(FactorLateInstallments, fmt.Sprintf("%d late installments", f.LateInstallments), -min(f.LateInstallments*50, 250))
+	}
+
+	if f.FailedPayments > 0 {
+		add(FactorFailedPayments, fmt.Sprintf("%d failed payments", f.FailedPayments), -min(f.FailedPayments*15, 60))
+	}
+
+	if f.OutstandingAmount > 0 && f.OutstandingAmount >= f.RequestedAmount {
+		add(FactorOutstandingBalance, "Outstanding balance exceeds requested amount", -40)
+	}
+
+	if loyalty := min(f.LoyaltyPoints/100, 30); loyalty > 0 {
+		add(FactorLoyalty, "Loyalty points", loyalty)
+	}
+
+	score := scorecardBaseScore
+	for _, factor := range factors {
+		score += factor.Points
+	}
+	score = utils.ClampCreditScore(score)
+
+	return &CreditScoreResult{
+		Score:        score,
+		Band:         utils.CreditScoreBand(score),
+		Factors:      factors,
+		ModelVersion: ScorecardModelVersion,
+		Latency:      time.Since(start),
+	}, nil
+}
+
+// buildApplicantFeatures derives scoring features from the applicant's user
+// record and their payment and installment history.
+func buildApplicantFeatures(user *domains.User, app *domains.CreditApplication, payments []*domains.Payment, installments []*domains.Installment, now time.Time) ApplicantFeatures {
+	features := ApplicantFeatures{
+		AccountAgeDays:  int(now.Sub(user.CreatedAt).Hours() / 24),
+		HasIDDocument:   user.IDImageURL != "",
+		HasPhoneNumber:  user.PhoneNumber != "",
+		HasAddress:      user.Address != "",
+		LoyaltyPoints:   user.LoyaltyPoints,
+		RequestedAmount: app.Amount,
+	}
+
+	for _, payment := range payments {
+		switch payment.Status {
+		case "SUCCESSFUL":
+			features.SuccessfulPayments++
+		case "FAILED":
+			features.FailedPayments++
+		}
+	}
+
+	today := now.Format("2006-01-02")
+	for _, installment := range installments {
+		if installment.Status == "PAID" {
+			features.PaidInstallments++
+			continue
+		}
+		// Refunded installments and those prepaid down to nothing are not owed
+		if installment.Status == "CANCELLED" || installment.Amount == 0 {
+			continue
+		}
+		features.OutstandingAmount += installment.Amount
+		if installment.Status == "FAILED" || installment.DueDate < today {
+			features.LateInstallments++
+		}
+	}
+
+	return features
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+)
+
+func TestBuildApplicantFeaturesCountsOnlyOwedInstallments(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	installments := []*domains.Installment{
+		{Status: "PAID", Amount: 0, DueDate: "2026-02-01"},
+		{Status: "OVERDUE", Amount: 300, DueDate: "2026-03-01"},
+		{Status: "FAILED", Amount: 300, DueDate: "2026-04-01"},
+		{Status: "PENDING", Amount: 300, DueDate: "2026-05-01"},
+		{Status: "CANCELLED", Amount: 0, DueDate: "2026-01-01"},
+		{Status: "CANCELLED", Amount: 300, DueDate: "2026-01-15"},
+		{Status: "PENDING", Amount: 0, DueDate: "2026-02-15"},
+	}
+
+	features := buildApplicantFeatures(&domains.User{}, &domains.CreditApplication{}, nil, installments, now)
+	if features.PaidInstallments != 1 {
+		t.Errorf("PaidInstallments = %d, want 1", features.PaidInstallments)
+	}
+	if features.LateInstallments != 2 {
+		t.Errorf("LateInstallments = %d, want 2", features.LateInstallments)
+	}
+	if features.OutstandingAmount != 900 {
+		t.Errorf("OutstandingAmount = %d, want 900", features.OutstandingAmount)
+	}
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// DunningJobName is the JobRunner name of the delinquency sweep.
+	DunningJobName = "dunning"
+	// dunningBatchSize caps how many installments one sweep moves per step.
+	dunningBatchSize = 100
+)
+
+// DunningPolicy decides when failed installment collections are retried and
+// when unpaid installments become overdue and then defaulted.
+type DunningPolicy struct {
+	// RetryDays are the delays, in days after each failed collection, of
+	// the next retry. No retry follows once they are used up.
+	RetryDays []int
+	// GraceDays is how long after its due date an unpaid installment
+	// becomes OVERDUE.
+	GraceDays int
+	// DefaultDays is how long after its due date an overdue installment
+	// becomes DEFAULTED.
+	DefaultDays int
+}
+
+func DefaultDunningPolicy() DunningPolicy {
+	return DunningPolicy{
+		RetryDays:   []int{1, 3, 7},
+		GraceDays:   3,
+		DefaultDays: 30,
+	}
+}
+
+// Validate checks that the policy's steps come in order.
+func (p DunningPolicy) Validate() error {
+	for _, days := range p.RetryDays {
+		if days <= 0 {
+			return fmt.Errorf("dunning retry delays must be positive")
+		}
+	}
+	if p.GraceDays < 0 || p.DefaultDays <= p.GraceDays {
+		return fmt.Errorf("dunning default period must be longer than the grace period")
+	}
+	return nil
+}
+
+// NextRetry returns when to retry after the failedCount-th failed collection,
+// or nil when no retry is left.
+func (p DunningPolicy) NextRetry(failedCount int, failedAt time.Time) *time.Time {
+	if failedCount < 1 || failedCount > len(p.RetryDays) {
+		return nil
+	}
+	next := failedAt.AddDate(0, 0, p.RetryDays[failedCount-1])
+	return &next
+}
+
+type DunningServiceInterface interface {
+	ListCollectionCases(ctx context.Context, status domains.CollectionCaseStatus, offset, limit int) ([]*domains.CollectionCase, int, error)
+}
+
+// DunningService follows up unpaid installments: it schedules retries of
+// failed collections, marks installments overdue and defaulted, and tells
+// the customer at each step. A default freezes the customer's credit line,
+// writes the installment off in the ledger and opens a collection case.
+type DunningService struct {
+	installmentRepo repository.InstallmentRepository
+	paymentRepo     repository.PaymentRepository
+	caseRepo        repository.CollectionCaseRepository
+	creditLines     *CreditLineService
+	notifications   *NotificationService
+	ledger          *LedgerService
+	txManager       *utils.TransactionManager
+	policy          DunningPolicy
+	logger          *zap.Logger
+}
+
+func NewDunningService(
+	installmentRepo repository.InstallmentRepository,
+	paymentRepo repository.PaymentRepository,
+	caseRepo repository.CollectionCaseRepository,
+	creditLines *CreditLineService,
+	notifications *NotificationService,
+	ledger *LedgerService,
+	txManager *utils.TransactionManager,
+	policy DunningPolicy,
+	logger *zap.Logger,
+) *DunningService {
+	return &DunningService{
+		installmentRepo: installmentRepo,
+		paymentRepo:     paymentRepo,
+		caseRepo:        caseRepo,
+		creditLines:     creditLines,
+		notifications:   notifications,
+		ledger:          ledger,
+		txManager:       txManager,
+		policy:          policy,
+		logger:          logger,
+	}
+}
+
+// RecordFailure records a failed collection of installment and schedules the
+// next retry. A PENDING installment becomes FAILED; an OVERDUE one stays
+// OVERDUE. It fails with *utils.ErrInvalidTransition if the installment can
+// no longer fail, e.g. because it was paid meanwhile.
+func (s *DunningService) RecordFailure(ctx context.Context, installment *domains.Installment, failedAt time.Time) error {
+	payment, err := s.paymentRepo.GetByID(ctx, installment.PaymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Error(err))
+		return fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	failed := *installment
+	failed.RetryCount++
+	failed.NextRetryAt = s.policy.NextRetry(failed.RetryCount, failedAt)
+	if failed.Status == "PENDING" {
+		failed.Status = "FAILED"
+	}
+
+	return s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.installmentRepo.RecordFailure(txCtx, &failed); err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("We could not collect installment %d of %d %s.",
+			installment.InstallmentNumber, installment.Amount, payment.Currency)
+		if failed.NextRetryAt != nil {
+			message += fmt.Sprintf(" We will try again on %s.", failed.NextRetryAt.Format(installmentDateLayout))
+		} else {
+			message += " Please pay it from your account to avoid it being sent to collections."
+		}
+		if err := s.notify(txCtx, payment, installment, domains.NotificationInstallmentFailed, message); err != nil {
+			return err
+		}
+
+		*installment = failed
+		s.logger.Info("Installment collection failure recorded",
+			zap.Uint("installmentID", installment.ID), zap.Int("retryCount", installment.RetryCount))
+		return nil
+	})
+}
+
+// ProcessDelinquencies marks unpaid installments past the grace period as
+// OVERDUE, then overdue installments past the default period as DEFAULTED.
+func (s *DunningService) ProcessDelinquencies(ctx context.Context) error {
+	today := time.Now()
+
+	overdue, err := s.installmentRepo.ListDueBefore(ctx, []string{"PENDING", "FAILED"},
+		today.AddDate(0, 0, -s.policy.GraceDays).Format(installmentDateLayout), dunningBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list overdue installments", zap.Error(err))
+		return fmt.Errorf("failed to list overdue installments: %w", err)
+	}
+	failed := 0
+	for _, installment := range overdue {
+		if err := s.markOverdue(ctx, installment); err != nil {
+			failed++
+		}
+	}
+
+	defaulted, err := s.installmentRepo.ListDueBefore(ctx, []string{"OVERDUE"},
+		today.AddDate(0, 0, -s.policy.DefaultDays).Format(installmentDateLayout), dunningBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list defaulted installments", zap.Error(err))
+		return fmt.Errorf("failed to list defaulted installments: %w", err)
+	}
+	for _, installment := range defaulted {
+		if err := s.markDefaulted(ctx, installment); err != nil {
+			failed++
+		}
+	}
+
+	s.logger.Info("Dunning sweep finished", zap.Int("overdue", len(overdue)), zap.Int("defaulted", len(defaulted)), zap.Int("failed", failed))
+	if failed > 0 {
+		return fmt.Errorf("%d dunning transitions failed", failed)
+	}
+	return nil
+}
+
+func (s *DunningService) markOverdue(ctx context.Context, installment *domains.Installment) error {
+	return s.transition(ctx, installment, "OVERDUE", []string{"PENDING", "FAILED"}, func(txCtx context.Context, payment *domains.Payment) error {
+		return s.notify(txCtx, payment, installment, domains.NotificationInstallmentOverdue,
+			fmt.Sprintf("Installment %d of %d %s was due on %s and is now overdue.",
+				installment.InstallmentNumber, installment.Amount, payment.Currency, installment.DueDate))
+	})
+}
+
+func (s *DunningService) markDefaulted(ctx context.Context, installment *domains.Installment) error {
+	return s.transition(ctx, installment, "DEFAULTED", []string{"OVERDUE"}, func(txCtx context.Context, payment *domains.Payment) error {
+		if err := s.creditLines.Freeze(txCtx, payment.UserID); err != nil {
+			return err
+		}
+		if err := s.ledger.PostWriteOff(txCtx, payment, installment); err != nil {
+			return err
+		}
+
+		err := s.caseRepo.Create(txCtx, &domains.CollectionCase{
+			UserID:        payment.UserID,
+			PaymentID:     payment.ID,
+			InstallmentID: installment.ID,
+			AmountDue:     installment.Amount,
+			Status:        domains.CollectionCaseOpen,
+			OpenedAt:      time.Now(),
+		})
+		var duplicateErr *utils.ErrDuplicateEntry
+		if err != nil && !errors.As(err, &duplicateErr) {
+			s.logger.Error("Failed to open collection case", zap.Uint("installmentID", installment.ID), zap.Error(err))
+			return fmt.Errorf("failed to open collection case: %w", err)
+		}
+
+		return s.notify(txCtx, payment, installment, domains.NotificationInstallmentDefaulted,
+			fmt.Sprintf("Installment %d of %d %s has defaulted. Your credit line is frozen and your account has been passed to our collections team.",
+				installment.InstallmentNumber, installment.Amount, payment.Currency))
+	})
+}
+
+// ResolveDefault closes the collection case of a defaulted installment that
+// was paid, and unfreezes the customer's credit line once none of their cases
+// is left open. It runs in the caller's transaction.
+func (s *DunningService) ResolveDefault(ctx context.Context, payment *domains.Payment, installment *domains.Installment) error {
+	if err := s.caseRepo.Resolve(ctx, installment.ID, time.Now()); err != nil {
+		s.logger.Error("Failed to resolve collection case", zap.Uint("installmentID", installment.ID), zap.Error(err))
+		return fmt.Errorf("failed to resolve collection case: %w", err)
+	}
+
+	open, err := s.caseRepo.CountOpenByUserID(ctx, payment.UserID)
+	if err != nil {
+		s.logger.Error("Failed to count open collection cases", zap.String("userID", payment.UserID), zap.Error(err))
+		return fmt.Errorf("failed to count open collection cases: %w", err)
+	}
+	if open > 0 {
+		return nil
+	}
+	return s.creditLines.Unfreeze(ctx, payment.UserID)
+}
+
+// transition moves installment to status and runs then in the same
+// transaction. An installment that left the from statuses meanwhile, e.g.
+// because it was paid, is skipped.
+func (s *DunningService) transition(ctx context.Context, installment *domains.Installment, status string, from []string, then func(txCtx context.Context, payment *domains.Payment) error) error {
+	err := s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.installmentRepo.UpdateStatus(txCtx, installment.ID, status, from...); err != nil {
+			return err
+		}
+		payment, err := s.paymentRepo.GetByID(txCtx, installment.PaymentID)
+		if err != nil {
+			return fmt.Errorf("failed to get payment: %w", err)
+		}
+		return then(txCtx, payment)
+	})
+
+	var transitionErr *utils.ErrInvalidTransition
+	if errors.As(err, &transitionErr) {
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to move installment", zap.Uint("installmentID", installment.ID), zap.String("status", status), zap.Error(err))
+		return err
+	}
+
+	installment.Status = status
+	s.logger.Info("Installment moved", zap.Uint("installmentID", installment.ID), zap.String("status", status))
+	return nil
+}
+
+func (s *DunningService) notify(ctx context.Context, payment *domains.Payment, installment *domains.Installment, notificationType, message string) error {
+	return s.notifications.Notify(ctx, &domains.Notification{
+		UserID:        payment.UserID,
+		Type:          notificationType,
+		Message:       message,
+		PaymentID:     payment.ID,
+		InstallmentID: installment.ID,
+	})
+}
+
+func (s *DunningService) ListCollectionCases(ctx context.Context, status domains.CollectionCaseStatus, offset, limit int) ([]*domains.CollectionCase, int, error) {
+	cases, total, err := s.caseRepo.List(ctx, status, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list collection cases", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list collection cases: %w", err)
+	}
+	return cases, total, nil
+}
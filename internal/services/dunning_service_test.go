@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"go.uber.org/zap"
+)
+
+func TestDunningPolicyNextRetry(t *testing.T) {
+	policy := DunningPolicy{RetryDays: []int{1, 3, 7}, GraceDays: 3, DefaultDays: 30}
+	failedAt := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		failedCount int
+		want        string
+	}{
+		{0, ""},
+		{1, "2026-03-11"},
+		{2, "2026-03-13"},
+		{3, "2026-03-17"},
+		{4, ""},
+	}
+	for _, tt := range tests {
+		next := policy.NextRetry(tt.failedCount, failedAt)
+		got := ""
+		if next != nil {
+			got = next.Format(installmentDateLayout)
+		}
+		if got != tt.want {
+			t.Errorf("NextRetry(%d) = %q, want %q", tt.failedCount, got, tt.want)
+		}
+	}
+}
+
+type dunningFixture struct {
+	service       *DunningService
+	installment   *domains.Installment
+	installments  *fakeInstallmentRepo
+	cases         *fakeCollectionCaseRepo
+	notifications *fakeNotificationRepo
+	creditLines   *fakeCreditLineRepo
+	ledger        *fakeLedgerRepo
+}
+
+// newDunningFixture sets up an unpaid installment of payment 1 that fell due
+// dueDaysAgo.
+func newDunningFixture(t *testing.T, status string, dueDaysAgo int) *dunningFixture {
+	t.Helper()
+	logger := zap.NewNop()
+	payments := newFakePaymentRepo()
+	if err := payments.Create(context.Background(), &domains.Payment{UserID: testUserID, Amount: 40000, Currency: "DZD", Status: "SUCCESSFUL"}); err != nil {
+		t.Fatalf("Create payment: %v", err)
+	}
+
+	f := &dunningFixture{
+		installment: &domains.Installment{
+			PaymentID:         1,
+			InstallmentNumber: 2,
+			DueDate:           time.Now().AddDate(0, 0, -dueDaysAgo).Format(installmentDateLayout),
+			Amount:            10000,
+			Principal:         10000,
+			Status:            status,
+		},
+		cases:         &fakeCollectionCaseRepo{},
+		notifications: &fakeNotificationRepo{},
+		creditLines: &fakeCreditLineRepo{lines: map[string]*domains.CreditLine{
+			testUserID: {UserID: testUserID, Currency: "DZD", CreditLimit: 50000, Status: domains.CreditLineActive},
+		}},
+		ledger: &fakeLedgerRepo{},
+	}
+	f.installment.ID = 10
+	f.installments = &fakeInstallmentRepo{installments: []*domains.Installment{f.installment}}
+	f.service = NewDunningService(
+		f.installments,
+		payments,
+		f.cases,
+		NewCreditLineService(f.creditLines, &fakeReservationRepo{}, time.Minute, logger),
+		NewNotificationService(f.notifications, logger),
+		NewLedgerService(f.ledger, logger),
+		newTestTransactionManager(t),
+		DunningPolicy{RetryDays: []int{1, 3}, GraceDays: 3, DefaultDays: 30},
+		logger,
+	)
+	return f
+}
+
+func (f *dunningFixture) lineStatus(t *testing.T) domains.CreditLineStatus {
+	t.Helper()
+	line, err := f.creditLines.GetByUserID(context.Background(), testUserID)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	return line.Status
+}
+
+func TestRecordFailureSchedulesRetriesUntilExhausted(t *testing.T) {
+	f := newDunningFixture(t, "PENDING", 0)
+	failedAt := time.Now()
+
+	for i, wantRetry := range []bool{true, true, false} {
+		if err := f.service.RecordFailure(context.Background(), f.installment, failedAt); err != nil {
+			t.Fatalf("RecordFailure %d: %v", i+1, err)
+		}
+		if f.installment.Status != "FAILED" || f.installment.RetryCount != i+1 {
+			t.Fatalf("installment is %s after %d failures, want FAILED after %d", f.installment.Status, f.installment.RetryCount, i+1)
+		}
+		if (f.installment.NextRetryAt != nil) != wantRetry {
+			t.Fatalf("failure %d: retry scheduled = %v, want %v", i+1, f.installment.NextRetryAt != nil, wantRetry)
+		}
+	}
+	if got := len(f.notifications.notifications); got != 3 {
+		t.Fatalf("%d notifications sent, want one per failure", got)
+	}
+}
+
+func TestProcessDelinquenciesMovesInstallmentsByAge(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		dueDaysAgo int
+		want       string
+	}{
+		{"within grace period", "PENDING", 2, "PENDING"},
+		{"past grace period", "PENDING", 5, "OVERDUE"},
+		{"failed past grace period", "FAILED", 5, "OVERDUE"},
+		{"overdue within default period", "OVERDUE", 20, "OVERDUE"},
+		{"past default period", "OVERDUE", 31, "DEFAULTED"},
+		{"never collected past default period", "PENDING", 40, "DEFAULTED"},
+		{"paid", "PAID", 40, "PAID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newDunningFixture(t, tt.status, tt.dueDaysAgo)
+			if err := f.service.ProcessDelinquencies(context.Background()); err != nil {
+				t.Fatalf("ProcessDelinquencies: %v", err)
+			}
+			if f.installment.Status != tt.want {
+				t.Fatalf("installment is %s, want %s", f.installment.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultFreezesLineUntilResolved(t *testing.T) {
+	f := newDunningFixture(t, "OVERDUE", 31)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := f.service.ProcessDelinquencies(ctx); err != nil {
+			t.Fatalf("ProcessDelinquencies: %v", err)
+		}
+	}
+	if f.installment.Status != "DEFAULTED" || f.lineStatus(t) != domains.CreditLineFrozen {
+		t.Fatalf("installment %s with line %s, want DEFAULTED with a frozen line", f.installment.Status, f.lineStatus(t))
+	}
+	if len(f.cases.cases) != 1 || len(f.ledger.journals) != 1 || len(f.notifications.notifications) != 1 {
+		t.Fatalf("%d cases, %d write-offs and %d notifications, want one of each",
+			len(f.cases.cases), len(f.ledger.journals), len(f.notifications.notifications))
+	}
+
+	payment := &domains.Payment{UserID: testUserID}
+	payment.ID = 1
+	if err := f.service.ResolveDefault(ctx, payment, f.installment); err != nil {
+		t.Fatalf("ResolveDefault: %v", err)
+	}
+	if f.cases.cases[0].Status != domains.CollectionCaseResolved || f.lineStatus(t) != domains.CreditLineActive {
+		t.Fatalf("case %s with line %s, want RESOLVED with an active line", f.cases.cases[0].Status, f.lineStatus(t))
+	}
+}
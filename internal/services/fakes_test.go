@@ -0,0 +1,590 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// noopConnector is a database/sql connector whose transactions do nothing.
+// Services under test get a real TransactionManager backed by it while their
+// repositories are in-memory fakes.
+type noopConnector struct{}
+
+func (noopConnector) Connect(context.Context) (driver.Conn, error) { return noopConn{}, nil }
+func (noopConnector) Driver() driver.Driver                        { return noopDriver{} }
+
+type noopDriver struct{}
+
+func (noopDriver) Open(string) (driver.Conn, error) { return noopConn{}, nil }
+
+type noopConn struct{}
+
+func (noopConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("noop connection runs no statements")
+}
+func (noopConn) Close() error              { return nil }
+func (noopConn) Begin() (driver.Tx, error) { return noopTx{}, nil }
+
+type noopTx struct{}
+
+func (noopTx) Commit() error   { return nil }
+func (noopTx) Rollback() error { return nil }
+
+func newTestTransactionManager(t *testing.T) *utils.TransactionManager {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(noopConnector{})}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	return utils.NewTransactionManager(db)
+}
+
+// The fakes below embed their repository interface, so a test calling a
+// method a fake does not implement panics rather than passing silently.
+
+type fakeCreditAppRepo struct {
+	repository.CreditApplicationRepository
+	apps map[uint]*domains.CreditApplication
+}
+
+func (r *fakeCreditAppRepo) GetByID(ctx context.Context, id uint) (*domains.CreditApplication, error) {
+	app, ok := r.apps[id]
+	if !ok {
+		return nil, &utils.ErrNotFound{Entity: "CreditApplication", ID: id}
+	}
+	copied := *app
+	return &copied, nil
+}
+
+type fakeMerchantRepo struct {
+	repository.MerchantRepository
+	merchants map[uint]*domains.Merchant
+}
+
+func (r *fakeMerchantRepo) GetByID(ctx context.Context, id uint) (*domains.Merchant, error) {
+	merchant, ok := r.merchants[id]
+	if !ok {
+		return nil, &utils.ErrNotFound{Entity: "Merchant", ID: id}
+	}
+	return merchant, nil
+}
+
+func (r *fakeMerchantRepo) GetStoreByID(ctx context.Context, storeID uint) (*domains.Store, error) {
+	for _, merchant := range r.merchants {
+		for i := range merchant.Stores {
+			if merchant.Stores[i].ID == storeID {
+				return &merchant.Stores[i], nil
+			}
+		}
+	}
+	return nil, &utils.ErrNotFound{Entity: "Store", ID: storeID}
+}
+
+type fakePlanRepo struct {
+	repository.PlanProductRepository
+}
+
+func (r *fakePlanRepo) List(ctx context.Context, activeOnly bool) ([]*domains.PlanProduct, error) {
+	return nil, nil
+}
+
+// fakeCreditLineRepo keeps credit lines in memory with the same versioned
+// update semantics as the database. Reads yield to other goroutines, so
+// parallel callers interleave between reading a line and updating it.
+type fakeCreditLineRepo struct {
+	repository.CreditLineRepository
+	mu    sync.Mutex
+	lines map[string]*domains.CreditLine
+}
+
+func (r *fakeCreditLineRepo) GetByUserID(ctx context.Context, userID string) (*domains.CreditLine, error) {
+	defer runtime.Gosched()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	line, ok := r.lines[userID]
+	if !ok {
+		return nil, &utils.ErrNotFound{Entity: "CreditLine", ID: userID}
+	}
+	copied := *line
+	return &copied, nil
+}
+
+func (r *fakeCreditLineRepo) UpdateVersioned(ctx context.Context, line *domains.CreditLine) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.lines[line.UserID]
+	if !ok || stored.Version != line.Version {
+		return &utils.ErrVersionConflict{Entity: "CreditLine", ID: line.ID}
+	}
+	line.Version++
+	copied := *line
+	r.lines[line.UserID] = &copied
+	return nil
+}
+
+func (r *fakeCreditLineRepo) Adjust(ctx context.Context, userID string, utilizedDelta, reservedDelta int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	line, ok := r.lines[userID]
+	if !ok {
+		return &utils.ErrNotFound{Entity: "CreditLine", ID: userID}
+	}
+	line.Utilized = max(line.Utilized+utilizedDelta, 0)
+	line.Reserved = max(line.Reserved+reservedDelta, 0)
+	line.Version++
+	return nil
+}
+
+func (r *fakeCreditLineRepo) SetStatus(ctx context.Context, userID string, status domains.CreditLineStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	line, ok := r.lines[userID]
+	if !ok {
+		return &utils.ErrNotFound{Entity: "CreditLine", ID: userID}
+	}
+	line.Status = status
+	line.Version++
+	return nil
+}
+
+type fakeReservationRepo struct {
+	repository.CreditReservationRepository
+	mu           sync.Mutex
+	reservations []*domains.CreditReservation
+}
+
+func (r *fakeReservationRepo) Create(ctx context.Context, reservation *domains.CreditReservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reservation.ID = uint(len(r.reservations) + 1)
+	r.reservations = append(r.reservations, reservation)
+	return nil
+}
+
+type fakePaymentRepo struct {
+	repository.PaymentRepository
+	mu       sync.Mutex
+	payments map[uint]*domains.Payment
+	nextID   uint
+}
+
+func newFakePaymentRepo() *fakePaymentRepo {
+	return &fakePaymentRepo{payments: make(map[uint]*domains.Payment)}
+}
+
+func (r *fakePaymentRepo) Create(ctx context.Context, payment *domains.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	payment.ID = r.nextID
+	copied := *payment
+	r.payments[payment.ID] = &copied
+	return nil
+}
+
+func (r *fakePaymentRepo) GetByID(ctx context.Context, id uint) (*domains.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payment, ok := r.payments[id]
+	if !ok {
+		return nil, &utils.ErrNotFound{Entity: "Payment", ID: id}
+	}
+	copied := *payment
+	return &copied, nil
+}
+
+func (r *fakePaymentRepo) Update(ctx context.Context, payment *domains.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.payments[payment.ID]; !ok {
+		return &utils.ErrNotFound{Entity: "Payment", ID: payment.ID}
+	}
+	copied := *payment
+	r.payments[payment.ID] = &copied
+	return nil
+}
+
+// fakeGateway registers every order and records the amounts it was asked
+// to take. Orders in statuses can be confirmed.
+type fakeGateway struct {
+	mu       sync.Mutex
+	orders   []GatewayOrderRequest
+	statuses map[string]GatewayOrderStatus
+}
+
+func (g *fakeGateway) RegisterOrder(ctx context.Context, req GatewayOrderRequest) (*GatewayOrder, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.orders = append(g.orders, req)
+	return &GatewayOrder{OrderID: req.OrderNumber, RedirectURL: "https://gateway.test/pay/" + req.OrderNumber}, nil
+}
+
+func (g *fakeGateway) ConfirmOrder(ctx context.Context, orderID string) (*GatewayOrderResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status, ok := g.statuses[orderID]
+	if !ok {
+		return nil, errors.New("order unknown to fakeGateway")
+	}
+	return &GatewayOrderResult{Status: status}, nil
+}
+
+func (g *fakeGateway) PayOrder(ctx context.Context, orderID string, card GatewayCard) error {
+	return errors.New("not implemented by fakeGateway")
+}
+
+func (g *fakeGateway) RefundOrder(ctx context.Context, orderID string, amount int) error {
+	return errors.New("not implemented by fakeGateway")
+}
+
+type fakeWebhookEndpointRepo struct {
+	repository.WebhookEndpointRepository
+	endpoints map[uint]*domains.WebhookEndpoint
+}
+
+func (r *fakeWebhookEndpointRepo) Create(ctx context.Context, endpoint *domains.WebhookEndpoint) error {
+	endpoint.ID = uint(len(r.endpoints) + 1)
+	copied := *endpoint
+	r.endpoints[endpoint.ID] = &copied
+	return nil
+}
+
+func (r *fakeWebhookEndpointRepo) GetByID(ctx context.Context, id uint) (*domains.WebhookEndpoint, error) {
+	endpoint, ok := r.endpoints[id]
+	if !ok {
+		return nil, &utils.ErrNotFound{Entity: "WebhookEndpoint", ID: id}
+	}
+	copied := *endpoint
+	return &copied, nil
+}
+
+func (r *fakeWebhookEndpointRepo) ListByMerchantID(ctx context.Context, merchantID uint) ([]*domains.WebhookEndpoint, error) {
+	var endpoints []*domains.WebhookEndpoint
+	for _, endpoint := range r.endpoints {
+		if endpoint.MerchantID == merchantID {
+			copied := *endpoint
+			endpoints = append(endpoints, &copied)
+		}
+	}
+	return endpoints, nil
+}
+
+type fakeWebhookDeliveryRepo struct {
+	repository.WebhookDeliveryRepository
+	deliveries map[uint]*domains.WebhookDelivery
+	attempts   []domains.WebhookDeliveryAttempt
+}
+
+func (r *fakeWebhookDeliveryRepo) Create(ctx context.Context, delivery *domains.WebhookDelivery) error {
+	delivery.ID = uint(len(r.deliveries) + 1)
+	copied := *delivery
+	r.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (r *fakeWebhookDeliveryRepo) GetByID(ctx context.Context, id uint) (*domains.WebhookDelivery, error) {
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, &utils.ErrNotFound{Entity: "WebhookDelivery", ID: id}
+	}
+	copied := *delivery
+	for _, attempt := range r.attempts {
+		if attempt.DeliveryID == id {
+			copied.AttemptLog = append(copied.AttemptLog, attempt)
+		}
+	}
+	return &copied, nil
+}
+
+func (r *fakeWebhookDeliveryRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]*domains.WebhookDelivery, error) {
+	var due []*domains.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == domains.WebhookDeliveryPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now) && len(due) < limit {
+			copied := *delivery
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeWebhookDeliveryRepo) CreateAttempt(ctx context.Context, attempt *domains.WebhookDeliveryAttempt) error {
+	attempt.ID = uint(len(r.attempts) + 1)
+	r.attempts = append(r.attempts, *attempt)
+	return nil
+}
+
+func (r *fakeWebhookDeliveryRepo) UpdateOutcome(ctx context.Context, delivery *domains.WebhookDelivery) error {
+	if _, ok := r.deliveries[delivery.ID]; !ok {
+		return &utils.ErrNotFound{Entity: "WebhookDelivery", ID: delivery.ID}
+	}
+	copied := *delivery
+	copied.AttemptLog = nil
+	r.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (r *fakeWebhookDeliveryRepo) Reschedule(ctx context.Context, id uint, at time.Time) error {
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return &utils.ErrNotFound{Entity: "WebhookDelivery", ID: id}
+	}
+	delivery.Status = domains.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &at
+	return nil
+}
+
+type fakeMerchantEventRepo struct {
+	repository.MerchantEventRepository
+	events map[uint]*domains.MerchantEvent
+}
+
+func (r *fakeMerchantEventRepo) GetByID(ctx context.Context, id uint) (*domains.MerchantEvent, error) {
+	event, ok := r.events[id]
+	if !ok {
+		return nil, &utils.ErrNotFound{Entity: "MerchantEvent", ID: id}
+	}
+	copied := *event
+	return &copied, nil
+}
+
+// fakeLedgerRepo keeps posted journals in memory, one per type and reference.
+type fakeLedgerRepo struct {
+	repository.LedgerRepository
+	journals []*domains.Journal
+}
+
+func (r *fakeLedgerRepo) CreateJournal(ctx context.Context, journal *domains.Journal) error {
+	for _, posted := range r.journals {
+		if posted.Type == journal.Type && posted.Reference == journal.Reference {
+			return &utils.ErrDuplicateEntry{Entity: "Journal", Field: "reference", Value: journal.Reference}
+		}
+	}
+	r.journals = append(r.journals, journal)
+	return nil
+}
+
+type fakeCardVaultRepo struct {
+	repository.CardVaultRepository
+	entries map[string]*domains.CardVaultEntry
+}
+
+func (r *fakeCardVaultRepo) Create(ctx context.Context, entry *domains.CardVaultEntry) error {
+	r.entries[entry.Token] = entry
+	return nil
+}
+
+func (r *fakeCardVaultRepo) GetByToken(ctx context.Context, token string) (*domains.CardVaultEntry, error) {
+	entry, ok := r.entries[token]
+	if !ok {
+		return nil, &utils.ErrNotFound{Entity: "Card", ID: token}
+	}
+	return entry, nil
+}
+
+func (r *fakeCardVaultRepo) GetByFingerprint(ctx context.Context, userID, fingerprint, expiryDate string) (*domains.CardVaultEntry, error) {
+	for _, entry := range r.entries {
+		if entry.UserID == userID && entry.Fingerprint == fingerprint && entry.ExpiryDate == expiryDate {
+			return entry, nil
+		}
+	}
+	return nil, &utils.ErrNotFound{Entity: "Card", ID: fingerprint}
+}
+
+type fakeInstallmentRepo struct {
+	repository.InstallmentRepository
+	installments []*domains.Installment
+}
+
+func (r *fakeInstallmentRepo) ListByStatus(ctx context.Context, statuses []string, afterID uint, limit int) ([]*domains.Installment, error) {
+	var found []*domains.Installment
+	for _, installment := range r.installments {
+		for _, status := range statuses {
+			if installment.Status == status && installment.ID > afterID && len(found) < limit {
+				found = append(found, installment)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeInstallmentRepo) ListDueBefore(ctx context.Context, statuses []string, dueBefore string, limit int) ([]*domains.Installment, error) {
+	var found []*domains.Installment
+	for _, installment := range r.installments {
+		for _, status := range statuses {
+			if installment.Status == status && installment.DueDate < dueBefore && len(found) < limit {
+				copied := *installment
+				found = append(found, &copied)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeInstallmentRepo) UpdateStatus(ctx context.Context, id uint, to string, from ...string) error {
+	for _, installment := range r.installments {
+		if installment.ID != id {
+			continue
+		}
+		for _, status := range from {
+			if installment.Status == status {
+				installment.Status = to
+				return nil
+			}
+		}
+	}
+	return &utils.ErrInvalidTransition{Entity: "Installment", ID: id, From: strings.Join(from, "|"), To: to}
+}
+
+func (r *fakeInstallmentRepo) RecordFailure(ctx context.Context, failed *domains.Installment) error {
+	for _, installment := range r.installments {
+		if installment.ID == failed.ID && installment.Status != "PAID" {
+			*installment = *failed
+			return nil
+		}
+	}
+	return &utils.ErrInvalidTransition{Entity: "Installment", ID: failed.ID, From: "PENDING|FAILED|OVERDUE", To: failed.Status}
+}
+
+type fakeCollectionCaseRepo struct {
+	repository.CollectionCaseRepository
+	cases []*domains.CollectionCase
+}
+
+func (r *fakeCollectionCaseRepo) Create(ctx context.Context, collectionCase *domains.CollectionCase) error {
+	for _, existing := range r.cases {
+		if existing.InstallmentID == collectionCase.InstallmentID {
+			return &utils.ErrDuplicateEntry{Entity: "CollectionCase", Field: "installment_id", Value: collectionCase.InstallmentID}
+		}
+	}
+	r.cases = append(r.cases, collectionCase)
+	return nil
+}
+
+func (r *fakeCollectionCaseRepo) Resolve(ctx context.Context, installmentID uint, resolvedAt time.Time) error {
+	for _, collectionCase := range r.cases {
+		if collectionCase.InstallmentID == installmentID && collectionCase.Status == domains.CollectionCaseOpen {
+			collectionCase.Status = domains.CollectionCaseResolved
+			collectionCase.ResolvedAt = &resolvedAt
+		}
+	}
+	return nil
+}
+
+func (r *fakeCollectionCaseRepo) CountOpenByUserID(ctx context.Context, userID string) (int, error) {
+	open := 0
+	for _, collectionCase := range r.cases {
+		if collectionCase.UserID == userID && collectionCase.Status == domains.CollectionCaseOpen {
+			open++
+		}
+	}
+	return open, nil
+}
+
+type fakeNotificationRepo struct {
+	repository.NotificationRepository
+	notifications []*domains.Notification
+}
+
+func (r *fakeNotificationRepo) Create(ctx context.Context, notification *domains.Notification) error {
+	r.notifications = append(r.notifications, notification)
+	return nil
+}
+
+// fakeChargeRepo keeps installment charges in memory with the same
+// conditional updates as the database.
+type fakeChargeRepo struct {
+	repository.InstallmentChargeRepository
+	charges []*domains.InstallmentCharge
+}
+
+func (r *fakeChargeRepo) Create(ctx context.Context, charge *domains.InstallmentCharge) error {
+	for _, existing := range r.charges {
+		if existing.InstallmentID == charge.InstallmentID && existing.Type == charge.Type && existing.AccrualDate == charge.AccrualDate {
+			return &utils.ErrDuplicateEntry{Entity: "InstallmentCharge", Field: "accrual_date", Value: charge.AccrualDate}
+		}
+	}
+	charge.ID = uint(len(r.charges) + 1)
+	r.charges = append(r.charges, charge)
+	return nil
+}
+
+func (r *fakeChargeRepo) GetByID(ctx context.Context, id uint) (*domains.InstallmentCharge, error) {
+	for _, charge := range r.charges {
+		if charge.ID == id {
+			copied := *charge
+			return &copied, nil
+		}
+	}
+	return nil, &utils.ErrNotFound{Entity: "InstallmentCharge", ID: id}
+}
+
+func (r *fakeChargeRepo) ListByInstallmentID(ctx context.Context, installmentID uint) ([]*domains.InstallmentCharge, error) {
+	var found []*domains.InstallmentCharge
+	for _, charge := range r.charges {
+		if charge.InstallmentID == installmentID {
+			copied := *charge
+			found = append(found, &copied)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeChargeRepo) ListByGatewayOrderID(ctx context.Context, gatewayOrderID string) ([]*domains.InstallmentCharge, error) {
+	var found []*domains.InstallmentCharge
+	for _, charge := range r.charges {
+		if charge.GatewayOrderID == gatewayOrderID {
+			copied := *charge
+			found = append(found, &copied)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeChargeRepo) BillOrder(ctx context.Context, ids []uint, gatewayOrderID string) error {
+	for _, charge := range r.charges {
+		for _, id := range ids {
+			if charge.ID == id && charge.Status == domains.ChargeAccrued {
+				charge.GatewayOrderID = gatewayOrderID
+			}
+		}
+	}
+	return nil
+}
+
+func (r *fakeChargeRepo) MarkPaid(ctx context.Context, id uint, gatewayOrderID string, paidAt time.Time) error {
+	for _, charge := range r.charges {
+		if charge.ID == id && charge.Status == domains.ChargeAccrued && charge.GatewayOrderID == gatewayOrderID {
+			charge.Status = domains.ChargePaid
+			charge.PaidAt = &paidAt
+			return nil
+		}
+	}
+	return &utils.ErrInvalidTransition{Entity: "InstallmentCharge", ID: id, From: string(domains.ChargeAccrued), To: string(domains.ChargePaid)}
+}
+
+func (r *fakeChargeRepo) Waive(ctx context.Context, id uint, gatewayOrderID, waivedBy, reason string, waivedAt time.Time) error {
+	for _, charge := range r.charges {
+		if charge.ID == id && charge.Status == domains.ChargeAccrued && charge.GatewayOrderID == gatewayOrderID {
+			charge.Status = domains.ChargeWaived
+			charge.WaivedBy = waivedBy
+			charge.WaiverReason = reason
+			charge.WaivedAt = &waivedAt
+			return nil
+		}
+	}
+	return &utils.ErrInvalidTransition{Entity: "InstallmentCharge", ID: id, From: string(domains.ChargeAccrued), To: string(domains.ChargeWaived)}
+}
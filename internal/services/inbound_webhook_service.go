@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidWebhookPayload = errors.New("invalid webhook payload")
+
+// Headers carrying the gateway's webhook signature
+const (
+	WebhookSignatureHeader = "X-Gateway-Signature"
+	WebhookTimestampHeader = "X-Gateway-Timestamp"
+)
+
+// Webhook sources recorded in the inbound webhook log
+const (
+	WebhookSourcePayment     = "payment"
+	WebhookSourceInstallment = "installment"
+	WebhookSourceRefund      = "refund"
+)
+
+// DefaultWebhookTolerance is how far a webhook timestamp may drift from our
+// clock before the delivery is rejected as a replay.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// InboundWebhook is one webhook delivery as received over HTTP.
+type InboundWebhook struct {
+	Source     string
+	ResourceID uint
+	Signature  string
+	Timestamp  string
+	Body       []byte
+}
+
+type InboundWebhookServiceInterface interface {
+	Receive(ctx context.Context, delivery InboundWebhook, handle func(ctx context.Context) error) (bool, error)
+	ListLogs(ctx context.Context, source string, offset, limit int) ([]*domains.InboundWebhookLog, int, error)
+}
+
+// InboundWebhookService authenticates gateway webhooks, makes sure each event
+// is handled once and logs every delivery.
+type InboundWebhookService struct {
+	logRepo   repository.InboundWebhookLogRepository
+	eventRepo repository.ProcessedWebhookEventRepository
+	txManager *utils.TransactionManager
+	secret    []byte
+	tolerance time.Duration
+	logger    *zap.Logger
+}
+
+// NewInboundWebhookService creates an InboundWebhookService. With an empty
+// secret every delivery is rejected. A zero tolerance uses
+// DefaultWebhookTolerance.
+func NewInboundWebhookService(
+	logRepo repository.InboundWebhookLogRepository,
+	eventRepo repository.ProcessedWebhookEventRepository,
+	txManager *utils.TransactionManager,
+	secret string,
+	tolerance time.Duration,
+	logger *zap.Logger,
+) *InboundWebhookService {
+	if tolerance <= 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+	if secret == "" {
+		logger.Warn("No webhook secret configured, inbound webhooks will be rejected")
+	}
+	return &InboundWebhookService{
+		logRepo:   logRepo,
+		eventRepo: eventRepo,
+		txManager: txManager,
+		secret:    []byte(secret),
+		tolerance: tolerance,
+		logger:    logger,
+	}
+}
+
+// Receive verifies a delivery and runs handle for it unless its event has
+// already been processed. It reports true for such duplicate deliveries. The
+// signed body must name the resource the delivery was posted for. The event
+// is claimed in the same transaction as handle runs in, so if handle fails
+// the claim is rolled back and the gateway can redeliver the event.
+func (s *InboundWebhookService) Receive(ctx context.Context, delivery InboundWebhook, handle func(ctx context.Context) error) (bool, error) {
+	entry := &domains.InboundWebhookLog{
+		Source:     delivery.Source,
+		ResourceID: delivery.ResourceID,
+		Signature:  delivery.Signature,
+		Timestamp:  delivery.Timestamp,
+		Payload:    string(delivery.Body),
+		Status:     domains.InboundWebhookReceived,
+		ReceivedAt: time.Now(),
+	}
+	if err := s.logRepo.Create(ctx, entry); err != nil {
+		s.logger.Error("Failed to log inbound webhook", zap.Error(err))
+		return false, fmt.Errorf("failed to log inbound webhook: %w", err)
+	}
+
+	err := utils.VerifyWebhookSignature(s.secret, delivery.Signature, delivery.Timestamp, delivery.Body, s.tolerance, time.Now())
+	if err != nil {
+		s.logger.Warn("Rejected inbound webhook",
+			zap.String("source", delivery.Source), zap.Uint("resourceID", delivery.ResourceID), zap.Error(err))
+		s.finish(ctx, entry, domains.InboundWebhookRejected, err)
+		return false, err
+	}
+
+	var envelope struct {
+		EventID    string `json:"event_id"`
+		ResourceID uint   `json:"resource_id"`
+	}
+	if err := json.Unmarshal(delivery.Body, &envelope); err != nil || envelope.EventID == "" {
+		err = fmt.Errorf("%w: missing event_id", ErrInvalidWebhookPayload)
+		s.finish(ctx, entry, domains.InboundWebhookRejected, err)
+		return false, err
+	}
+	entry.EventID = envelope.EventID
+	// The path is not signed, so a signed body must not be replayed against
+	// another resource
+	if envelope.ResourceID != delivery.ResourceID {
+		err = fmt.Errorf("%w: resource_id %d does not match %s %d", ErrInvalidWebhookPayload, envelope.ResourceID, delivery.Source, delivery.ResourceID)
+		s.logger.Warn("Rejected inbound webhook", zap.String("source", delivery.Source), zap.Uint("resourceID", delivery.ResourceID), zap.Error(err))
+		s.finish(ctx, entry, domains.InboundWebhookRejected, err)
+		return false, err
+	}
+
+	var duplicateErr *utils.ErrDuplicateEntry
+	duplicate := false
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		claim := &domains.ProcessedWebhookEvent{EventID: envelope.EventID, Source: delivery.Source, ProcessedAt: time.Now()}
+		if err := s.eventRepo.Create(txCtx, claim); err != nil {
+			if errors.As(err, &duplicateErr) {
+				duplicate = true
+				return nil
+			}
+			s.logger.Error("Failed to record webhook event", zap.Error(err))
+			return fmt.Errorf("failed to record webhook event: %w", err)
+		}
+		return handle(txCtx)
+	})
+	if err != nil {
+		s.finish(ctx, entry, domains.InboundWebhookFailed, err)
+		return false, err
+	}
+	if duplicate {
+		s.logger.Info("Ignoring duplicate webhook event", zap.String("eventID", envelope.EventID))
+		s.finish(ctx, entry, domains.InboundWebhookDuplicate, nil)
+		return true, nil
+	}
+
+	s.finish(ctx, entry, domains.InboundWebhookProcessed, nil)
+	return false, nil
+}
+
+func (s *InboundWebhookService) ListLogs(ctx context.Context, source string, offset, limit int) ([]*domains.InboundWebhookLog, int, error) {
+	entries, total, err := s.logRepo.List(ctx, source, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list inbound webhooks", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list inbound webhooks: %w", err)
+	}
+	return entries, total, nil
+}
+
+func (s *InboundWebhookService) finish(ctx context.Context, entry *domains.InboundWebhookLog, status domains.InboundWebhookStatus, cause error) {
+	processedAt := time.Now()
+	entry.Status = status
+	entry.ProcessedAt = &processedAt
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+	if err := s.logRepo.Update(ctx, entry); err != nil {
+		s.logger.Error("Failed to update inbound webhook log", zap.Uint("id", entry.ID), zap.Error(err))
+	}
+}
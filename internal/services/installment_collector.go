@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// CollectionJobName is the JobRunner name of the installment collection.
+	CollectionJobName = "installment-collection"
+	// collectionBatchSize caps how many installments one run charges.
+	collectionBatchSize = 50
+	// staleAttemptAfter is how long an attempt may stay in progress before it
+	// is reconciled with the gateway, e.g. after a crash mid-charge.
+	staleAttemptAfter = 10 * time.Minute
+	// customerOrderWindow is how long a gateway order the customer opened to
+	// pay an installment or prepay keeps it from being charged automatically.
+	customerOrderWindow = 30 * time.Minute
+)
+
+// InstallmentCollector charges due installments to the card the customer paid
+// the purchase with. Every charge is recorded as a CollectionAttempt before
+// the card is touched, and an installment is never charged while an earlier
+// attempt may still have gone through.
+type InstallmentCollector struct {
+	installmentRepo repository.InstallmentRepository
+	paymentRepo     repository.PaymentRepository
+	attemptRepo     repository.CollectionAttemptRepository
+	cards           *CardVaultService
+	payments        *CreditPaymentService
+	paymentGateway  PaymentGateway
+	txManager       *utils.TransactionManager
+	owner           string
+	logger          *zap.Logger
+}
+
+func NewInstallmentCollector(
+	installmentRepo repository.InstallmentRepository,
+	paymentRepo repository.PaymentRepository,
+	attemptRepo repository.CollectionAttemptRepository,
+	cards *CardVaultService,
+	payments *CreditPaymentService,
+	paymentGateway PaymentGateway,
+	txManager *utils.TransactionManager,
+	owner string,
+	logger *zap.Logger,
+) *InstallmentCollector {
+	return &InstallmentCollector{
+		installmentRepo: installmentRepo,
+		paymentRepo:     paymentRepo,
+		attemptRepo:     attemptRepo,
+		cards:           cards,
+		payments:        payments,
+		paymentGateway:  paymentGateway,
+		txManager:       txManager,
+		owner:           owner,
+		logger:          logger,
+	}
+}
+
+// CollectDue reconciles attempts left in progress, then charges the
+// installments due today or earlier. Declined cards are not errors; the
+// returned error reports charges that could not be carried out.
+func (s *InstallmentCollector) CollectDue(ctx context.Context) error {
+	now := time.Now()
+	s.reconcileStaleAttempts(ctx, now.Add(-staleAttemptAfter))
+
+	installments, err := s.installmentRepo.ListDue(ctx, now.Format(installmentDateLayout), now, now.Add(-customerOrderWindow), collectionBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list due installments", zap.Error(err))
+		return fmt.Errorf("failed to list due installments: %w", err)
+	}
+
+	failed := 0
+	for _, installment := range installments {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.Collect(ctx, installment); err != nil {
+			failed++
+		}
+	}
+
+	s.logger.Info("Installment collection finished", zap.Int("due", len(installments)), zap.Int("failed", failed))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d installment collections failed", failed, len(installments))
+	}
+	return nil
+}
+
+// Collect charges one installment, with the late fees it owes, to the card on
+// file of its payment.
+func (s *InstallmentCollector) Collect(ctx context.Context, installment *domains.Installment) error {
+	payment, err := s.paymentRepo.GetByID(ctx, installment.PaymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Uint("paymentID", installment.PaymentID), zap.Error(err))
+		return fmt.Errorf("failed to get payment: %w", err)
+	}
+	details := payment.PaymentMethod.Details
+	if details.CardToken == "" {
+		s.logger.Warn("No card on file for installment", zap.Uint("installmentID", installment.ID))
+		return fmt.Errorf("no card on file for payment %d", payment.ID)
+	}
+
+	charges, fees, err := s.payments.penalties.BillableCharges(ctx, installment.ID)
+	if err != nil {
+		return err
+	}
+
+	attempt := &domains.CollectionAttempt{
+		InstallmentID: installment.ID,
+		OrderNumber:   uuid.Must(uuid.NewV4()).String(),
+		Amount:        installment.Amount + fees,
+		Status:        domains.CollectionAttemptInProgress,
+		Owner:         s.owner,
+		StartedAt:     time.Now(),
+	}
+	if err := s.attemptRepo.Create(ctx, attempt); err != nil {
+		var duplicateErr *utils.ErrDuplicateEntry
+		if errors.As(err, &duplicateErr) {
+			s.logger.Info("Installment is already being collected", zap.Uint("installmentID", installment.ID))
+			return nil
+		}
+		s.logger.Error("Failed to record collection attempt", zap.Uint("installmentID", installment.ID), zap.Error(err))
+		return fmt.Errorf("failed to record collection attempt: %w", err)
+	}
+
+	card, err := s.cards.Reveal(ctx, details.CardToken)
+	if err != nil {
+		return s.abandonAttempt(ctx, attempt, fmt.Errorf("failed to read card on file: %w", err))
+	}
+
+	order, err := s.paymentGateway.RegisterOrder(ctx, GatewayOrderRequest{
+		OrderNumber: attempt.OrderNumber,
+		Amount:      attempt.Amount,
+		Currency:    payment.Currency,
+		ReturnPath:  InstallmentReturnPath,
+		Description: fmt.Sprintf("Installment %d of payment %d", installment.InstallmentNumber, payment.ID),
+	})
+	if err != nil {
+		return s.abandonAttempt(ctx, attempt, fmt.Errorf("failed to register gateway order: %w", err))
+	}
+
+	// The order is saved before the card is charged, so that an interrupted
+	// attempt can be reconciled instead of charged again
+	attempt.GatewayOrderID = order.OrderID
+	if err := s.attemptRepo.Update(ctx, attempt); err != nil {
+		s.logger.Error("Failed to save collection attempt", zap.Uint("attemptID", attempt.ID), zap.Error(err))
+		return fmt.Errorf("failed to save collection attempt: %w", err)
+	}
+	if err := s.payments.penalties.BillCharges(ctx, charges, order.OrderID); err != nil {
+		return s.abandonAttempt(ctx, attempt, err)
+	}
+
+	err = s.paymentGateway.PayOrder(ctx, order.OrderID, GatewayCard{
+		Number:     card.Number,
+		HolderName: card.HolderName,
+		ExpiryDate: details.ExpiryDate,
+	})
+	if err != nil {
+		// The charge may still have gone through; the attempt stays in
+		// progress until the gateway tells us
+		s.logger.Error("Failed to charge installment", zap.Uint("installmentID", installment.ID), zap.Error(err))
+		return fmt.Errorf("failed to charge installment: %w", err)
+	}
+
+	return s.settleAttempt(ctx, attempt, installment)
+}
+
+// settleAttempt asks the gateway for the outcome of an attempt's order and
+// records it on the attempt and the installment.
+func (s *InstallmentCollector) settleAttempt(ctx context.Context, attempt *domains.CollectionAttempt, installment *domains.Installment) error {
+	result, err := s.paymentGateway.ConfirmOrder(ctx, attempt.GatewayOrderID)
+	if err != nil {
+		s.logger.Error("Failed to confirm collection", zap.Uint("attemptID", attempt.ID), zap.Error(err))
+		return fmt.Errorf("failed to confirm collection: %w", err)
+	}
+
+	var attemptStatus domains.CollectionAttemptStatus
+	var installmentStatus string
+	switch result.Status {
+	case GatewayOrderPaid:
+		attemptStatus, installmentStatus = domains.CollectionAttemptSucceeded, "PAID"
+	case GatewayOrderDeclined:
+		attemptStatus, installmentStatus = domains.CollectionAttemptFailed, "FAILED"
+	default:
+		s.logger.Info("Collection still pending at gateway", zap.Uint("attemptID", attempt.ID))
+		return nil
+	}
+
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.finishAttempt(txCtx, attempt, attemptStatus, result.Reason); err != nil {
+			return err
+		}
+		return s.payments.applyInstallmentResult(txCtx, installment, installmentStatus, attempt.GatewayOrderID, attempt.Amount)
+	})
+	if err != nil {
+		s.logger.Error("Failed to record collection outcome", zap.Uint("attemptID", attempt.ID), zap.Error(err))
+		return fmt.Errorf("failed to record collection outcome: %w", err)
+	}
+
+	s.logger.Info("Installment collection settled",
+		zap.Uint("installmentID", installment.ID), zap.String("status", string(attemptStatus)))
+	return nil
+}
+
+// reconcileStaleAttempts settles attempts that were interrupted before their
+// outcome was recorded. Orders that never reached the gateway are closed as
+// failed so the installment can be charged again. Orders the gateway still
+// has pending may yet be paid, so their attempts stay in progress, keeping
+// the installment from being charged again, until the gateway settles them.
+func (s *InstallmentCollector) reconcileStaleAttempts(ctx context.Context, startedBefore time.Time) {
+	attempts, err := s.attemptRepo.ListStale(ctx, startedBefore, collectionBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list stale collection attempts", zap.Error(err))
+		return
+	}
+
+	for _, attempt := range attempts {
+		if attempt.GatewayOrderID == "" {
+			_ = s.finishAttempt(ctx, attempt, domains.CollectionAttemptFailed, "interrupted before reaching the gateway")
+			continue
+		}
+
+		installment, err := s.installmentRepo.GetByID(ctx, attempt.InstallmentID)
+		if err != nil {
+			s.logger.Error("Failed to get installment", zap.Uint("installmentID", attempt.InstallmentID), zap.Error(err))
+			continue
+		}
+		_ = s.settleAttempt(ctx, attempt, installment)
+	}
+}
+
+// abandonAttempt closes an attempt that failed before the card was charged.
+func (s *InstallmentCollector) abandonAttempt(ctx context.Context, attempt *domains.CollectionAttempt, cause error) error {
+	s.logger.Error("Collection attempt abandoned", zap.Uint("attemptID", attempt.ID), zap.Error(cause))
+	if err := s.finishAttempt(ctx, attempt, domains.CollectionAttemptFailed, cause.Error()); err != nil {
+		return err
+	}
+	return cause
+}
+
+func (s *InstallmentCollector) finishAttempt(ctx context.Context, attempt *domains.CollectionAttempt, status domains.CollectionAttemptStatus, reason string) error {
+	now := time.Now()
+	attempt.Status = status
+	attempt.FailureReason = ""
+	if status == domains.CollectionAttemptFailed {
+		attempt.FailureReason = reason
+	}
+	attempt.FinishedAt = &now
+	if err := s.attemptRepo.Update(ctx, attempt); err != nil {
+		s.logger.Error("Failed to finish collection attempt", zap.Uint("attemptID", attempt.ID), zap.Error(err))
+		return fmt.Errorf("failed to finish collection attempt: %w", err)
+	}
+	return nil
+}
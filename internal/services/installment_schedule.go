@@ -0,0 +1,53 @@
+package service
+
+import (
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+)
+
+// installmentDateLayout is the format of Installment.DueDate.
+const installmentDateLayout = "2006-01-02"
+
+// BuildSchedule splits a purchase of amount into the installments of a plan
+// with the given terms, starting at start. A down payment is due at start
+// and the financed amount and fee of PricePlan are spread evenly over the
+// remaining installments, one period apart; without a down payment the first
+// installment is due one period after start. The installments add up to the
+// total repayable to the unit. They are not persisted.
+func BuildSchedule(terms domains.PlanTerms, amount int, start time.Time) []domains.Installment {
+	count := terms.InstallmentCount
+	if count < 1 {
+		count = 1
+	}
+	price := PricePlan(terms, amount)
+	installments := make([]domains.Installment, 0, count)
+
+	firstPeriod := 1
+	if price.DownPayment > 0 {
+		installments = append(installments, domains.Installment{
+			InstallmentNumber: 1,
+			DueDate:           start.Format(installmentDateLayout),
+			Amount:            price.DownPayment,
+			Principal:         price.DownPayment,
+			Status:            "PENDING",
+		})
+		firstPeriod = 0
+	}
+
+	spread := count - len(installments)
+	principals := allocate(price.Financed, spread)
+	fees := allocate(price.Fee, spread)
+	for i := 0; i < spread; i++ {
+		number := len(installments) + 1
+		installments = append(installments, domains.Installment{
+			InstallmentNumber: number,
+			DueDate:           terms.Frequency.DueDate(start, number-1+firstPeriod).Format(installmentDateLayout),
+			Amount:            principals[i] + fees[i],
+			Principal:         principals[i],
+			Fee:               fees[i],
+			Status:            "PENDING",
+		})
+	}
+	return installments
+}
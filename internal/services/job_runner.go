@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// JobFunc is the body of a background job.
+type JobFunc func(ctx context.Context) error
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	lease    time.Duration
+	run      JobFunc
+}
+
+// JobRunner runs periodic jobs whose schedule is kept in the database. Every
+// replica polls the same jobs, but a job only runs on the replica that takes
+// its lease, and its next run time survives restarts.
+type JobRunner struct {
+	leaseRepo repository.JobLeaseRepository
+	owner     string
+	logger    *zap.Logger
+	jobs      []scheduledJob
+}
+
+// NewJobRunner creates a JobRunner. owner identifies this replica in the
+// leases it takes and must differ between replicas.
+func NewJobRunner(leaseRepo repository.JobLeaseRepository, owner string, logger *zap.Logger) *JobRunner {
+	return &JobRunner{
+		leaseRepo: leaseRepo,
+		owner:     owner,
+		logger:    logger,
+	}
+}
+
+// Register adds a job that runs every interval. lease bounds how long one run
+// may take: the run is cancelled when it expires, and another replica may
+// then take the job over.
+func (r *JobRunner) Register(name string, interval, lease time.Duration, run JobFunc) {
+	r.jobs = append(r.jobs, scheduledJob{
+		name:     name,
+		interval: interval,
+		lease:    lease,
+		run:      run,
+	})
+}
+
+// RunDue runs, one after the other, the registered jobs that are due and not
+// leased by another replica.
+func (r *JobRunner) RunDue(ctx context.Context) {
+	for _, job := range r.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		r.runJob(ctx, job)
+	}
+}
+
+// Start polls for due jobs every poll interval until ctx is cancelled.
+func (r *JobRunner) Start(ctx context.Context, poll time.Duration) {
+	go func() {
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+		for {
+			r.RunDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *JobRunner) runJob(ctx context.Context, job scheduledJob) {
+	startedAt := time.Now()
+	acquired, err := r.leaseRepo.Acquire(ctx, job.name, r.owner, startedAt, startedAt.Add(job.lease))
+	if err != nil {
+		r.logger.Error("Failed to acquire job lease", zap.String("job", job.name), zap.Error(err))
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	r.logger.Info("Running job", zap.String("job", job.name), zap.String("owner", r.owner))
+	jobCtx, cancel := context.WithTimeout(ctx, job.lease)
+	runErr := job.run(jobCtx)
+	cancel()
+
+	var lastError string
+	if runErr != nil {
+		lastError = runErr.Error()
+		r.logger.Error("Job failed", zap.String("job", job.name), zap.Error(runErr))
+	}
+
+	finishedAt := time.Now()
+	if err := r.leaseRepo.Finish(ctx, job.name, r.owner, finishedAt, startedAt.Add(job.interval), lastError); err != nil {
+		r.logger.Error("Failed to release job lease", zap.String("job", job.name), zap.Error(err))
+		return
+	}
+	r.logger.Info("Job finished", zap.String("job", job.name), zap.Duration("duration", finishedAt.Sub(startedAt)))
+}
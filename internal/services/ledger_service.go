@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var ErrUnbalancedJournal = errors.New("journal does not balance")
+
+type LedgerServiceInterface interface {
+	ListJournals(ctx context.Context, paymentID uint, offset, limit int) ([]*domains.Journal, int, error)
+	Balances(ctx context.Context, asOf time.Time, userID string, paymentID uint) ([]domains.AccountBalance, error)
+}
+
+// LedgerService posts every money movement to the double-entry ledger.
+// Journals are posted in the transaction of the change they record, so the
+// ledger and the payment tables cannot drift apart.
+//
+// A purchase makes the customer owe the amount and the plan fee, owes the
+// amount to the merchant and earns the fee. Collections turn receivables
+// into cash in transit, late fees add to receivables, refunds reverse a
+// purchase partly or fully and defaults write receivables off. Settlements
+// pay merchants out of cash in transit, keeping the merchant discount.
+type LedgerService struct {
+	ledgerRepo repository.LedgerRepository
+	logger     *zap.Logger
+}
+
+func NewLedgerService(ledgerRepo repository.LedgerRepository, logger *zap.Logger) *LedgerService {
+	return &LedgerService{
+		ledgerRepo: ledgerRepo,
+		logger:     logger,
+	}
+}
+
+// Post validates and stores a journal. Entries of zero are dropped, and the
+// rest must each debit or credit a known account and balance overall. A
+// journal already posted for the same event is ignored.
+func (s *LedgerService) Post(ctx context.Context, journal *domains.Journal) error {
+	if journal.PostedAt.IsZero() {
+		journal.PostedAt = time.Now()
+	}
+
+	entries := journal.Entries[:0]
+	for _, entry := range journal.Entries {
+		if entry.Debit == 0 && entry.Credit == 0 {
+			continue
+		}
+		if !entry.Account.Valid() || entry.Debit < 0 || entry.Credit < 0 || (entry.Debit > 0 && entry.Credit > 0) {
+			return fmt.Errorf("%w: invalid %s entry", ErrUnbalancedJournal, entry.Account)
+		}
+		entry.PaymentID = journal.PaymentID
+		entry.UserID = journal.UserID
+		entry.PostedAt = journal.PostedAt
+		entries = append(entries, entry)
+	}
+	journal.Entries = entries
+	if len(journal.Entries) == 0 {
+		return nil
+	}
+	if len(journal.Entries) < 2 || !journal.Balanced() {
+		s.logger.Error("Refusing unbalanced journal", zap.String("type", string(journal.Type)), zap.String("reference", journal.Reference))
+		return fmt.Errorf("%w: %s %s", ErrUnbalancedJournal, journal.Type, journal.Reference)
+	}
+
+	err := s.ledgerRepo.CreateJournal(ctx, journal)
+	var duplicateErr *utils.ErrDuplicateEntry
+	if errors.As(err, &duplicateErr) {
+		s.logger.Info("Journal already posted", zap.String("type", string(journal.Type)), zap.String("reference", journal.Reference))
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to post journal", zap.String("type", string(journal.Type)), zap.String("reference", journal.Reference), zap.Error(err))
+		return fmt.Errorf("failed to post journal: %w", err)
+	}
+	return nil
+}
+
+// PostPurchase records a payment settled into installments.
+func (s *LedgerService) PostPurchase(ctx context.Context, payment *domains.Payment) error {
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalPurchase,
+		Reference:   fmt.Sprintf("payment:%d", payment.ID),
+		PaymentID:   payment.ID,
+		UserID:      payment.UserID,
+		Currency:    payment.Currency,
+		Description: fmt.Sprintf("Purchase %s", payment.OrderID),
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountCustomerReceivable, Debit: payment.Amount + payment.FeeAmount},
+			{Account: domains.AccountMerchantPayable, Credit: payment.Amount},
+			{Account: domains.AccountFeeIncome, Credit: payment.FeeAmount},
+		},
+	})
+}
+
+// PostInstallmentCollection records an installment paid through the
+// gateway. Paying a defaulted installment recovers what was written off.
+func (s *LedgerService) PostInstallmentCollection(ctx context.Context, payment *domains.Payment, installment *domains.Installment, defaulted bool) error {
+	settled := domains.AccountCustomerReceivable
+	if defaulted {
+		settled = domains.AccountCreditLosses
+	}
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalCollection,
+		Reference:   fmt.Sprintf("installment:%d", installment.ID),
+		PaymentID:   payment.ID,
+		UserID:      payment.UserID,
+		Currency:    payment.Currency,
+		Description: fmt.Sprintf("Installment %d collected", installment.InstallmentNumber),
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountCashInTransit, Debit: installment.Amount},
+			{Account: settled, Credit: installment.Amount},
+		},
+	})
+}
+
+// PostPrepaymentCollection records the applied lines of a paid prepayment.
+// The plan fee rebated on them is given up.
+func (s *LedgerService) PostPrepaymentCollection(ctx context.Context, prepayment *domains.Prepayment, lines []domains.PrepaymentLine) error {
+	paid, rebate := 0, 0
+	for _, line := range lines {
+		paid += line.Amount()
+		rebate += line.Rebate
+	}
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalCollection,
+		Reference:   fmt.Sprintf("prepayment:%d", prepayment.ID),
+		PaymentID:   prepayment.PaymentID,
+		UserID:      prepayment.UserID,
+		Currency:    prepayment.Currency,
+		Description: fmt.Sprintf("%s prepayment collected", prepayment.Type),
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountCashInTransit, Debit: paid},
+			{Account: domains.AccountFeeIncome, Debit: rebate},
+			{Account: domains.AccountCustomerReceivable, Credit: paid + rebate},
+		},
+	})
+}
+
+// PostLateFee records an accrued late fee. It is owed to charity, not
+// earned.
+func (s *LedgerService) PostLateFee(ctx context.Context, charge *domains.InstallmentCharge) error {
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalFee,
+		Reference:   fmt.Sprintf("charge:%d", charge.ID),
+		PaymentID:   charge.PaymentID,
+		UserID:      charge.UserID,
+		Currency:    charge.Currency,
+		Description: fmt.Sprintf("Late fee for %s", charge.AccrualDate),
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountCustomerReceivable, Debit: charge.Amount},
+			{Account: domains.AccountPenaltyPayable, Credit: charge.Amount},
+		},
+	})
+}
+
+// PostChargeCollection records late fees paid along with their installment
+// through a gateway order.
+func (s *LedgerService) PostChargeCollection(ctx context.Context, gatewayOrderID string, charges []*domains.InstallmentCharge) error {
+	paid := 0
+	for _, charge := range charges {
+		paid += charge.Amount
+	}
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalCollection,
+		Reference:   fmt.Sprintf("charges:%s", gatewayOrderID),
+		PaymentID:   charges[0].PaymentID,
+		UserID:      charges[0].UserID,
+		Currency:    charges[0].Currency,
+		Description: fmt.Sprintf("%d late fees collected", len(charges)),
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountCashInTransit, Debit: paid},
+			{Account: domains.AccountCustomerReceivable, Credit: paid},
+		},
+	})
+}
+
+// PostFeeWaiver reverses a waived late fee.
+func (s *LedgerService) PostFeeWaiver(ctx context.Context, charge *domains.InstallmentCharge) error {
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalFee,
+		Reference:   fmt.Sprintf("charge:%d:waiver", charge.ID),
+		PaymentID:   charge.PaymentID,
+		UserID:      charge.UserID,
+		Currency:    charge.Currency,
+		Description: fmt.Sprintf("Late fee for %s waived", charge.AccrualDate),
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountPenaltyPayable, Debit: charge.Amount},
+			{Account: domains.AccountCustomerReceivable, Credit: charge.Amount},
+		},
+	})
+}
+
+// PostRefund records a refund: the merchant is owed less, the forgiven fee
+// is given up, and the refund either lowers what the customer owes or goes
+// back to the card.
+func (s *LedgerService) PostRefund(ctx context.Context, refund *domains.Refund) error {
+	receivable, writtenOff := 0, 0
+	for _, line := range refund.Lines {
+		if line.Kind != domains.RefundLineSchedule {
+			continue
+		}
+		if line.WrittenOff {
+			writtenOff += line.Amount
+		} else {
+			receivable += line.Amount
+		}
+	}
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalRefund,
+		Reference:   fmt.Sprintf("refund:%d", refund.ID),
+		PaymentID:   refund.PaymentID,
+		UserID:      refund.UserID,
+		Currency:    refund.Currency,
+		Description: refund.Reason,
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountMerchantPayable, Debit: refund.Amount},
+			{Account: domains.AccountFeeIncome, Debit: refund.FeeReduction},
+			{Account: domains.AccountCustomerReceivable, Credit: receivable},
+			{Account: domains.AccountCreditLosses, Credit: writtenOff},
+			{Account: domains.AccountCashInTransit, Credit: refund.CardRefund},
+		},
+	})
+}
+
+// PostOverpayment records money a gateway order took that the customer did
+// not owe.
+func (s *LedgerService) PostOverpayment(ctx context.Context, payment *domains.Payment, receipt *domains.PaymentReceipt) error {
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalCollection,
+		Reference:   fmt.Sprintf("overpayment:%s", receipt.GatewayOrderID),
+		PaymentID:   payment.ID,
+		UserID:      payment.UserID,
+		Currency:    payment.Currency,
+		Description: fmt.Sprintf("Overpayment by order %s", receipt.GatewayOrderID),
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountCashInTransit, Debit: receipt.Amount},
+			{Account: domains.AccountCustomerOverpayments, Credit: receipt.Amount},
+		},
+	})
+}
+
+// PostOverpaymentRefund records an overpayment given back to the card.
+func (s *LedgerService) PostOverpaymentRefund(ctx context.Context, payment *domains.Payment, receipt *domains.PaymentReceipt, amount int) error {
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalRefund,
+		Reference:   fmt.Sprintf("overpayment:%s", receipt.GatewayOrderID),
+		PaymentID:   payment.ID,
+		UserID:      payment.UserID,
+		Currency:    payment.Currency,
+		Description: fmt.Sprintf("Overpayment by order %s refunded", receipt.GatewayOrderID),
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountCustomerOverpayments, Debit: amount},
+			{Account: domains.AccountCashInTransit, Credit: amount},
+		},
+	})
+}
+
+// PostWriteOff writes off a defaulted installment.
+func (s *LedgerService) PostWriteOff(ctx context.Context, payment *domains.Payment, installment *domains.Installment) error {
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalWriteOff,
+		Reference:   fmt.Sprintf("installment:%d", installment.ID),
+		PaymentID:   payment.ID,
+		UserID:      payment.UserID,
+		Currency:    payment.Currency,
+		Description: fmt.Sprintf("Installment %d defaulted", installment.InstallmentNumber),
+		Entries: []domains.LedgerEntry{
+			{Account: domains.AccountCreditLosses, Debit: installment.Amount},
+			{Account: domains.AccountCustomerReceivable, Credit: installment.Amount},
+		},
+	})
+}
+
+// PostSettlement records a purchase or refund settled with its merchant: the
+// merchant is paid what it is owed less the merchant discount, which is
+// earned. Refunds settle negative amounts and give the discount back.
+func (s *LedgerService) PostSettlement(ctx context.Context, batch *domains.SettlementBatch, item *domains.SettlementItem) error {
+	return s.Post(ctx, &domains.Journal{
+		Type:        domains.JournalSettlement,
+		Reference:   fmt.Sprintf("settlement-item:%d", item.ID),
+		PaymentID:   item.PaymentID,
+		UserID:      item.UserID,
+		Currency:    batch.Currency,
+		Description: fmt.Sprintf("Settlement batch %d for %s", batch.ID, batch.SettlementDate),
+		Entries: []domains.LedgerEntry{
+			signedEntry(domains.AccountMerchantPayable, item.GrossAmount),
+			signedEntry(domains.AccountFeeIncome, -item.FeeAmount),
+			signedEntry(domains.AccountCashInTransit, -item.NetAmount),
+		},
+	})
+}
+
+// signedEntry debits account with amount, or credits it when amount is
+// negative.
+func signedEntry(account domains.LedgerAccount, amount int) domains.LedgerEntry {
+	if amount < 0 {
+		return domains.LedgerEntry{Account: account, Credit: -amount}
+	}
+	return domains.LedgerEntry{Account: account, Debit: amount}
+}
+
+func (s *LedgerService) ListJournals(ctx context.Context, paymentID uint, offset, limit int) ([]*domains.Journal, int, error) {
+	journals, total, err := s.ledgerRepo.ListJournals(ctx, paymentID, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list journals", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list journals: %w", err)
+	}
+	return journals, total, nil
+}
+
+// Balances returns the balance of every account with entries up to and
+// including asOf, for one customer or payment when given.
+func (s *LedgerService) Balances(ctx context.Context, asOf time.Time, userID string, paymentID uint) ([]domains.AccountBalance, error) {
+	balances, err := s.ledgerRepo.Balances(ctx, asOf, userID, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to get ledger balances", zap.Time("asOf", asOf), zap.Error(err))
+		return nil, fmt.Errorf("failed to get ledger balances: %w", err)
+	}
+	return balances, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"go.uber.org/zap"
+)
+
+func TestLedgerPost(t *testing.T) {
+	tests := []struct {
+		name        string
+		entries     []domains.LedgerEntry
+		wantErr     error
+		wantEntries int
+	}{
+		{
+			name: "balanced purchase",
+			entries: []domains.LedgerEntry{
+				{Account: domains.AccountCustomerReceivable, Debit: 3300},
+				{Account: domains.AccountMerchantPayable, Credit: 3000},
+				{Account: domains.AccountFeeIncome, Credit: 300},
+			},
+			wantEntries: 3,
+		},
+		{
+			name: "zero entries are dropped",
+			entries: []domains.LedgerEntry{
+				{Account: domains.AccountCustomerReceivable, Debit: 3000},
+				{Account: domains.AccountMerchantPayable, Credit: 3000},
+				{Account: domains.AccountFeeIncome},
+			},
+			wantEntries: 2,
+		},
+		{
+			name:    "nothing to post",
+			entries: []domains.LedgerEntry{{Account: domains.AccountFeeIncome}},
+		},
+		{
+			name: "debits exceed credits",
+			entries: []domains.LedgerEntry{
+				{Account: domains.AccountCustomerReceivable, Debit: 3300},
+				{Account: domains.AccountMerchantPayable, Credit: 3000},
+			},
+			wantErr: ErrUnbalancedJournal,
+		},
+		{
+			name:    "single entry",
+			entries: []domains.LedgerEntry{{Account: domains.AccountCashInTransit, Debit: 1000}},
+			wantErr: ErrUnbalancedJournal,
+		},
+		{
+			name: "unknown account",
+			entries: []domains.LedgerEntry{
+				{Account: "SUSPENSE", Debit: 1000},
+				{Account: domains.AccountCashInTransit, Credit: 1000},
+			},
+			wantErr: ErrUnbalancedJournal,
+		},
+		{
+			name: "negative amount",
+			entries: []domains.LedgerEntry{
+				{Account: domains.AccountCustomerReceivable, Debit: -1000},
+				{Account: domains.AccountCashInTransit, Credit: -1000},
+			},
+			wantErr: ErrUnbalancedJournal,
+		},
+		{
+			name: "entry both debited and credited",
+			entries: []domains.LedgerEntry{
+				{Account: domains.AccountCustomerReceivable, Debit: 1000, Credit: 1000},
+				{Account: domains.AccountCashInTransit, Debit: 500},
+				{Account: domains.AccountFeeIncome, Credit: 500},
+			},
+			wantErr: ErrUnbalancedJournal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeLedgerRepo{}
+			ledger := NewLedgerService(repo, zap.NewNop())
+			journal := &domains.Journal{
+				Type:      domains.JournalPurchase,
+				Reference: "payment:1",
+				PaymentID: 1,
+				UserID:    testUserID,
+				Currency:  "DZD",
+				Entries:   tt.entries,
+			}
+
+			err := ledger.Post(context.Background(), journal)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Post: got %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil || tt.wantEntries == 0 {
+				if len(repo.journals) != 0 {
+					t.Fatalf("%d journals stored, want none", len(repo.journals))
+				}
+				return
+			}
+
+			if len(repo.journals) != 1 || len(repo.journals[0].Entries) != tt.wantEntries {
+				t.Fatalf("stored %d journals, want 1 with %d entries", len(repo.journals), tt.wantEntries)
+			}
+			for _, entry := range repo.journals[0].Entries {
+				if entry.PaymentID != 1 || entry.UserID != testUserID || !entry.PostedAt.Equal(journal.PostedAt) {
+					t.Fatalf("%s entry not stamped with the journal's payment, user and date", entry.Account)
+				}
+			}
+		})
+	}
+}
+
+func TestLedgerPostIgnoresRepostedEvent(t *testing.T) {
+	repo := &fakeLedgerRepo{}
+	ledger := NewLedgerService(repo, zap.NewNop())
+	payment := &domains.Payment{UserID: testUserID, Amount: 3000, FeeAmount: 300, Currency: "DZD"}
+	payment.ID = 1
+
+	for i := 0; i < 2; i++ {
+		if err := ledger.PostPurchase(context.Background(), payment); err != nil {
+			t.Fatalf("PostPurchase %d: %v", i+1, err)
+		}
+	}
+	if len(repo.journals) != 1 {
+		t.Fatalf("%d journals stored, want the purchase once", len(repo.journals))
+	}
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+type MerchantEventServiceInterface interface {
+	ListEvents(ctx context.Context, merchantID uint, offset, limit int) ([]*domains.MerchantEvent, int, error)
+}
+
+// PaymentEventPayload is the body of payment events. CheckoutSessionID is
+// set for payments made through a hosted checkout.
+type PaymentEventPayload struct {
+	PaymentID         uint   `json:"payment_id"`
+	OrderID           string `json:"order_id"`
+	CheckoutSessionID string `json:"checkout_session_id,omitempty"`
+	StoreID           *uint  `json:"store_id,omitempty"`
+	Amount            int    `json:"amount"`
+	Currency          string `json:"currency"`
+	Status            string `json:"status"`
+}
+
+// RefundEventPayload is the body of refund events. RefundedAmount is what
+// all refunds of the payment add up to.
+type RefundEventPayload struct {
+	PaymentID      uint   `json:"payment_id"`
+	OrderID        string `json:"order_id"`
+	RefundID       uint   `json:"refund_id"`
+	Amount         int    `json:"amount"`
+	RefundedAmount int    `json:"refunded_amount"`
+	Currency       string `json:"currency"`
+	Reason         string `json:"reason"`
+}
+
+// InstallmentEventPayload is the body of installment events. Amount is what
+// the customer paid towards the installment with the payment settling it.
+type InstallmentEventPayload struct {
+	PaymentID         uint   `json:"payment_id"`
+	OrderID           string `json:"order_id"`
+	InstallmentID     uint   `json:"installment_id"`
+	InstallmentNumber int    `json:"installment_number"`
+	DueDate           string `json:"due_date"`
+	Amount            int    `json:"amount"`
+	Currency          string `json:"currency"`
+}
+
+// CheckoutSessionEventPayload is the body of checkout session events.
+type CheckoutSessionEventPayload struct {
+	CheckoutSessionID string `json:"checkout_session_id"`
+	OrderReference    string `json:"order_reference"`
+	Amount            int    `json:"amount"`
+	Currency          string `json:"currency"`
+	Status            string `json:"status"`
+}
+
+// MerchantEventService records the events merchants are told about, queues
+// them for the merchant's webhook endpoints, and keeps checkout sessions in
+// step with the outcome of their payments. Events are recorded in the
+// caller's transaction so they are only kept, and delivered, if the change
+// they report is.
+type MerchantEventService struct {
+	checkoutRepo repository.CheckoutSessionRepository
+	eventRepo    repository.MerchantEventRepository
+	webhooks     *MerchantWebhookService
+	logger       *zap.Logger
+}
+
+func NewMerchantEventService(
+	checkoutRepo repository.CheckoutSessionRepository,
+	eventRepo repository.MerchantEventRepository,
+	webhooks *MerchantWebhookService,
+	logger *zap.Logger,
+) *MerchantEventService {
+	return &MerchantEventService{
+		checkoutRepo: checkoutRepo,
+		eventRepo:    eventRepo,
+		webhooks:     webhooks,
+		logger:       logger,
+	}
+}
+
+// ListEvents returns the events recorded for a merchant, newest first.
+func (s *MerchantEventService) ListEvents(ctx context.Context, merchantID uint, offset, limit int) ([]*domains.MerchantEvent, int, error) {
+	events, total, err := s.eventRepo.ListByMerchantID(ctx, merchantID, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list merchant events", zap.Uint("merchantID", merchantID), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list merchant events: %w", err)
+	}
+	return events, total, nil
+}
+
+// PaymentSettled reports the gateway outcome of a payment to its merchant. A
+// checkout session paid by a successful payment is completed; one whose
+// payment failed is opened again for the customer to retry.
+func (s *MerchantEventService) PaymentSettled(ctx context.Context, payment *domains.Payment, status string) error {
+	if payment.MerchantID == 0 {
+		return nil
+	}
+
+	payload := PaymentEventPayload{
+		PaymentID: payment.ID,
+		OrderID:   payment.OrderID,
+		StoreID:   payment.StoreID,
+		Amount:    payment.Amount,
+		Currency:  payment.Currency,
+		Status:    status,
+	}
+
+	session, err := s.checkoutRepo.GetByPaymentID(ctx, payment.ID)
+	var notFoundErr *utils.ErrNotFound
+	switch {
+	case errors.As(err, &notFoundErr):
+	case err != nil:
+		s.logger.Error("Failed to get checkout session", zap.Uint("paymentID", payment.ID), zap.Error(err))
+		return fmt.Errorf("failed to get checkout session: %w", err)
+	default:
+		to := domains.CheckoutCompleted
+		if status == "FAILED" {
+			to = domains.CheckoutOpen
+		}
+		// A session that is no longer pending must not undo the payment's
+		// outcome, which the caller would take for a concurrent settlement
+		err := s.checkoutRepo.UpdateStatus(ctx, session.ID, to, domains.CheckoutPending)
+		var transitionErr *utils.ErrInvalidTransition
+		if errors.As(err, &transitionErr) {
+			s.logger.Warn("Checkout session is not awaiting its payment", zap.String("sessionID", session.ID),
+				zap.String("status", string(session.Status)), zap.Uint("paymentID", payment.ID))
+		} else if err != nil {
+			s.logger.Error("Failed to update checkout session", zap.String("sessionID", session.ID), zap.Error(err))
+			return fmt.Errorf("failed to update checkout session: %w", err)
+		}
+		payload.CheckoutSessionID = session.ID
+	}
+
+	eventType := domains.EventPaymentSucceeded
+	if status == "FAILED" {
+		eventType = domains.EventPaymentFailed
+	}
+	return s.Record(ctx, payment.MerchantID, eventType, payload)
+}
+
+// PaymentRefunded reports a refund of a merchant's payment. payment carries
+// the refunded amount including refund.
+func (s *MerchantEventService) PaymentRefunded(ctx context.Context, payment *domains.Payment, refund *domains.Refund) error {
+	if payment.MerchantID == 0 {
+		return nil
+	}
+	return s.Record(ctx, payment.MerchantID, domains.EventPaymentRefunded, RefundEventPayload{
+		PaymentID:      payment.ID,
+		OrderID:        payment.OrderID,
+		RefundID:       refund.ID,
+		Amount:         refund.Amount,
+		RefundedAmount: payment.RefundedAmount,
+		Currency:       refund.Currency,
+		Reason:         refund.Reason,
+	})
+}
+
+// InstallmentPaid reports that an installment of a merchant's payment was
+// paid off, amount being what settled it.
+func (s *MerchantEventService) InstallmentPaid(ctx context.Context, payment *domains.Payment, installment *domains.Installment, amount int) error {
+	if payment.MerchantID == 0 {
+		return nil
+	}
+	return s.Record(ctx, payment.MerchantID, domains.EventInstallmentPaid, InstallmentEventPayload{
+		PaymentID:         payment.ID,
+		OrderID:           payment.OrderID,
+		InstallmentID:     installment.ID,
+		InstallmentNumber: installment.InstallmentNumber,
+		DueDate:           installment.DueDate,
+		Amount:            amount,
+		Currency:          payment.Currency,
+	})
+}
+
+// Record stores an event for a merchant with payload as its JSON body and
+// queues it for the merchant's webhook endpoints.
+func (s *MerchantEventService) Record(ctx context.Context, merchantID uint, eventType domains.MerchantEventType, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode merchant event: %w", err)
+	}
+
+	event := &domains.MerchantEvent{
+		MerchantID: merchantID,
+		Type:       eventType,
+		Payload:    string(body),
+	}
+	if err := s.eventRepo.Create(ctx, event); err != nil {
+		s.logger.Error("Failed to record merchant event", zap.Uint("merchantID", merchantID), zap.String("type", string(eventType)), zap.Error(err))
+		return fmt.Errorf("failed to record merchant event: %w", err)
+	}
+	if err := s.webhooks.Enqueue(ctx, event); err != nil {
+		return err
+	}
+
+	s.logger.Info("Merchant event recorded", zap.Uint("merchantID", merchantID), zap.String("type", string(eventType)), zap.Uint("eventID", event.ID))
+	return nil
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidMerchant   = errors.New("invalid merchant")
+	ErrMerchantNotActive = errors.New("merchant is not active")
+	ErrInvalidAPIKey     = errors.New("invalid API key")
+)
+
+// Merchant API keys read "mk_" and 12 hex characters, the prefix they are
+// looked up by, then "_" and the 64 hex character secret.
+const (
+	apiKeyScheme       = "mk_"
+	apiKeyPrefixLength = len(apiKeyScheme) + 12
+	apiKeySecretBytes  = 32
+)
+
+// merchantTransitions lists, for each status a merchant may be moved to, the
+// statuses it may be moved from.
+var merchantTransitions = map[domains.MerchantStatus][]domains.MerchantStatus{
+	domains.MerchantActive:    {domains.MerchantPending, domains.MerchantSuspended},
+	domains.MerchantSuspended: {domains.MerchantActive},
+	domains.MerchantClosed:    {domains.MerchantPending, domains.MerchantActive, domains.MerchantSuspended},
+}
+
+type MerchantServiceInterface interface {
+	CreateMerchant(ctx context.Context, merchant *domains.Merchant) error
+	GetMerchant(ctx context.Context, id uint) (*domains.Merchant, error)
+	ListMerchants(ctx context.Context, status domains.MerchantStatus, offset, limit int) ([]*domains.Merchant, int, error)
+	UpdateMerchant(ctx context.Context, merchant *domains.Merchant) (*domains.Merchant, error)
+	SetMerchantStatus(ctx context.Context, id uint, status domains.MerchantStatus) (*domains.Merchant, error)
+	AddStore(ctx context.Context, store *domains.Store) error
+	UpdateStore(ctx context.Context, store *domains.Store) (*domains.Store, error)
+	CreateAPIKey(ctx context.Context, merchantID uint, name string) (*domains.MerchantAPIKey, string, error)
+	ListAPIKeys(ctx context.Context, merchantID uint) ([]*domains.MerchantAPIKey, error)
+	RevokeAPIKey(ctx context.Context, merchantID, keyID uint) error
+	AuthenticateAPIKey(ctx context.Context, key string) (*domains.Merchant, error)
+	ListPayments(ctx context.Context, merchantID uint, offset, limit int) ([]*domains.Payment, int, error)
+}
+
+// MerchantService onboards the merchants whose goods customers finance,
+// manages their stores and the API keys their systems call us with, and
+// checks that payments are made at active merchants.
+type MerchantService struct {
+	merchantRepo repository.MerchantRepository
+	apiKeyRepo   repository.MerchantAPIKeyRepository
+	paymentRepo  repository.PaymentRepository
+	logger       *zap.Logger
+}
+
+func NewMerchantService(
+	merchantRepo repository.MerchantRepository,
+	apiKeyRepo repository.MerchantAPIKeyRepository,
+	paymentRepo repository.PaymentRepository,
+	logger *zap.Logger,
+) *MerchantService {
+	return &MerchantService{
+		merchantRepo: merchantRepo,
+		apiKeyRepo:   apiKeyRepo,
+		paymentRepo:  paymentRepo,
+		logger:       logger,
+	}
+}
+
+// CreateMerchant starts onboarding a merchant. Merchants start PENDING and
+// take payments once activated.
+func (s *MerchantService) CreateMerchant(ctx context.Context, merchant *domains.Merchant) error {
+	if err := s.validate(merchant); err != nil {
+		return err
+	}
+	merchant.Status = domains.MerchantPending
+	merchant.ActivatedAt = nil
+
+	if err := s.merchantRepo.Create(ctx, merchant); err != nil {
+		s.logger.Error("Failed to create merchant", zap.String("taxID", merchant.TaxID), zap.Error(err))
+		return fmt.Errorf("failed to create merchant: %w", err)
+	}
+
+	s.logger.Info("Merchant created", zap.Uint("merchantID", merchant.ID), zap.String("name", merchant.Name))
+	return nil
+}
+
+func (s *MerchantService) GetMerchant(ctx context.Context, id uint) (*domains.Merchant, error) {
+	merchant, err := s.merchantRepo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get merchant", zap.Uint("merchantID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get merchant: %w", err)
+	}
+	return merchant, nil
+}
+
+func (s *MerchantService) ListMerchants(ctx context.Context, status domains.MerchantStatus, offset, limit int) ([]*domains.Merchant, int, error) {
+	merchants, total, err := s.merchantRepo.List(ctx, status, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list merchants", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list merchants: %w", err)
+	}
+	return merchants, total, nil
+}
+
+// UpdateMerchant changes a merchant's details and returns it as saved.
+func (s *MerchantService) UpdateMerchant(ctx context.Context, merchant *domains.Merchant) (*domains.Merchant, error) {
+	if err := s.validate(merchant); err != nil {
+		return nil, err
+	}
+	if _, err := s.GetMerchant(ctx, merchant.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.merchantRepo.Update(ctx, merchant); err != nil {
+		s.logger.Error("Failed to update merchant", zap.Uint("merchantID", merchant.ID), zap.Error(err))
+		return nil, fmt.Errorf("failed to update merchant: %w", err)
+	}
+
+	s.logger.Info("Merchant updated", zap.Uint("merchantID", merchant.ID))
+	return s.GetMerchant(ctx, merchant.ID)
+}
+
+// SetMerchantStatus activates, suspends or closes a merchant. Activation
+// needs at least one active store. Closing is final.
+func (s *MerchantService) SetMerchantStatus(ctx context.Context, id uint, status domains.MerchantStatus) (*domains.Merchant, error) {
+	from, ok := merchantTransitions[status]
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot move a merchant to %s", ErrInvalidMerchant, status)
+	}
+
+	merchant, err := s.GetMerchant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status == domains.MerchantActive && !hasActiveStore(merchant) {
+		return nil, fmt.Errorf("%w: a merchant needs an active store to be activated", ErrInvalidMerchant)
+	}
+
+	if err := s.merchantRepo.UpdateStatus(ctx, id, status, from...); err != nil {
+		s.logger.Error("Failed to update merchant status", zap.Uint("merchantID", id), zap.String("status", string(status)), zap.Error(err))
+		return nil, fmt.Errorf("failed to update merchant status: %w", err)
+	}
+
+	s.logger.Info("Merchant status changed", zap.Uint("merchantID", id),
+		zap.String("from", string(merchant.Status)), zap.String("to", string(status)))
+	return s.GetMerchant(ctx, id)
+}
+
+// AddStore adds a store to a merchant that is not closed.
+func (s *MerchantService) AddStore(ctx context.Context, store *domains.Store) error {
+	merchant, err := s.GetMerchant(ctx, store.MerchantID)
+	if err != nil {
+		return err
+	}
+	if merchant.Status == domains.MerchantClosed {
+		return fmt.Errorf("%w: merchant is closed", ErrInvalidMerchant)
+	}
+
+	store.Active = true
+	if err := s.merchantRepo.CreateStore(ctx, store); err != nil {
+		s.logger.Error("Failed to create store", zap.Uint("merchantID", store.MerchantID), zap.Error(err))
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+
+	s.logger.Info("Store added", zap.Uint("merchantID", store.MerchantID), zap.Uint("storeID", store.ID))
+	return nil
+}
+
+// UpdateStore changes a store's details or whether it is active.
+func (s *MerchantService) UpdateStore(ctx context.Context, store *domains.Store) (*domains.Store, error) {
+	existing, err := s.merchantRepo.GetStore(ctx, store.MerchantID, store.ID)
+	if err != nil {
+		s.logger.Error("Failed to get store", zap.Uint("merchantID", store.MerchantID), zap.Uint("storeID", store.ID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+
+	existing.Name = store.Name
+	existing.Address = store.Address
+	existing.City = store.City
+	existing.Active = store.Active
+	if err := s.merchantRepo.UpdateStore(ctx, existing); err != nil {
+		s.logger.Error("Failed to update store", zap.Uint("storeID", store.ID), zap.Error(err))
+		return nil, fmt.Errorf("failed to update store: %w", err)
+	}
+
+	s.logger.Info("Store updated", zap.Uint("merchantID", store.MerchantID), zap.Uint("storeID", store.ID), zap.Bool("active", existing.Active))
+	return existing, nil
+}
+
+// CreateAPIKey issues a new API key to a merchant. The key is returned once
+// and only its hash is kept.
+func (s *MerchantService) CreateAPIKey(ctx context.Context, merchantID uint, name string) (*domains.MerchantAPIKey, string, error) {
+	merchant, err := s.GetMerchant(ctx, merchantID)
+	if err != nil {
+		return nil, "", err
+	}
+	if merchant.Status == domains.MerchantClosed {
+		return nil, "", fmt.Errorf("%w: merchant is closed", ErrInvalidMerchant)
+	}
+
+	prefix, err := randomHex((apiKeyPrefixLength - len(apiKeyScheme)) / 2)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	prefix = apiKeyScheme + prefix
+	key := prefix + "_" + secret
+
+	apiKey := &domains.MerchantAPIKey{
+		MerchantID: merchantID,
+		Name:       strings.TrimSpace(name),
+		Prefix:     prefix,
+		KeyHash:    hashAPIKey(key),
+	}
+	if err := s.apiKeyRepo.Create(ctx, apiKey); err != nil {
+		s.logger.Error("Failed to create API key", zap.Uint("merchantID", merchantID), zap.Error(err))
+		return nil, "", fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	s.logger.Info("API key created", zap.Uint("merchantID", merchantID), zap.String("prefix", prefix))
+	return apiKey, key, nil
+}
+
+func (s *MerchantService) ListAPIKeys(ctx context.Context, merchantID uint) ([]*domains.MerchantAPIKey, error) {
+	keys, err := s.apiKeyRepo.ListByMerchantID(ctx, merchantID)
+	if err != nil {
+		s.logger.Error("Failed to list API keys", zap.Uint("merchantID", merchantID), zap.Error(err))
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *MerchantService) RevokeAPIKey(ctx context.Context, merchantID, keyID uint) error {
+	if err := s.apiKeyRepo.Revoke(ctx, merchantID, keyID, time.Now()); err != nil {
+		s.logger.Error("Failed to revoke API key", zap.Uint("merchantID", merchantID), zap.Uint("keyID", keyID), zap.Error(err))
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	s.logger.Info("API key revoked", zap.Uint("merchantID", merchantID), zap.Uint("keyID", keyID))
+	return nil
+}
+
+// AuthenticateAPIKey returns the merchant an API key belongs to. Unknown and
+// revoked keys, and keys of closed merchants, fail with ErrInvalidAPIKey.
+func (s *MerchantService) AuthenticateAPIKey(ctx context.Context, key string) (*domains.Merchant, error) {
+	if len(key) <= apiKeyPrefixLength || !strings.HasPrefix(key, apiKeyScheme) || key[apiKeyPrefixLength] != '_' {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.apiKeyRepo.GetByPrefix(ctx, key[:apiKeyPrefixLength])
+	var notFoundErr *utils.ErrNotFound
+	if errors.As(err, &notFoundErr) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		s.logger.Error("Failed to look up API key", zap.Error(err))
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if apiKey.RevokedAt != nil || subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hashAPIKey(key))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	merchant, err := s.GetMerchant(ctx, apiKey.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.Status == domains.MerchantClosed {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err := s.apiKeyRepo.TouchLastUsed(ctx, apiKey.ID, time.Now()); err != nil {
+		s.logger.Warn("Failed to record API key use", zap.Uint("keyID", apiKey.ID), zap.Error(err))
+	}
+	return merchant, nil
+}
+
+// ListPayments returns the payments made at a merchant, newest first.
+func (s *MerchantService) ListPayments(ctx context.Context, merchantID uint, offset, limit int) ([]*domains.Payment, int, error) {
+	payments, total, err := s.paymentRepo.ListByMerchantID(ctx, merchantID, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list merchant payments", zap.Uint("merchantID", merchantID), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list merchant payments: %w", err)
+	}
+	return payments, total, nil
+}
+
+// ResolvePaymentMerchant returns the merchant a new payment is made at. Without
+// merchantID, as when a customer pays in store, it is the merchant of the
+// store. The merchant must be active and the store, if given, one of its
+// active stores.
+func (s *MerchantService) ResolvePaymentMerchant(ctx context.Context, merchantID uint, storeID *uint) (*domains.Merchant, error) {
+	if merchantID == 0 && storeID != nil {
+		store, err := s.merchantRepo.GetStoreByID(ctx, *storeID)
+		var notFoundErr *utils.ErrNotFound
+		if errors.As(err, &notFoundErr) {
+			return nil, fmt.Errorf("%w: store %d does not exist", ErrInvalidMerchant, *storeID)
+		}
+		if err != nil {
+			s.logger.Error("Failed to get store", zap.Uint("storeID", *storeID), zap.Error(err))
+			return nil, fmt.Errorf("failed to get store: %w", err)
+		}
+		merchantID = store.MerchantID
+	}
+	if merchantID == 0 {
+		return nil, fmt.Errorf("%w: a merchant is required", ErrInvalidMerchant)
+	}
+	merchant, err := s.GetMerchant(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.Status != domains.MerchantActive {
+		return nil, ErrMerchantNotActive
+	}
+
+	if storeID != nil {
+		for _, store := range merchant.Stores {
+			if store.ID == *storeID && store.Active {
+				return merchant, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: store %d is not an active store of the merchant", ErrInvalidMerchant, *storeID)
+	}
+	return merchant, nil
+}
+
+// validate checks and normalizes a merchant's details.
+func (s *MerchantService) validate(merchant *domains.Merchant) error {
+	merchant.RIB = utils.NormalizeRIB(merchant.RIB)
+	if !utils.RIBValid(merchant.RIB) {
+		return fmt.Errorf("%w: RIB must be 20 digits with a valid key", ErrInvalidMerchant)
+	}
+	merchant.Category = strings.TrimSpace(merchant.Category)
+	if merchant.Category == "" {
+		return fmt.Errorf("%w: a category is required", ErrInvalidMerchant)
+	}
+	if merchant.DiscountRateBps < 0 || merchant.DiscountRateBps > 10000 {
+		return fmt.Errorf("%w: discount rate must be between 0 and 10000 basis points", ErrInvalidMerchant)
+	}
+	return nil
+}
+
+func hasActiveStore(merchant *domains.Merchant) bool {
+	for _, store := range merchant.Stores {
+		if store.Active {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidWebhookEndpoint = errors.New("invalid webhook endpoint")
+
+const (
+	// MerchantWebhookJobName is the JobRunner name of the webhook delivery
+	// sweep.
+	MerchantWebhookJobName = "merchant-webhooks"
+	// merchantWebhookBatchSize caps how many deliveries one sweep sends.
+	merchantWebhookBatchSize = 100
+	// maxWebhookResponseBody is how much of an endpoint's answer is read
+	// before the connection is reused. The answer itself is not kept.
+	maxWebhookResponseBody = 1024
+	webhookSecretPrefix    = "whsec_"
+)
+
+// Headers of the webhooks posted to merchants. The signature is
+// "sha256=" followed by utils.SignWebhook of the timestamp and body with the
+// endpoint's secret.
+const (
+	MerchantWebhookSignatureHeader = "X-Sahla-Signature"
+	MerchantWebhookTimestampHeader = "X-Sahla-Timestamp"
+	MerchantWebhookEventHeader     = "X-Sahla-Event"
+	MerchantWebhookDeliveryHeader  = "X-Sahla-Delivery"
+)
+
+// merchantEventTypes are the events endpoints can subscribe to.
+var merchantEventTypes = []domains.MerchantEventType{
+	domains.EventPaymentSucceeded,
+	domains.EventPaymentFailed,
+	domains.EventPaymentRefunded,
+	domains.EventInstallmentPaid,
+	domains.EventCheckoutSessionExpired,
+}
+
+// WebhookRetryPolicy is how failed webhook deliveries are retried: after
+// the n-th failed attempt the next one waits BaseDelay doubled n-1 times, up
+// to MaxDelay. A delivery is dead once MaxAttempts have failed.
+type WebhookRetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultWebhookRetryPolicy retries for about eight and a half hours.
+func DefaultWebhookRetryPolicy() WebhookRetryPolicy {
+	return WebhookRetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   time.Minute,
+		MaxDelay:    6 * time.Hour,
+	}
+}
+
+// Validate checks that the policy makes at least one attempt and that its
+// delays grow.
+func (p WebhookRetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("webhook deliveries need at least one attempt")
+	}
+	if p.BaseDelay <= 0 || p.MaxDelay < p.BaseDelay {
+		return fmt.Errorf("webhook retry delays must be positive, the longest no shorter than the first")
+	}
+	return nil
+}
+
+// NextAttempt returns when to retry after the attempts-th failed attempt,
+// or nil when no attempt is left.
+func (p WebhookRetryPolicy) NextAttempt(attempts int, failedAt time.Time) *time.Time {
+	if attempts < 1 || attempts >= p.MaxAttempts {
+		return nil
+	}
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	next := failedAt.Add(delay)
+	return &next
+}
+
+// MerchantWebhookEnvelope is the body posted for an event. ID is the event's
+// and stays the same across attempts and replays, so receivers can drop
+// events they already handled.
+type MerchantWebhookEnvelope struct {
+	ID        uint                      `json:"id"`
+	Type      domains.MerchantEventType `json:"type"`
+	CreatedAt time.Time                 `json:"created_at"`
+	Data      json.RawMessage           `json:"data"`
+}
+
+type MerchantWebhookServiceInterface interface {
+	CreateEndpoint(ctx context.Context, endpoint *domains.WebhookEndpoint) error
+	ListEndpoints(ctx context.Context, merchantID uint) ([]*domains.WebhookEndpoint, error)
+	DisableEndpoint(ctx context.Context, merchantID, id uint) error
+	ListDeliveries(ctx context.Context, merchantID, endpointID uint, status domains.WebhookDeliveryStatus, offset, limit int) ([]*domains.WebhookDelivery, int, error)
+	GetDelivery(ctx context.Context, merchantID, id uint) (*domains.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, merchantID, id uint) (*domains.WebhookDelivery, error)
+}
+
+// MerchantWebhookService posts merchant events to the endpoints merchants
+// register. A delivery is queued for each endpoint in the transaction that
+// records the event, then sent by a background sweep and retried with
+// exponential backoff until it succeeds or runs out of attempts. Every
+// attempt is logged, and merchants can replay any delivery. Deliveries are
+// at least once: a replay or a lost answer sends an event again.
+type MerchantWebhookService struct {
+	endpointRepo repository.WebhookEndpointRepository
+	deliveryRepo repository.WebhookDeliveryRepository
+	eventRepo    repository.MerchantEventRepository
+	client       *http.Client
+	policy       WebhookRetryPolicy
+	txManager    *utils.TransactionManager
+	logger       *zap.Logger
+}
+
+// NewMerchantWebhookService creates a MerchantWebhookService posting with
+// client, whose timeout bounds each attempt. Redirects are taken for
+// failures, so client should not follow them, and client should refuse to
+// dial addresses of this network, which endpoint URLs may resolve to.
+func NewMerchantWebhookService(
+	endpointRepo repository.WebhookEndpointRepository,
+	deliveryRepo repository.WebhookDeliveryRepository,
+	eventRepo repository.MerchantEventRepository,
+	client *http.Client,
+	policy WebhookRetryPolicy,
+	txManager *utils.TransactionManager,
+	logger *zap.Logger,
+) *MerchantWebhookService {
+	return &MerchantWebhookService{
+		endpointRepo: endpointRepo,
+		deliveryRepo: deliveryRepo,
+		eventRepo:    eventRepo,
+		client:       client,
+		policy:       policy,
+		txManager:    txManager,
+		logger:       logger,
+	}
+}
+
+// CreateEndpoint registers an endpoint for a merchant's events with a new
+// signing secret. Events are only posted over https.
+func (s *MerchantWebhookService) CreateEndpoint(ctx context.Context, endpoint *domains.WebhookEndpoint) error {
+	endpoint.URL = strings.TrimSpace(endpoint.URL)
+	endpoint.Description = strings.TrimSpace(endpoint.Description)
+	target, err := url.ParseRequestURI(endpoint.URL)
+	if err != nil || target.Scheme != "https" || target.Host == "" {
+		return fmt.Errorf("%w: the URL must be an absolute https URL", ErrInvalidWebhookEndpoint)
+	}
+	for _, eventType := range endpoint.EventTypes {
+		if !validMerchantEventType(eventType) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhookEndpoint, eventType)
+		}
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	endpoint.Secret = webhookSecretPrefix + secret
+	endpoint.DisabledAt = nil
+
+	if err := s.endpointRepo.Create(ctx, endpoint); err != nil {
+		s.logger.Error("Failed to create webhook endpoint", zap.Uint("merchantID", endpoint.MerchantID), zap.Error(err))
+		return fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+
+	s.logger.Info("Webhook endpoint created", zap.Uint("merchantID", endpoint.MerchantID), zap.Uint("endpointID", endpoint.ID))
+	return nil
+}
+
+func (s *MerchantWebhookService) ListEndpoints(ctx context.Context, merchantID uint) ([]*domains.WebhookEndpoint, error) {
+	endpoints, err := s.endpointRepo.ListByMerchantID(ctx, merchantID)
+	if err != nil {
+		s.logger.Error("Failed to list webhook endpoints", zap.Uint("merchantID", merchantID), zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+// DisableEndpoint stops posting events to a merchant's endpoint. Its
+// pending deliveries go dead when they come up.
+func (s *MerchantWebhookService) DisableEndpoint(ctx context.Context, merchantID, id uint) error {
+	if err := s.endpointRepo.Disable(ctx, merchantID, id, time.Now()); err != nil {
+		s.logger.Error("Failed to disable webhook endpoint", zap.Uint("merchantID", merchantID), zap.Uint("endpointID", id), zap.Error(err))
+		return fmt.Errorf("failed to disable webhook endpoint: %w", err)
+	}
+
+	s.logger.Info("Webhook endpoint disabled", zap.Uint("merchantID", merchantID), zap.Uint("endpointID", id))
+	return nil
+}
+
+// Enqueue queues event for each enabled endpoint of its merchant that
+// subscribes to it. It is called in the transaction recording the event.
+func (s *MerchantWebhookService) Enqueue(ctx context.Context, event *domains.MerchantEvent) error {
+	endpoints, err := s.endpointRepo.ListByMerchantID(ctx, event.MerchantID)
+	if err != nil {
+		s.logger.Error("Failed to list webhook endpoints", zap.Uint("merchantID", event.MerchantID), zap.Error(err))
+		return fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+
+	now := time.Now()
+	for _, endpoint := range endpoints {
+		if endpoint.DisabledAt != nil || !endpoint.Subscribes(event.Type) {
+			continue
+		}
+		delivery := &domains.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       event.ID,
+			MerchantID:    event.MerchantID,
+			EventType:     event.Type,
+			Status:        domains.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
+			s.logger.Error("Failed to queue webhook delivery", zap.Uint("endpointID", endpoint.ID), zap.Uint("eventID", event.ID), zap.Error(err))
+			return fmt.Errorf("failed to queue webhook delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeliverDue sends the deliveries that are due. A failed delivery is
+// rescheduled or dead-lettered and does not stop the others.
+func (s *MerchantWebhookService) DeliverDue(ctx context.Context) error {
+	deliveries, err := s.deliveryRepo.ListDue(ctx, time.Now(), merchantWebhookBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list due webhook deliveries", zap.Error(err))
+		return fmt.Errorf("failed to list due webhook deliveries: %w", err)
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		_ = s.deliver(ctx, delivery)
+	}
+	return nil
+}
+
+func (s *MerchantWebhookService) ListDeliveries(ctx context.Context, merchantID, endpointID uint, status domains.WebhookDeliveryStatus, offset, limit int) ([]*domains.WebhookDelivery, int, error) {
+	deliveries, total, err := s.deliveryRepo.ListByMerchantID(ctx, merchantID, endpointID, status, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list webhook deliveries", zap.Uint("merchantID", merchantID), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+// GetDelivery returns a delivery of a merchant with its attempt log.
+func (s *MerchantWebhookService) GetDelivery(ctx context.Context, merchantID, id uint) (*domains.WebhookDelivery, error) {
+	delivery, err := s.deliveryRepo.GetByID(ctx, id)
+	if err == nil && delivery.MerchantID != merchantID {
+		err = &utils.ErrNotFound{Entity: "WebhookDelivery", ID: id}
+	}
+	if err != nil {
+		s.logger.Error("Failed to get webhook delivery", zap.Uint("merchantID", merchantID), zap.Uint("deliveryID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// ReplayDelivery sends a delivery of a merchant again right away, whatever
+// its state, with a fresh allowance of attempts should it fail.
+func (s *MerchantWebhookService) ReplayDelivery(ctx context.Context, merchantID, id uint) (*domains.WebhookDelivery, error) {
+	delivery, err := s.GetDelivery(ctx, merchantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.deliveryRepo.Reschedule(ctx, id, now); err != nil {
+		s.logger.Error("Failed to reschedule webhook delivery", zap.Uint("deliveryID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to reschedule webhook delivery: %w", err)
+	}
+	delivery.Status = domains.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+
+	s.logger.Info("Replaying webhook delivery", zap.Uint("merchantID", merchantID), zap.Uint("deliveryID", id))
+	if err := s.deliver(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return s.GetDelivery(ctx, merchantID, id)
+}
+
+// deliver makes one attempt at a delivery and records its outcome.
+func (s *MerchantWebhookService) deliver(ctx context.Context, delivery *domains.WebhookDelivery) error {
+	endpoint, err := s.endpointRepo.GetByID(ctx, delivery.EndpointID)
+	if err != nil {
+		s.logger.Error("Failed to get webhook endpoint", zap.Uint("endpointID", delivery.EndpointID), zap.Error(err))
+		return fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+	if endpoint.DisabledAt != nil {
+		delivery.Status = domains.WebhookDeliveryDead
+		delivery.NextAttemptAt = nil
+		delivery.LastError = "endpoint is disabled"
+		if err := s.deliveryRepo.UpdateOutcome(ctx, delivery); err != nil {
+			s.logger.Error("Failed to update webhook delivery", zap.Uint("deliveryID", delivery.ID), zap.Error(err))
+			return fmt.Errorf("failed to update webhook delivery: %w", err)
+		}
+		return nil
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, delivery.EventID)
+	if err != nil {
+		s.logger.Error("Failed to get merchant event", zap.Uint("eventID", delivery.EventID), zap.Error(err))
+		return fmt.Errorf("failed to get merchant event: %w", err)
+	}
+	body, err := json.Marshal(MerchantWebhookEnvelope{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook: %w", err)
+	}
+
+	attempt := s.post(ctx, endpoint, delivery, body)
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &attempt.AttemptedAt
+	delivery.LastResponseStatus = attempt.ResponseStatus
+	delivery.LastError = attempt.Error
+	if attempt.Error == "" {
+		delivery.Status = domains.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &attempt.AttemptedAt
+	} else if next := s.policy.NextAttempt(delivery.Attempts, attempt.AttemptedAt); next != nil {
+		delivery.NextAttemptAt = next
+	} else {
+		delivery.Status = domains.WebhookDeliveryDead
+		delivery.NextAttemptAt = nil
+	}
+
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		attempt.DeliveryID = delivery.ID
+		if err := s.deliveryRepo.CreateAttempt(txCtx, attempt); err != nil {
+			return err
+		}
+		return s.deliveryRepo.UpdateOutcome(txCtx, delivery)
+	})
+	if err != nil {
+		s.logger.Error("Failed to record webhook attempt", zap.Uint("deliveryID", delivery.ID), zap.Error(err))
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+
+	fields := []zap.Field{
+		zap.Uint("deliveryID", delivery.ID), zap.Uint("endpointID", endpoint.ID), zap.String("type", string(delivery.EventType)),
+		zap.Int("attempts", delivery.Attempts), zap.String("status", string(delivery.Status)),
+	}
+	switch delivery.Status {
+	case domains.WebhookDeliverySucceeded:
+		s.logger.Info("Webhook delivered", fields...)
+	case domains.WebhookDeliveryDead:
+		s.logger.Warn("Webhook delivery dead-lettered", append(fields, zap.String("error", attempt.Error))...)
+	default:
+		s.logger.Info("Webhook delivery failed, will retry", append(fields, zap.String("error", attempt.Error), zap.Timep("nextAttemptAt", delivery.NextAttemptAt))...)
+	}
+	return nil
+}
+
+// post sends a signed webhook to an endpoint. Anything but a 2xx answer is
+// a failed attempt.
+func (s *MerchantWebhookService) post(ctx context.Context, endpoint *domains.WebhookEndpoint, delivery *domains.WebhookDelivery, body []byte) *domains.WebhookDeliveryAttempt {
+	attempt := &domains.WebhookDeliveryAttempt{AttemptedAt: time.Now()}
+	timestamp := strconv.FormatInt(attempt.AttemptedAt.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(MerchantWebhookSignatureHeader, "sha256="+utils.SignWebhook([]byte(endpoint.Secret), timestamp, body))
+	req.Header.Set(MerchantWebhookTimestampHeader, timestamp)
+	req.Header.Set(MerchantWebhookEventHeader, string(delivery.EventType))
+	req.Header.Set(MerchantWebhookDeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+
+	resp, err := s.client.Do(req)
+	attempt.DurationMs = time.Since(attempt.AttemptedAt).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxWebhookResponseBody))
+	attempt.ResponseStatus = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("endpoint answered %d", resp.StatusCode)
+	}
+	return attempt
+}
+
+func validMerchantEventType(eventType domains.MerchantEventType) bool {
+	for _, known := range merchantEventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
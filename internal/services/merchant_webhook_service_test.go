@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+// webhookReceiver is a merchant endpoint that checks the signature of what
+// it is sent and answers with status.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	secret   string
+	status   int
+	received []MerchantWebhookEnvelope
+	invalid  int
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	err := utils.VerifyWebhookSignature([]byte(rcv.secret), r.Header.Get(MerchantWebhookSignatureHeader),
+		r.Header.Get(MerchantWebhookTimestampHeader), body, time.Minute, time.Now())
+	var envelope MerchantWebhookEnvelope
+	if err == nil {
+		err = json.Unmarshal(body, &envelope)
+	}
+	if err != nil || r.Header.Get(MerchantWebhookEventHeader) != string(envelope.Type) {
+		rcv.invalid++
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rcv.received = append(rcv.received, envelope)
+	w.WriteHeader(rcv.status)
+}
+
+func (rcv *webhookReceiver) answer(status int) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.status = status
+}
+
+type webhookFixture struct {
+	service    *MerchantWebhookService
+	deliveries *fakeWebhookDeliveryRepo
+	receiver   *webhookReceiver
+	delivery   *domains.WebhookDelivery
+}
+
+// newWebhookFixture registers a TLS receiver for merchant 1 and queues one
+// event for it.
+func newWebhookFixture(t *testing.T, policy WebhookRetryPolicy, status int) *webhookFixture {
+	t.Helper()
+	f := &webhookFixture{
+		deliveries: &fakeWebhookDeliveryRepo{deliveries: map[uint]*domains.WebhookDelivery{}},
+		receiver:   &webhookReceiver{status: status},
+	}
+	srv := httptest.NewTLSServer(f.receiver)
+	t.Cleanup(srv.Close)
+
+	events := &fakeMerchantEventRepo{events: map[uint]*domains.MerchantEvent{
+		7: {ID: 7, MerchantID: 1, Type: domains.EventPaymentSucceeded, Payload: `{"payment_id":3}`, CreatedAt: time.Now()},
+	}}
+	f.service = NewMerchantWebhookService(
+		&fakeWebhookEndpointRepo{endpoints: map[uint]*domains.WebhookEndpoint{}},
+		f.deliveries,
+		events,
+		srv.Client(),
+		policy,
+		newTestTransactionManager(t),
+		zap.NewNop(),
+	)
+
+	ctx := context.Background()
+	endpoint := &domains.WebhookEndpoint{MerchantID: 1, URL: srv.URL}
+	if err := f.service.CreateEndpoint(ctx, endpoint); err != nil {
+		t.Fatalf("CreateEndpoint: %v", err)
+	}
+	f.receiver.secret = endpoint.Secret
+	if err := f.service.Enqueue(ctx, events.events[7]); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	f.delivery = f.deliveries.deliveries[1]
+	return f
+}
+
+// deliverNow makes the queued delivery due and runs a sweep.
+func (f *webhookFixture) deliverNow(t *testing.T) *domains.WebhookDelivery {
+	t.Helper()
+	if queued := f.deliveries.deliveries[f.delivery.ID]; queued.NextAttemptAt != nil {
+		past := time.Now().Add(-time.Second)
+		queued.NextAttemptAt = &past
+	}
+	if err := f.service.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	delivery, err := f.deliveries.GetByID(context.Background(), f.delivery.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	return delivery
+}
+
+func TestCreateEndpointRequiresHTTPS(t *testing.T) {
+	f := newWebhookFixture(t, DefaultWebhookRetryPolicy(), http.StatusOK)
+	err := f.service.CreateEndpoint(context.Background(), &domains.WebhookEndpoint{MerchantID: 1, URL: "http://merchant.test/hooks"})
+	if !errors.Is(err, ErrInvalidWebhookEndpoint) {
+		t.Fatalf("got %v, want ErrInvalidWebhookEndpoint", err)
+	}
+}
+
+func TestDeliverDueSendsSignedEvent(t *testing.T) {
+	f := newWebhookFixture(t, DefaultWebhookRetryPolicy(), http.StatusNoContent)
+
+	delivery := f.deliverNow(t)
+	if delivery.Status != domains.WebhookDeliverySucceeded || delivery.Attempts != 1 || delivery.DeliveredAt == nil {
+		t.Fatalf("delivery is %s after %d attempts, want SUCCEEDED after 1", delivery.Status, delivery.Attempts)
+	}
+	if f.receiver.invalid != 0 || len(f.receiver.received) != 1 {
+		t.Fatalf("receiver got %d valid and %d invalid webhooks, want 1 valid", len(f.receiver.received), f.receiver.invalid)
+	}
+	if got := f.receiver.received[0]; got.ID != 7 || got.Type != domains.EventPaymentSucceeded || string(got.Data) != `{"payment_id":3}` {
+		t.Fatalf("receiver got event %d %s %s, want event 7", got.ID, got.Type, got.Data)
+	}
+}
+
+func TestDeliverDueBacksOffThenDeadLetters(t *testing.T) {
+	policy := WebhookRetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: 90 * time.Second}
+	f := newWebhookFixture(t, policy, http.StatusInternalServerError)
+
+	for i, wantDelay := range []time.Duration{time.Minute, 90 * time.Second} {
+		delivery := f.deliverNow(t)
+		if delivery.Status != domains.WebhookDeliveryPending || delivery.Attempts != i+1 {
+			t.Fatalf("delivery is %s after %d attempts, want PENDING after %d", delivery.Status, delivery.Attempts, i+1)
+		}
+		if got := delivery.NextAttemptAt.Sub(*delivery.LastAttemptAt); got != wantDelay {
+			t.Fatalf("retry %d waits %s, want %s", i+1, got, wantDelay)
+		}
+	}
+
+	delivery := f.deliverNow(t)
+	if delivery.Status != domains.WebhookDeliveryDead || delivery.NextAttemptAt != nil {
+		t.Fatalf("delivery is %s after %d attempts, want DEAD", delivery.Status, delivery.Attempts)
+	}
+	if len(delivery.AttemptLog) != 3 || delivery.LastResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("logged %d attempts, last answered %d; want 3 answered 500", len(delivery.AttemptLog), delivery.LastResponseStatus)
+	}
+
+	f.deliverNow(t)
+	if got := len(f.receiver.received); got != 3 {
+		t.Fatalf("receiver got %d webhooks, want no more after the delivery died", got)
+	}
+}
+
+func TestReplayDeliveryResendsDeadDelivery(t *testing.T) {
+	f := newWebhookFixture(t, WebhookRetryPolicy{MaxAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Minute}, http.StatusServiceUnavailable)
+	if delivery := f.deliverNow(t); delivery.Status != domains.WebhookDeliveryDead {
+		t.Fatalf("delivery is %s, want DEAD", delivery.Status)
+	}
+
+	f.receiver.answer(http.StatusOK)
+	if _, err := f.service.ReplayDelivery(context.Background(), 2, f.delivery.ID); err == nil {
+		t.Fatal("another merchant replayed the delivery")
+	}
+	delivery, err := f.service.ReplayDelivery(context.Background(), 1, f.delivery.ID)
+	if err != nil {
+		t.Fatalf("ReplayDelivery: %v", err)
+	}
+	if delivery.Status != domains.WebhookDeliverySucceeded || delivery.Attempts != 1 || len(delivery.AttemptLog) != 2 {
+		t.Fatalf("delivery is %s after %d attempts with %d logged, want SUCCEEDED after 1 with 2 logged",
+			delivery.Status, delivery.Attempts, len(delivery.AttemptLog))
+	}
+	if len(f.receiver.received) != 2 || f.receiver.received[0].ID != f.receiver.received[1].ID {
+		t.Fatalf("receiver got %d webhooks, want the same event twice", len(f.receiver.received))
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var ErrModelUnavailable = errors.New("scoring model unavailable")
+
+// ModelScorerConfig configures the HTTP client for the external scoring model.
+type ModelScorerConfig struct {
+	Endpoint         string
+	Timeout          time.Duration
+	MaxRetries       int
+	RetryBackoff     time.Duration
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// DefaultModelScorerConfig returns a configuration suitable for the flask-api
+// service shipped in docker_compose.yaml.
+func DefaultModelScorerConfig(endpoint string) ModelScorerConfig {
+	return ModelScorerConfig{
+		Endpoint:         endpoint,
+		Timeout:          2 * time.Second,
+		MaxRetries:       2,
+		RetryBackoff:     200 * time.Millisecond,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+type modelScoreRequest struct {
+	Features ApplicantFeatures `json:"features"`
+}
+
+type modelScoreResponse struct {
+	Score        int                   `json:"score"`
+	ModelVersion string                `json:"model_version"`
+	Factors      []domains.ScoreFactor `json:"factors"`
+}
+
+// ModelScorer is a CreditScorer backed by the external ML scoring model. It
+// falls back to another CreditScorer when the model cannot be reached or its
+// circuit breaker is open.
+type ModelScorer struct {
+	config   ModelScorerConfig
+	client   *http.Client
+	breaker  *utils.CircuitBreaker
+	fallback CreditScorer
+	logger   *zap.Logger
+}
+
+// NewModelScorer creates a ModelScorer. A nil client uses http.DefaultClient;
+// per-attempt timeouts are applied through the request context.
+func NewModelScorer(config ModelScorerConfig, client *http.Client, fallback CreditScorer, logger *zap.Logger) *ModelScorer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &ModelScorer{
+		config:   config,
+		client:   client,
+		breaker:  utils.NewCircuitBreaker(config.FailureThreshold, config.Cooldown),
+		fallback: fallback,
+		logger:   logger,
+	}
+}
+
+func (s *ModelScorer) Score(ctx context.Context, features ApplicantFeatures) (*CreditScoreResult, error) {
+	start := time.Now()
+
+	if !s.breaker.Allow() {
+		s.logger.Warn("Scoring model circuit open, using fallback scorer")
+		return s.scoreWithFallback(ctx, features, start, utils.ErrCircuitOpen)
+	}
+
+	result, err := s.callWithRetries(ctx, features)
+	if err != nil {
+		s.breaker.RecordFailure()
+		s.logger.Error("Scoring model call failed", zap.Error(err), zap.Duration("latency", time.Since(start)))
+		return s.scoreWithFallback(ctx, features, start, err)
+	}
+	s.breaker.RecordSuccess()
+
+	result.Latency = time.Since(start)
+	s.logger.Info("Scored applicant with model",
+		zap.String("modelVersion", result.ModelVersion),
+		zap.Int("score", result.Score),
+		zap.Duration("latency", result.Latency))
+
+	return result, nil
+}
+
+// scoreWithFallback scores with the fallback scorer. The latency reported is
+// measured from start, so it includes the failed model call.
+func (s *ModelScorer) scoreWithFallback(ctx context.Context, features ApplicantFeatures, start time.Time, cause error) (*CreditScoreResult, error) {
+	if s.fallback == nil {
+		return nil, fmt.Errorf("%w: %v", ErrModelUnavailable, cause)
+	}
+	result, err := s.fallback.Score(ctx, features)
+	if err != nil {
+		return nil, err
+	}
+	result.Latency = time.Since(start)
+	return result, nil
+}
+
+func (s *ModelScorer) callWithRetries(ctx context.Context, features ApplicantFeatures) (*CreditScoreResult, error) {
+	body, err := json.Marshal(modelScoreRequest{Features: features})
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(s.config.RetryBackoff * time.Duration(attempt)):
+			}
+		}
+
+		result, retryable, err := s.call(ctx, body)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+		s.logger.Warn("Retrying scoring model call", zap.Int("attempt", attempt+1), zap.Error(err))
+	}
+
+	return nil, lastErr
+}
+
+// call performs a single request to the model. The boolean reports whether a
+// failed call is worth retrying.
+func (s *ModelScorer) call(ctx context.Context, body []byte) (*CreditScoreResult, bool, error) {
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, true, fmt.Errorf("scoring model returned status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("scoring model returned status %d", resp.StatusCode)
+	}
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	var out modelScoreResponse
+	if err := json.Unmarshal(payload, &out); err != nil {
+		return nil, false, fmt.Errorf("invalid scoring model response: %w", err)
+	}
+
+	score := utils.ClampCreditScore(out.Score)
+	return &CreditScoreResult{
+		Score:        score,
+		Band:         utils.CreditScoreBand(score),
+		Factors:      out.Factors,
+		ModelVersion: out.ModelVersion,
+	}, false, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func testModelScorerConfig(endpoint string) ModelScorerConfig {
+	return ModelScorerConfig{
+		Endpoint:         endpoint,
+		Timeout:          time.Second,
+		MaxRetries:       2,
+		RetryBackoff:     time.Millisecond,
+		FailureThreshold: 2,
+		Cooldown:         time.Minute,
+	}
+}
+
+func writeModelScore(w http.ResponseWriter, score int) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(modelScoreResponse{Score: score, ModelVersion: "model-test"})
+}
+
+func TestModelScorerRetriesTransientFailures(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeModelScore(w, 700)
+	}))
+	defer srv.Close()
+
+	scorer := NewModelScorer(testModelScorerConfig(srv.URL), srv.Client(), NewScorecardScorer(), zap.NewNop())
+	result, err := scorer.Score(context.Background(), ApplicantFeatures{})
+	if err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+	if result.ModelVersion != "model-test" || result.Score != 700 {
+		t.Fatalf("got %s score %d, want the model's score", result.ModelVersion, result.Score)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("model called %d times, want 3", got)
+	}
+}
+
+func TestModelScorerDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	scorer := NewModelScorer(testModelScorerConfig(srv.URL), srv.Client(), NewScorecardScorer(), zap.NewNop())
+	if _, err := scorer.Score(context.Background(), ApplicantFeatures{}); err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("model called %d times, want 1", got)
+	}
+}
+
+func TestModelScorerFallsBackToScorecard(t *testing.T) {
+	delay := 20 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	config := testModelScorerConfig(srv.URL)
+	config.MaxRetries = 0
+	scorer := NewModelScorer(config, srv.Client(), NewScorecardScorer(), zap.NewNop())
+
+	result, err := scorer.Score(context.Background(), ApplicantFeatures{AccountAgeDays: 400})
+	if err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+	if result.ModelVersion != ScorecardModelVersion {
+		t.Fatalf("got model version %s, want %s", result.ModelVersion, ScorecardModelVersion)
+	}
+	if result.Latency < delay {
+		t.Fatalf("latency %s does not include the failed model call (%s)", result.Latency, delay)
+	}
+}
+
+func TestModelScorerWithoutFallbackReportsUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	scorer := NewModelScorer(testModelScorerConfig(srv.URL), srv.Client(), nil, zap.NewNop())
+	if _, err := scorer.Score(context.Background(), ApplicantFeatures{}); err == nil {
+		t.Fatal("Score succeeded without a model or fallback")
+	}
+}
+
+func TestModelScorerOpensBreaker(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	config := testModelScorerConfig(srv.URL)
+	config.MaxRetries = 0
+	scorer := NewModelScorer(config, srv.Client(), NewScorecardScorer(), zap.NewNop())
+
+	for i := 0; i < config.FailureThreshold; i++ {
+		if _, err := scorer.Score(context.Background(), ApplicantFeatures{}); err != nil {
+			t.Fatalf("Score %d: %v", i, err)
+		}
+	}
+	if !scorer.breaker.IsOpen() {
+		t.Fatalf("breaker still closed after %d failures", config.FailureThreshold)
+	}
+
+	result, err := scorer.Score(context.Background(), ApplicantFeatures{})
+	if err != nil {
+		t.Fatalf("Score with open breaker: %v", err)
+	}
+	if result.ModelVersion != ScorecardModelVersion {
+		t.Fatalf("got model version %s with open breaker, want %s", result.ModelVersion, ScorecardModelVersion)
+	}
+	if got := atomic.LoadInt32(&calls); got != int32(config.FailureThreshold) {
+		t.Fatalf("model called %d times, want %d; the open breaker let a call through", got, config.FailureThreshold)
+	}
+}
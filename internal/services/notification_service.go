@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"go.uber.org/zap"
+)
+
+type NotificationServiceInterface interface {
+	ListNotifications(ctx context.Context, userID string, offset, limit int) ([]*domains.Notification, int, error)
+}
+
+// NotificationService records the messages sent to customers. A notification
+// created inside a transaction is only kept if the transaction commits.
+type NotificationService struct {
+	notificationRepo repository.NotificationRepository
+	logger           *zap.Logger
+}
+
+func NewNotificationService(notificationRepo repository.NotificationRepository, logger *zap.Logger) *NotificationService {
+	return &NotificationService{
+		notificationRepo: notificationRepo,
+		logger:           logger,
+	}
+}
+
+// Notify stores notification for delivery to its customer.
+func (s *NotificationService) Notify(ctx context.Context, notification *domains.Notification) error {
+	notification.CreatedAt = time.Now()
+	if err := s.notificationRepo.Create(ctx, notification); err != nil {
+		s.logger.Error("Failed to store notification",
+			zap.String("userID", notification.UserID), zap.String("type", notification.Type), zap.Error(err))
+		return fmt.Errorf("failed to store notification: %w", err)
+	}
+	s.logger.Info("Customer notified",
+		zap.String("userID", notification.UserID), zap.String("type", notification.Type), zap.Uint("notificationID", notification.ID))
+	return nil
+}
+
+func (s *NotificationService) ListNotifications(ctx context.Context, userID string, offset, limit int) ([]*domains.Notification, int, error) {
+	notifications, total, err := s.notificationRepo.ListByUserID(ctx, userID, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list notifications", zap.String("userID", userID), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	return notifications, total, nil
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWaiverReasonRequired = errors.New("a reason is required to waive a charge")
+	ErrChargeBilled         = errors.New("charge is billed to a gateway order that may still be paid")
+)
+
+const (
+	// LateFeeJobName is the JobRunner name of the late fee accrual.
+	LateFeeJobName = "late-fee-accrual"
+	// lateFeeBatchSize is how many overdue installments are read at a time.
+	lateFeeBatchSize = 100
+)
+
+// LateFeePolicy is how late fees accrue on overdue installments. A fee
+// accrues for every day an installment is overdue after the grace period,
+// until the fees of the installment reach the cap.
+type LateFeePolicy struct {
+	// Model is FLAT for a fixed fee per day, PERCENTAGE for Value basis
+	// points of the installment amount per day, or NONE for no late fees.
+	Model domains.FeeModel
+	Value int
+	// Cap bounds the late fees of one installment, waived ones included, so
+	// that a waiver is not undone by later accruals. Zero means no cap.
+	Cap int
+	// GraceDays is how many days after the due date pass before the first fee.
+	GraceDays int
+}
+
+// DefaultLateFeePolicy charges no late fees.
+func DefaultLateFeePolicy() LateFeePolicy {
+	return LateFeePolicy{
+		Model:     domains.FeeModelNone,
+		GraceDays: 3,
+	}
+}
+
+// Validate checks that the policy's model and amounts are consistent.
+func (p LateFeePolicy) Validate() error {
+	switch {
+	case !p.Model.Valid():
+		return fmt.Errorf("unknown late fee model %q", p.Model)
+	case p.Value < 0 || (p.Model == domains.FeeModelNone && p.Value != 0):
+		return fmt.Errorf("late fee value does not match the late fee model")
+	case p.Cap < 0:
+		return fmt.Errorf("late fee cap must not be negative")
+	case p.GraceDays < 0:
+		return fmt.Errorf("late fee grace period must not be negative")
+	}
+	return nil
+}
+
+// DailyFee returns the fee of one overdue day of an installment of amount,
+// rounded half up to a whole unit.
+func (p LateFeePolicy) DailyFee(amount int) int {
+	switch p.Model {
+	case domains.FeeModelFlat:
+		return p.Value
+	case domains.FeeModelPercentage:
+		return (amount*p.Value + 5000) / 10000
+	}
+	return 0
+}
+
+type PenaltyServiceInterface interface {
+	WaiveCharge(ctx context.Context, chargeID uint, waivedBy, reason string) (*domains.InstallmentCharge, error)
+}
+
+// PenaltyService accrues late fees on overdue installments as separate
+// charges and lets admins waive them.
+type PenaltyService struct {
+	installmentRepo repository.InstallmentRepository
+	paymentRepo     repository.PaymentRepository
+	chargeRepo      repository.InstallmentChargeRepository
+	ledger          *LedgerService
+	paymentGateway  PaymentGateway
+	txManager       *utils.TransactionManager
+	policy          LateFeePolicy
+	logger          *zap.Logger
+}
+
+func NewPenaltyService(
+	installmentRepo repository.InstallmentRepository,
+	paymentRepo repository.PaymentRepository,
+	chargeRepo repository.InstallmentChargeRepository,
+	ledger *LedgerService,
+	paymentGateway PaymentGateway,
+	txManager *utils.TransactionManager,
+	policy LateFeePolicy,
+	logger *zap.Logger,
+) *PenaltyService {
+	return &PenaltyService{
+		installmentRepo: installmentRepo,
+		paymentRepo:     paymentRepo,
+		chargeRepo:      chargeRepo,
+		ledger:          ledger,
+		paymentGateway:  paymentGateway,
+		txManager:       txManager,
+		policy:          policy,
+		logger:          logger,
+	}
+}
+
+// AccrueLateFees charges the late fees of every OVERDUE installment up to
+// today. Days missed by earlier runs are caught up, and days already charged
+// are skipped, so the job may run any number of times a day.
+func (s *PenaltyService) AccrueLateFees(ctx context.Context) error {
+	if s.policy.Model == domains.FeeModelNone {
+		return nil
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var afterID uint
+	accrued, failed := 0, 0
+	for {
+		installments, err := s.installmentRepo.ListByStatus(ctx, []string{"OVERDUE"}, afterID, lateFeeBatchSize)
+		if err != nil {
+			s.logger.Error("Failed to list overdue installments", zap.Error(err))
+			return fmt.Errorf("failed to list overdue installments: %w", err)
+		}
+		for _, installment := range installments {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			n, err := s.accrue(ctx, installment, today)
+			accrued += n
+			if err != nil {
+				failed++
+			}
+		}
+		if len(installments) < lateFeeBatchSize {
+			break
+		}
+		afterID = installments[len(installments)-1].ID
+	}
+
+	s.logger.Info("Late fee accrual finished", zap.Int("charges", accrued), zap.Int("failed", failed))
+	if failed > 0 {
+		return fmt.Errorf("late fee accrual failed for %d installments", failed)
+	}
+	return nil
+}
+
+// accrue charges the late fees of installment for the days up to today that
+// have none yet, and returns how many it charged.
+func (s *PenaltyService) accrue(ctx context.Context, installment *domains.Installment, today time.Time) (int, error) {
+	dueOn, err := installment.DueOn()
+	if err != nil {
+		s.logger.Error("Invalid installment due date", zap.Uint("installmentID", installment.ID), zap.String("dueDate", installment.DueDate))
+		return 0, err
+	}
+	firstDay := dueOn.AddDate(0, 0, s.policy.GraceDays+1)
+	if today.Before(firstDay) {
+		return 0, nil
+	}
+
+	charges, err := s.chargeRepo.ListByInstallmentID(ctx, installment.ID)
+	if err != nil {
+		s.logger.Error("Failed to list installment charges", zap.Uint("installmentID", installment.ID), zap.Error(err))
+		return 0, err
+	}
+	charged := make(map[string]bool, len(charges))
+	total := 0
+	for _, charge := range charges {
+		if charge.Type == domains.ChargeTypeLateFee {
+			charged[charge.AccrualDate] = true
+			total += charge.Amount
+		}
+	}
+
+	payment, err := s.paymentRepo.GetByID(ctx, installment.PaymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Uint("paymentID", installment.PaymentID), zap.Error(err))
+		return 0, err
+	}
+
+	daily := s.policy.DailyFee(installment.Amount)
+	accrued := 0
+	for day := firstDay; !day.After(today); day = day.AddDate(0, 0, 1) {
+		accrualDate := day.Format(installmentDateLayout)
+		if charged[accrualDate] {
+			continue
+		}
+		fee := daily
+		if s.policy.Cap > 0 && total+fee > s.policy.Cap {
+			fee = s.policy.Cap - total
+		}
+		if fee <= 0 {
+			break
+		}
+
+		charge := &domains.InstallmentCharge{
+			InstallmentID: installment.ID,
+			PaymentID:     payment.ID,
+			UserID:        payment.UserID,
+			Type:          domains.ChargeTypeLateFee,
+			AccrualDate:   accrualDate,
+			Amount:        fee,
+			Currency:      payment.Currency,
+			Status:        domains.ChargeAccrued,
+		}
+		err := s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+			if err := s.chargeRepo.Create(txCtx, charge); err != nil {
+				return err
+			}
+			return s.ledger.PostLateFee(txCtx, charge)
+		})
+		var duplicateErr *utils.ErrDuplicateEntry
+		if errors.As(err, &duplicateErr) {
+			// Charged by a concurrent run; its amount is not known here
+			break
+		}
+		if err != nil {
+			s.logger.Error("Failed to accrue late fee", zap.Uint("installmentID", installment.ID), zap.String("date", accrualDate), zap.Error(err))
+			return accrued, err
+		}
+		total += fee
+		accrued++
+	}
+
+	if accrued > 0 {
+		s.logger.Info("Late fees accrued", zap.Uint("installmentID", installment.ID), zap.Int("days", accrued), zap.Int("total", total))
+	}
+	return accrued, nil
+}
+
+// BillableCharges returns the charges still owed on an installment and what
+// they add up to. They are collected in the same gateway order as the
+// installment.
+func (s *PenaltyService) BillableCharges(ctx context.Context, installmentID uint) ([]*domains.InstallmentCharge, int, error) {
+	charges, err := s.chargeRepo.ListByInstallmentID(ctx, installmentID)
+	if err != nil {
+		s.logger.Error("Failed to list installment charges", zap.Uint("installmentID", installmentID), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list installment charges: %w", err)
+	}
+
+	var billable []*domains.InstallmentCharge
+	total := 0
+	for _, charge := range charges {
+		if charge.Outstanding() {
+			billable = append(billable, charge)
+			total += charge.Amount
+		}
+	}
+	return billable, total, nil
+}
+
+// BillCharges records that charges are collected by a gateway order, so that
+// they are settled when it is paid.
+func (s *PenaltyService) BillCharges(ctx context.Context, charges []*domains.InstallmentCharge, gatewayOrderID string) error {
+	if len(charges) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(charges))
+	for i, charge := range charges {
+		ids[i] = charge.ID
+	}
+	if err := s.chargeRepo.BillOrder(ctx, ids, gatewayOrderID); err != nil {
+		s.logger.Error("Failed to bill charges", zap.String("gatewayOrderID", gatewayOrderID), zap.Error(err))
+		return fmt.Errorf("failed to bill charges: %w", err)
+	}
+	return nil
+}
+
+// SettleCharges marks the charges billed to a paid gateway order as paid,
+// posts their collection and returns what they add up to. Charges billed to
+// another order meanwhile are left to that order; WaiveCharge refuses charges
+// whose order may still be paid.
+func (s *PenaltyService) SettleCharges(ctx context.Context, gatewayOrderID string) (int, error) {
+	charges, err := s.chargeRepo.ListByGatewayOrderID(ctx, gatewayOrderID)
+	if err != nil {
+		s.logger.Error("Failed to list billed charges", zap.String("gatewayOrderID", gatewayOrderID), zap.Error(err))
+		return 0, fmt.Errorf("failed to list billed charges: %w", err)
+	}
+
+	now := time.Now()
+	var paid []*domains.InstallmentCharge
+	total := 0
+	for _, charge := range charges {
+		err := s.chargeRepo.MarkPaid(ctx, charge.ID, gatewayOrderID, now)
+		var transitionErr *utils.ErrInvalidTransition
+		if errors.As(err, &transitionErr) {
+			s.logger.Warn("Billed charge no longer owed", zap.Uint("chargeID", charge.ID), zap.String("status", string(charge.Status)))
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		charge.Status = domains.ChargePaid
+		charge.PaidAt = &now
+		paid = append(paid, charge)
+		total += charge.Amount
+	}
+	if len(paid) == 0 {
+		return 0, nil
+	}
+
+	if err := s.ledger.PostChargeCollection(ctx, gatewayOrderID, paid); err != nil {
+		return 0, err
+	}
+	s.logger.Info("Charges collected", zap.String("gatewayOrderID", gatewayOrderID), zap.Int("count", len(paid)), zap.Int("total", total))
+	return total, nil
+}
+
+// ListCharges returns the charges of all installments of a payment.
+func (s *PenaltyService) ListCharges(ctx context.Context, paymentID uint) ([]*domains.InstallmentCharge, error) {
+	charges, err := s.chargeRepo.ListByPaymentID(ctx, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to list charges", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to list charges: %w", err)
+	}
+	return charges, nil
+}
+
+// WaiveCharge cancels an accrued charge and reverses it in the ledger. The
+// admin and reason are kept on the charge for audit. A charge billed to a
+// gateway order can only be waived once the gateway declined the order, as
+// what a paid order took for it would otherwise not be accounted for.
+func (s *PenaltyService) WaiveCharge(ctx context.Context, chargeID uint, waivedBy, reason string) (*domains.InstallmentCharge, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrWaiverReasonRequired
+	}
+
+	charge, err := s.chargeRepo.GetByID(ctx, chargeID)
+	if err != nil {
+		s.logger.Error("Failed to get charge", zap.Uint("chargeID", chargeID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get charge: %w", err)
+	}
+	if charge.Outstanding() && charge.GatewayOrderID != "" {
+		result, err := s.paymentGateway.ConfirmOrder(ctx, charge.GatewayOrderID)
+		if err != nil {
+			s.logger.Error("Failed to check billed order with gateway", zap.Uint("chargeID", chargeID), zap.Error(err))
+			if errors.Is(err, ErrGatewayTimeout) {
+				return nil, ErrGatewayTimeout
+			}
+			return nil, fmt.Errorf("failed to check billed order with gateway: %w", err)
+		}
+		if result.Status != GatewayOrderDeclined {
+			return nil, ErrChargeBilled
+		}
+	}
+
+	// The charge must still be billed to the order checked above
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.chargeRepo.Waive(txCtx, chargeID, charge.GatewayOrderID, waivedBy, reason, time.Now()); err != nil {
+			return err
+		}
+		var err error
+		if charge, err = s.chargeRepo.GetByID(txCtx, chargeID); err != nil {
+			return err
+		}
+		return s.ledger.PostFeeWaiver(txCtx, charge)
+	})
+	if err != nil {
+		s.logger.Error("Failed to waive charge", zap.Uint("chargeID", chargeID), zap.Error(err))
+		return nil, fmt.Errorf("failed to waive charge: %w", err)
+	}
+
+	s.logger.Info("Charge waived",
+		zap.Uint("chargeID", chargeID), zap.Uint("installmentID", charge.InstallmentID),
+		zap.Int("amount", charge.Amount), zap.String("waivedBy", waivedBy), zap.String("reason", reason))
+	return charge, nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"go.uber.org/zap"
+)
+
+type penaltyFixture struct {
+	service     *PenaltyService
+	installment *domains.Installment
+	charges     *fakeChargeRepo
+	ledger      *fakeLedgerRepo
+	gateway     *fakeGateway
+}
+
+// newPenaltyFixture sets up an installment of payment 1 that fell due
+// overdueDays ago.
+func newPenaltyFixture(t *testing.T, policy LateFeePolicy, overdueDays int) *penaltyFixture {
+	t.Helper()
+	payments := newFakePaymentRepo()
+	if err := payments.Create(context.Background(), &domains.Payment{UserID: testUserID, Amount: 40000, Currency: "DZD", Status: "SUCCESSFUL"}); err != nil {
+		t.Fatalf("Create payment: %v", err)
+	}
+
+	f := &penaltyFixture{
+		installment: &domains.Installment{
+			PaymentID:         1,
+			InstallmentNumber: 2,
+			DueDate:           time.Now().AddDate(0, 0, -overdueDays).Format(installmentDateLayout),
+			Amount:            10000,
+			Status:            "OVERDUE",
+		},
+		charges: &fakeChargeRepo{},
+		ledger:  &fakeLedgerRepo{},
+		gateway: &fakeGateway{statuses: map[string]GatewayOrderStatus{}},
+	}
+	f.installment.ID = 10
+	f.service = NewPenaltyService(
+		&fakeInstallmentRepo{installments: []*domains.Installment{f.installment}},
+		payments,
+		f.charges,
+		NewLedgerService(f.ledger, zap.NewNop()),
+		f.gateway,
+		newTestTransactionManager(t),
+		policy,
+		zap.NewNop(),
+	)
+	return f
+}
+
+func (f *penaltyFixture) accrue(t *testing.T) {
+	t.Helper()
+	if err := f.service.AccrueLateFees(context.Background()); err != nil {
+		t.Fatalf("AccrueLateFees: %v", err)
+	}
+}
+
+func TestAccrueLateFeesCatchesUpToCapOnce(t *testing.T) {
+	policy := LateFeePolicy{Model: domains.FeeModelFlat, Value: 100, Cap: 450, GraceDays: 3}
+	f := newPenaltyFixture(t, policy, 10)
+
+	f.accrue(t)
+	f.accrue(t)
+
+	// Days 4 to 10 after the due date are chargeable, the cap stops at 450
+	wantAmounts := []int{100, 100, 100, 100, 50}
+	if len(f.charges.charges) != len(wantAmounts) {
+		t.Fatalf("%d charges accrued, want %d", len(f.charges.charges), len(wantAmounts))
+	}
+	firstDay := time.Now().AddDate(0, 0, -6).Format(installmentDateLayout)
+	for i, charge := range f.charges.charges {
+		if charge.Amount != wantAmounts[i] || charge.Status != domains.ChargeAccrued || charge.UserID != testUserID {
+			t.Fatalf("charge %d is %d %s for %s, want %d ACCRUED", i+1, charge.Amount, charge.Status, charge.UserID, wantAmounts[i])
+		}
+	}
+	if got := f.charges.charges[0].AccrualDate; got != firstDay {
+		t.Fatalf("first charge accrued on %s, want %s, after the grace period", got, firstDay)
+	}
+	if len(f.ledger.journals) != len(wantAmounts) {
+		t.Fatalf("%d late fee journals posted, want %d", len(f.ledger.journals), len(wantAmounts))
+	}
+}
+
+func TestAccrueLateFeesWaitsForGracePeriod(t *testing.T) {
+	policy := LateFeePolicy{Model: domains.FeeModelPercentage, Value: 50, GraceDays: 3}
+	f := newPenaltyFixture(t, policy, 3)
+
+	f.accrue(t)
+	if len(f.charges.charges) != 0 {
+		t.Fatalf("%d charges accrued within the grace period", len(f.charges.charges))
+	}
+}
+
+func TestWaiveChargeRefusesChargesOfOrdersThatMayBePaid(t *testing.T) {
+	f := newPenaltyFixture(t, DefaultLateFeePolicy(), 10)
+	f.gateway.statuses["order-pending"] = GatewayOrderPending
+	f.gateway.statuses["order-paid"] = GatewayOrderPaid
+	f.gateway.statuses["order-declined"] = GatewayOrderDeclined
+	for i, orderID := range []string{"", "order-pending", "order-paid", "order-declined"} {
+		charge := &domains.InstallmentCharge{
+			InstallmentID:  f.installment.ID,
+			PaymentID:      1,
+			UserID:         testUserID,
+			Type:           domains.ChargeTypeLateFee,
+			AccrualDate:    time.Now().AddDate(0, 0, -i).Format(installmentDateLayout),
+			Amount:         100,
+			Currency:       "DZD",
+			Status:         domains.ChargeAccrued,
+			GatewayOrderID: orderID,
+		}
+		if err := f.charges.Create(context.Background(), charge); err != nil {
+			t.Fatalf("Create charge: %v", err)
+		}
+	}
+
+	if _, err := f.service.WaiveCharge(context.Background(), 1, "admin", " "); !errors.Is(err, ErrWaiverReasonRequired) {
+		t.Fatalf("waiver without a reason: got %v, want ErrWaiverReasonRequired", err)
+	}
+
+	tests := []struct {
+		chargeID uint
+		want     error
+	}{
+		{1, nil},
+		{2, ErrChargeBilled},
+		{3, ErrChargeBilled},
+		{4, nil},
+	}
+	for _, tt := range tests {
+		charge, err := f.service.WaiveCharge(context.Background(), tt.chargeID, "admin", "goodwill")
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("waiving charge %d: got %v, want %v", tt.chargeID, err, tt.want)
+		}
+		if err == nil && (charge.Status != domains.ChargeWaived || charge.WaiverReason != "goodwill") {
+			t.Fatalf("charge %d is %s after the waiver", tt.chargeID, charge.Status)
+		}
+	}
+	if len(f.ledger.journals) != 2 {
+		t.Fatalf("%d waiver journals posted, want 2", len(f.ledger.journals))
+	}
+}
+
+func TestSettleChargesPaysOnlyChargesStillBilledToOrder(t *testing.T) {
+	policy := LateFeePolicy{Model: domains.FeeModelFlat, Value: 100, GraceDays: 0}
+	f := newPenaltyFixture(t, policy, 3)
+	f.accrue(t)
+	ctx := context.Background()
+
+	charges, fees, err := f.service.BillableCharges(ctx, f.installment.ID)
+	if err != nil {
+		t.Fatalf("BillableCharges: %v", err)
+	}
+	if len(charges) != 3 || fees != 300 {
+		t.Fatalf("%d charges owing %d, want 3 owing 300", len(charges), fees)
+	}
+	if err := f.service.BillCharges(ctx, charges, "order-1"); err != nil {
+		t.Fatalf("BillCharges: %v", err)
+	}
+	// The customer opens another order for the last charge
+	if err := f.service.BillCharges(ctx, charges[2:], "order-2"); err != nil {
+		t.Fatalf("BillCharges: %v", err)
+	}
+
+	settled, err := f.service.SettleCharges(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("SettleCharges: %v", err)
+	}
+	if settled != 200 {
+		t.Fatalf("order-1 settled %d of charges, want 200", settled)
+	}
+	if _, fees, _ := f.service.BillableCharges(ctx, f.installment.ID); fees != 100 {
+		t.Fatalf("%d still owed after order-1 was paid, want 100 billed to order-2", fees)
+	}
+
+	// Settling again finds nothing left to pay
+	if settled, err := f.service.SettleCharges(ctx, "order-1"); err != nil || settled != 0 {
+		t.Fatalf("settling order-1 again: %d, %v; want 0", settled, err)
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import "github.com/mohamed2394/sahla/internal/domains"
+
+// PlanPrice is the disclosed cost of financing a purchase with a plan. The
+// fee is a fixed markup agreed at purchase time, in the spirit of a Murabaha
+// sale: it never grows with time, and late penalties are accounted apart
+// from it.
+type PlanPrice struct {
+	// Amount is the purchase amount.
+	Amount int
+	// DownPayment is the part of Amount due at purchase, which carries no fee.
+	DownPayment int
+	// Financed is the part of Amount repaid over the remaining installments.
+	Financed int
+	// Fee is the markup or service fee charged on the financed amount.
+	Fee int
+}
+
+// TotalRepayable returns what the customer pays in total.
+func (p PlanPrice) TotalRepayable() int {
+	return p.Amount + p.Fee
+}
+
+// PricePlan computes the down payment and fee of a purchase of amount under
+// terms. Percentage fees are in basis points of the financed amount and are
+// rounded half up to a whole unit.
+func PricePlan(terms domains.PlanTerms, amount int) PlanPrice {
+	price := PlanPrice{Amount: amount}
+	if terms.DownPaymentPercent > 0 && terms.InstallmentCount > 1 {
+		price.DownPayment = amount * terms.DownPaymentPercent / 100
+	}
+	price.Financed = amount - price.DownPayment
+
+	switch terms.FeeModel {
+	case domains.FeeModelFlat:
+		price.Fee = terms.FeeValue
+	case domains.FeeModelPercentage:
+		price.Fee = (price.Financed*terms.FeeValue + 5000) / 10000
+	}
+	return price
+}
+
+// allocate splits total into n parts that differ by at most one unit and add
+// up to total exactly. The extra units go to the last parts.
+func allocate(total, n int) []int {
+	parts := make([]int, n)
+	if n == 0 {
+		return parts
+	}
+	base, extra := total/n, total%n
+	for i := range parts {
+		parts[i] = base
+		if i >= n-extra {
+			parts[i]++
+		}
+	}
+	return parts
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+)
+
+func TestPricePlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		terms  domains.PlanTerms
+		amount int
+		want   PlanPrice
+	}{
+		{
+			name:   "no fee",
+			terms:  domains.PlanTerms{InstallmentCount: 4, FeeModel: domains.FeeModelNone},
+			amount: 10001,
+			want:   PlanPrice{Amount: 10001, Financed: 10001},
+		},
+		{
+			name:   "down payment rounds down",
+			terms:  domains.PlanTerms{InstallmentCount: 4, DownPaymentPercent: 25, FeeModel: domains.FeeModelNone},
+			amount: 10001,
+			want:   PlanPrice{Amount: 10001, DownPayment: 2500, Financed: 7501},
+		},
+		{
+			name:   "no down payment on a single installment",
+			terms:  domains.PlanTerms{InstallmentCount: 1, DownPaymentPercent: 20, FeeModel: domains.FeeModelFlat, FeeValue: 500},
+			amount: 8000,
+			want:   PlanPrice{Amount: 8000, Financed: 8000, Fee: 500},
+		},
+		{
+			name:   "percentage fee on the financed amount",
+			terms:  domains.PlanTerms{InstallmentCount: 12, DownPaymentPercent: 10, FeeModel: domains.FeeModelPercentage, FeeValue: 1200},
+			amount: 50000,
+			want:   PlanPrice{Amount: 50000, DownPayment: 5000, Financed: 45000, Fee: 5400},
+		},
+		{
+			name:   "percentage fee rounds half up",
+			terms:  domains.PlanTerms{InstallmentCount: 3, FeeModel: domains.FeeModelPercentage, FeeValue: 15},
+			amount: 1000,
+			want:   PlanPrice{Amount: 1000, Financed: 1000, Fee: 2},
+		},
+		{
+			name:   "percentage fee rounds below half down",
+			terms:  domains.PlanTerms{InstallmentCount: 3, FeeModel: domains.FeeModelPercentage, FeeValue: 14},
+			amount: 1000,
+			want:   PlanPrice{Amount: 1000, Financed: 1000, Fee: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PricePlan(tt.terms, tt.amount); got != tt.want {
+				t.Fatalf("PricePlan = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		total, n int
+		want     []int
+	}{
+		{12, 4, []int{3, 3, 3, 3}},
+		{10, 3, []int{3, 3, 4}},
+		{2, 4, []int{0, 0, 1, 1}},
+		{0, 3, []int{0, 0, 0}},
+		{9, 0, []int{}},
+	}
+
+	for _, tt := range tests {
+		got := allocate(tt.total, tt.n)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("allocate(%d, %d) = %v, want %v", tt.total, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestBuildScheduleAddsUpToPrice(t *testing.T) {
+	tests := []struct {
+		terms  domains.PlanTerms
+		amount int
+	}{
+		{domains.PlanTerms{InstallmentCount: 4, Frequency: domains.PlanFrequencyBiweekly, FeeModel: domains.FeeModelNone}, 10001},
+		{domains.PlanTerms{InstallmentCount: 4, Frequency: domains.PlanFrequencyBiweekly, DownPaymentPercent: 25, FeeModel: domains.FeeModelNone}, 9999},
+		{domains.PlanTerms{InstallmentCount: 3, Frequency: domains.PlanFrequencyMonthly, FeeModel: domains.FeeModelFlat, FeeValue: 100}, 1000},
+		{domains.PlanTerms{InstallmentCount: 12, Frequency: domains.PlanFrequencyMonthly, DownPaymentPercent: 10, FeeModel: domains.FeeModelPercentage, FeeValue: 1234}, 77777},
+		{domains.PlanTerms{InstallmentCount: 6, Frequency: domains.PlanFrequencyWeekly, FeeModel: domains.FeeModelPercentage, FeeValue: 250}, 5},
+	}
+
+	start := time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		price := PricePlan(tt.terms, tt.amount)
+		installments := BuildSchedule(tt.terms, tt.amount, start)
+		if len(installments) != tt.terms.InstallmentCount {
+			t.Errorf("%+v: %d installments, want %d", tt.terms, len(installments), tt.terms.InstallmentCount)
+			continue
+		}
+
+		total, principal, fee := 0, 0, 0
+		for _, installment := range installments {
+			if installment.Amount != installment.Principal+installment.Fee {
+				t.Errorf("%+v: installment %d of %d is not its principal %d plus fee %d", tt.terms,
+					installment.InstallmentNumber, installment.Amount, installment.Principal, installment.Fee)
+			}
+			total += installment.Amount
+			principal += installment.Principal
+			fee += installment.Fee
+		}
+		if total != price.TotalRepayable() || principal != tt.amount || fee != price.Fee {
+			t.Errorf("%+v on %d: installments add up to %d (principal %d, fee %d), want %d (principal %d, fee %d)",
+				tt.terms, tt.amount, total, principal, fee, price.TotalRepayable(), tt.amount, price.Fee)
+		}
+	}
+}
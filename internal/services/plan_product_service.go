@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidPlan     = errors.New("invalid installment plan")
+	ErrPlanNotEligible = errors.New("installment plan is not available for this purchase")
+)
+
+type PlanProductServiceInterface interface {
+	CreatePlan(ctx context.Context, plan *domains.PlanProduct) error
+	GetPlan(ctx context.Context, id uint) (*domains.PlanProduct, error)
+	UpdatePlan(ctx context.Context, plan *domains.PlanProduct) error
+	DeletePlan(ctx context.Context, id uint) error
+	ListPlans(ctx context.Context, activeOnly bool) ([]*domains.PlanProduct, error)
+	Quote(ctx context.Context, req QuoteRequest) (*PlanQuote, error)
+}
+
+// PlanProductService manages the catalog of installment plans, decides
+// which plan finances a purchase and issues signed quotes of its schedule.
+type PlanProductService struct {
+	planRepo      repository.PlanProductRepository
+	quoteSecret   []byte
+	quoteValidity time.Duration
+	logger        *zap.Logger
+}
+
+// NewPlanProductService creates a PlanProductService. Quotes are signed with
+// quoteSecret; a zero quoteValidity uses DefaultQuoteValidity.
+func NewPlanProductService(
+	planRepo repository.PlanProductRepository,
+	quoteSecret []byte,
+	quoteValidity time.Duration,
+	logger *zap.Logger,
+) *PlanProductService {
+	if quoteValidity <= 0 {
+		quoteValidity = DefaultQuoteValidity
+	}
+	return &PlanProductService{
+		planRepo:      planRepo,
+		quoteSecret:   quoteSecret,
+		quoteValidity: quoteValidity,
+		logger:        logger,
+	}
+}
+
+func (s *PlanProductService) CreatePlan(ctx context.Context, plan *domains.PlanProduct) error {
+	if err := validatePlan(plan); err != nil {
+		return err
+	}
+	if err := s.planRepo.Create(ctx, plan); err != nil {
+		s.logger.Error("Failed to create plan", zap.String("code", plan.Code), zap.Error(err))
+		return fmt.Errorf("failed to create plan: %w", err)
+	}
+	s.logger.Info("Plan created", zap.Uint("planID", plan.ID), zap.String("code", plan.Code))
+	return nil
+}
+
+func (s *PlanProductService) GetPlan(ctx context.Context, id uint) (*domains.PlanProduct, error) {
+	plan, err := s.planRepo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get plan", zap.Uint("planID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+	return plan, nil
+}
+
+// UpdatePlan replaces a plan's definition. Payments already made keep the
+// terms they were created with.
+func (s *PlanProductService) UpdatePlan(ctx context.Context, plan *domains.PlanProduct) error {
+	if err := validatePlan(plan); err != nil {
+		return err
+	}
+	existing, err := s.GetPlan(ctx, plan.ID)
+	if err != nil {
+		return err
+	}
+	plan.CreatedAt = existing.CreatedAt
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		s.logger.Error("Failed to update plan", zap.Uint("planID", plan.ID), zap.Error(err))
+		return fmt.Errorf("failed to update plan: %w", err)
+	}
+	s.logger.Info("Plan updated", zap.Uint("planID", plan.ID))
+	return nil
+}
+
+func (s *PlanProductService) DeletePlan(ctx context.Context, id uint) error {
+	if err := s.planRepo.Delete(ctx, id); err != nil {
+		s.logger.Error("Failed to delete plan", zap.Uint("planID", id), zap.Error(err))
+		return fmt.Errorf("failed to delete plan: %w", err)
+	}
+	s.logger.Info("Plan deleted", zap.Uint("planID", id))
+	return nil
+}
+
+func (s *PlanProductService) ListPlans(ctx context.Context, activeOnly bool) ([]*domains.PlanProduct, error) {
+	plans, err := s.planRepo.List(ctx, activeOnly)
+	if err != nil {
+		s.logger.Error("Failed to list plans", zap.Error(err))
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+	return plans, nil
+}
+
+// SelectPlan returns the plan that finances a purchase of amount in
+// category. A chosen plan must be active and eligible. Without a choice, the
+// shortest eligible plan is used, and an empty catalog falls back to
+// defaultPlanTerms.
+func (s *PlanProductService) SelectPlan(ctx context.Context, planID *uint, amount int, category string) (*domains.PlanProduct, error) {
+	if planID != nil {
+		plan, err := s.GetPlan(ctx, *planID)
+		if err != nil {
+			return nil, err
+		}
+		if !plan.Active || !plan.CoversAmount(amount) || !plan.CoversCategory(category) {
+			s.logger.Warn("Plan not eligible for purchase",
+				zap.Uint("planID", plan.ID), zap.Int("amount", amount), zap.String("category", category))
+			return nil, ErrPlanNotEligible
+		}
+		return plan, nil
+	}
+
+	plans, err := s.ListPlans(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return &domains.PlanProduct{Terms: defaultPlanTerms(amount)}, nil
+	}
+	for _, plan := range plans {
+		if plan.CoversAmount(amount) && plan.CoversCategory(category) {
+			return plan, nil
+		}
+	}
+	return nil, ErrPlanNotEligible
+}
+
+// defaultPlanTerms is the monthly schedule used before plans were
+// configurable: 3, 6 or 12 installments depending on the amount.
+func defaultPlanTerms(amount int) domains.PlanTerms {
+	terms := domains.PlanTerms{
+		InstallmentCount: 12,
+		Frequency:        domains.PlanFrequencyMonthly,
+		FeeModel:         domains.FeeModelNone,
+	}
+	if amount < 1000 {
+		terms.InstallmentCount = 3
+	} else if amount < 5000 {
+		terms.InstallmentCount = 6
+	}
+	return terms
+}
+
+func validatePlan(plan *domains.PlanProduct) error {
+	terms := plan.Terms
+	switch {
+	case terms.InstallmentCount < 1:
+		return fmt.Errorf("%w: installment count must be at least 1", ErrInvalidPlan)
+	case !terms.Frequency.Valid():
+		return fmt.Errorf("%w: unknown frequency %q", ErrInvalidPlan, terms.Frequency)
+	case terms.DownPaymentPercent < 0 || terms.DownPaymentPercent >= 100:
+		return fmt.Errorf("%w: down payment percent must be between 0 and 99", ErrInvalidPlan)
+	case terms.DownPaymentPercent > 0 && terms.InstallmentCount < 2:
+		return fmt.Errorf("%w: a down payment needs at least 2 installments", ErrInvalidPlan)
+	case !terms.FeeModel.Valid():
+		return fmt.Errorf("%w: unknown fee model %q", ErrInvalidPlan, terms.FeeModel)
+	case terms.FeeValue < 0 || (terms.FeeModel == domains.FeeModelNone && terms.FeeValue != 0):
+		return fmt.Errorf("%w: fee value does not match the fee model", ErrInvalidPlan)
+	case plan.MinAmount < 0 || (plan.MaxAmount != 0 && plan.MaxAmount < plan.MinAmount):
+		return fmt.Errorf("%w: max amount must not be below min amount", ErrInvalidPlan)
+	}
+	return nil
+}
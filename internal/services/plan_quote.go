@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mohamed2394/sahla/internal/domains"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidQuote = errors.New("invalid plan quote")
+	ErrQuoteExpired = errors.New("plan quote has expired")
+)
+
+// DefaultQuoteValidity is how long a signed quote is honoured.
+const DefaultQuoteValidity = 15 * time.Minute
+
+// QuoteRequest describes the purchase a customer wants a schedule for.
+type QuoteRequest struct {
+	Amount           int
+	Currency         string
+	PlanID           *uint
+	MerchantCategory string
+}
+
+// PlanQuote is the schedule a purchase would get, as shown to the customer
+// before paying.
+type PlanQuote struct {
+	ID               string
+	PlanID           *uint
+	PlanCode         string
+	Amount           int
+	Currency         string
+	MerchantCategory string
+	Terms            domains.PlanTerms
+	Price            PlanPrice
+	Installments     []domains.Installment
+	IssuedAt         time.Time
+	ExpiresAt        time.Time
+	// Token is the signed form of the quote, to be passed back when paying.
+	Token string
+}
+
+// quoteClaims is the signed content of a quote token: everything needed to
+// honour the quoted terms without reading the catalog again. Start is when
+// the quoted schedule starts, so that installments fall due on the quoted
+// dates.
+type quoteClaims struct {
+	ID               string            `json:"id"`
+	PlanID           *uint             `json:"plan_id,omitempty"`
+	Amount           int               `json:"amount"`
+	Currency         string            `json:"currency"`
+	MerchantCategory string            `json:"merchant_category,omitempty"`
+	Terms            domains.PlanTerms `json:"terms"`
+	Start            int64             `json:"start"`
+	ExpiresAt        int64             `json:"exp"`
+}
+
+// Quote returns the schedule a purchase would get with the chosen plan, or
+// with the plan SelectPlan would pick, built by the same code that creates
+// installments once the payment succeeds.
+func (s *PlanProductService) Quote(ctx context.Context, req QuoteRequest) (*PlanQuote, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	plan, err := s.SelectPlan(ctx, req.PlanID, req.Amount, req.MerchantCategory)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	quote := &PlanQuote{
+		ID:               uuid.Must(uuid.NewV4()).String(),
+		PlanCode:         plan.Code,
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+		MerchantCategory: req.MerchantCategory,
+		Terms:            plan.Terms,
+		Price:            PricePlan(plan.Terms, req.Amount),
+		Installments:     BuildSchedule(plan.Terms, req.Amount, now),
+		IssuedAt:         now,
+		ExpiresAt:        now.Add(s.quoteValidity),
+	}
+	if plan.ID != 0 {
+		quote.PlanID = &plan.ID
+	}
+
+	quote.Token, err = s.signQuote(quoteClaims{
+		ID:               quote.ID,
+		PlanID:           quote.PlanID,
+		Amount:           quote.Amount,
+		Currency:         quote.Currency,
+		MerchantCategory: quote.MerchantCategory,
+		Terms:            quote.Terms,
+		Start:            now.Unix(),
+		ExpiresAt:        quote.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		s.logger.Error("Failed to sign quote", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Plan quoted", zap.String("quoteID", quote.ID), zap.String("plan", quote.PlanCode), zap.Int("amount", quote.Amount))
+	return quote, nil
+}
+
+// HonourQuote checks a quote token against the payment it is used for and,
+// while it is valid, applies the quoted plan, fee and schedule start to the
+// payment even if the catalog has changed since.
+func (s *PlanProductService) HonourQuote(payment *domains.Payment, token string, now time.Time) error {
+	claims, err := s.verifyQuote(token, now)
+	if err != nil {
+		return err
+	}
+	if claims.Amount != payment.Amount || claims.Currency != payment.Currency || claims.MerchantCategory != payment.MerchantCategory {
+		s.logger.Warn("Quote does not match payment", zap.String("quoteID", claims.ID))
+		return ErrInvalidQuote
+	}
+	if payment.PlanProductID != nil && (claims.PlanID == nil || *claims.PlanID != *payment.PlanProductID) {
+		s.logger.Warn("Quote was made for another plan", zap.String("quoteID", claims.ID))
+		return ErrInvalidQuote
+	}
+
+	payment.QuoteID = claims.ID
+	payment.PlanProductID = claims.PlanID
+	payment.Plan = claims.Terms
+	payment.FeeAmount = PricePlan(claims.Terms, payment.Amount).Fee
+	if claims.Start != 0 {
+		start := time.Unix(claims.Start, 0)
+		payment.ScheduleStart = &start
+	}
+	return nil
+}
+
+// signQuote encodes claims as "<base64url json>.<hex hmac>".
+func (s *PlanProductService) signQuote(claims quoteClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.quoteMAC(encoded), nil
+}
+
+func (s *PlanProductService) verifyQuote(token string, now time.Time) (*quoteClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.quoteMAC(encoded))) {
+		return nil, ErrInvalidQuote
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidQuote
+	}
+	var claims quoteClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidQuote
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrQuoteExpired
+	}
+	return &claims, nil
+}
+
+func (s *PlanProductService) quoteMAC(encoded string) string {
+	mac := hmac.New(sha256.New, s.quoteSecret)
+	mac.Write([]byte(encoded))
+	return hex.EncodeToString(mac.Sum(nil))
+}
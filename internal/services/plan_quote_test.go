@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	"go.uber.org/zap"
+)
+
+func TestHonourQuoteKeepsQuotedDueDates(t *testing.T) {
+	plans := NewPlanProductService(&fakePlanRepo{}, []byte("quote-secret"), 72*time.Hour, zap.NewNop())
+	quote, err := plans.Quote(context.Background(), QuoteRequest{Amount: 3000, Currency: "DZD"})
+	if err != nil {
+		t.Fatalf("Quote: %v", err)
+	}
+
+	// Paid two days later, still within the quote's validity
+	paidAt := quote.IssuedAt.Add(48 * time.Hour)
+	payment := &domains.Payment{Amount: 3000, Currency: "DZD"}
+	if err := plans.HonourQuote(payment, quote.Token, paidAt); err != nil {
+		t.Fatalf("HonourQuote: %v", err)
+	}
+
+	installments := BuildSchedule(paymentTerms(payment), payment.Amount, scheduleStart(payment, paidAt))
+	if len(installments) != len(quote.Installments) {
+		t.Fatalf("%d installments, quoted %d", len(installments), len(quote.Installments))
+	}
+	for i, installment := range installments {
+		if want := quote.Installments[i]; installment.DueDate != want.DueDate || installment.Amount != want.Amount {
+			t.Errorf("installment %d due %s for %d, quoted %s for %d",
+				installment.InstallmentNumber, installment.DueDate, installment.Amount, want.DueDate, want.Amount)
+		}
+	}
+}
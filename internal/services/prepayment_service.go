@@ -0,0 +1,554 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNothingToPrepay   = errors.New("payment has no unpaid installments")
+	ErrInvalidPrepayment = errors.New("invalid prepayment")
+)
+
+const (
+	// PrepaymentRefundJobName is the JobRunner name of the refund of unapplied
+	// prepayments.
+	PrepaymentRefundJobName = "prepayment-refunds"
+	// prepaymentRefundBatchSize caps how many prepayments one run refunds.
+	prepaymentRefundBatchSize = 50
+)
+
+// unpaidInstallmentStatuses are the installments a prepayment can settle.
+// DEFAULTED installments are left to the collections team.
+var unpaidInstallmentStatuses = []string{"PENDING", "FAILED", "OVERDUE"}
+
+// PrepaymentPolicy is how paying ahead of schedule is rewarded.
+type PrepaymentPolicy struct {
+	// FeeRebatePercent is the share of the plan fee of installments not yet
+	// due that is forgiven on an early payoff.
+	FeeRebatePercent int
+}
+
+// DefaultPrepaymentPolicy forgives no fee on early payoffs.
+func DefaultPrepaymentPolicy() PrepaymentPolicy {
+	return PrepaymentPolicy{}
+}
+
+// Validate checks that the rebate is a percentage.
+func (p PrepaymentPolicy) Validate() error {
+	if p.FeeRebatePercent < 0 || p.FeeRebatePercent > 100 {
+		return fmt.Errorf("prepayment fee rebate must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+// PayoffQuote is what settling a payment today would cost.
+type PayoffQuote struct {
+	PaymentID uint
+	Currency  string
+	// Remaining is what the unpaid installments add up to on schedule.
+	Remaining int
+	Rebate    int
+	// LateFees is what the charges still owed on the unpaid installments add
+	// up to. They are billed to the payoff order.
+	LateFees int
+	// PayoffAmount is Remaining less Rebate plus LateFees, charged in one
+	// order.
+	PayoffAmount int
+	Lines        []domains.PrepaymentLine
+	QuotedAt     time.Time
+
+	charges []*domains.InstallmentCharge
+}
+
+type PrepaymentServiceInterface interface {
+	QuotePayoff(ctx context.Context, userID string, paymentID uint) (*PayoffQuote, error)
+	Payoff(ctx context.Context, userID string, paymentID uint) (*domains.Prepayment, error)
+	Prepay(ctx context.Context, userID string, paymentID uint, amount int, allocation domains.PrepaymentAllocation) (*domains.Prepayment, error)
+	ConfirmPrepaymentOrder(ctx context.Context, gatewayOrderID string) (*domains.Prepayment, error)
+}
+
+// PrepaymentService lets customers pay installments ahead of schedule, either
+// settling a payment at once or prepaying part of it. Prepayments are charged
+// through the payment gateway like installments, and only change the
+// schedule once the gateway reports them paid.
+type PrepaymentService struct {
+	paymentRepo     repository.PaymentRepository
+	installmentRepo repository.InstallmentRepository
+	prepaymentRepo  repository.PrepaymentRepository
+	creditLines     *CreditLineService
+	refunds         *RefundService
+	penalties       *PenaltyService
+	ledger          *LedgerService
+	merchantEvents  *MerchantEventService
+	paymentGateway  PaymentGateway
+	txManager       *utils.TransactionManager
+	policy          PrepaymentPolicy
+	logger          *zap.Logger
+}
+
+func NewPrepaymentService(
+	paymentRepo repository.PaymentRepository,
+	installmentRepo repository.InstallmentRepository,
+	prepaymentRepo repository.PrepaymentRepository,
+	creditLines *CreditLineService,
+	refunds *RefundService,
+	penalties *PenaltyService,
+	ledger *LedgerService,
+	merchantEvents *MerchantEventService,
+	paymentGateway PaymentGateway,
+	txManager *utils.TransactionManager,
+	policy PrepaymentPolicy,
+	logger *zap.Logger,
+) *PrepaymentService {
+	return &PrepaymentService{
+		paymentRepo:     paymentRepo,
+		installmentRepo: installmentRepo,
+		prepaymentRepo:  prepaymentRepo,
+		creditLines:     creditLines,
+		refunds:         refunds,
+		penalties:       penalties,
+		ledger:          ledger,
+		merchantEvents:  merchantEvents,
+		paymentGateway:  paymentGateway,
+		txManager:       txManager,
+		policy:          policy,
+		logger:          logger,
+	}
+}
+
+// QuotePayoff returns what settling every unpaid installment of a payment of
+// userID costs today. The configured share of the plan fee of installments not yet
+// due is rebated. Late fees still owed on the installments are added.
+func (s *PrepaymentService) QuotePayoff(ctx context.Context, userID string, paymentID uint) (*PayoffQuote, error) {
+	payment, installments, err := s.unpaidInstallments(ctx, userID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	today := now.Format(installmentDateLayout)
+	quote := &PayoffQuote{
+		PaymentID: payment.ID,
+		Currency:  payment.Currency,
+		Lines:     make([]domains.PrepaymentLine, len(installments)),
+		QuotedAt:  now,
+	}
+	for i, installment := range installments {
+		rebate := 0
+		if dueOn, err := installment.DueOn(); err == nil && dueOn.Format(installmentDateLayout) > today {
+			rebate = installment.Fee * s.policy.FeeRebatePercent / 100
+		}
+		quote.Lines[i] = domains.PrepaymentLine{
+			InstallmentID: installment.ID,
+			Principal:     installment.Principal,
+			Fee:           installment.Fee - rebate,
+			Rebate:        rebate,
+		}
+		quote.Remaining += installment.Amount
+		quote.Rebate += rebate
+	}
+	quote.charges, quote.LateFees, err = s.outstandingCharges(ctx, installments)
+	if err != nil {
+		return nil, err
+	}
+	quote.PayoffAmount = quote.Remaining - quote.Rebate + quote.LateFees
+	return quote, nil
+}
+
+// Payoff registers a gateway order settling every unpaid installment of a
+// payment of userID at the quoted payoff amount.
+func (s *PrepaymentService) Payoff(ctx context.Context, userID string, paymentID uint) (*domains.Prepayment, error) {
+	quote, err := s.QuotePayoff(ctx, userID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.registerPrepayment(ctx, paymentID, &domains.Prepayment{
+		Type:     domains.PrepaymentPayoff,
+		Amount:   quote.PayoffAmount,
+		Rebate:   quote.Rebate,
+		LateFees: quote.LateFees,
+		Lines:    quote.Lines,
+	}, quote.charges)
+}
+
+// Prepay registers a gateway order paying amount towards the unpaid
+// installments of a payment of userID, spread as allocation says. Each installment's
+// principal is paid before its fee. Paying the whole balance is a payoff, and
+// so is paying while late fees are owed, as they are only collected with the
+// whole balance.
+func (s *PrepaymentService) Prepay(ctx context.Context, userID string, paymentID uint, amount int, allocation domains.PrepaymentAllocation) (*domains.Prepayment, error) {
+	if !allocation.Valid() {
+		return nil, fmt.Errorf("%w: unknown allocation %q", ErrInvalidPrepayment, allocation)
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	_, installments, err := s.unpaidInstallments(ctx, userID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := 0
+	for _, installment := range installments {
+		remaining += installment.Amount
+	}
+	if _, lateFees, err := s.outstandingCharges(ctx, installments); err != nil {
+		return nil, err
+	} else if lateFees > 0 {
+		return nil, fmt.Errorf("%w: late fees of %d are owed, use a payoff instead", ErrInvalidPrepayment, lateFees)
+	}
+	if amount >= remaining {
+		return nil, fmt.Errorf("%w: amount covers the remaining balance of %d, use a payoff instead", ErrInvalidPrepayment, remaining)
+	}
+
+	var shares []int
+	if allocation == domains.AllocationProRata {
+		shares = proRataShares(installments, amount, remaining)
+	} else {
+		shares = nextShares(installments, amount)
+	}
+
+	var lines []domains.PrepaymentLine
+	for i, installment := range installments {
+		if shares[i] == 0 {
+			continue
+		}
+		principal := shares[i]
+		if principal > installment.Principal {
+			principal = installment.Principal
+		}
+		lines = append(lines, domains.PrepaymentLine{
+			InstallmentID: installment.ID,
+			Principal:     principal,
+			Fee:           shares[i] - principal,
+		})
+	}
+
+	return s.registerPrepayment(ctx, paymentID, &domains.Prepayment{
+		Type:       domains.PrepaymentPartial,
+		Allocation: allocation,
+		Amount:     amount,
+		Lines:      lines,
+	}, nil)
+}
+
+// nextShares pays installments in due date order until amount runs out.
+func nextShares(installments []*domains.Installment, amount int) []int {
+	shares := make([]int, len(installments))
+	for i, installment := range installments {
+		share := installment.Amount
+		if share > amount {
+			share = amount
+		}
+		shares[i] = share
+		amount -= share
+	}
+	return shares
+}
+
+// proRataShares spreads amount over installments in proportion to what they
+// owe. As amount is below remaining, every rounded down share is below what
+// its installment owes, so the leftover units can go to the last ones.
+func proRataShares(installments []*domains.Installment, amount, remaining int) []int {
+	shares := make([]int, len(installments))
+	leftover := amount
+	for i, installment := range installments {
+		shares[i] = amount * installment.Amount / remaining
+		leftover -= shares[i]
+	}
+	for i := len(shares) - leftover; i < len(shares); i++ {
+		shares[i]++
+	}
+	return shares
+}
+
+// registerPrepayment registers the gateway order of prepayment and stores it
+// with the URL where the customer pays it. charges are billed to the order.
+func (s *PrepaymentService) registerPrepayment(ctx context.Context, paymentID uint, prepayment *domains.Prepayment, charges []*domains.InstallmentCharge) (*domains.Prepayment, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	order, err := s.paymentGateway.RegisterOrder(ctx, GatewayOrderRequest{
+		OrderNumber: uuid.Must(uuid.NewV4()).String(),
+		Amount:      prepayment.Amount,
+		Currency:    payment.Currency,
+		ReturnPath:  PrepaymentReturnPath,
+		Description: fmt.Sprintf("Prepayment of payment %d", payment.ID),
+	})
+	if err != nil {
+		s.logger.Error("Failed to register prepayment with gateway", zap.Uint("paymentID", payment.ID), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", ErrPaymentFailed, err)
+	}
+
+	prepayment.PaymentID = payment.ID
+	prepayment.UserID = payment.UserID
+	prepayment.Currency = payment.Currency
+	prepayment.Status = domains.PrepaymentPending
+	prepayment.GatewayOrderID = order.OrderID
+	prepayment.RedirectURL = order.RedirectURL
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.prepaymentRepo.Create(txCtx, prepayment); err != nil {
+			return err
+		}
+		return s.penalties.BillCharges(txCtx, charges, order.OrderID)
+	})
+	if err != nil {
+		s.logger.Error("Failed to create prepayment", zap.Uint("paymentID", payment.ID), zap.Error(err))
+		return nil, fmt.Errorf("failed to create prepayment: %w", err)
+	}
+
+	s.logger.Info("Prepayment registered",
+		zap.Uint("prepaymentID", prepayment.ID), zap.Uint("paymentID", payment.ID),
+		zap.String("type", string(prepayment.Type)), zap.Int("amount", prepayment.Amount))
+	return prepayment, nil
+}
+
+// ConfirmPrepaymentOrder is called when the gateway sends the customer back
+// after paying a prepayment. A paid prepayment is applied to its installments
+// and their principal is given back to the credit line.
+func (s *PrepaymentService) ConfirmPrepaymentOrder(ctx context.Context, gatewayOrderID string) (*domains.Prepayment, error) {
+	prepayment, err := s.prepaymentRepo.GetByGatewayOrderID(ctx, gatewayOrderID)
+	if err != nil {
+		s.logger.Error("Failed to get prepayment", zap.String("gatewayOrderID", gatewayOrderID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get prepayment: %w", err)
+	}
+	if prepayment.Status != domains.PrepaymentPending {
+		return prepayment, nil
+	}
+
+	result, err := s.paymentGateway.ConfirmOrder(ctx, gatewayOrderID)
+	if err != nil {
+		s.logger.Error("Failed to confirm prepayment with gateway", zap.Uint("prepaymentID", prepayment.ID), zap.Error(err))
+		if errors.Is(err, ErrGatewayTimeout) {
+			return nil, ErrGatewayTimeout
+		}
+		return nil, fmt.Errorf("failed to confirm prepayment with gateway: %w", err)
+	}
+
+	switch result.Status {
+	case GatewayOrderPaid:
+		err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+			return s.applyPrepayment(txCtx, prepayment)
+		})
+	case GatewayOrderDeclined:
+		s.logger.Info("Prepayment declined by gateway", zap.Uint("prepaymentID", prepayment.ID), zap.String("reason", result.Reason))
+		err = s.prepaymentRepo.UpdateStatus(ctx, prepayment.ID, domains.PrepaymentFailed, domains.PrepaymentPending)
+		if err == nil {
+			prepayment.Status = domains.PrepaymentFailed
+		}
+	default:
+		return prepayment, nil
+	}
+
+	var transitionErr *utils.ErrInvalidTransition
+	if errors.As(err, &transitionErr) {
+		s.logger.Info("Prepayment already confirmed", zap.Uint("prepaymentID", prepayment.ID))
+		return s.prepaymentRepo.GetByGatewayOrderID(ctx, gatewayOrderID)
+	}
+	if err != nil {
+		s.logger.Error("Failed to record prepayment outcome", zap.Uint("prepaymentID", prepayment.ID), zap.Error(err))
+		return nil, fmt.Errorf("failed to record prepayment outcome: %w", err)
+	}
+	return prepayment, nil
+}
+
+// applyPrepayment marks prepayment PAID, applies its lines and settles the
+// late fees billed to it. Lines whose installment was settled meanwhile, and
+// late fees collected or waived meanwhile, are kept as unapplied.
+func (s *PrepaymentService) applyPrepayment(ctx context.Context, prepayment *domains.Prepayment) error {
+	if err := s.prepaymentRepo.UpdateStatus(ctx, prepayment.ID, domains.PrepaymentPaid, domains.PrepaymentPending); err != nil {
+		return err
+	}
+
+	unapplied, restored := 0, 0
+	var applied []domains.PrepaymentLine
+	for _, line := range prepayment.Lines {
+		err := s.installmentRepo.ApplyPrepayment(ctx, line)
+		var transitionErr *utils.ErrInvalidTransition
+		if errors.As(err, &transitionErr) {
+			s.logger.Warn("Prepayment line not applied", zap.Uint("prepaymentID", prepayment.ID), zap.Uint("installmentID", line.InstallmentID))
+			unapplied += line.Amount()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		restored += line.Principal
+		applied = append(applied, line)
+	}
+
+	if prepayment.LateFees > 0 {
+		fees, err := s.penalties.SettleCharges(ctx, prepayment.GatewayOrderID)
+		if err != nil {
+			return err
+		}
+		unapplied += prepayment.LateFees - fees
+	}
+
+	// Only the principal was drawn from the credit line
+	if restored > 0 {
+		if err := s.creditLines.Restore(ctx, prepayment.UserID, restored); err != nil {
+			return err
+		}
+	}
+
+	prepayment.Status = domains.PrepaymentPaid
+	prepayment.Unapplied = unapplied
+	if err := s.prepaymentRepo.Update(ctx, prepayment); err != nil {
+		return err
+	}
+
+	// Unapplied money is refunded to the card by RefundUnapplied, so it is
+	// neither posted nor receipted here
+	if err := s.ledger.PostPrepaymentCollection(ctx, prepayment, applied); err != nil {
+		return err
+	}
+	if received := prepayment.Amount - unapplied; received > 0 {
+		prepaymentID := prepayment.ID
+		err := s.refunds.RecordReceipt(ctx, &domains.PaymentReceipt{
+			PaymentID:      prepayment.PaymentID,
+			PrepaymentID:   &prepaymentID,
+			GatewayOrderID: prepayment.GatewayOrderID,
+			Amount:         received,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.reportPaidInstallments(ctx, prepayment, applied); err != nil {
+		return err
+	}
+
+	s.logger.Info("Prepayment applied",
+		zap.Uint("prepaymentID", prepayment.ID), zap.Int("amount", prepayment.Amount), zap.Int("unapplied", unapplied))
+	return nil
+}
+
+// RefundUnapplied refunds the unapplied part of paid prepayments to the card
+// they were paid with. Refunds the gateway fails or times out on are tried
+// again on the next run.
+func (s *PrepaymentService) RefundUnapplied(ctx context.Context) error {
+	prepayments, err := s.prepaymentRepo.ListUnrefunded(ctx, prepaymentRefundBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list unrefunded prepayments", zap.Error(err))
+		return fmt.Errorf("failed to list unrefunded prepayments: %w", err)
+	}
+
+	failed := 0
+	for _, prepayment := range prepayments {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.paymentGateway.RefundOrder(ctx, prepayment.GatewayOrderID, prepayment.Unapplied); err != nil {
+			s.logger.Error("Failed to refund unapplied prepayment",
+				zap.Uint("prepaymentID", prepayment.ID), zap.Int("unapplied", prepayment.Unapplied), zap.Error(err))
+			failed++
+			continue
+		}
+		if err := s.prepaymentRepo.MarkUnappliedRefunded(ctx, prepayment.ID, time.Now()); err != nil {
+			s.logger.Error("Failed to record unapplied prepayment refund", zap.Uint("prepaymentID", prepayment.ID), zap.Error(err))
+			failed++
+			continue
+		}
+		s.logger.Info("Unapplied prepayment refunded", zap.Uint("prepaymentID", prepayment.ID), zap.Int("amount", prepayment.Unapplied))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d unapplied prepayment refunds failed", failed, len(prepayments))
+	}
+	return nil
+}
+
+// reportPaidInstallments tells the merchant about the installments applied
+// lines paid off.
+func (s *PrepaymentService) reportPaidInstallments(ctx context.Context, prepayment *domains.Prepayment, applied []domains.PrepaymentLine) error {
+	if len(applied) == 0 {
+		return nil
+	}
+	payment, err := s.paymentRepo.GetByID(ctx, prepayment.PaymentID)
+	if err != nil {
+		return err
+	}
+	if payment.MerchantID == 0 {
+		return nil
+	}
+
+	for _, line := range applied {
+		installment, err := s.installmentRepo.GetByID(ctx, line.InstallmentID)
+		if err != nil {
+			return err
+		}
+		if installment.Status != "PAID" {
+			continue
+		}
+		if err := s.merchantEvents.InstallmentPaid(ctx, payment, installment, line.Amount()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outstandingCharges returns the charges still owed on installments and what
+// they add up to.
+func (s *PrepaymentService) outstandingCharges(ctx context.Context, installments []*domains.Installment) ([]*domains.InstallmentCharge, int, error) {
+	var charges []*domains.InstallmentCharge
+	total := 0
+	for _, installment := range installments {
+		owed, fees, err := s.penalties.BillableCharges(ctx, installment.ID)
+		if err != nil {
+			return nil, 0, err
+		}
+		charges = append(charges, owed...)
+		total += fees
+	}
+	return charges, total, nil
+}
+
+// unpaidInstallments returns a payment of userID with its unpaid installments
+// in due date order, failing with ErrNothingToPrepay if there are none. Other
+// users' payments are not found.
+func (s *PrepaymentService) unpaidInstallments(ctx context.Context, userID string, paymentID uint) (*domains.Payment, []*domains.Installment, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	if payment.UserID != userID {
+		return nil, nil, &utils.ErrNotFound{Entity: "Payment", ID: paymentID}
+	}
+	all, err := s.installmentRepo.GetByPaymentID(ctx, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to get installments for payment", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to get installments for payment: %w", err)
+	}
+
+	var unpaid []*domains.Installment
+	for _, installment := range all {
+		for _, status := range unpaidInstallmentStatuses {
+			if installment.Status == status && installment.Amount > 0 {
+				unpaid = append(unpaid, installment)
+				break
+			}
+		}
+	}
+	if len(unpaid) == 0 {
+		return nil, nil, ErrNothingToPrepay
+	}
+	sort.Slice(unpaid, func(i, j int) bool {
+		return unpaid[i].InstallmentNumber < unpaid[j].InstallmentNumber
+	})
+	return payment, unpaid, nil
+}
@@ -0,0 +1,557 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidRefund = errors.New("invalid refund")
+
+const (
+	// OverpaymentRefundJobName is the JobRunner name of the refund of
+	// overpayments.
+	OverpaymentRefundJobName = "overpayment-refunds"
+	// overpaymentRefundBatchSize caps how many overpayments one run refunds.
+	overpaymentRefundBatchSize = 50
+)
+
+// refundableInstallmentStatuses are the installments a refund can lower.
+var refundableInstallmentStatuses = []string{"PENDING", "FAILED", "OVERDUE", "DEFAULTED"}
+
+type RefundServiceInterface interface {
+	CreateRefund(ctx context.Context, paymentID uint, amount int, reason, requestedBy string) (*domains.Refund, error)
+	GetRefund(ctx context.Context, id uint) (*domains.Refund, error)
+	ListRefunds(ctx context.Context, paymentID uint) ([]*domains.Refund, error)
+	ListUserRefunds(ctx context.Context, userID string, paymentID uint) ([]*domains.Refund, error)
+	ListRefundsByStatus(ctx context.Context, status domains.RefundStatus, offset, limit int) ([]*domains.Refund, int, error)
+	RetryCardRefunds(ctx context.Context, id uint) (*domains.Refund, error)
+	HandleRefundWebhook(ctx context.Context, refundID uint, status string) error
+}
+
+// RefundService refunds returned purchases. It keeps the receipts of the card
+// payments made towards each purchase, so that money already paid can be
+// refunded through the gateway orders that took it.
+type RefundService struct {
+	paymentRepo     repository.PaymentRepository
+	installmentRepo repository.InstallmentRepository
+	receiptRepo     repository.PaymentReceiptRepository
+	refundRepo      repository.RefundRepository
+	creditLines     *CreditLineService
+	ledger          *LedgerService
+	merchantEvents  *MerchantEventService
+	paymentGateway  PaymentGateway
+	txManager       *utils.TransactionManager
+	logger          *zap.Logger
+}
+
+func NewRefundService(
+	paymentRepo repository.PaymentRepository,
+	installmentRepo repository.InstallmentRepository,
+	receiptRepo repository.PaymentReceiptRepository,
+	refundRepo repository.RefundRepository,
+	creditLines *CreditLineService,
+	ledger *LedgerService,
+	merchantEvents *MerchantEventService,
+	paymentGateway PaymentGateway,
+	txManager *utils.TransactionManager,
+	logger *zap.Logger,
+) *RefundService {
+	return &RefundService{
+		paymentRepo:     paymentRepo,
+		installmentRepo: installmentRepo,
+		receiptRepo:     receiptRepo,
+		refundRepo:      refundRepo,
+		creditLines:     creditLines,
+		ledger:          ledger,
+		merchantEvents:  merchantEvents,
+		paymentGateway:  paymentGateway,
+		txManager:       txManager,
+		logger:          logger,
+	}
+}
+
+// RecordReceipt records money taken from the customer's card towards a
+// payment. A gateway order already recorded is ignored.
+func (s *RefundService) RecordReceipt(ctx context.Context, receipt *domains.PaymentReceipt) error {
+	if receipt.ReceivedAt.IsZero() {
+		receipt.ReceivedAt = time.Now()
+	}
+	err := s.receiptRepo.Create(ctx, receipt)
+	var duplicateErr *utils.ErrDuplicateEntry
+	if errors.As(err, &duplicateErr) {
+		s.logger.Info("Payment receipt already recorded", zap.String("gatewayOrderID", receipt.GatewayOrderID))
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to record payment receipt", zap.Uint("paymentID", receipt.PaymentID), zap.Error(err))
+		return fmt.Errorf("failed to record payment receipt: %w", err)
+	}
+	return nil
+}
+
+// RecordOverpayment records money a gateway order took that the customer did
+// not owe, for RefundOverpayments to give back to the card. A gateway order
+// already recorded, such as the one that paid the installment, is ignored.
+func (s *RefundService) RecordOverpayment(ctx context.Context, receipt *domains.PaymentReceipt) error {
+	receipt.Overpayment = true
+	if receipt.ReceivedAt.IsZero() {
+		receipt.ReceivedAt = time.Now()
+	}
+	err := s.receiptRepo.Create(ctx, receipt)
+	var duplicateErr *utils.ErrDuplicateEntry
+	if errors.As(err, &duplicateErr) {
+		s.logger.Info("Payment receipt already recorded", zap.String("gatewayOrderID", receipt.GatewayOrderID))
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to record overpayment", zap.Uint("paymentID", receipt.PaymentID), zap.Error(err))
+		return fmt.Errorf("failed to record overpayment: %w", err)
+	}
+
+	payment, err := s.paymentRepo.GetByID(ctx, receipt.PaymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Uint("paymentID", receipt.PaymentID), zap.Error(err))
+		return fmt.Errorf("failed to get payment: %w", err)
+	}
+	if err := s.ledger.PostOverpayment(ctx, payment, receipt); err != nil {
+		return err
+	}
+
+	s.logger.Warn("Overpayment recorded",
+		zap.Uint("paymentID", receipt.PaymentID), zap.String("gatewayOrderID", receipt.GatewayOrderID), zap.Int("amount", receipt.Amount))
+	return nil
+}
+
+// RefundOverpayments refunds recorded overpayments to the card that paid
+// them. Refunds the gateway fails or times out on are tried again on the
+// next run.
+func (s *RefundService) RefundOverpayments(ctx context.Context) error {
+	receipts, err := s.receiptRepo.ListUnrefundedOverpayments(ctx, overpaymentRefundBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list unrefunded overpayments", zap.Error(err))
+		return fmt.Errorf("failed to list unrefunded overpayments: %w", err)
+	}
+
+	failed := 0
+	for _, receipt := range receipts {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		amount := receipt.Refundable()
+		if err := s.paymentGateway.RefundOrder(ctx, receipt.GatewayOrderID, amount); err != nil {
+			s.logger.Error("Failed to refund overpayment",
+				zap.Uint("receiptID", receipt.ID), zap.Int("amount", amount), zap.Error(err))
+			failed++
+			continue
+		}
+		err := s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+			if err := s.receiptRepo.AddRefunded(txCtx, receipt.ID, amount); err != nil {
+				return err
+			}
+			payment, err := s.paymentRepo.GetByID(txCtx, receipt.PaymentID)
+			if err != nil {
+				return err
+			}
+			return s.ledger.PostOverpaymentRefund(txCtx, payment, receipt, amount)
+		})
+		if err != nil {
+			s.logger.Error("Failed to record overpayment refund", zap.Uint("receiptID", receipt.ID), zap.Error(err))
+			failed++
+			continue
+		}
+		s.logger.Info("Overpayment refunded", zap.Uint("receiptID", receipt.ID), zap.Int("amount", amount))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d overpayment refunds failed", failed, len(receipts))
+	}
+	return nil
+}
+
+// CreateRefund refunds amount of a successful payment's purchase, with the
+// share of the plan fee that goes with it. Unpaid installments are lowered
+// or cancelled first, latest first, and the credit they drew is restored;
+// what was already paid beyond that is refunded to the card. The schedule
+// changes commit before the card refunds are sent to the gateway.
+func (s *RefundService) CreateRefund(ctx context.Context, paymentID uint, amount int, reason, requestedBy string) (*domains.Refund, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidRefund)
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	refund, err := s.planRefund(ctx, paymentID, amount)
+	if err != nil {
+		return nil, err
+	}
+	refund.Reason = reason
+	refund.RequestedBy = requestedBy
+
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		return s.applySchedule(txCtx, refund)
+	})
+	if err != nil {
+		s.logger.Error("Failed to apply refund", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to apply refund: %w", err)
+	}
+	s.logger.Info("Refund created",
+		zap.Uint("refundID", refund.ID), zap.Uint("paymentID", paymentID), zap.Int("amount", amount),
+		zap.Int("scheduleReduction", refund.ScheduleReduction), zap.Int("cardRefund", refund.CardRefund))
+
+	if refund.Status == domains.RefundPending {
+		s.refundCard(ctx, refund)
+	}
+	return refund, nil
+}
+
+// planRefund works out how a refund of amount splits between the schedule
+// and the card.
+func (s *RefundService) planRefund(ctx context.Context, paymentID uint, amount int) (*domains.Refund, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	if payment.Status != "SUCCESSFUL" {
+		return nil, fmt.Errorf("%w: payment is %s", ErrInvalidRefund, payment.Status)
+	}
+	refundable := payment.Amount - payment.RefundedAmount
+	if amount > refundable {
+		return nil, fmt.Errorf("%w: only %d is left to refund", ErrInvalidRefund, refundable)
+	}
+
+	installments, err := s.installmentRepo.GetByPaymentID(ctx, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to get installments for payment", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get installments for payment: %w", err)
+	}
+	previous, err := s.ListRefunds(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The fee left to forgive is what the schedule still carries, less the
+	// fee earlier refunds gave back to the card
+	feeRemaining := 0
+	for _, installment := range installments {
+		feeRemaining += installment.Fee
+	}
+	for _, earlier := range previous {
+		feeRemaining -= earlier.FeeReduction
+		for _, line := range earlier.Lines {
+			feeRemaining += line.Fee
+		}
+	}
+	feeReduction := feeRemaining
+	if amount < refundable {
+		feeReduction = feeRemaining * amount / refundable
+	}
+	if feeReduction < 0 {
+		feeReduction = 0
+	}
+
+	refund := &domains.Refund{
+		PaymentID:    payment.ID,
+		UserID:       payment.UserID,
+		Amount:       amount,
+		FeeReduction: feeReduction,
+		Currency:     payment.Currency,
+	}
+
+	// Lower the latest unpaid installments first
+	sort.Slice(installments, func(i, j int) bool {
+		return installments[i].InstallmentNumber > installments[j].InstallmentNumber
+	})
+	principalLeft, feeLeft := amount, feeReduction
+	for _, installment := range installments {
+		if !containsStatus(refundableInstallmentStatuses, installment.Status) {
+			continue
+		}
+		principal := min(principalLeft, installment.Principal)
+		fee := min(feeLeft, installment.Fee)
+		if principal+fee == 0 {
+			continue
+		}
+		refund.Lines = append(refund.Lines, domains.RefundLine{
+			Kind:          domains.RefundLineSchedule,
+			InstallmentID: installment.ID,
+			Principal:     principal,
+			Fee:           fee,
+			Amount:        principal + fee,
+			Status:        domains.RefundSucceeded,
+			WrittenOff:    installment.Status == "DEFAULTED",
+		})
+		refund.ScheduleReduction += principal + fee
+		principalLeft -= principal
+		feeLeft -= fee
+	}
+
+	// Refund the rest to the card, from the latest receipts first
+	cardLeft := principalLeft + feeLeft
+	if cardLeft > 0 {
+		receipts, err := s.receiptRepo.ListByPaymentID(ctx, paymentID)
+		if err != nil {
+			s.logger.Error("Failed to list payment receipts", zap.Uint("paymentID", paymentID), zap.Error(err))
+			return nil, fmt.Errorf("failed to list payment receipts: %w", err)
+		}
+		for i := len(receipts) - 1; i >= 0 && cardLeft > 0; i-- {
+			receipt := receipts[i]
+			if receipt.Overpayment {
+				continue
+			}
+			refundAmount := min(cardLeft, receipt.Refundable())
+			if refundAmount == 0 {
+				continue
+			}
+			refund.Lines = append(refund.Lines, domains.RefundLine{
+				Kind:           domains.RefundLineCard,
+				ReceiptID:      receipt.ID,
+				GatewayOrderID: receipt.GatewayOrderID,
+				Amount:         refundAmount,
+				Status:         domains.RefundPending,
+			})
+			refund.CardRefund += refundAmount
+			cardLeft -= refundAmount
+		}
+	}
+	if cardLeft > 0 {
+		s.logger.Warn("Card payments on record do not cover refund", zap.Uint("paymentID", paymentID), zap.Int("missing", cardLeft))
+		return nil, fmt.Errorf("%w: card payments on record do not cover %d of the refund", ErrInvalidRefund, cardLeft)
+	}
+
+	refund.Status = domains.RefundPending
+	if refund.CardRefund == 0 {
+		now := time.Now()
+		refund.Status = domains.RefundSucceeded
+		refund.CompletedAt = &now
+	}
+	return refund, nil
+}
+
+// applySchedule records the refund on the payment, lowers the installments,
+// restores the credit they drew, sets the card refunds aside on their
+// receipts, posts the refund to the ledger and tells the merchant.
+func (s *RefundService) applySchedule(ctx context.Context, refund *domains.Refund) error {
+	if err := s.paymentRepo.AddRefunded(ctx, refund.PaymentID, refund.Amount); err != nil {
+		return err
+	}
+
+	restored := 0
+	for _, line := range refund.Lines {
+		switch line.Kind {
+		case domains.RefundLineSchedule:
+			if err := s.installmentRepo.ApplyRefund(ctx, line); err != nil {
+				return err
+			}
+			restored += line.Principal
+		case domains.RefundLineCard:
+			if err := s.receiptRepo.AddRefunded(ctx, line.ReceiptID, line.Amount); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Only the principal of unpaid installments is still drawn from the
+	// credit line; paid principal was restored when it was paid
+	if restored > 0 {
+		if err := s.creditLines.Restore(ctx, refund.UserID, restored); err != nil {
+			return err
+		}
+	}
+
+	if err := s.refundRepo.Create(ctx, refund); err != nil {
+		return err
+	}
+	if err := s.ledger.PostRefund(ctx, refund); err != nil {
+		return err
+	}
+
+	payment, err := s.paymentRepo.GetByID(ctx, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+	return s.merchantEvents.PaymentRefunded(ctx, payment, refund)
+}
+
+// refundCard sends the card refunds of a refund to the gateway. Timed out
+// refunds stay pending until the gateway's webhook reports their outcome.
+func (s *RefundService) refundCard(ctx context.Context, refund *domains.Refund) {
+	for i := range refund.Lines {
+		line := &refund.Lines[i]
+		if line.Kind != domains.RefundLineCard || line.Status != domains.RefundPending {
+			continue
+		}
+		err := s.paymentGateway.RefundOrder(ctx, line.GatewayOrderID, line.Amount)
+		switch {
+		case err == nil:
+			line.Status = domains.RefundSucceeded
+		case errors.Is(err, ErrGatewayTimeout):
+			s.logger.Warn("Card refund timed out", zap.Uint("refundID", refund.ID), zap.String("gatewayOrderID", line.GatewayOrderID))
+		default:
+			line.Status = domains.RefundFailed
+			line.Error = err.Error()
+		}
+	}
+
+	s.settle(refund)
+	if err := s.refundRepo.Update(ctx, refund); err != nil {
+		s.logger.Error("Failed to save card refund outcome", zap.Uint("refundID", refund.ID), zap.Error(err))
+	}
+}
+
+// settle derives the status of a refund from its card refunds.
+func (s *RefundService) settle(refund *domains.Refund) {
+	status := domains.RefundSucceeded
+	for _, line := range refund.Lines {
+		if line.Kind != domains.RefundLineCard {
+			continue
+		}
+		if line.Status == domains.RefundFailed {
+			status = domains.RefundFailed
+			break
+		}
+		if line.Status == domains.RefundPending {
+			status = domains.RefundPending
+		}
+	}
+
+	refund.Status = status
+	if status != domains.RefundPending {
+		now := time.Now()
+		refund.CompletedAt = &now
+		s.logger.Info("Refund completed", zap.Uint("refundID", refund.ID), zap.String("status", string(status)))
+	}
+}
+
+// RetryCardRefunds sends the card refunds the gateway refused for a FAILED
+// refund again. What the refund already changed on the schedule, receipts
+// and ledger stands, so only the card refunds are repeated.
+func (s *RefundService) RetryCardRefunds(ctx context.Context, id uint) (*domains.Refund, error) {
+	refund, err := s.GetRefund(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refundRepo.UpdateStatus(ctx, refund.ID, domains.RefundPending, domains.RefundFailed); err != nil {
+		s.logger.Error("Failed to reopen refund", zap.Uint("refundID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to reopen refund: %w", err)
+	}
+	for i := range refund.Lines {
+		line := &refund.Lines[i]
+		if line.Kind == domains.RefundLineCard && line.Status == domains.RefundFailed {
+			line.Status = domains.RefundPending
+			line.Error = ""
+		}
+	}
+	refund.Status = domains.RefundPending
+	refund.CompletedAt = nil
+
+	s.logger.Info("Retrying card refunds", zap.Uint("refundID", id))
+	s.refundCard(ctx, refund)
+	return refund, nil
+}
+
+// HandleRefundWebhook applies the gateway's outcome, SUCCEEDED or FAILED, to
+// the card refunds of a refund still pending.
+func (s *RefundService) HandleRefundWebhook(ctx context.Context, refundID uint, status string) error {
+	outcome := domains.RefundStatus(status)
+	if outcome != domains.RefundSucceeded && outcome != domains.RefundFailed {
+		return fmt.Errorf("%w: unknown refund status %q", ErrInvalidWebhookPayload, status)
+	}
+
+	refund, err := s.GetRefund(ctx, refundID)
+	if err != nil {
+		return err
+	}
+	if refund.Status != domains.RefundPending {
+		s.logger.Info("Ignoring webhook for completed refund", zap.Uint("refundID", refundID), zap.String("status", string(refund.Status)))
+		return nil
+	}
+
+	for i := range refund.Lines {
+		if refund.Lines[i].Kind == domains.RefundLineCard && refund.Lines[i].Status == domains.RefundPending {
+			refund.Lines[i].Status = outcome
+		}
+	}
+	s.settle(refund)
+
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.refundRepo.UpdateStatus(txCtx, refund.ID, refund.Status, domains.RefundPending); err != nil {
+			return err
+		}
+		return s.refundRepo.Update(txCtx, refund)
+	})
+	var transitionErr *utils.ErrInvalidTransition
+	if errors.As(err, &transitionErr) {
+		s.logger.Info("Refund was completed concurrently", zap.Uint("refundID", refundID))
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to update refund", zap.Uint("refundID", refundID), zap.Error(err))
+		return fmt.Errorf("failed to update refund: %w", err)
+	}
+	return nil
+}
+
+func (s *RefundService) GetRefund(ctx context.Context, id uint) (*domains.Refund, error) {
+	refund, err := s.refundRepo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get refund", zap.Uint("refundID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get refund: %w", err)
+	}
+	return refund, nil
+}
+
+func (s *RefundService) ListRefunds(ctx context.Context, paymentID uint) ([]*domains.Refund, error) {
+	refunds, err := s.refundRepo.ListByPaymentID(ctx, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to list refunds", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to list refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+// ListUserRefunds lists the refunds of a payment of userID. Other users'
+// payments are not found.
+func (s *RefundService) ListUserRefunds(ctx context.Context, userID string, paymentID uint) ([]*domains.Refund, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.Uint("paymentID", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	if payment.UserID != userID {
+		return nil, &utils.ErrNotFound{Entity: "Payment", ID: paymentID}
+	}
+	return s.ListRefunds(ctx, paymentID)
+}
+
+// ListRefundsByStatus lists refunds for admins, e.g. the FAILED ones whose
+// card refunds need retrying.
+func (s *RefundService) ListRefundsByStatus(ctx context.Context, status domains.RefundStatus, offset, limit int) ([]*domains.Refund, int, error) {
+	refunds, total, err := s.refundRepo.List(ctx, status, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list refunds", zap.String("status", string(status)), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list refunds: %w", err)
+	}
+	return refunds, total, nil
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, candidate := range statuses {
+		if candidate == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrManualReviewPending   = errors.New("credit application is waiting for manual review")
+	ErrReviewNotClaimed      = errors.New("review must be claimed by the reviewer before it is decided")
+	ErrInvalidApprovedAmount = errors.New("approved amount must be positive and not exceed the requested amount")
+	ErrInvalidDeclineReason  = errors.New("unknown decline reason code")
+)
+
+// UnderwritingDecision is the automatic outcome for a credit score.
+type UnderwritingDecision string
+
+const (
+	DecisionApprove UnderwritingDecision = "APPROVE"
+	DecisionReview  UnderwritingDecision = "REVIEW"
+	DecisionReject  UnderwritingDecision = "REJECT"
+)
+
+// UnderwritingPolicy splits credit scores into automatic rejections, a grey
+// zone sent to manual review, and automatic approvals.
+type UnderwritingPolicy struct {
+	// ReviewMinScore is the lowest score sent to manual review; anything
+	// below it is rejected.
+	ReviewMinScore int
+	// ApproveMinScore is the lowest score approved automatically.
+	ApproveMinScore int
+}
+
+func DefaultUnderwritingPolicy() UnderwritingPolicy {
+	return UnderwritingPolicy{
+		ReviewMinScore:  620,
+		ApproveMinScore: 680,
+	}
+}
+
+// Validate checks that the grey zone lies within the credit score range.
+func (p UnderwritingPolicy) Validate() error {
+	if p.ReviewMinScore < utils.PoorScore.Min || p.ApproveMinScore > utils.ExcellentScore.Max {
+		return fmt.Errorf("underwriting scores must be between %d and %d", utils.PoorScore.Min, utils.ExcellentScore.Max)
+	}
+	if p.ApproveMinScore < p.ReviewMinScore {
+		return fmt.Errorf("underwriting approval score must not be below the review score")
+	}
+	return nil
+}
+
+func (p UnderwritingPolicy) Decide(score int) UnderwritingDecision {
+	switch {
+	case score >= p.ApproveMinScore:
+		return DecisionApprove
+	case score >= p.ReviewMinScore:
+		return DecisionReview
+	default:
+		return DecisionReject
+	}
+}
+
+type ReviewServiceInterface interface {
+	ListReviews(ctx context.Context, status domains.ManualReviewStatus, offset, limit int) ([]*domains.ManualReview, int, error)
+	ClaimReview(ctx context.Context, id uint, reviewerID string) (*domains.ManualReview, error)
+	ApproveReview(ctx context.Context, id uint, reviewerID, reason string, approvedAmount int) (*domains.ManualReview, error)
+	DeclineReview(ctx context.Context, id uint, reviewerID, reason string, reasonCodes []string) (*domains.ManualReview, error)
+}
+
+// ReviewService manages the manual underwriting queue.
+type ReviewService struct {
+	reviewRepo    repository.ManualReviewRepository
+	creditAppRepo repository.CreditApplicationRepository
+	lifecycle     *CreditApplicationLifecycle
+	creditLines   *CreditLineService
+	txManager     *utils.TransactionManager
+	logger        *zap.Logger
+}
+
+func NewReviewService(
+	reviewRepo repository.ManualReviewRepository,
+	creditAppRepo repository.CreditApplicationRepository,
+	lifecycle *CreditApplicationLifecycle,
+	creditLines *CreditLineService,
+	txManager *utils.TransactionManager,
+	logger *zap.Logger,
+) *ReviewService {
+	return &ReviewService{
+		reviewRepo:    reviewRepo,
+		creditAppRepo: creditAppRepo,
+		lifecycle:     lifecycle,
+		creditLines:   creditLines,
+		txManager:     txManager,
+		logger:        logger,
+	}
+}
+
+func (s *ReviewService) ListReviews(ctx context.Context, status domains.ManualReviewStatus, offset, limit int) ([]*domains.ManualReview, int, error) {
+	reviews, total, err := s.reviewRepo.List(ctx, status, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list manual reviews", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list manual reviews: %w", err)
+	}
+	return reviews, total, nil
+}
+
+func (s *ReviewService) ClaimReview(ctx context.Context, id uint, reviewerID string) (*domains.ManualReview, error) {
+	s.logger.Info("Claiming manual review", zap.Uint("reviewID", id), zap.String("reviewerID", reviewerID))
+
+	if err := s.reviewRepo.Claim(ctx, id, reviewerID, time.Now()); err != nil {
+		s.logger.Error("Failed to claim manual review", zap.Error(err))
+		return nil, fmt.Errorf("failed to claim manual review: %w", err)
+	}
+
+	return s.reviewRepo.GetByID(ctx, id)
+}
+
+func (s *ReviewService) ApproveReview(ctx context.Context, id uint, reviewerID, reason string, approvedAmount int) (*domains.ManualReview, error) {
+	s.logger.Info("Approving manual review", zap.Uint("reviewID", id), zap.String("reviewerID", reviewerID))
+
+	review, app, err := s.claimedReview(ctx, id, reviewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if approvedAmount == 0 {
+		approvedAmount = app.Amount
+	}
+	if approvedAmount < 0 || approvedAmount > app.Amount {
+		return nil, ErrInvalidApprovedAmount
+	}
+
+	app.ApprovedAmount = approvedAmount
+	review.ApprovedAmount = approvedAmount
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.creditAppRepo.Update(txCtx, app); err != nil {
+			s.logger.Error("Failed to update credit application", zap.Error(err))
+			return fmt.Errorf("failed to update credit application: %w", err)
+		}
+
+		if err := s.lifecycle.Transition(txCtx, app, domains.CreditApplicationApproved, reviewerID, reason); err != nil {
+			s.logger.Error("Failed to approve credit application", zap.Error(err))
+			return fmt.Errorf("failed to approve credit application: %w", err)
+		}
+
+		if _, err := s.creditLines.EnsureLimit(txCtx, app.UserID, app.Currency, app.CreditLimit()); err != nil {
+			return err
+		}
+
+		return s.recordDecision(txCtx, review, domains.ReviewDecisionApproved, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logDecision(review)
+	return review, nil
+}
+
+func (s *ReviewService) DeclineReview(ctx context.Context, id uint, reviewerID, reason string, reasonCodes []string) (*domains.ManualReview, error) {
+	s.logger.Info("Declining manual review", zap.Uint("reviewID", id), zap.String("reviewerID", reviewerID))
+
+	if len(reasonCodes) == 0 {
+		reasonCodes = []string{domains.DeclineUnderwriterDecision}
+	}
+	declineReasons := make([]domains.DeclineReason, 0, len(reasonCodes))
+	for _, code := range reasonCodes {
+		declineReason, ok := domains.NewDeclineReason(code)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidDeclineReason, code)
+		}
+		declineReasons = append(declineReasons, declineReason)
+	}
+
+	review, app, err := s.claimedReview(ctx, id, reviewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	app.DeclineReasons = declineReasons
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.creditAppRepo.Update(txCtx, app); err != nil {
+			s.logger.Error("Failed to update credit application", zap.Error(err))
+			return fmt.Errorf("failed to update credit application: %w", err)
+		}
+
+		if err := s.lifecycle.Transition(txCtx, app, domains.CreditApplicationRejected, reviewerID, reason); err != nil {
+			s.logger.Error("Failed to reject credit application", zap.Error(err))
+			return fmt.Errorf("failed to reject credit application: %w", err)
+		}
+
+		return s.recordDecision(txCtx, review, domains.ReviewDecisionDeclined, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logDecision(review)
+	return review, nil
+}
+
+// claimedReview loads a review that reviewerID has claimed, along with its
+// credit application.
+func (s *ReviewService) claimedReview(ctx context.Context, id uint, reviewerID string) (*domains.ManualReview, *domains.CreditApplication, error) {
+	review, err := s.reviewRepo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get manual review", zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to get manual review: %w", err)
+	}
+
+	if review.Status != domains.ManualReviewClaimed || review.ReviewerID != reviewerID {
+		s.logger.Warn("Manual review not claimed by reviewer",
+			zap.Uint("reviewID", id), zap.String("status", string(review.Status)), zap.String("reviewerID", reviewerID))
+		return nil, nil, ErrReviewNotClaimed
+	}
+
+	app, err := s.creditAppRepo.GetByID(ctx, review.CreditApplicationID)
+	if err != nil {
+		s.logger.Error("Failed to get credit application", zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to get credit application: %w", err)
+	}
+
+	return review, app, nil
+}
+
+func (s *ReviewService) recordDecision(ctx context.Context, review *domains.ManualReview, decision, reason string) error {
+	decidedAt := time.Now()
+	review.Status = domains.ManualReviewDecided
+	review.Decision = decision
+	review.DecisionReason = reason
+	review.DecidedAt = &decidedAt
+
+	if err := s.reviewRepo.Update(ctx, review); err != nil {
+		s.logger.Error("Failed to record manual review decision", zap.Error(err))
+		return fmt.Errorf("failed to record manual review decision: %w", err)
+	}
+	return nil
+}
+
+func (s *ReviewService) logDecision(review *domains.ManualReview) {
+	s.logger.Info("Manual review decided",
+		zap.Uint("reviewID", review.ID),
+		zap.String("decision", review.Decision),
+		zap.String("reviewerID", review.ReviewerID))
+}
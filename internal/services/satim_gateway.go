@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mohamed2394/sahla/internal/utils"
+	"github.com/mohamed2394/sahla/pkg/satim"
+	"go.uber.org/zap"
+)
+
+// satimCurrencies maps ISO 4217 alphabetic codes to the numeric codes the
+// gateway expects.
+var satimCurrencies = map[string]string{
+	"DZD": satim.CurrencyDZD,
+}
+
+// SatimGateway is a PaymentGateway for CIB/EDAHABIA cards processed through a
+// SATIM-style gateway.
+type SatimGateway struct {
+	client          *satim.Client
+	callbackBaseURL string
+	logger          *zap.Logger
+}
+
+// NewSatimGateway creates a SatimGateway. callbackBaseURL is the public URL of
+// this API, which customers are sent back to after paying.
+func NewSatimGateway(client *satim.Client, callbackBaseURL string, logger *zap.Logger) *SatimGateway {
+	return &SatimGateway{
+		client:          client,
+		callbackBaseURL: strings.TrimRight(callbackBaseURL, "/"),
+		logger:          logger,
+	}
+}
+
+func (g *SatimGateway) RegisterOrder(ctx context.Context, req GatewayOrderRequest) (*GatewayOrder, error) {
+	currency, ok := satimCurrencies[req.Currency]
+	if !ok {
+		return nil, fmt.Errorf("unsupported currency for card payments: %s", req.Currency)
+	}
+
+	returnURL := g.callbackBaseURL + req.ReturnPath
+	resp, err := g.client.RegisterOrder(ctx, satim.RegisterOrderRequest{
+		OrderNumber: req.OrderNumber,
+		// Amounts are kept in dinars; the gateway works in centimes
+		Amount:      int64(req.Amount) * 100,
+		Currency:    currency,
+		ReturnURL:   returnURL,
+		FailURL:     returnURL,
+		Description: req.Description,
+	})
+	if err != nil {
+		g.logger.Error("Failed to register gateway order", zap.String("orderNumber", req.OrderNumber), zap.Error(err))
+		return nil, translateSatimError(err)
+	}
+
+	g.logger.Info("Gateway order registered", zap.String("orderNumber", req.OrderNumber), zap.String("gatewayOrderID", resp.OrderID))
+	return &GatewayOrder{OrderID: resp.OrderID, RedirectURL: resp.FormURL}, nil
+}
+
+func (g *SatimGateway) ConfirmOrder(ctx context.Context, orderID string) (*GatewayOrderResult, error) {
+	resp, err := g.client.ConfirmOrder(ctx, orderID)
+	if err != nil {
+		g.logger.Error("Failed to confirm gateway order", zap.String("gatewayOrderID", orderID), zap.Error(err))
+		return nil, translateSatimError(err)
+	}
+
+	result := &GatewayOrderResult{Status: GatewayOrderPending, Reason: resp.ActionCodeDescription}
+	switch {
+	case resp.OrderStatus.Paid():
+		result.Status = GatewayOrderPaid
+		result.Amount = int(resp.Amount / 100)
+	case resp.OrderStatus == satim.OrderStatusDeclined:
+		result.Status = GatewayOrderDeclined
+	}
+	return result, nil
+}
+
+func (g *SatimGateway) PayOrder(ctx context.Context, orderID string, card GatewayCard) error {
+	expiry, err := utils.ParseCardExpiry(card.ExpiryDate)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCard, err)
+	}
+
+	err = g.client.PayOrder(ctx, satim.PayOrderRequest{
+		OrderID: orderID,
+		PAN:     card.Number,
+		// ParseCardExpiry returns the first day after the card expires
+		Expiry:         expiry.AddDate(0, -1, 0).Format("200601"),
+		CardholderName: card.HolderName,
+	})
+	if err != nil {
+		g.logger.Error("Failed to pay gateway order", zap.String("gatewayOrderID", orderID), zap.Error(err))
+		return translateSatimError(err)
+	}
+	return nil
+}
+
+func (g *SatimGateway) RefundOrder(ctx context.Context, orderID string, amount int) error {
+	// Amounts are kept in dinars; the gateway works in centimes
+	if err := g.client.Refund(ctx, orderID, int64(amount)*100); err != nil {
+		g.logger.Error("Failed to refund gateway order", zap.String("gatewayOrderID", orderID), zap.Int("amount", amount), zap.Error(err))
+		return translateSatimError(err)
+	}
+	g.logger.Info("Gateway order refunded", zap.String("gatewayOrderID", orderID), zap.Int("amount", amount))
+	return nil
+}
+
+func translateSatimError(err error) error {
+	if errors.Is(err, satim.ErrTimeout) {
+		return ErrGatewayTimeout
+	}
+	return err
+}
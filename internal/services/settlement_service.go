@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohamed2394/sahla/internal/domains"
+	repository "github.com/mohamed2394/sahla/internal/repositories"
+	"github.com/mohamed2394/sahla/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidSettlement = errors.New("invalid settlement")
+	ErrNothingToSettle   = errors.New("no settlement batches to export")
+)
+
+// SettlementJobName is the JobRunner name of the daily merchant settlement.
+const SettlementJobName = "merchant-settlement"
+
+type SettlementServiceInterface interface {
+	GenerateBatches(ctx context.Context, day time.Time) ([]*domains.SettlementBatch, error)
+	ListBatches(ctx context.Context, merchantID uint, status domains.SettlementBatchStatus, offset, limit int) ([]*domains.SettlementBatch, int, error)
+	GetBatch(ctx context.Context, id uint) (*domains.SettlementBatch, error)
+	ExportBatches(ctx context.Context) (*domains.BankFile, error)
+	GetBankFile(ctx context.Context, id uint) (*domains.BankFile, error)
+	MarkBatchPaid(ctx context.Context, id uint, bankReference string) (*domains.SettlementBatch, error)
+	MarkBatchFailed(ctx context.Context, id uint, reason string) (*domains.SettlementBatch, error)
+}
+
+// SettlementService pays merchants what they are owed. Each day, the
+// purchases and refunds of each merchant that have not been settled are
+// gathered into a payout batch, less the merchant discount rate. Batches are
+// sent to the bank in a transfer file and marked paid once the bank
+// confirms the transfer, which is when the payout is posted to the ledger.
+type SettlementService struct {
+	settlementRepo repository.SettlementRepository
+	merchants      *MerchantService
+	ledger         *LedgerService
+	txManager      *utils.TransactionManager
+	bankFile       BankFileConfig
+	location       *time.Location
+	logger         *zap.Logger
+}
+
+// NewSettlementService creates a SettlementService whose settlement days
+// start at midnight in location.
+func NewSettlementService(
+	settlementRepo repository.SettlementRepository,
+	merchants *MerchantService,
+	ledger *LedgerService,
+	txManager *utils.TransactionManager,
+	bankFile BankFileConfig,
+	location *time.Location,
+	logger *zap.Logger,
+) *SettlementService {
+	return &SettlementService{
+		settlementRepo: settlementRepo,
+		merchants:      merchants,
+		ledger:         ledger,
+		txManager:      txManager,
+		bankFile:       bankFile,
+		location:       location,
+		logger:         logger,
+	}
+}
+
+// SettlePreviousDay makes the payout batches of yesterday.
+func (s *SettlementService) SettlePreviousDay(ctx context.Context) error {
+	_, err := s.GenerateBatches(ctx, time.Now().In(s.location).AddDate(0, 0, -1))
+	return err
+}
+
+// GenerateBatches makes a payout batch per merchant and currency of the
+// purchases and refunds posted up to the end of the calendar day of day, in
+// the settlement time zone, that are not settled yet. A merchant whose
+// refunds outweigh its purchases gets no batch; what it owes is netted
+// against its next payout.
+func (s *SettlementService) GenerateBatches(ctx context.Context, day time.Time) ([]*domains.SettlementBatch, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, s.location)
+	if !start.Before(time.Now()) {
+		return nil, fmt.Errorf("%w: %s has not started yet", ErrInvalidSettlement, start.Format("2006-01-02"))
+	}
+	settlementDate := start.Format("2006-01-02")
+
+	movements, err := s.settlementRepo.ListUnsettled(ctx, start.AddDate(0, 0, 1))
+	if err != nil {
+		s.logger.Error("Failed to list unsettled movements", zap.String("settlementDate", settlementDate), zap.Error(err))
+		return nil, fmt.Errorf("failed to list unsettled movements: %w", err)
+	}
+
+	type batchKey struct {
+		merchantID uint
+		currency   string
+	}
+	var keys []batchKey
+	grouped := make(map[batchKey][]domains.UnsettledMovement)
+	for _, movement := range movements {
+		key := batchKey{movement.MerchantID, movement.Currency}
+		if _, ok := grouped[key]; !ok {
+			keys = append(keys, key)
+		}
+		grouped[key] = append(grouped[key], movement)
+	}
+
+	var batches []*domains.SettlementBatch
+	for _, key := range keys {
+		merchant, err := s.merchants.GetMerchant(ctx, key.merchantID)
+		if err != nil {
+			return batches, err
+		}
+
+		batch := buildSettlementBatch(merchant, key.currency, settlementDate, grouped[key])
+		if batch.NetAmount <= 0 {
+			s.logger.Info("Carrying merchant balance over to the next settlement",
+				zap.Uint("merchantID", merchant.ID), zap.String("currency", key.currency), zap.Int("netAmount", batch.NetAmount))
+			continue
+		}
+
+		if err := s.settlementRepo.CreateBatch(ctx, batch); err != nil {
+			s.logger.Error("Failed to create settlement batch", zap.Uint("merchantID", merchant.ID), zap.Error(err))
+			return batches, fmt.Errorf("failed to create settlement batch: %w", err)
+		}
+		s.logger.Info("Settlement batch created", zap.Uint("batchID", batch.ID), zap.Uint("merchantID", merchant.ID),
+			zap.String("settlementDate", settlementDate), zap.Int("netAmount", batch.NetAmount))
+		batches = append(batches, batch)
+	}
+
+	return batches, nil
+}
+
+// buildSettlementBatch prices movements of a merchant at its discount rate.
+func buildSettlementBatch(merchant *domains.Merchant, currency, settlementDate string, movements []domains.UnsettledMovement) *domains.SettlementBatch {
+	batch := &domains.SettlementBatch{
+		MerchantID:      merchant.ID,
+		SettlementDate:  settlementDate,
+		Currency:        currency,
+		RIB:             merchant.RIB,
+		BeneficiaryName: merchant.LegalName,
+		Status:          domains.SettlementPending,
+		Items:           make([]domains.SettlementItem, len(movements)),
+	}
+	for i, movement := range movements {
+		fee := discountFee(movement.Amount, merchant.DiscountRateBps)
+		batch.Items[i] = domains.SettlementItem{
+			JournalID:       movement.JournalID,
+			Type:            movement.Type,
+			PaymentID:       movement.PaymentID,
+			UserID:          movement.UserID,
+			GrossAmount:     movement.Amount,
+			DiscountRateBps: merchant.DiscountRateBps,
+			FeeAmount:       fee,
+			NetAmount:       movement.Amount - fee,
+			PostedAt:        movement.PostedAt,
+		}
+		batch.GrossAmount += movement.Amount
+		batch.FeeAmount += fee
+		batch.NetAmount += movement.Amount - fee
+	}
+	batch.ItemCount = len(batch.Items)
+	return batch
+}
+
+// discountFee returns the merchant discount on amount at rateBps basis
+// points, rounded half away from zero so that a refund gives back exactly
+// the discount kept on the same amount.
+func discountFee(amount, rateBps int) int {
+	if amount < 0 {
+		return -discountFee(-amount, rateBps)
+	}
+	return (amount*rateBps + 5000) / 10000
+}
+
+func (s *SettlementService) ListBatches(ctx context.Context, merchantID uint, status domains.SettlementBatchStatus, offset, limit int) ([]*domains.SettlementBatch, int, error) {
+	batches, total, err := s.settlementRepo.ListBatches(ctx, merchantID, status, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to list settlement batches", zap.Uint("merchantID", merchantID), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list settlement batches: %w", err)
+	}
+	return batches, total, nil
+}
+
+func (s *SettlementService) GetBatch(ctx context.Context, id uint) (*domains.SettlementBatch, error) {
+	batch, err := s.settlementRepo.GetBatch(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get settlement batch", zap.Uint("batchID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get settlement batch: %w", err)
+	}
+	return batch, nil
+}
+
+// ExportBatches writes every pending batch, and every batch the bank
+// rejected, to a new bank transfer file.
+func (s *SettlementService) ExportBatches(ctx context.Context) (*domains.BankFile, error) {
+	batches, err := s.settlementRepo.ListByStatus(ctx, domains.SettlementPending, domains.SettlementFailed)
+	if err != nil {
+		s.logger.Error("Failed to list settlement batches to export", zap.Error(err))
+		return nil, fmt.Errorf("failed to list settlement batches to export: %w", err)
+	}
+	if len(batches) == 0 {
+		return nil, ErrNothingToSettle
+	}
+
+	content, err := s.bankFile.Render(batches)
+	if err != nil {
+		s.logger.Error("Failed to render bank file", zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSettlement, err)
+	}
+
+	now := time.Now()
+	file := &domains.BankFile{
+		FileName:   fmt.Sprintf("settlement-%s.%s", now.In(s.location).Format("20060102-150405"), s.bankFile.Extension()),
+		Format:     string(s.bankFile.Format),
+		Content:    string(content),
+		BatchCount: len(batches),
+	}
+	for _, batch := range batches {
+		file.TotalAmount += batch.NetAmount
+	}
+
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		if err := s.settlementRepo.CreateBankFile(txCtx, file); err != nil {
+			return err
+		}
+		for _, batch := range batches {
+			changes := map[string]interface{}{"bank_file_id": file.ID, "exported_at": now, "failure_reason": ""}
+			if err := s.settlementRepo.UpdateStatus(txCtx, batch.ID, domains.SettlementExported, changes,
+				domains.SettlementPending, domains.SettlementFailed); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to export settlement batches", zap.Error(err))
+		return nil, fmt.Errorf("failed to export settlement batches: %w", err)
+	}
+
+	s.logger.Info("Bank file exported", zap.Uint("fileID", file.ID), zap.Int("batchCount", file.BatchCount), zap.Int("totalAmount", file.TotalAmount))
+	return file, nil
+}
+
+func (s *SettlementService) GetBankFile(ctx context.Context, id uint) (*domains.BankFile, error) {
+	file, err := s.settlementRepo.GetBankFile(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get bank file", zap.Uint("fileID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get bank file: %w", err)
+	}
+	return file, nil
+}
+
+// MarkBatchPaid records the bank's confirmation that an exported batch was
+// paid, and posts the payout of each of its items to the ledger.
+func (s *SettlementService) MarkBatchPaid(ctx context.Context, id uint, bankReference string) (*domains.SettlementBatch, error) {
+	bankReference = strings.TrimSpace(bankReference)
+	if bankReference == "" {
+		return nil, fmt.Errorf("%w: the bank reference of the transfer is required", ErrInvalidSettlement)
+	}
+
+	batch, err := s.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		changes := map[string]interface{}{"bank_reference": bankReference, "paid_at": time.Now()}
+		if err := s.settlementRepo.UpdateStatus(txCtx, id, domains.SettlementPaid, changes, domains.SettlementExported); err != nil {
+			return err
+		}
+		for i := range batch.Items {
+			if err := s.ledger.PostSettlement(txCtx, batch, &batch.Items[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to mark settlement batch paid", zap.Uint("batchID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to mark settlement batch paid: %w", err)
+	}
+
+	s.logger.Info("Settlement batch paid", zap.Uint("batchID", id), zap.Uint("merchantID", batch.MerchantID), zap.String("bankReference", bankReference))
+	return s.GetBatch(ctx, id)
+}
+
+// MarkBatchFailed records that the bank rejected an exported batch. It is
+// sent again in the next bank file.
+func (s *SettlementService) MarkBatchFailed(ctx context.Context, id uint, reason string) (*domains.SettlementBatch, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidSettlement)
+	}
+
+	changes := map[string]interface{}{"failure_reason": reason}
+	if err := s.settlementRepo.UpdateStatus(ctx, id, domains.SettlementFailed, changes, domains.SettlementExported); err != nil {
+		s.logger.Error("Failed to mark settlement batch failed", zap.Uint("batchID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to mark settlement batch failed: %w", err)
+	}
+
+	s.logger.Warn("Settlement batch rejected by the bank", zap.Uint("batchID", id), zap.String("reason", reason))
+	return s.GetBatch(ctx, id)
+}
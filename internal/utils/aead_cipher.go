@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrCiphertextTooShort = errors.New("ciphertext too short")
+
+// Cipher encrypts and authenticates small values with AES-256-GCM. Each
+// ciphertext carries its own random nonce.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher from a 32 byte key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt seals plaintext. additionalData is authenticated but not encrypted,
+// binding the ciphertext to e.g. the row it is stored in.
+func (c *Cipher) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt with the same additionalData.
+func (c *Cipher) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, ErrCiphertextTooShort
+	}
+	return c.aead.Open(nil, ciphertext[:size], ciphertext[size:], additionalData)
+}
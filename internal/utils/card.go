@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Card brands we accept
+const (
+	CardBrandEdahabia   = "EDAHABIA"
+	CardBrandCIB        = "CIB"
+	CardBrandVisa       = "VISA"
+	CardBrandMastercard = "MASTERCARD"
+)
+
+// edahabiaBIN is the issuer prefix of Algérie Poste EDAHABIA cards.
+const edahabiaBIN = "628058"
+
+// NormalizeCardNumber strips the spaces and dashes customers type into card
+// numbers.
+func NormalizeCardNumber(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// LuhnValid reports whether number is 12 to 19 digits with a valid Luhn
+// check digit.
+func LuhnValid(number string) bool {
+	if len(number) < 12 || len(number) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// CardBrandOf guesses the brand of a card from its number. Domestic cards
+// that are not EDAHABIA are reported as CIB.
+func CardBrandOf(number string) string {
+	switch {
+	case strings.HasPrefix(number, edahabiaBIN):
+		return CardBrandEdahabia
+	case strings.HasPrefix(number, "4"):
+		return CardBrandVisa
+	case len(number) >= 2 && number[:2] >= "51" && number[:2] <= "55",
+		len(number) >= 4 && number[:4] >= "2221" && number[:4] <= "2720":
+		return CardBrandMastercard
+	default:
+		return CardBrandCIB
+	}
+}
+
+// CardLast4 returns the last four digits of a card number.
+func CardLast4(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return number[len(number)-4:]
+}
+
+// MaskCardNumber hides all but the last four digits of a card number.
+func MaskCardNumber(number string) string {
+	if number == "" {
+		return ""
+	}
+	return "**** **** **** " + CardLast4(NormalizeCardNumber(number))
+}
+
+// ParseCardExpiry parses an MM/YY expiry date and returns the first moment
+// the card is no longer valid.
+func ParseCardExpiry(expiry string) (time.Time, error) {
+	parts := strings.Split(expiry, "/")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return time.Time{}, fmt.Errorf("expiry date must be MM/YY")
+	}
+	month, err := strconv.Atoi(parts[0])
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("invalid expiry month")
+	}
+	year, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry year")
+	}
+	return time.Date(2000+year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC), nil
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreaker stops calls to a failing dependency once it has failed
+// failureThreshold times in a row, and lets a single trial call through after
+// the cooldown has elapsed.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	cooldown         time.Duration
+	failures         int
+	state            circuitState
+	openedAt         time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, cooldown time.Duration) *CircuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+	}
+}
+
+// Allow reports whether a call may be attempted.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		cb.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		// Only one trial call is allowed while half open
+		return false
+	default:
+		return true
+	}
+}
+
+// RecordSuccess closes the breaker and resets the failure count.
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	cb.state = circuitClosed
+}
+
+// RecordFailure counts a failed call and opens the breaker when the threshold
+// is reached or when the trial call of a half open breaker fails.
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.failureThreshold {
+		cb.state = circuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// IsOpen reports whether the breaker is currently rejecting calls.
+func (cb *CircuitBreaker) IsOpen() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state == circuitOpen && time.Since(cb.openedAt) < cb.cooldown
+}
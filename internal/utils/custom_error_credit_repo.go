@@ -27,4 +27,28 @@ type ErrDatabase struct {
 
 func (e *ErrDatabase) Error() string {
 	return fmt.Sprintf("database error: %v", e.Err)
-}
\ No newline at end of file
+}
+
+// ErrInvalidTransition is returned when an entity is asked to move to a
+// status that its current status does not allow.
+type ErrInvalidTransition struct {
+	Entity string
+	ID     interface{}
+	From   string
+	To     string
+}
+
+func (e *ErrInvalidTransition) Error() string {
+	return fmt.Sprintf("%s with ID %v cannot move from %s to %s", e.Entity, e.ID, e.From, e.To)
+}
+
+// ErrVersionConflict is returned when an optimistic update loses a race with
+// a concurrent writer.
+type ErrVersionConflict struct {
+	Entity string
+	ID     interface{}
+}
+
+func (e *ErrVersionConflict) Error() string {
+	return fmt.Sprintf("%s with ID %v was modified concurrently", e.Entity, e.ID)
+}
@@ -19,6 +19,42 @@ var (
 	ExcellentScore = CreditScoreRange{800, 850}
 )
 
+// Credit score band names, one per CreditScoreRange
+const (
+	PoorBand      = "POOR"
+	FairBand      = "FAIR"
+	GoodBand      = "GOOD"
+	VeryGoodBand  = "VERY_GOOD"
+	ExcellentBand = "EXCELLENT"
+)
+
+// ClampCreditScore bounds a score to the 300-850 scale
+func ClampCreditScore(score int) int {
+	if score < PoorScore.Min {
+		return PoorScore.Min
+	}
+	if score > ExcellentScore.Max {
+		return ExcellentScore.Max
+	}
+	return score
+}
+
+// CreditScoreBand returns the name of the range the given score falls into
+func CreditScoreBand(score int) string {
+	switch {
+	case score <= PoorScore.Max:
+		return PoorBand
+	case score <= FairScore.Max:
+		return FairBand
+	case score <= GoodScore.Max:
+		return GoodBand
+	case score <= VeryGoodScore.Max:
+		return VeryGoodBand
+	default:
+		return ExcellentBand
+	}
+}
+
 // GenerateRandomCreditScore generates a random credit score between 300 and 850
 func GenerateRandomCreditScore() int {
 	// Seed the random number generator
@@ -0,0 +1,36 @@
+package utils
+
+import "strings"
+
+// NormalizeRIB strips the spaces and dashes bank account numbers are often
+// written with.
+func NormalizeRIB(rib string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(rib)
+}
+
+// RIBValid reports whether rib is a 20 digit Algerian bank account number
+// (bank, agency and account, then a two digit key) whose key matches: the key
+// is 97 less the first 18 digits times 100, modulo 97.
+func RIBValid(rib string) bool {
+	if len(rib) != 20 {
+		return false
+	}
+
+	remainder := 0
+	for i := 0; i < len(rib); i++ {
+		c := rib[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		if i < 18 {
+			remainder = (remainder*10 + int(c-'0')) % 97
+		}
+	}
+
+	key := 97 - remainder*100%97
+	return rib[18:] == twoDigits(key)
+}
+
+func twoDigits(n int) string {
+	return string([]byte{byte('0' + n/10), byte('0' + n%10)})
+}
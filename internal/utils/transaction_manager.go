@@ -6,6 +6,10 @@ import (
 	"gorm.io/gorm"
 )
 
+// txKey is the context key under which RunInTransaction stores the open
+// transaction.
+type txKey struct{}
+
 type TransactionManager struct {
 	db *gorm.DB
 }
@@ -14,9 +18,24 @@ func NewTransactionManager(db *gorm.DB) *TransactionManager {
 	return &TransactionManager{db: db}
 }
 
+// RunInTransaction runs fn in a database transaction that repositories pick
+// up from txCtx. The transaction commits if fn returns nil and rolls back
+// otherwise. Nested calls join the outer transaction.
 func (tm *TransactionManager) RunInTransaction(ctx context.Context, fn func(txCtx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
 	return tm.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		txCtx := context.WithValue(ctx, "tx", tx)
+		txCtx := context.WithValue(ctx, txKey{}, tx)
 		return fn(txCtx)
 	})
-}
\ No newline at end of file
+}
+
+// DBFromContext returns the transaction opened by RunInTransaction, or db
+// when ctx carries none.
+func DBFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrStaleWebhook            = errors.New("webhook timestamp outside the accepted window")
+)
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with
+// secret.
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks a signature made by SignWebhook and rejects
+// timestamps (unix seconds) more than tolerance away from now. The signature
+// may carry a "sha256=" prefix.
+func VerifyWebhookSignature(secret []byte, signature, timestamp string, body []byte, tolerance time.Duration, now time.Time) error {
+	if len(secret) == 0 || signature == "" || timestamp == "" {
+		return ErrInvalidWebhookSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrStaleWebhook
+	}
+
+	expected := SignWebhook(secret, timestamp, body)
+	signature = strings.TrimPrefix(signature, "sha256=")
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret := []byte("whsec-test")
+	body := []byte(`{"order_id":"abc","status":"PAID"}`)
+	now := time.Unix(1760000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := SignWebhook(secret, timestamp, body)
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		signature string
+		timestamp string
+		body      []byte
+		want      error
+	}{
+		{"valid", secret, signature, timestamp, body, nil},
+		{"valid with prefix", secret, "sha256=" + signature, timestamp, body, nil},
+		{"tampered body", secret, signature, timestamp, []byte(`{"order_id":"abc","status":"DECLINED"}`), ErrInvalidWebhookSignature},
+		{"other secret", []byte("whsec-other"), signature, timestamp, body, ErrInvalidWebhookSignature},
+		{"timestamp not signed", secret, signature, strconv.FormatInt(now.Unix()+1, 10), body, ErrInvalidWebhookSignature},
+		{"missing signature", secret, "", timestamp, body, ErrInvalidWebhookSignature},
+		{"no secret", nil, signature, timestamp, body, ErrInvalidWebhookSignature},
+		{"malformed timestamp", secret, signature, "yesterday", body, ErrInvalidWebhookSignature},
+		{"too old", secret, SignWebhook(secret, "1759999000", body), "1759999000", body, ErrStaleWebhook},
+		{"too far ahead", secret, SignWebhook(secret, "1760001000", body), "1760001000", body, ErrStaleWebhook},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.secret, tt.signature, tt.timestamp, tt.body, 5*time.Minute, now)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
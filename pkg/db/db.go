@@ -11,7 +11,8 @@ var dbInstance *gorm.DB
 
 // Connect initializes the database connection
 func Connect(dsn string) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError maps unique violations to gorm.ErrDuplicatedKey
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
@@ -21,23 +22,73 @@ func Connect(dsn string) (*gorm.DB, error) {
 
 // AutoMigrateModels migrates the database models
 func AutoMigrateModels() error {
+	if err := dropGlobalOrderIDUniqueness(); err != nil {
+		return err
+	}
+
 	err := dbInstance.AutoMigrate(
 		&domain.User{},
 		&domain.CreditApplication{},
+		&domain.CreditApplicationTransition{},
+		&domain.ManualReview{},
+		&domain.CreditLine{},
+		&domain.CreditReservation{},
+		&domain.PlanProduct{},
 		&domain.Payment{},
 		&domain.Installment{},
+		&domain.IdempotencyRecord{},
+		&domain.InboundWebhookLog{},
+		&domain.ProcessedWebhookEvent{},
+		&domain.CardVaultEntry{},
+		&domain.JobLease{},
+		&domain.CollectionAttempt{},
+		&domain.CollectionCase{},
+		&domain.Notification{},
+		&domain.InstallmentCharge{},
+		&domain.Prepayment{},
+		&domain.PaymentReceipt{},
+		&domain.Refund{},
+		&domain.Journal{},
+		&domain.LedgerEntry{},
+		&domain.Merchant{},
+		&domain.Store{},
+		&domain.MerchantAPIKey{},
+		&domain.CheckoutSession{},
+		&domain.MerchantEvent{},
+		&domain.SettlementBatch{},
+		&domain.SettlementItem{},
+		&domain.BankFile{},
+		&domain.WebhookEndpoint{},
+		&domain.WebhookDelivery{},
+		&domain.WebhookDeliveryAttempt{},
 	)
 	if err != nil {
 		return err
 	}
 
+	if err := dropRawCardColumns(); err != nil {
+		return err
+	}
+
+	if err := dropWebhookResponseBodies(); err != nil {
+		return err
+	}
+
+	// Installments created before plans had fees are all principal
+	err = dbInstance.Exec("UPDATE installments SET principal = amount WHERE principal = 0 AND fee = 0").Error
+	if err != nil {
+		return err
+	}
+
 	// Ensure indexes are created for foreign keys and unique constraints
 	err = dbInstance.Exec("CREATE INDEX IF NOT EXISTS idx_payments_credit_application_id ON payments(credit_application_id)").Error
 	if err != nil {
 		return err
 	}
 
-	err = dbInstance.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id)").Error
+	// Order IDs are the merchant's order references: a merchant may retry an
+	// order whose payment failed, but not pay it twice
+	err = dbInstance.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_merchant_order_id ON payments(merchant_id, order_id) WHERE status <> 'FAILED'").Error
 	if err != nil {
 		return err
 	}
@@ -47,9 +98,64 @@ func AutoMigrateModels() error {
 		return err
 	}
 
+	// Only one collection attempt per installment may be charging the card
+	err = dbInstance.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_collection_attempts_in_progress ON collection_attempts(installment_id) WHERE status = 'IN_PROGRESS'").Error
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// dropGlobalOrderIDUniqueness drops the constraint and index that kept payment
+// order IDs unique across merchants. It runs before AutoMigrate so the column
+// can change from uuid to a free form order reference.
+func dropGlobalOrderIDUniqueness() error {
+	for _, statement := range []string{
+		"ALTER TABLE IF EXISTS payments DROP CONSTRAINT IF EXISTS uni_payments_order_id",
+		"ALTER TABLE IF EXISTS payments DROP CONSTRAINT IF EXISTS payments_order_id_key",
+		"DROP INDEX IF EXISTS idx_payments_order_id",
+	} {
+		if err := dbInstance.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropRawCardColumns removes the card number, holder name and CVV columns
+// that payments used to store in clear, keeping the last four digits.
+func dropRawCardColumns() error {
+	migrator := dbInstance.Migrator()
+	if !migrator.HasColumn(&domain.Payment{}, "card_number") {
+		return nil
+	}
+
+	err := dbInstance.Exec("UPDATE payments SET card_last4 = RIGHT(card_number, 4) WHERE card_number IS NOT NULL AND card_number <> '' AND (card_last4 IS NULL OR card_last4 = '')").Error
+	if err != nil {
+		return err
+	}
+
+	for _, column := range []string{"card_number", "card_holder_name", "cvv"} {
+		if migrator.HasColumn(&domain.Payment{}, column) {
+			if err := migrator.DropColumn(&domain.Payment{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dropWebhookResponseBodies removes what merchant endpoints answered to
+// webhooks, which attempts used to store and show back to merchants.
+func dropWebhookResponseBodies() error {
+	migrator := dbInstance.Migrator()
+	if !migrator.HasColumn(&domain.WebhookDeliveryAttempt{}, "response_body") {
+		return nil
+	}
+	return migrator.DropColumn(&domain.WebhookDeliveryAttempt{}, "response_body")
+}
+
 // GetDB returns the instance of the database connection
 func GetDB() *gorm.DB {
 	if dbInstance == nil {
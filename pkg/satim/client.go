@@ -0,0 +1,260 @@
+// Package satim is a client for SATIM-style CIB/EDAHABIA card payment
+// gateways. A payment is an order that is registered by the merchant, paid by
+// the customer on the gateway's hosted form, and then confirmed by the
+// merchant once the customer is redirected back. Orders can also be paid by
+// the merchant with a card on file, without the customer present, and paid
+// orders can be refunded in full or in part.
+package satim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	registerPath = "/payment/rest/register.do"
+	confirmPath  = "/payment/rest/confirmOrder.do"
+	payPath      = "/payment/rest/paymentorder.do"
+	refundPath   = "/payment/rest/refund.do"
+
+	// CurrencyDZD is the ISO 4217 numeric code of the Algerian dinar.
+	CurrencyDZD = "012"
+)
+
+// OrderStatus is the state of an order as reported by confirmOrder.do.
+type OrderStatus int
+
+const (
+	OrderStatusRegistered OrderStatus = 0
+	OrderStatusPreAuth    OrderStatus = 1
+	OrderStatusDeposited  OrderStatus = 2
+	OrderStatusReversed   OrderStatus = 3
+	OrderStatusRefunded   OrderStatus = 4
+	OrderStatusDeclined   OrderStatus = 6
+)
+
+// Paid reports whether the customer's card was charged for the order.
+func (s OrderStatus) Paid() bool {
+	return s == OrderStatusPreAuth || s == OrderStatusDeposited
+}
+
+var ErrTimeout = errors.New("satim: gateway timed out")
+
+// Error is a business error returned by the gateway in its errorCode field.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("satim: error %s: %s", e.Code, e.Message)
+}
+
+// Config holds the merchant credentials and endpoint of the gateway.
+type Config struct {
+	BaseURL  string
+	Username string
+	Password string
+	Language string
+	Timeout  time.Duration
+}
+
+// RegisterOrderRequest describes an order to register. Amount is in minor
+// units (centimes).
+type RegisterOrderRequest struct {
+	OrderNumber string
+	Amount      int64
+	Currency    string
+	ReturnURL   string
+	FailURL     string
+	Description string
+}
+
+// RegisterOrderResponse holds the gateway's order ID and the URL of the
+// hosted payment form the customer must be redirected to.
+type RegisterOrderResponse struct {
+	OrderID string `json:"orderId"`
+	FormURL string `json:"formUrl"`
+}
+
+// ConfirmOrderResponse is the outcome of an order.
+type ConfirmOrderResponse struct {
+	OrderStatus           OrderStatus `json:"OrderStatus"`
+	OrderNumber           string      `json:"OrderNumber"`
+	Amount                int64       `json:"Amount"`
+	ActionCode            int         `json:"actionCode"`
+	ActionCodeDescription string      `json:"actionCodeDescription"`
+}
+
+// PayOrderRequest is a card on file used to pay a registered order. Expiry is
+// in YYYYMM form.
+type PayOrderRequest struct {
+	OrderID        string
+	PAN            string
+	Expiry         string
+	CardholderName string
+}
+
+// Client talks to the gateway's REST API.
+type Client struct {
+	config Config
+	http   *http.Client
+}
+
+// NewClient creates a Client. A nil httpClient uses http.DefaultClient.
+func NewClient(config Config, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if config.Language == "" {
+		config.Language = "fr"
+	}
+	return &Client{config: config, http: httpClient}
+}
+
+// RegisterOrder registers an order and returns where to send the customer.
+func (c *Client) RegisterOrder(ctx context.Context, req RegisterOrderRequest) (*RegisterOrderResponse, error) {
+	params := c.credentials()
+	params.Set("orderNumber", req.OrderNumber)
+	params.Set("amount", strconv.FormatInt(req.Amount, 10))
+	params.Set("currency", req.Currency)
+	params.Set("returnUrl", req.ReturnURL)
+	if req.FailURL != "" {
+		params.Set("failUrl", req.FailURL)
+	}
+	if req.Description != "" {
+		params.Set("description", req.Description)
+	}
+
+	var out struct {
+		RegisterOrderResponse
+		ErrorCode    string `json:"errorCode"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if err := c.post(ctx, registerPath, params, &out); err != nil {
+		return nil, err
+	}
+	if out.ErrorCode != "" && out.ErrorCode != "0" {
+		return nil, &Error{Code: out.ErrorCode, Message: out.ErrorMessage}
+	}
+	return &out.RegisterOrderResponse, nil
+}
+
+// ConfirmOrder fetches the outcome of an order once the customer has been
+// through the payment form.
+func (c *Client) ConfirmOrder(ctx context.Context, orderID string) (*ConfirmOrderResponse, error) {
+	params := c.credentials()
+	params.Set("orderId", orderID)
+
+	var out struct {
+		ConfirmOrderResponse
+		ErrorCode    string `json:"ErrorCode"`
+		ErrorMessage string `json:"ErrorMessage"`
+	}
+	if err := c.post(ctx, confirmPath, params, &out); err != nil {
+		return nil, err
+	}
+	// A declined card is reported through OrderStatus, not as an error
+	if out.ErrorCode != "" && out.ErrorCode != "0" && out.OrderStatus != OrderStatusDeclined {
+		return nil, &Error{Code: out.ErrorCode, Message: out.ErrorMessage}
+	}
+	return &out.ConfirmOrderResponse, nil
+}
+
+// PayOrder charges a card on file for a registered order. The outcome must
+// then be read with ConfirmOrder: a declined card is not an error here.
+func (c *Client) PayOrder(ctx context.Context, req PayOrderRequest) error {
+	params := c.credentials()
+	params.Set("MDORDER", req.OrderID)
+	params.Set("$PAN", req.PAN)
+	params.Set("$EXPIRY", req.Expiry)
+	params.Set("TEXT", req.CardholderName)
+
+	var out struct {
+		ErrorCode    string `json:"errorCode"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if err := c.post(ctx, payPath, params, &out); err != nil {
+		return err
+	}
+	if out.ErrorCode != "" && out.ErrorCode != "0" {
+		return &Error{Code: out.ErrorCode, Message: out.ErrorMessage}
+	}
+	return nil
+}
+
+// Refund gives amount, in minor units, of a paid order back to the card. An
+// order may be refunded several times up to its amount.
+func (c *Client) Refund(ctx context.Context, orderID string, amount int64) error {
+	params := c.credentials()
+	params.Set("orderId", orderID)
+	params.Set("amount", strconv.FormatInt(amount, 10))
+
+	var out struct {
+		ErrorCode    string `json:"errorCode"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if err := c.post(ctx, refundPath, params, &out); err != nil {
+		return err
+	}
+	if out.ErrorCode != "" && out.ErrorCode != "0" {
+		return &Error{Code: out.ErrorCode, Message: out.ErrorMessage}
+	}
+	return nil
+}
+
+func (c *Client) credentials() url.Values {
+	params := url.Values{}
+	params.Set("userName", c.config.Username)
+	params.Set("password", c.config.Password)
+	params.Set("language", c.config.Language)
+	return params
+}
+
+func (c *Client) post(ctx context.Context, path string, params url.Values, out interface{}) error {
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+
+	endpoint := strings.TrimRight(c.config.BaseURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout
+		}
+		return fmt.Errorf("satim: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout
+		}
+		return fmt.Errorf("satim: failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("satim: gateway returned status %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("satim: invalid response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package satim
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outcome decides what the simulator does with the orders it is paid for.
+type Outcome string
+
+const (
+	OutcomeSucceed Outcome = "succeed"
+	OutcomeDecline Outcome = "decline"
+	OutcomeTimeout Outcome = "timeout"
+)
+
+const formPath = "/payment/merchants/form"
+
+// ParseOutcome parses an outcome name, defaulting to OutcomeSucceed when name
+// is empty.
+func ParseOutcome(name string) (Outcome, error) {
+	switch Outcome(name) {
+	case "":
+		return OutcomeSucceed, nil
+	case OutcomeSucceed, OutcomeDecline, OutcomeTimeout:
+		return Outcome(name), nil
+	default:
+		return "", fmt.Errorf("satim: unknown simulator outcome %q", name)
+	}
+}
+
+type simulatedOrder struct {
+	number    string
+	amount    int64
+	returnURL string
+	failURL   string
+	status    OrderStatus
+	outcome   Outcome
+	refunded  int64
+}
+
+// Simulator is an in-memory stand-in for the gateway, so the payment flow can
+// run end to end without SATIM credentials. Orders follow the configured
+// Outcome: succeed charges the card, decline refuses it and timeout leaves
+// confirmOrder.do hanging for TimeoutDelay. A card on file paid under the
+// timeout outcome is charged, but paymentorder.do only answers after
+// TimeoutDelay, as when the gateway's response is lost.
+type Simulator struct {
+	// TimeoutDelay is how long confirmOrder.do and paymentorder.do stall
+	// under OutcomeTimeout.
+	TimeoutDelay time.Duration
+
+	mu      sync.Mutex
+	outcome Outcome
+	orders  map[string]*simulatedOrder
+}
+
+func NewSimulator(outcome Outcome) *Simulator {
+	return &Simulator{
+		TimeoutDelay: 30 * time.Second,
+		outcome:      outcome,
+		orders:       make(map[string]*simulatedOrder),
+	}
+}
+
+// SetOutcome changes the outcome applied to orders paid from now on.
+func (s *Simulator) SetOutcome(outcome Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outcome = outcome
+}
+
+// Handler serves the gateway's REST endpoints and hosted payment form.
+func (s *Simulator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(registerPath, s.handleRegister)
+	mux.HandleFunc(confirmPath, s.handleConfirm)
+	mux.HandleFunc(formPath, s.handleForm)
+	mux.HandleFunc(payPath, s.handlePayOrder)
+	mux.HandleFunc(refundPath, s.handleRefund)
+	return mux
+}
+
+// ListenAndServe runs the simulator on addr until it fails.
+func (s *Simulator) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// Pay completes the hosted form for an order, as the customer would, and
+// returns the URL the customer is redirected to.
+func (s *Simulator) Pay(orderID string, outcome Outcome) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return "", fmt.Errorf("satim: unknown order %s", orderID)
+	}
+	if outcome == "" {
+		outcome = s.outcome
+	}
+
+	order.outcome = outcome
+	redirect := order.returnURL
+	switch outcome {
+	case OutcomeSucceed:
+		order.status = OrderStatusDeposited
+	case OutcomeDecline:
+		order.status = OrderStatusDeclined
+		if order.failURL != "" {
+			redirect = order.failURL
+		}
+	}
+
+	return withQuery(redirect, "orderId", orderID), nil
+}
+
+func (s *Simulator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, map[string]string{"errorCode": "1", "errorMessage": "malformed request"})
+		return
+	}
+
+	amount, err := strconv.ParseInt(r.Form.Get("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		writeJSON(w, map[string]string{"errorCode": "4", "errorMessage": "invalid amount"})
+		return
+	}
+	if r.Form.Get("returnUrl") == "" {
+		writeJSON(w, map[string]string{"errorCode": "4", "errorMessage": "returnUrl is required"})
+		return
+	}
+
+	orderID := newOrderID()
+	s.mu.Lock()
+	s.orders[orderID] = &simulatedOrder{
+		number:    r.Form.Get("orderNumber"),
+		amount:    amount,
+		returnURL: r.Form.Get("returnUrl"),
+		failURL:   r.Form.Get("failUrl"),
+		status:    OrderStatusRegistered,
+	}
+	s.mu.Unlock()
+
+	formURL := withQuery("http://"+r.Host+formPath, "mdOrder", orderID)
+	writeJSON(w, map[string]string{"errorCode": "0", "orderId": orderID, "formUrl": formURL})
+}
+
+// handleForm plays the customer paying on the hosted form. The outcome can be
+// overridden per order with ?outcome=.
+func (s *Simulator) handleForm(w http.ResponseWriter, r *http.Request) {
+	outcome := Outcome(r.URL.Query().Get("outcome"))
+	if outcome != "" {
+		if _, err := ParseOutcome(string(outcome)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	redirect, err := s.Pay(r.URL.Query().Get("mdOrder"), outcome)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// handlePayOrder charges a card on file for a registered order.
+func (s *Simulator) handlePayOrder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, map[string]string{"errorCode": "1", "errorMessage": "malformed request"})
+		return
+	}
+	if r.Form.Get("$PAN") == "" || len(r.Form.Get("$EXPIRY")) != 6 {
+		writeJSON(w, map[string]string{"errorCode": "5", "errorMessage": "invalid card"})
+		return
+	}
+
+	s.mu.Lock()
+	order, ok := s.orders[r.Form.Get("MDORDER")]
+	outcome := s.outcome
+	if ok {
+		if order.status != OrderStatusRegistered {
+			s.mu.Unlock()
+			writeJSON(w, map[string]string{"errorCode": "7", "errorMessage": "order already processed"})
+			return
+		}
+		order.status = OrderStatusDeposited
+		if outcome == OutcomeDecline {
+			order.status = OrderStatusDeclined
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, map[string]string{"errorCode": "6", "errorMessage": "unknown order"})
+		return
+	}
+
+	if outcome == OutcomeTimeout {
+		select {
+		case <-time.After(s.TimeoutDelay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	writeJSON(w, map[string]string{"errorCode": "0"})
+}
+
+// handleRefund refunds part or all of a paid order. Once nothing is left to
+// refund the order is reported as refunded.
+func (s *Simulator) handleRefund(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, map[string]string{"errorCode": "1", "errorMessage": "malformed request"})
+		return
+	}
+	amount, err := strconv.ParseInt(r.Form.Get("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		writeJSON(w, map[string]string{"errorCode": "4", "errorMessage": "invalid amount"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[r.Form.Get("orderId")]
+	switch {
+	case !ok:
+		writeJSON(w, map[string]string{"errorCode": "6", "errorMessage": "unknown order"})
+		return
+	case order.status != OrderStatusDeposited:
+		writeJSON(w, map[string]string{"errorCode": "7", "errorMessage": "order is not paid"})
+		return
+	case order.refunded+amount > order.amount:
+		writeJSON(w, map[string]string{"errorCode": "4", "errorMessage": "refund exceeds the order amount"})
+		return
+	}
+
+	order.refunded += amount
+	if order.refunded == order.amount {
+		order.status = OrderStatusRefunded
+	}
+	writeJSON(w, map[string]string{"errorCode": "0"})
+}
+
+func (s *Simulator) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, map[string]string{"ErrorCode": "1", "ErrorMessage": "malformed request"})
+		return
+	}
+
+	s.mu.Lock()
+	order, ok := s.orders[r.Form.Get("orderId")]
+	var snapshot simulatedOrder
+	if ok {
+		snapshot = *order
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, map[string]string{"ErrorCode": "6", "ErrorMessage": "unknown order"})
+		return
+	}
+
+	if snapshot.outcome == OutcomeTimeout {
+		select {
+		case <-time.After(s.TimeoutDelay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	resp := map[string]interface{}{
+		"ErrorCode":   "0",
+		"OrderStatus": snapshot.status,
+		"OrderNumber": snapshot.number,
+		"Amount":      snapshot.amount,
+		"actionCode":  0,
+	}
+	switch snapshot.status {
+	case OrderStatusDeclined:
+		resp["ErrorCode"] = "2"
+		resp["ErrorMessage"] = "Payment is declined"
+		resp["actionCode"] = 116
+		resp["actionCodeDescription"] = "Insufficient funds"
+	case OrderStatusDeposited:
+		resp["actionCodeDescription"] = "Your payment has been accepted"
+	}
+	writeJSON(w, resp)
+}
+
+func newOrderID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func withQuery(rawURL, key, value string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}